@@ -0,0 +1,70 @@
+package main
+
+import "net/http"
+
+// AnalyticsResult holds numeric aggregates over the legs of a set of animals. With no animals
+// every number is 0 and ByClass is empty.
+type AnalyticsResult struct {
+	Count   int            `json:"count"`
+	MinLegs int            `json:"min_legs"`
+	MaxLegs int            `json:"max_legs"`
+	AvgLegs float64        `json:"avg_legs"`
+	ByClass map[string]int `json:"by_class"` // Class -> number of animals
+}
+
+// analyticsAccumulator computes an AnalyticsResult from animals fed to it one at a time, so a
+// store can aggregate in the same pass that scans it.
+type analyticsAccumulator struct {
+	result    AnalyticsResult
+	totalLegs int64 // Summed in 64 bits, since legs may each be up to the int32 maximum
+}
+
+// newAnalyticsAccumulator returns an accumulator that has seen no animals.
+func newAnalyticsAccumulator() *analyticsAccumulator {
+	return &analyticsAccumulator{result: AnalyticsResult{ByClass: make(map[string]int)}}
+}
+
+// add includes the animal in the aggregates.
+func (a *analyticsAccumulator) add(animal Animal) {
+	r := &a.result
+	if r.Count == 0 || animal.Legs < r.MinLegs {
+		r.MinLegs = animal.Legs
+	}
+	if r.Count == 0 || animal.Legs > r.MaxLegs {
+		r.MaxLegs = animal.Legs
+	}
+	r.Count++
+	r.ByClass[animal.Class]++
+	a.totalLegs += int64(animal.Legs)
+}
+
+// analytics returns the aggregates of the animals added so far.
+func (a *analyticsAccumulator) analytics() AnalyticsResult {
+	result := a.result
+	if result.Count > 0 {
+		result.AvgLegs = float64(a.totalLegs) / float64(result.Count)
+	}
+	return result
+}
+
+// analyticsHandler handles GET requests for leg statistics of the animals: how many there are,
+// the fewest, most and average legs, and how many animals each class has. It takes the same
+// ?class=, ?endangered= and ?filter= parameters as the facets, so the numbers describe the
+// current query. An empty store, or a filter that matches nothing, yields zeros.
+func analyticsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		filter, err := parseAggregateFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := store.Analytics(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, result)
+	}
+}
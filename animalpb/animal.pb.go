@@ -0,0 +1,623 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: animal.proto
+
+package animalpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// An animal, with the fields of the REST representation.
+type Animal struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name       string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Class      string                 `protobuf:"bytes,3,opt,name=class,proto3" json:"class,omitempty"`
+	Legs       int32                  `protobuf:"varint,4,opt,name=legs,proto3" json:"legs,omitempty"`
+	PhotoUrl   string                 `protobuf:"bytes,5,opt,name=photo_url,json=photoUrl,proto3" json:"photo_url,omitempty"`
+	Endangered bool                   `protobuf:"varint,6,opt,name=endangered,proto3" json:"endangered,omitempty"`
+	// Location of the habitat in degrees; both or neither.
+	Latitude  *float64 `protobuf:"fixed64,7,opt,name=latitude,proto3,oneof" json:"latitude,omitempty"`
+	Longitude *float64 `protobuf:"fixed64,8,opt,name=longitude,proto3,oneof" json:"longitude,omitempty"`
+	// Managed by the store; ignored on writes.
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Animal) Reset() {
+	*x = Animal{}
+	mi := &file_animal_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Animal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Animal) ProtoMessage() {}
+
+func (x *Animal) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Animal.ProtoReflect.Descriptor instead.
+func (*Animal) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Animal) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Animal) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Animal) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *Animal) GetLegs() int32 {
+	if x != nil {
+		return x.Legs
+	}
+	return 0
+}
+
+func (x *Animal) GetPhotoUrl() string {
+	if x != nil {
+		return x.PhotoUrl
+	}
+	return ""
+}
+
+func (x *Animal) GetEndangered() bool {
+	if x != nil {
+		return x.Endangered
+	}
+	return false
+}
+
+func (x *Animal) GetLatitude() float64 {
+	if x != nil && x.Latitude != nil {
+		return *x.Latitude
+	}
+	return 0
+}
+
+func (x *Animal) GetLongitude() float64 {
+	if x != nil && x.Longitude != nil {
+		return *x.Longitude
+	}
+	return 0
+}
+
+func (x *Animal) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Animal) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type GetAnimalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAnimalRequest) Reset() {
+	*x = GetAnimalRequest{}
+	mi := &file_animal_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAnimalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAnimalRequest) ProtoMessage() {}
+
+func (x *GetAnimalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAnimalRequest.ProtoReflect.Descriptor instead.
+func (*GetAnimalRequest) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetAnimalRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+// The criteria of a list, as in the body of POST /v1/animals/query.
+type ListAnimalsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Animals of any of these classes, case-insensitive; empty matches every class.
+	Classes []string `protobuf:"bytes,1,rep,name=classes,proto3" json:"classes,omitempty"`
+	MinLegs *int32   `protobuf:"varint,2,opt,name=min_legs,json=minLegs,proto3,oneof" json:"min_legs,omitempty"`
+	MaxLegs *int32   `protobuf:"varint,3,opt,name=max_legs,json=maxLegs,proto3,oneof" json:"max_legs,omitempty"`
+	// A case-insensitive substring of the name.
+	NameContains string `protobuf:"bytes,4,opt,name=name_contains,json=nameContains,proto3" json:"name_contains,omitempty"`
+	// Sort keys, most significant first, e.g. "class", "-legs".
+	Sort []string `protobuf:"bytes,5,rep,name=sort,proto3" json:"sort,omitempty"`
+	// Page size; the server's default when unset, clamped to its maximum.
+	Limit         *int32 `protobuf:"varint,6,opt,name=limit,proto3,oneof" json:"limit,omitempty"`
+	Offset        int32  `protobuf:"varint,7,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnimalsRequest) Reset() {
+	*x = ListAnimalsRequest{}
+	mi := &file_animal_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnimalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnimalsRequest) ProtoMessage() {}
+
+func (x *ListAnimalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnimalsRequest.ProtoReflect.Descriptor instead.
+func (*ListAnimalsRequest) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListAnimalsRequest) GetClasses() []string {
+	if x != nil {
+		return x.Classes
+	}
+	return nil
+}
+
+func (x *ListAnimalsRequest) GetMinLegs() int32 {
+	if x != nil && x.MinLegs != nil {
+		return *x.MinLegs
+	}
+	return 0
+}
+
+func (x *ListAnimalsRequest) GetMaxLegs() int32 {
+	if x != nil && x.MaxLegs != nil {
+		return *x.MaxLegs
+	}
+	return 0
+}
+
+func (x *ListAnimalsRequest) GetNameContains() string {
+	if x != nil {
+		return x.NameContains
+	}
+	return ""
+}
+
+func (x *ListAnimalsRequest) GetSort() []string {
+	if x != nil {
+		return x.Sort
+	}
+	return nil
+}
+
+func (x *ListAnimalsRequest) GetLimit() int32 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+func (x *ListAnimalsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ListAnimalsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Animals []*Animal              `protobuf:"bytes,1,rep,name=animals,proto3" json:"animals,omitempty"`
+	// Number of matching animals across all pages.
+	Total         int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int32 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnimalsResponse) Reset() {
+	*x = ListAnimalsResponse{}
+	mi := &file_animal_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnimalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnimalsResponse) ProtoMessage() {}
+
+func (x *ListAnimalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnimalsResponse.ProtoReflect.Descriptor instead.
+func (*ListAnimalsResponse) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListAnimalsResponse) GetAnimals() []*Animal {
+	if x != nil {
+		return x.Animals
+	}
+	return nil
+}
+
+func (x *ListAnimalsResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListAnimalsResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListAnimalsResponse) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type CreateAnimalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Animal        *Animal                `protobuf:"bytes,1,opt,name=animal,proto3" json:"animal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateAnimalRequest) Reset() {
+	*x = CreateAnimalRequest{}
+	mi := &file_animal_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateAnimalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateAnimalRequest) ProtoMessage() {}
+
+func (x *CreateAnimalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateAnimalRequest.ProtoReflect.Descriptor instead.
+func (*CreateAnimalRequest) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateAnimalRequest) GetAnimal() *Animal {
+	if x != nil {
+		return x.Animal
+	}
+	return nil
+}
+
+type UpdateAnimalRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The new state of the animal; its id is ignored in favour of the one above.
+	Animal        *Animal `protobuf:"bytes,2,opt,name=animal,proto3" json:"animal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateAnimalRequest) Reset() {
+	*x = UpdateAnimalRequest{}
+	mi := &file_animal_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateAnimalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateAnimalRequest) ProtoMessage() {}
+
+func (x *UpdateAnimalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateAnimalRequest.ProtoReflect.Descriptor instead.
+func (*UpdateAnimalRequest) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateAnimalRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateAnimalRequest) GetAnimal() *Animal {
+	if x != nil {
+		return x.Animal
+	}
+	return nil
+}
+
+type DeleteAnimalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteAnimalRequest) Reset() {
+	*x = DeleteAnimalRequest{}
+	mi := &file_animal_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteAnimalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteAnimalRequest) ProtoMessage() {}
+
+func (x *DeleteAnimalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animal_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteAnimalRequest.ProtoReflect.Descriptor instead.
+func (*DeleteAnimalRequest) Descriptor() ([]byte, []int) {
+	return file_animal_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeleteAnimalRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+var File_animal_proto protoreflect.FileDescriptor
+
+const file_animal_proto_rawDesc = "" +
+	"\n" +
+	"\fanimal.proto\x12\vanekazoo.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe8\x02\n" +
+	"\x06Animal\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05class\x18\x03 \x01(\tR\x05class\x12\x12\n" +
+	"\x04legs\x18\x04 \x01(\x05R\x04legs\x12\x1b\n" +
+	"\tphoto_url\x18\x05 \x01(\tR\bphotoUrl\x12\x1e\n" +
+	"\n" +
+	"endangered\x18\x06 \x01(\bR\n" +
+	"endangered\x12\x1f\n" +
+	"\blatitude\x18\a \x01(\x01H\x00R\blatitude\x88\x01\x01\x12!\n" +
+	"\tlongitude\x18\b \x01(\x01H\x01R\tlongitude\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\v\n" +
+	"\t_latitudeB\f\n" +
+	"\n" +
+	"_longitude\"\"\n" +
+	"\x10GetAnimalRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xfe\x01\n" +
+	"\x12ListAnimalsRequest\x12\x18\n" +
+	"\aclasses\x18\x01 \x03(\tR\aclasses\x12\x1e\n" +
+	"\bmin_legs\x18\x02 \x01(\x05H\x00R\aminLegs\x88\x01\x01\x12\x1e\n" +
+	"\bmax_legs\x18\x03 \x01(\x05H\x01R\amaxLegs\x88\x01\x01\x12#\n" +
+	"\rname_contains\x18\x04 \x01(\tR\fnameContains\x12\x12\n" +
+	"\x04sort\x18\x05 \x03(\tR\x04sort\x12\x19\n" +
+	"\x05limit\x18\x06 \x01(\x05H\x02R\x05limit\x88\x01\x01\x12\x16\n" +
+	"\x06offset\x18\a \x01(\x05R\x06offsetB\v\n" +
+	"\t_min_legsB\v\n" +
+	"\t_max_legsB\b\n" +
+	"\x06_limit\"\x88\x01\n" +
+	"\x13ListAnimalsResponse\x12-\n" +
+	"\aanimals\x18\x01 \x03(\v2\x13.anekazoo.v1.AnimalR\aanimals\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x05R\x06offset\"B\n" +
+	"\x13CreateAnimalRequest\x12+\n" +
+	"\x06animal\x18\x01 \x01(\v2\x13.anekazoo.v1.AnimalR\x06animal\"R\n" +
+	"\x13UpdateAnimalRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12+\n" +
+	"\x06animal\x18\x02 \x01(\v2\x13.anekazoo.v1.AnimalR\x06animal\"%\n" +
+	"\x13DeleteAnimalRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id2\xfa\x02\n" +
+	"\rAnimalService\x12?\n" +
+	"\tGetAnimal\x12\x1d.anekazoo.v1.GetAnimalRequest\x1a\x13.anekazoo.v1.Animal\x12P\n" +
+	"\vListAnimals\x12\x1f.anekazoo.v1.ListAnimalsRequest\x1a .anekazoo.v1.ListAnimalsResponse\x12E\n" +
+	"\fCreateAnimal\x12 .anekazoo.v1.CreateAnimalRequest\x1a\x13.anekazoo.v1.Animal\x12E\n" +
+	"\fUpdateAnimal\x12 .anekazoo.v1.UpdateAnimalRequest\x1a\x13.anekazoo.v1.Animal\x12H\n" +
+	"\fDeleteAnimal\x12 .anekazoo.v1.DeleteAnimalRequest\x1a\x16.google.protobuf.EmptyB\x13Z\x11AnekaZoo/animalpbb\x06proto3"
+
+var (
+	file_animal_proto_rawDescOnce sync.Once
+	file_animal_proto_rawDescData []byte
+)
+
+func file_animal_proto_rawDescGZIP() []byte {
+	file_animal_proto_rawDescOnce.Do(func() {
+		file_animal_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_animal_proto_rawDesc), len(file_animal_proto_rawDesc)))
+	})
+	return file_animal_proto_rawDescData
+}
+
+var file_animal_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_animal_proto_goTypes = []any{
+	(*Animal)(nil),                // 0: anekazoo.v1.Animal
+	(*GetAnimalRequest)(nil),      // 1: anekazoo.v1.GetAnimalRequest
+	(*ListAnimalsRequest)(nil),    // 2: anekazoo.v1.ListAnimalsRequest
+	(*ListAnimalsResponse)(nil),   // 3: anekazoo.v1.ListAnimalsResponse
+	(*CreateAnimalRequest)(nil),   // 4: anekazoo.v1.CreateAnimalRequest
+	(*UpdateAnimalRequest)(nil),   // 5: anekazoo.v1.UpdateAnimalRequest
+	(*DeleteAnimalRequest)(nil),   // 6: anekazoo.v1.DeleteAnimalRequest
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),         // 8: google.protobuf.Empty
+}
+var file_animal_proto_depIdxs = []int32{
+	7,  // 0: anekazoo.v1.Animal.created_at:type_name -> google.protobuf.Timestamp
+	7,  // 1: anekazoo.v1.Animal.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: anekazoo.v1.ListAnimalsResponse.animals:type_name -> anekazoo.v1.Animal
+	0,  // 3: anekazoo.v1.CreateAnimalRequest.animal:type_name -> anekazoo.v1.Animal
+	0,  // 4: anekazoo.v1.UpdateAnimalRequest.animal:type_name -> anekazoo.v1.Animal
+	1,  // 5: anekazoo.v1.AnimalService.GetAnimal:input_type -> anekazoo.v1.GetAnimalRequest
+	2,  // 6: anekazoo.v1.AnimalService.ListAnimals:input_type -> anekazoo.v1.ListAnimalsRequest
+	4,  // 7: anekazoo.v1.AnimalService.CreateAnimal:input_type -> anekazoo.v1.CreateAnimalRequest
+	5,  // 8: anekazoo.v1.AnimalService.UpdateAnimal:input_type -> anekazoo.v1.UpdateAnimalRequest
+	6,  // 9: anekazoo.v1.AnimalService.DeleteAnimal:input_type -> anekazoo.v1.DeleteAnimalRequest
+	0,  // 10: anekazoo.v1.AnimalService.GetAnimal:output_type -> anekazoo.v1.Animal
+	3,  // 11: anekazoo.v1.AnimalService.ListAnimals:output_type -> anekazoo.v1.ListAnimalsResponse
+	0,  // 12: anekazoo.v1.AnimalService.CreateAnimal:output_type -> anekazoo.v1.Animal
+	0,  // 13: anekazoo.v1.AnimalService.UpdateAnimal:output_type -> anekazoo.v1.Animal
+	8,  // 14: anekazoo.v1.AnimalService.DeleteAnimal:output_type -> google.protobuf.Empty
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_animal_proto_init() }
+func file_animal_proto_init() {
+	if File_animal_proto != nil {
+		return
+	}
+	file_animal_proto_msgTypes[0].OneofWrappers = []any{}
+	file_animal_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_animal_proto_rawDesc), len(file_animal_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_animal_proto_goTypes,
+		DependencyIndexes: file_animal_proto_depIdxs,
+		MessageInfos:      file_animal_proto_msgTypes,
+	}.Build()
+	File_animal_proto = out.File
+	file_animal_proto_goTypes = nil
+	file_animal_proto_depIdxs = nil
+}
@@ -0,0 +1,5 @@
+// Package animalpb holds the protobuf messages and gRPC stubs of the AnimalService defined in
+// animal.proto. The .pb.go files are generated; edit animal.proto and run go generate.
+package animalpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative animal.proto
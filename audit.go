@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Operations recorded in the audit log.
+const (
+	auditCreate     = "create"
+	auditUpdate     = "update"
+	auditUpsert     = "upsert"
+	auditDelete     = "delete"
+	auditMerge      = "merge"
+	auditReplaceAll = "replace_all"
+)
+
+// AuditEntry records a single mutation: when it happened, what it did, to which animal and who asked.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`           // One of create, update, upsert, delete, merge, replace_all
+	AnimalID  int       `json:"animal_id,omitempty"` // Absent for replace_all
+	Before    *Animal   `json:"before,omitempty"`    // The record before the change; absent for creates
+	After     *Animal   `json:"after,omitempty"`     // The record after the change; absent for deletes
+	Client    string    `json:"client,omitempty"`    // Identity of the client that made the request
+	Tenant    string    `json:"tenant,omitempty"`    // Tenant whose animal changed; absent without tenancy
+
+	Changes map[string]FieldChange `json:"changes,omitempty"` // Fields changed by an update or upsert of an existing animal
+}
+
+// FieldChange is the old and new value of one field changed by an update.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// diffAnimals returns the client-visible fields that differ between before and after, keyed by
+// their JSON name. The store-managed ID and timestamps are not compared.
+func diffAnimals(before, after Animal) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if before.Name != after.Name {
+		changes["name"] = FieldChange{Old: before.Name, New: after.Name}
+	}
+	if before.Class != after.Class {
+		changes["class"] = FieldChange{Old: before.Class, New: after.Class}
+	}
+	if before.Legs != after.Legs {
+		changes["legs"] = FieldChange{Old: before.Legs, New: after.Legs}
+	}
+	if before.PhotoURL != after.PhotoURL {
+		changes["photo_url"] = FieldChange{Old: before.PhotoURL, New: after.PhotoURL}
+	}
+	if before.Endangered != after.Endangered {
+		changes["endangered"] = FieldChange{Old: before.Endangered, New: after.Endangered}
+	}
+	if !sameCoordinate(before.Latitude, after.Latitude) {
+		changes["latitude"] = FieldChange{Old: before.Latitude, New: after.Latitude}
+	}
+	if !sameCoordinate(before.Longitude, after.Longitude) {
+		changes["longitude"] = FieldChange{Old: before.Longitude, New: after.Longitude}
+	}
+	return changes
+}
+
+// sameCoordinate reports whether two optional coordinates are both absent or equal.
+func sameCoordinate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// AuditSink receives audit entries. Implementations must be safe for concurrent use.
+type AuditSink interface {
+	Record(entry AuditEntry) error
+}
+
+// jsonAuditSink writes each entry as one line of JSON.
+type jsonAuditSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONAuditSink returns a sink writing entries as newline-delimited JSON to w (a file or stdout).
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return &jsonAuditSink{encoder: json.NewEncoder(w)}
+}
+
+// Record writes the entry as a JSON line.
+func (s *jsonAuditSink) Record(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(entry)
+}
+
+// AuditRing is a sink keeping the most recent entries in memory for the audit endpoint.
+type AuditRing struct {
+	mu      sync.Mutex
+	entries []AuditEntry // Ring buffer; next is the slot the next entry goes into
+	next    int
+	full    bool
+}
+
+// NewAuditRing creates a ring holding the last size entries (at least 1).
+func NewAuditRing(size int) *AuditRing {
+	if size < 1 {
+		size = 1
+	}
+	return &AuditRing{entries: make([]AuditEntry, size)}
+}
+
+// Record stores the entry, overwriting the oldest one once the ring is full.
+func (r *AuditRing) Record(entry AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// Recent returns up to limit entries of the tenant ("" without tenancy), newest first.
+func (r *AuditRing) Recent(tenant string, limit int) []AuditEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	recent := make([]AuditEntry, 0, min(limit, count))
+	for i := 1; i <= count && len(recent) < limit; i++ {
+		if entry := r.entries[(r.next-i+len(r.entries))%len(r.entries)]; entry.Tenant == tenant {
+			recent = append(recent, entry)
+		}
+	}
+	return recent
+}
+
+// multiAuditSink hands every entry to each of its sinks, returning the first error.
+type multiAuditSink []AuditSink
+
+// Record passes the entry to every sink.
+func (m multiAuditSink) Record(entry AuditEntry) error {
+	var first error
+	for _, sink := range m {
+		if err := sink.Record(entry); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// auditBuffer collects the entries of a transaction until it commits.
+type auditBuffer struct {
+	entries []AuditEntry
+}
+
+// Record appends the entry to the buffer.
+func (b *auditBuffer) Record(entry AuditEntry) error {
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+// clientIdentityKey is the context key under which the requesting client's identity is stored.
+type clientIdentityKey struct{}
+
+// withClientIdentity is router middleware that stores the client's identity in the request
+// context for the audit log. Without authentication the identity is the client's IP address.
+func withClientIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			client = host
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, client)))
+	})
+}
+
+// clientIdentity returns the client identity stored in ctx, or "" outside a request.
+func clientIdentity(ctx context.Context) string {
+	client, _ := ctx.Value(clientIdentityKey{}).(string)
+	return client
+}
+
+// AuditingAnimalStore is an AnimalStore decorator that records every successful mutation to an
+// AuditSink, with snapshots of the affected animal before and after the change. Reads pass
+// through unchanged. The snapshots are read separately from the mutation, so outside a
+// transaction a concurrent write may slip in between. Bound to a request via WithContext,
+// entries carry the requesting client's identity.
+type AuditingAnimalStore struct {
+	inner AnimalStore
+	sink  AuditSink
+	ctx   context.Context
+}
+
+// NewAuditingAnimalStore wraps inner so that its mutations are recorded to sink.
+func NewAuditingAnimalStore(inner AnimalStore, sink AuditSink) *AuditingAnimalStore {
+	return &AuditingAnimalStore{inner: inner, sink: sink, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the store recording the client identity found in ctx.
+func (a *AuditingAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &AuditingAnimalStore{inner: bindContext(a.inner, ctx), sink: a.sink, ctx: ctx}
+}
+
+// snapshot returns the current record of the animal, or nil if it does not exist.
+func (a *AuditingAnimalStore) snapshot(id int) *Animal {
+	animal, err := a.inner.GetAnimalByID(id)
+	if err != nil {
+		return nil
+	}
+	return animal
+}
+
+// record hands an entry to the sink. A failing sink is logged rather than failing the
+// mutation, which has already been applied. When an existing animal is overwritten the entry
+// lists the changed fields, and a write that changed none is not recorded at all.
+func (a *AuditingAnimalStore) record(operation string, id int, before, after *Animal) {
+	var changes map[string]FieldChange
+	if before != nil && after != nil {
+		if changes = diffAnimals(*before, *after); len(changes) == 0 {
+			return
+		}
+	}
+	entry := AuditEntry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		AnimalID:  id,
+		Before:    before,
+		After:     after,
+		Client:    clientIdentity(a.ctx),
+		Tenant:    tenantFromContext(a.ctx),
+		Changes:   changes,
+	}
+	if err := a.sink.Record(entry); err != nil {
+		log.Printf("audit: recording %s of animal %d: %v", operation, id, err)
+	}
+}
+
+// GetAllAnimals passes through to the underlying store.
+func (a *AuditingAnimalStore) GetAllAnimals() ([]Animal, error) {
+	return a.inner.GetAllAnimals()
+}
+
+// FilterAnimals passes through to the underlying store.
+func (a *AuditingAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	return a.inner.FilterAnimals(filter)
+}
+
+// GetAnimalIDs passes through to the underlying store.
+func (a *AuditingAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	return a.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals passes through to the underlying store.
+func (a *AuditingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	return a.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach passes through to the underlying store.
+func (a *AuditingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return a.inner.ForEach(ctx, fn)
+}
+
+// Facets passes through to the underlying store.
+func (a *AuditingAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	return a.inner.Facets(filter)
+}
+
+// Analytics passes through to the underlying store.
+func (a *AuditingAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	return a.inner.Analytics(filter)
+}
+
+// GroupAnimalsByClass passes through to the underlying store.
+func (a *AuditingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	return a.inner.GroupAnimalsByClass(filter)
+}
+
+// FuzzySearch passes through to the underlying store.
+func (a *AuditingAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	return a.inner.FuzzySearch(query, maxDistance)
+}
+
+// Query passes through to the underlying store.
+func (a *AuditingAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	return a.inner.Query(q)
+}
+
+// SampleAnimals passes through to the underlying store.
+func (a *AuditingAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	return a.inner.SampleAnimals(filter, n)
+}
+
+// GetAnimalsModifiedSince passes through to the underlying store.
+func (a *AuditingAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	return a.inner.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified passes through to the underlying store.
+func (a *AuditingAnimalStore) LatestModified() (time.Time, error) {
+	return a.inner.LatestModified()
+}
+
+// ReserveID passes through to the underlying store; reserving writes no animal.
+func (a *AuditingAnimalStore) ReserveID() (int, time.Time, error) {
+	return a.inner.ReserveID()
+}
+
+// GetAnimalByID passes through to the underlying store.
+func (a *AuditingAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	return a.inner.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs passes through to the underlying store.
+func (a *AuditingAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	return a.inner.GetAnimalsByIDs(ids)
+}
+
+// NextID passes through to the underlying store.
+func (a *AuditingAnimalStore) NextID() (int, error) {
+	return a.inner.NextID()
+}
+
+// CreateAnimal creates the animal and records it.
+func (a *AuditingAnimalStore) CreateAnimal(animal Animal) error {
+	if err := a.inner.CreateAnimal(animal); err != nil {
+		return err
+	}
+	after := &animal
+	if animal.ID != 0 {
+		after = a.snapshot(animal.ID)
+	}
+	a.record(auditCreate, animal.ID, nil, after)
+	return nil
+}
+
+// CreateAnimalAssigningID creates the animal and records it under the ID it was given.
+func (a *AuditingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	created, err := a.inner.CreateAnimalAssigningID(animal, skip)
+	if err != nil {
+		return created, err
+	}
+	a.record(auditCreate, created.ID, nil, &created)
+	return created, nil
+}
+
+// UpdateAnimal updates the animal and records the change.
+func (a *AuditingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	before := a.snapshot(id)
+	if err := a.inner.UpdateAnimal(id, animal); err != nil {
+		return err
+	}
+	a.record(auditUpdate, id, before, a.snapshot(id))
+	return nil
+}
+
+// UpsertAnimal upserts the animal and records the change; before is absent if it was created.
+func (a *AuditingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	before := a.snapshot(id)
+	if err := a.inner.UpsertAnimal(id, animal); err != nil {
+		return err
+	}
+	a.record(auditUpsert, id, before, a.snapshot(id))
+	return nil
+}
+
+// UpsertAnimalIf conditionally upserts the animal and records the change; a refused write is not recorded.
+func (a *AuditingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	before := a.snapshot(id)
+	created, err := a.inner.UpsertAnimalIf(id, animal, matches)
+	if err != nil {
+		return false, err
+	}
+	if created {
+		before = nil
+	}
+	a.record(auditUpsert, id, before, a.snapshot(id))
+	return created, nil
+}
+
+// MergeAnimals merges the animals and records a merge entry for the target and a delete entry
+// for the source.
+func (a *AuditingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	source, target := a.snapshot(sourceID), a.snapshot(targetID)
+	merged, err := a.inner.MergeAnimals(sourceID, targetID, merge)
+	if err != nil {
+		return Animal{}, err
+	}
+	a.record(auditMerge, targetID, target, &merged)
+	a.record(auditDelete, sourceID, source, nil)
+	return merged, nil
+}
+
+// UpsertAnimals upserts the animals and records one upsert entry per animal; before is absent
+// for the created ones.
+func (a *AuditingAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	ids := make([]int, len(animals))
+	for i, animal := range animals {
+		ids[i] = animal.ID
+	}
+	existing, _, err := a.inner.GetAnimalsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[int]Animal, len(existing))
+	for _, animal := range existing {
+		before[animal.ID] = animal
+	}
+
+	results, err := a.inner.UpsertAnimals(animals)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		var snapshot *Animal
+		if animal, ok := before[result.ID]; ok {
+			snapshot = &animal
+		}
+		a.record(auditUpsert, result.ID, snapshot, a.snapshot(result.ID))
+	}
+	return results, nil
+}
+
+// DeleteAnimal deletes the animal and records the removed record.
+func (a *AuditingAnimalStore) DeleteAnimal(id int) error {
+	before := a.snapshot(id)
+	if err := a.inner.DeleteAnimal(id); err != nil {
+		return err
+	}
+	a.record(auditDelete, id, before, nil)
+	return nil
+}
+
+// DeleteAnimalIfVersion conditionally deletes the animal and records the removed record; a refused
+// delete is not recorded.
+func (a *AuditingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	before := a.snapshot(id)
+	if err := a.inner.DeleteAnimalIfVersion(id, version); err != nil {
+		return err
+	}
+	a.record(auditDelete, id, before, nil)
+	return nil
+}
+
+// DeleteAnimals deletes the animals and records one delete entry per removed animal.
+func (a *AuditingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	existing, _, err := a.inner.GetAnimalsByIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := make(map[int]Animal, len(existing))
+	for _, animal := range existing {
+		before[animal.ID] = animal
+	}
+
+	deleted, notFound, err := a.inner.DeleteAnimals(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range deleted {
+		var snapshot *Animal
+		if animal, ok := before[id]; ok {
+			snapshot = &animal
+		}
+		a.record(auditDelete, id, snapshot, nil)
+	}
+	return deleted, notFound, nil
+}
+
+// SetEndangered sets the flag and records the change as an update.
+func (a *AuditingAnimalStore) SetEndangered(id int, endangered bool) error {
+	before := a.snapshot(id)
+	if err := a.inner.SetEndangered(id, endangered); err != nil {
+		return err
+	}
+	a.record(auditUpdate, id, before, a.snapshot(id))
+	return nil
+}
+
+// ReclassifyAnimals reclassifies the animals and records one update entry per changed animal.
+func (a *AuditingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	before, err := a.inner.FilterAnimals(AnimalFilter{Classes: []string{from}})
+	if err != nil && !errors.Is(err, ErrEmpty) {
+		return 0, err
+	}
+
+	changed, err := a.inner.ReclassifyAnimals(from, to)
+	if err != nil {
+		return 0, err
+	}
+	for i := range before {
+		// record skips animals whose class was already spelled exactly like to
+		a.record(auditUpdate, before[i].ID, &before[i], a.snapshot(before[i].ID))
+	}
+	return changed, nil
+}
+
+// ReplaceAllAnimals replaces the dataset and records a single replace_all entry without snapshots.
+func (a *AuditingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	if err := a.inner.ReplaceAllAnimals(animals); err != nil {
+		return err
+	}
+	a.record(auditReplaceAll, 0, nil, nil)
+	return nil
+}
+
+// WithTransaction buffers the entries of the transaction's mutations and passes them to the
+// sink only once the transaction has committed, so rolled-back changes are never audited.
+func (a *AuditingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	buffer := &auditBuffer{}
+	err := a.inner.WithTransaction(ctx, func(tx AnimalStore) error {
+		return fn(&AuditingAnimalStore{inner: tx, sink: buffer, ctx: a.ctx})
+	})
+	if err != nil {
+		return err
+	}
+	for _, entry := range buffer.entries {
+		if err := a.sink.Record(entry); err != nil {
+			log.Printf("audit: recording %s of animal %d: %v", entry.Operation, entry.AnimalID, err)
+		}
+	}
+	return nil
+}
+
+// auditLogResponse is the body of the audit endpoint.
+type auditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// auditLogHandler handles GET requests for the most recent audit entries, newest first.
+// ?limit= caps the number of entries (default 100).
+func auditLogHandler(ring *AuditRing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		limit := 100
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+		respondJSON(w, r, auditLogResponse{Entries: ring.Recent(tenantFromContext(r.Context()), limit)})
+	}
+}
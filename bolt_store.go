@@ -0,0 +1,948 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// animalsBucket is the bbolt bucket holding one JSON-encoded animal per big-endian ID key.
+var animalsBucket = []byte("animals")
+
+// BoltAnimalStore implements AnimalStore on an embedded bbolt database file, so data survives
+// restarts without a separate database server. bbolt allows many concurrent read transactions
+// but only one write transaction at a time; every method runs in its own transaction.
+type BoltAnimalStore struct {
+	db         *bolt.DB
+	maxAnimals int       // Maximum number of stored animals; 0 means unlimited
+	modified   watermark // When a write transaction last committed; starts at the newest stored UpdatedAt
+}
+
+// NewBoltAnimalStore opens (creating if necessary) the bbolt database at path holding at most
+// maxAnimals animals (0 means unlimited). The file is locked while open, so a second process
+// using the same path waits up to a second and then fails. Call Close when done.
+func NewBoltAnimalStore(path string, maxAnimals int) (*BoltAnimalStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(animalsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// The watermark is not persisted: recover it from the data with one scan
+	s := &BoltAnimalStore{db: db, maxAnimals: maxAnimals}
+	err = s.view(func(tx *boltTxStore) error {
+		latest, err := tx.LatestModified()
+		s.modified.advance(latest)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Ping checks that the database file can still be read and holds the animals bucket.
+func (s *BoltAnimalStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(animalsBucket) == nil {
+			return errors.New("the animals bucket is missing")
+		}
+		return nil
+	})
+}
+
+// Close flushes and closes the database file.
+func (s *BoltAnimalStore) Close() error {
+	return s.db.Close()
+}
+
+// view runs fn against a read-only transaction.
+func (s *BoltAnimalStore) view(fn func(tx *boltTxStore) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTxStore{tx: tx, maxAnimals: s.maxAnimals})
+	})
+}
+
+// update runs fn against a read-write transaction that commits if fn returns nil, and then
+// advances the watermark.
+func (s *BoltAnimalStore) update(fn func(tx *boltTxStore) error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTxStore{tx: tx, maxAnimals: s.maxAnimals})
+	})
+	if err == nil {
+		s.modified.advance(time.Now().UTC())
+	}
+	return err
+}
+
+// GetAllAnimals retrieves all animals from the database.
+func (s *BoltAnimalStore) GetAllAnimals() (animals []Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animals, err = tx.GetAllAnimals()
+		return err
+	})
+	return animals, err
+}
+
+// FilterAnimals retrieves all animals matching the filter, ordered by its sort keys (ID by default).
+func (s *BoltAnimalStore) FilterAnimals(filter AnimalFilter) (animals []Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animals, err = tx.FilterAnimals(filter)
+		return err
+	})
+	return animals, err
+}
+
+// GetAnimalIDs returns the IDs of the matching animals in one read transaction.
+func (s *BoltAnimalStore) GetAnimalIDs(filter AnimalFilter) (ids []int, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		ids, err = tx.GetAnimalIDs(filter)
+		return err
+	})
+	return ids, err
+}
+
+// StreamAnimals calls fn for every animal matching the filter, in the filter's sort order,
+// inside a single read transaction.
+func (s *BoltAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	return s.view(func(tx *boltTxStore) error {
+		return tx.StreamAnimals(filter, fn)
+	})
+}
+
+// ForEach calls fn for every animal in ID order inside a single read transaction.
+func (s *BoltAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return s.view(func(tx *boltTxStore) error {
+		return tx.ForEach(ctx, fn)
+	})
+}
+
+// Query returns the requested page of matching animals and how many match, in one read transaction.
+func (s *BoltAnimalStore) Query(q AnimalQuery) (page []Animal, total int, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		page, total, err = tx.Query(q)
+		return err
+	})
+	return page, total, err
+}
+
+// SampleAnimals draws a uniform random sample of up to n matching animals in one read transaction.
+func (s *BoltAnimalStore) SampleAnimals(filter AnimalFilter, n int) (animals []Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animals, err = tx.SampleAnimals(filter, n)
+		return err
+	})
+	return animals, err
+}
+
+// LatestModified returns when a write transaction last committed, or the zero time if the
+// database is empty. Transactions that changed nothing still advance it.
+func (s *BoltAnimalStore) LatestModified() (latest time.Time, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		if key, _ := tx.bucket().Cursor().First(); key != nil {
+			latest = s.modified.time()
+		}
+		return nil
+	})
+	return latest, err
+}
+
+// GetAnimalsModifiedSince returns the animals written after t, ordered by UpdatedAt, in one read transaction.
+func (s *BoltAnimalStore) GetAnimalsModifiedSince(t time.Time) (animals []Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animals, err = tx.GetAnimalsModifiedSince(t)
+		return err
+	})
+	return animals, err
+}
+
+// GroupAnimalsByClass buckets the animals matching the filter by their class, each bucket ordered by ID.
+func (s *BoltAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (groups map[string][]Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		groups, err = tx.GroupAnimalsByClass(filter)
+		return err
+	})
+	return groups, err
+}
+
+// Facets counts the animals matching the filter per class in one read transaction.
+func (s *BoltAnimalStore) Facets(filter AnimalFilter) (facets []Facet, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		facets, err = tx.Facets(filter)
+		return err
+	})
+	return facets, err
+}
+
+// Analytics aggregates the legs of the animals matching the filter in one read transaction.
+func (s *BoltAnimalStore) Analytics(filter AnimalFilter) (result AnalyticsResult, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		result, err = tx.Analytics(filter)
+		return err
+	})
+	return result, err
+}
+
+// FuzzySearch returns the animals whose name is within maxDistance edits of the query, closest first.
+func (s *BoltAnimalStore) FuzzySearch(query string, maxDistance int) (animals []Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animals, err = tx.FuzzySearch(query, maxDistance)
+		return err
+	})
+	return animals, err
+}
+
+// GetAnimalByID retrieves a single animal by its ID.
+func (s *BoltAnimalStore) GetAnimalByID(id int) (animal *Animal, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		animal, err = tx.GetAnimalByID(id)
+		return err
+	})
+	return animal, err
+}
+
+// GetAnimalsByIDs looks up several animals in one read transaction.
+func (s *BoltAnimalStore) GetAnimalsByIDs(ids []int) (found []Animal, missing []int, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		found, missing, err = tx.GetAnimalsByIDs(ids)
+		return err
+	})
+	return found, missing, err
+}
+
+// NextID returns the current highest ID plus one (1 for an empty database).
+func (s *BoltAnimalStore) NextID() (next int, err error) {
+	err = s.view(func(tx *boltTxStore) error {
+		next, err = tx.NextID()
+		return err
+	})
+	return next, err
+}
+
+// ReserveID is not supported by the store itself; see ReservingAnimalStore.
+func (s *BoltAnimalStore) ReserveID() (int, time.Time, error) {
+	return 0, time.Time{}, errReservationsUnsupported
+}
+
+// CreateAnimal adds a new animal, returning ErrAlreadyExists if the ID is taken or
+// ErrCapacityExceeded if the database is full.
+func (s *BoltAnimalStore) CreateAnimal(animal Animal) error {
+	return s.update(func(tx *boltTxStore) error { return tx.CreateAnimal(animal) })
+}
+
+// CreateAnimalAssigningID chooses the ID and inserts the animal in one write transaction.
+func (s *BoltAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (created Animal, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		created, err = tx.CreateAnimalAssigningID(animal, skip)
+		return err
+	})
+	return created, err
+}
+
+// UpdateAnimal updates an existing animal, returning ErrNotFound if it does not exist.
+func (s *BoltAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	return s.update(func(tx *boltTxStore) error { return tx.UpdateAnimal(id, animal) })
+}
+
+// UpsertAnimal updates an existing animal or creates a new one if it doesn't exist.
+func (s *BoltAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	return s.update(func(tx *boltTxStore) error { return tx.UpsertAnimal(id, animal) })
+}
+
+// UpsertAnimalIf checks matches against the existing animal and upserts it in one write transaction.
+func (s *BoltAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (created bool, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		created, err = tx.UpsertAnimalIf(id, animal, matches)
+		return err
+	})
+	return created, err
+}
+
+// UpsertAnimals upserts every animal in one write transaction, so a failing one leaves the others unwritten.
+func (s *BoltAnimalStore) UpsertAnimals(animals []Animal) (results []BatchResult, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		results, err = tx.UpsertAnimals(animals)
+		return err
+	})
+	return results, err
+}
+
+// DeleteAnimal removes an animal, returning ErrNotFound if it does not exist.
+func (s *BoltAnimalStore) DeleteAnimal(id int) error {
+	return s.update(func(tx *boltTxStore) error { return tx.DeleteAnimal(id) })
+}
+
+// DeleteAnimalIfVersion checks the animal's version and deletes it in one write transaction.
+func (s *BoltAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return s.update(func(tx *boltTxStore) error { return tx.DeleteAnimalIfVersion(id, version) })
+}
+
+// DeleteAnimals removes every listed animal in one write transaction.
+func (s *BoltAnimalStore) DeleteAnimals(ids []int) (deleted []int, notFound []int, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		deleted, notFound, err = tx.DeleteAnimals(ids)
+		return err
+	})
+	return deleted, notFound, err
+}
+
+// SetEndangered sets the Endangered flag of an animal in one write transaction.
+func (s *BoltAnimalStore) SetEndangered(id int, endangered bool) error {
+	return s.update(func(tx *boltTxStore) error { return tx.SetEndangered(id, endangered) })
+}
+
+// MergeAnimals merges the two animals in one write transaction.
+func (s *BoltAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (merged Animal, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		merged, err = tx.MergeAnimals(sourceID, targetID, merge)
+		return err
+	})
+	return merged, err
+}
+
+// ReclassifyAnimals moves the matching animals to class to in one write transaction.
+func (s *BoltAnimalStore) ReclassifyAnimals(from, to string) (changed int, err error) {
+	err = s.update(func(tx *boltTxStore) error {
+		changed, err = tx.ReclassifyAnimals(from, to)
+		return err
+	})
+	return changed, err
+}
+
+// ReplaceAllAnimals atomically replaces the whole dataset in one write transaction.
+func (s *BoltAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	return s.update(func(tx *boltTxStore) error { return tx.ReplaceAllAnimals(animals) })
+}
+
+// WithTransaction runs fn inside a single bbolt write transaction, which is serializable:
+// bbolt runs one writer at a time. The transaction commits only if fn succeeds and ctx is
+// still active; otherwise it is rolled back.
+func (s *BoltAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.update(func(tx *boltTxStore) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// boltTxStore implements AnimalStore on a single open bbolt transaction. BoltAnimalStore
+// runs each of its methods through one, and WithTransaction hands one to its callback.
+// Writes fail on a read-only transaction.
+type boltTxStore struct {
+	tx         *bolt.Tx
+	maxAnimals int
+}
+
+// boltKey encodes an ID as a big-endian key, so the cursor visits positive IDs in ascending order.
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// bucket returns the animals bucket of the transaction.
+func (t *boltTxStore) bucket() *bolt.Bucket {
+	return t.tx.Bucket(animalsBucket)
+}
+
+// get decodes the animal stored under id, reporting whether it exists.
+func (t *boltTxStore) get(id int) (Animal, bool, error) {
+	value := t.bucket().Get(boltKey(id))
+	if value == nil {
+		return Animal{}, false, nil
+	}
+	var animal Animal
+	if err := json.Unmarshal(value, &animal); err != nil {
+		return Animal{}, false, fmt.Errorf("decoding animal %d: %w", id, err)
+	}
+	return animal, true, nil
+}
+
+// put encodes and stores the animal under its ID.
+func (t *boltTxStore) put(animal Animal) error {
+	value, err := json.Marshal(animal)
+	if err != nil {
+		return err
+	}
+	return t.bucket().Put(boltKey(animal.ID), value)
+}
+
+// each decodes every stored animal in key order and passes it to fn, stopping at fn's first error.
+func (t *boltTxStore) each(fn func(Animal) error) error {
+	cursor := t.bucket().Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		var animal Animal
+		if err := json.Unmarshal(value, &animal); err != nil {
+			return fmt.Errorf("decoding animal %d: %w", binary.BigEndian.Uint64(key), err)
+		}
+		if err := fn(animal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matching returns the animals matching the filter in ID order, or ErrEmpty if the bucket is empty.
+func (t *boltTxStore) matching(filter AnimalFilter) ([]Animal, error) {
+	if key, _ := t.bucket().Cursor().First(); key == nil {
+		return nil, ErrEmpty
+	}
+	matched := []Animal{}
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			matched = append(matched, animal)
+		}
+		return nil
+	})
+	return matched, err
+}
+
+// full reports whether the bucket has reached capacity. Counting keys walks the bucket, so
+// the check costs O(n) and is skipped when the store is unlimited.
+func (t *boltTxStore) full() bool {
+	return t.maxAnimals > 0 && t.bucket().Stats().KeyN >= t.maxAnimals
+}
+
+// GetAllAnimals returns every animal in ID order, or ErrEmpty.
+func (t *boltTxStore) GetAllAnimals() ([]Animal, error) {
+	return t.matching(AnimalFilter{})
+}
+
+// FilterAnimals returns the matching animals in the filter's sort order, or ErrEmpty.
+func (t *boltTxStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	matched, err := t.matching(filter)
+	if err != nil {
+		return nil, err
+	}
+	sortAnimals(matched, filter.Sort)
+	return matched, nil
+}
+
+// GetAnimalIDs walks the cursor in key order, which is ID order. A filter without criteria and
+// sort keys only reads the keys, without decoding a single animal; other filters decode each
+// animal to match it, and sort keys need the matches collected and sorted first.
+func (t *boltTxStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	if len(filter.Sort) > 0 {
+		matched, err := t.FilterAnimals(filter)
+		if err != nil {
+			return nil, err
+		}
+		return animalIDs(matched), nil
+	}
+
+	cursor := t.bucket().Cursor()
+	if key, _ := cursor.First(); key == nil {
+		return nil, ErrEmpty
+	}
+	ids := []int{}
+	if filter.matchesAll() {
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			ids = append(ids, int(binary.BigEndian.Uint64(key)))
+		}
+		return ids, nil
+	}
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			ids = append(ids, animal.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// StreamAnimals walks the cursor directly when no sort keys are given; sorting by other
+// fields needs the matches collected first.
+func (t *boltTxStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	if len(filter.Sort) > 0 {
+		matched, err := t.FilterAnimals(filter)
+		if err != nil {
+			return err
+		}
+		for _, animal := range matched {
+			if err := fn(animal); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if key, _ := t.bucket().Cursor().First(); key == nil {
+		return ErrEmpty
+	}
+	return t.each(func(animal Animal) error {
+		if !filter.matches(animal) {
+			return nil
+		}
+		return fn(animal)
+	})
+}
+
+// ForEach walks the cursor, which is in ID order, decoding one animal at a time.
+func (t *boltTxStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return t.each(func(animal Animal) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(animal)
+	})
+}
+
+// Query returns the requested page of the animals matching the query's filter, and how many match.
+func (t *boltTxStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	matched, err := t.FilterAnimals(q.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return q.Page.apply(matched), len(matched), nil
+}
+
+// SampleAnimals feeds every matching animal through a reservoir, so only n are decoded into the sample at a time.
+func (t *boltTxStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	if key, _ := t.bucket().Cursor().First(); key == nil {
+		return nil, ErrEmpty
+	}
+	sample := newReservoir(n)
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			sample.add(animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sample.result(), nil
+}
+
+// LatestModified scans the transaction's animals for the newest UpdatedAt, since a transaction
+// has no watermark of its own. Deletes made earlier in the transaction are not reflected.
+func (t *boltTxStore) LatestModified() (time.Time, error) {
+	var latest time.Time
+	err := t.each(func(animal Animal) error {
+		if animal.UpdatedAt.After(latest) {
+			latest = animal.UpdatedAt
+		}
+		return nil
+	})
+	return latest, err
+}
+
+// GetAnimalsModifiedSince scans every animal for those written after t. There is no index on
+// UpdatedAt, so this is O(n) like the other scans.
+func (t *boltTxStore) GetAnimalsModifiedSince(since time.Time) ([]Animal, error) {
+	if key, _ := t.bucket().Cursor().First(); key == nil {
+		return nil, ErrEmpty
+	}
+	modified := []Animal{}
+	err := t.each(func(animal Animal) error {
+		if animal.UpdatedAt.After(since) {
+			modified = append(modified, animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortByUpdatedAt(modified)
+	return modified, nil
+}
+
+// GroupAnimalsByClass buckets the matching animals by class, each bucket ordered by ID.
+func (t *boltTxStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	groups := make(map[string][]Animal)
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			groups[animal.Class] = append(groups[animal.Class], animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range groups {
+		sortAnimals(bucket, nil)
+	}
+	return groups, nil
+}
+
+// Facets feeds every matching animal through a facet counter; an empty bucket yields no facets.
+func (t *boltTxStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	counter := newFacetCounter()
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			counter.add(animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counter.result(), nil
+}
+
+// Analytics feeds every matching animal through an accumulator; an empty bucket yields zeros.
+func (t *boltTxStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	acc := newAnalyticsAccumulator()
+	err := t.each(func(animal Animal) error {
+		if filter.matches(animal) {
+			acc.add(animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return AnalyticsResult{}, err
+	}
+	return acc.analytics(), nil
+}
+
+// FuzzySearch ranks every animal by name distance to the query, or returns ErrEmpty.
+func (t *boltTxStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	all, err := t.matching(AnimalFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return rankByDistance(all, query, maxDistance), nil
+}
+
+// GetAnimalByID returns the animal stored under id, or ErrNotFound.
+func (t *boltTxStore) GetAnimalByID(id int) (*Animal, error) {
+	animal, ok, err := t.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("animal with ID %d %w", id, ErrNotFound)
+	}
+	return &animal, nil
+}
+
+// GetAnimalsByIDs looks up each distinct ID, returning found animals and missing IDs in request order.
+func (t *boltTxStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	found := []Animal{}
+	missing := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		animal, ok, err := t.get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			found = append(found, animal)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+// NextID scans the keys for the highest ID, since keys of negative IDs sort after positive ones.
+func (t *boltTxStore) NextID() (int, error) {
+	next := 1
+	cursor := t.bucket().Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		if id := int(binary.BigEndian.Uint64(key)); id >= next {
+			next = id + 1
+		}
+	}
+	return next, nil
+}
+
+// ReserveID is not supported inside a transaction.
+func (t *boltTxStore) ReserveID() (int, time.Time, error) {
+	return 0, time.Time{}, errReservationsUnsupported
+}
+
+// CreateAnimal checks the key for an existing animal before inserting. An animal without an
+// ID gets the current highest ID plus one.
+func (t *boltTxStore) CreateAnimal(animal Animal) error {
+	if animal.ID != 0 {
+		if _, exists, err := t.get(animal.ID); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("animal with ID %d %w", animal.ID, ErrAlreadyExists)
+		}
+	}
+	if t.full() {
+		return ErrCapacityExceeded
+	}
+
+	if animal.ID == 0 {
+		next, err := t.NextID()
+		if err != nil {
+			return err
+		}
+		animal.ID = next
+	}
+
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	return t.put(animal)
+}
+
+// CreateAnimalAssigningID inserts the animal under the current highest ID plus one, moved past
+// the IDs skip reports.
+func (t *boltTxStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	if t.full() {
+		return animal, ErrCapacityExceeded
+	}
+	next, err := t.NextID()
+	if err != nil {
+		return animal, err
+	}
+	for skip != nil && skip(next) {
+		next++
+	}
+
+	animal.ID = next
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	return animal, t.put(animal)
+}
+
+// UpdateAnimal overwrites an existing animal, keeping its CreatedAt, or returns ErrNotFound.
+func (t *boltTxStore) UpdateAnimal(id int, animal Animal) error {
+	existing, exists, err := t.get(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("animal with ID %d %w for update", id, ErrNotFound)
+	}
+	animal.ID = id
+	animal.CreatedAt = existing.CreatedAt
+	animal.UpdatedAt = time.Now().UTC()
+	return t.put(animal)
+}
+
+// UpsertAnimal updates or creates the animal under id; only creating is subject to the capacity check.
+func (t *boltTxStore) UpsertAnimal(id int, animal Animal) error {
+	_, err := t.UpsertAnimalIf(id, animal, nil)
+	return err
+}
+
+// UpsertAnimalIf upserts like UpsertAnimal, refusing with ErrVersionMismatch to overwrite an
+// existing animal that matches (when non-nil) rejects.
+func (t *boltTxStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	existing, exists, err := t.get(id)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now().UTC()
+	animal.ID = id
+	if exists {
+		if matches != nil && !matches(existing) {
+			return false, fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+		}
+		animal.CreatedAt = existing.CreatedAt
+	} else if t.full() {
+		return false, ErrCapacityExceeded
+	} else {
+		animal.CreatedAt = now
+	}
+	animal.UpdatedAt = now
+	return !exists, t.put(animal)
+}
+
+// UpsertAnimals upserts each animal under its own ID, reporting whether it was created or updated.
+func (t *boltTxStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	if err := checkUpsertBatch(animals); err != nil {
+		return nil, err
+	}
+	results := make([]BatchResult, 0, len(animals))
+	for _, animal := range animals {
+		_, exists, err := t.get(animal.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.UpsertAnimal(animal.ID, animal); err != nil {
+			return nil, err
+		}
+		result := batchCreated
+		if exists {
+			result = batchUpdated
+		}
+		results = append(results, BatchResult{ID: animal.ID, Result: result})
+	}
+	return results, nil
+}
+
+// DeleteAnimal removes the animal under id, or returns ErrNotFound.
+func (t *boltTxStore) DeleteAnimal(id int) error {
+	return t.deleteAnimalIf(id, nil)
+}
+
+// DeleteAnimalIfVersion deletes like DeleteAnimal, but only the given version of the animal.
+func (t *boltTxStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return t.deleteAnimalIf(id, func(current Animal) bool { return animalVersion(current) == version })
+}
+
+// deleteAnimalIf deletes like DeleteAnimal, refusing with ErrVersionMismatch to delete an
+// animal that matches (when non-nil) rejects.
+func (t *boltTxStore) deleteAnimalIf(id int, matches func(current Animal) bool) error {
+	existing, exists, err := t.get(id)
+	if err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("animal with ID %d %w for deletion", id, ErrNotFound)
+	}
+	if matches != nil && !matches(existing) {
+		return fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+	}
+	return t.bucket().Delete(boltKey(id))
+}
+
+// DeleteAnimals removes each distinct listed ID, reporting deleted and missing IDs in request order.
+func (t *boltTxStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	deleted := []int{}
+	notFound := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if _, exists, err := t.get(id); err != nil {
+			return nil, nil, err
+		} else if !exists {
+			notFound = append(notFound, id)
+			continue
+		}
+		if err := t.bucket().Delete(boltKey(id)); err != nil {
+			return nil, nil, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, notFound, nil
+}
+
+// SetEndangered rewrites the animal with the new flag and a fresh UpdatedAt.
+func (t *boltTxStore) SetEndangered(id int, endangered bool) error {
+	animal, exists, err := t.get(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("animal with ID %d %w", id, ErrNotFound)
+	}
+	animal.Endangered = endangered
+	animal.UpdatedAt = time.Now().UTC()
+	return t.put(animal)
+}
+
+// MergeAnimals rewrites the target with the merged record and deletes the source.
+func (t *boltTxStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	if err := checkMergeIDs(sourceID, targetID); err != nil {
+		return Animal{}, err
+	}
+	source, exists, err := t.get(sourceID)
+	if err != nil {
+		return Animal{}, err
+	}
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", sourceID, ErrNotFound)
+	}
+	target, exists, err := t.get(targetID)
+	if err != nil {
+		return Animal{}, err
+	}
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", targetID, ErrNotFound)
+	}
+	merged := mergedRecord(source, target, merge, time.Now().UTC())
+	if err := t.put(merged); err != nil {
+		return Animal{}, err
+	}
+	if err := t.bucket().Delete(boltKey(sourceID)); err != nil {
+		return Animal{}, err
+	}
+	return merged, nil
+}
+
+// ReclassifyAnimals rewrites the matching animals, collecting them first because the bucket
+// must not be modified while a cursor walks it.
+func (t *boltTxStore) ReclassifyAnimals(from, to string) (int, error) {
+	var matched []Animal
+	err := t.each(func(animal Animal) error {
+		if strings.EqualFold(animal.Class, from) && animal.Class != to {
+			matched = append(matched, animal)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, animal := range matched {
+		animal.Class = to
+		animal.UpdatedAt = now
+		if err := t.put(animal); err != nil {
+			return 0, err
+		}
+	}
+	return len(matched), nil
+}
+
+// ReplaceAllAnimals follows InMemoryAnimalStore.ReplaceAllAnimals for ID assignment and the
+// capacity check, then recreates the bucket with the result.
+func (t *boltTxStore) ReplaceAllAnimals(animals []Animal) error {
+	staged := NewInMemoryAnimalStore(t.maxAnimals)
+	if err := staged.ReplaceAllAnimals(animals); err != nil {
+		return err
+	}
+
+	if err := t.tx.DeleteBucket(animalsBucket); err != nil {
+		return err
+	}
+	if _, err := t.tx.CreateBucket(animalsBucket); err != nil {
+		return err
+	}
+	for _, animal := range staged.animals.items {
+		if err := t.put(animal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithTransaction runs fn in the enclosing transaction: bbolt has no nested transactions, so
+// an error from fn rolls back the whole outer transaction.
+func (t *boltTxStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(t)
+}
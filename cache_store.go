@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a single cached read together with its expiry time.
+type cacheEntry struct {
+	animal    Animal   // Set for single-animal entries
+	animals   []Animal // Set for list entries
+	expiresAt time.Time
+}
+
+// CachingAnimalStore is an AnimalStore decorator that caches GetAnimalByID, GetAllAnimals
+// and FilterAnimals results for a fixed TTL. Any mutation invalidates the affected animal
+// and every cached list, so reads never observe data older than the last write made
+// through this store. It composes with any backend because it only relies on AnimalStore.
+type CachingAnimalStore struct {
+	inner      AnimalStore
+	ttl        time.Duration
+	maxEntries int
+
+	mu         sync.Mutex
+	animals    map[int]cacheEntry    // Cached single animals by ID
+	lists      map[string]cacheEntry // Cached list results by filter key
+	generation uint64                // Bumped on every invalidation so in-flight loads started before a write are not cached
+}
+
+// NewCachingAnimalStore wraps inner with a read cache whose entries live for ttl.
+// maxEntries bounds the total number of cached entries; values below 1 are treated as 1.
+func NewCachingAnimalStore(inner AnimalStore, ttl time.Duration, maxEntries int) *CachingAnimalStore {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &CachingAnimalStore{
+		inner:      inner,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		animals:    make(map[int]cacheEntry),
+		lists:      make(map[string]cacheEntry),
+	}
+}
+
+// GetAllAnimals returns the cached full list, loading it from the underlying store on a miss.
+func (c *CachingAnimalStore) GetAllAnimals() ([]Animal, error) {
+	return c.cachedList("all", c.inner.GetAllAnimals)
+}
+
+// FilterAnimals returns the cached result for this filter, loading it from the underlying store on a miss.
+func (c *CachingAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	return c.cachedList("filter:"+filter.key(), func() ([]Animal, error) { return c.inner.FilterAnimals(filter) })
+}
+
+// GetAnimalIDs passes through to the underlying store; ID lists are cheap to compute and not cached.
+func (c *CachingAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	return c.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals is not cached: streaming exists to avoid holding whole result sets in memory.
+func (c *CachingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	return c.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach is not cached either, for the same reason.
+func (c *CachingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return c.inner.ForEach(ctx, fn)
+}
+
+// Facets is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	return c.inner.Facets(filter)
+}
+
+// Analytics is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	return c.inner.Analytics(filter)
+}
+
+// GroupAnimalsByClass is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	return c.inner.GroupAnimalsByClass(filter)
+}
+
+// FuzzySearch is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	return c.inner.FuzzySearch(query, maxDistance)
+}
+
+// GetAnimalByID returns the cached animal, loading it from the underlying store on a miss.
+// Lookups that fail (e.g. not found) are not cached.
+func (c *CachingAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	c.mu.Lock()
+	entry, ok := c.animals[id]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		animal := entry.animal
+		return &animal, nil
+	}
+
+	animal, err := c.inner.GetAnimalByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.makeRoom()
+		c.animals[id] = cacheEntry{animal: *animal, expiresAt: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	result := *animal
+	return &result, nil
+}
+
+// GetAnimalsByIDs is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	return c.inner.GetAnimalsByIDs(ids)
+}
+
+// NextID is passed through to the underlying store uncached.
+func (c *CachingAnimalStore) NextID() (int, error) {
+	return c.inner.NextID()
+}
+
+// ReserveID is passed through to the underlying store.
+func (c *CachingAnimalStore) ReserveID() (int, time.Time, error) {
+	return c.inner.ReserveID()
+}
+
+// GetAnimalsModifiedSince passes through uncached: every sync asks for a different time.
+func (c *CachingAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	return c.inner.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified passes through uncached: it is a cheap read, and a stale answer would produce stale 304s.
+func (c *CachingAnimalStore) LatestModified() (time.Time, error) {
+	return c.inner.LatestModified()
+}
+
+// Query pages through the cached FilterAnimals result, so queries that differ only in their page share an entry.
+func (c *CachingAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	matched, err := c.FilterAnimals(q.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return q.Page.apply(matched), len(matched), nil
+}
+
+// SampleAnimals is never cached, since every call should draw a fresh sample.
+func (c *CachingAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	return c.inner.SampleAnimals(filter, n)
+}
+
+// CreateAnimal creates the animal in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) CreateAnimal(animal Animal) error {
+	defer c.invalidate(animal.ID)
+	return c.inner.CreateAnimal(animal)
+}
+
+// CreateAnimalAssigningID creates the animal in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	created, err := c.inner.CreateAnimalAssigningID(animal, skip)
+	c.invalidate(created.ID)
+	return created, err
+}
+
+// UpdateAnimal updates the animal in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	defer c.invalidate(id)
+	return c.inner.UpdateAnimal(id, animal)
+}
+
+// UpsertAnimal upserts the animal in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	defer c.invalidate(id)
+	return c.inner.UpsertAnimal(id, animal)
+}
+
+// UpsertAnimalIf conditionally upserts the animal in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	defer c.invalidate(id)
+	return c.inner.UpsertAnimalIf(id, animal, matches)
+}
+
+// MergeAnimals merges the animals in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	defer c.invalidate(sourceID, targetID)
+	return c.inner.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals upserts the animals in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	ids := make([]int, len(animals))
+	for i, animal := range animals {
+		ids[i] = animal.ID
+	}
+	defer c.invalidate(ids...)
+	return c.inner.UpsertAnimals(animals)
+}
+
+// DeleteAnimal deletes the animal from the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) DeleteAnimal(id int) error {
+	defer c.invalidate(id)
+	return c.inner.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion conditionally deletes the animal from the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	defer c.invalidate(id)
+	return c.inner.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals deletes the animals from the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	defer c.invalidate(ids...)
+	return c.inner.DeleteAnimals(ids)
+}
+
+// SetEndangered sets the flag in the underlying store and invalidates the cache.
+func (c *CachingAnimalStore) SetEndangered(id int, endangered bool) error {
+	defer c.invalidate(id)
+	return c.inner.SetEndangered(id, endangered)
+}
+
+// ReclassifyAnimals reclassifies in the underlying store and drops the whole cache, since any animal may have changed.
+func (c *CachingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	defer c.invalidateAll()
+	return c.inner.ReclassifyAnimals(from, to)
+}
+
+// ReplaceAllAnimals replaces the dataset in the underlying store and drops the whole cache.
+func (c *CachingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	defer c.invalidateAll()
+	return c.inner.ReplaceAllAnimals(animals)
+}
+
+// WithTransaction runs the transaction on the underlying store, bypassing the cache, and
+// drops the whole cache afterwards because the transaction may have touched anything.
+func (c *CachingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	defer c.invalidateAll()
+	return c.inner.WithTransaction(ctx, fn)
+}
+
+// cachedList serves a list result from the cache under key, calling load on a miss.
+// A copy is returned so callers cannot modify the cached slice.
+func (c *CachingAnimalStore) cachedList(key string, load func() ([]Animal, error)) ([]Animal, error) {
+	c.mu.Lock()
+	entry, ok := c.lists[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return append([]Animal(nil), entry.animals...), nil
+	}
+
+	animals, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.makeRoom()
+		c.lists[key] = cacheEntry{animals: append([]Animal(nil), animals...), expiresAt: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return animals, nil
+}
+
+// invalidate drops the given animals and all cached lists, since any write can change list results.
+// It runs after the write and bumps the generation, so a read that started before the write
+// cannot re-cache the value it loaded.
+func (c *CachingAnimalStore) invalidate(ids ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		delete(c.animals, id)
+	}
+	c.lists = make(map[string]cacheEntry)
+	c.generation++
+}
+
+// invalidateAll drops every cached entry.
+func (c *CachingAnimalStore) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.animals = make(map[int]cacheEntry)
+	c.lists = make(map[string]cacheEntry)
+	c.generation++
+}
+
+// makeRoom evicts entries until there is space for one more. Expired entries go first;
+// otherwise the entry closest to expiry is evicted. Callers must hold c.mu.
+func (c *CachingAnimalStore) makeRoom() {
+	now := time.Now()
+	for id, entry := range c.animals {
+		if !entry.expiresAt.After(now) {
+			delete(c.animals, id)
+		}
+	}
+	for key, entry := range c.lists {
+		if !entry.expiresAt.After(now) {
+			delete(c.lists, key)
+		}
+	}
+
+	for len(c.animals)+len(c.lists) >= c.maxEntries {
+		oldestID, oldestKey := 0, ""
+		var oldestAnimal, oldestList time.Time
+		for id, entry := range c.animals {
+			if oldestAnimal.IsZero() || entry.expiresAt.Before(oldestAnimal) {
+				oldestID, oldestAnimal = id, entry.expiresAt
+			}
+		}
+		for key, entry := range c.lists {
+			if oldestList.IsZero() || entry.expiresAt.Before(oldestList) {
+				oldestKey, oldestList = key, entry.expiresAt
+			}
+		}
+
+		if !oldestAnimal.IsZero() && (oldestList.IsZero() || oldestAnimal.Before(oldestList)) {
+			delete(c.animals, oldestID)
+		} else {
+			delete(c.lists, oldestKey)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachingAnimalStore(t *testing.T) {
+	lion := Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}
+	behindTheCache := Animal{ID: 1, Name: "Changed elsewhere", Class: "mammal", Legs: 4}
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		write     func(cache, inner AnimalStore) error // Run after the first read has filled the cache
+		wait      time.Duration
+		wantName  string
+		wantClass string
+	}{
+		{
+			name:      "hit serves the cached animal",
+			ttl:       time.Hour,
+			write:     func(_, inner AnimalStore) error { return inner.UpdateAnimal(1, behindTheCache) },
+			wantName:  "Lion",
+			wantClass: "mammal",
+		},
+		{
+			name:      "expired entry is reloaded",
+			ttl:       10 * time.Millisecond,
+			write:     func(_, inner AnimalStore) error { return inner.UpdateAnimal(1, behindTheCache) },
+			wait:      20 * time.Millisecond,
+			wantName:  "Changed elsewhere",
+			wantClass: "mammal",
+		},
+		{
+			name: "update through the cache invalidates",
+			ttl:  time.Hour,
+			write: func(cache, _ AnimalStore) error {
+				return cache.UpdateAnimal(1, Animal{Name: "Leo", Class: "mammal", Legs: 4})
+			},
+			wantName:  "Leo",
+			wantClass: "mammal",
+		},
+		{
+			name: "upsert through the cache invalidates",
+			ttl:  time.Hour,
+			write: func(cache, _ AnimalStore) error {
+				return cache.UpsertAnimal(1, Animal{Name: "Leo", Class: "mammal", Legs: 4})
+			},
+			wantName:  "Leo",
+			wantClass: "mammal",
+		},
+		{
+			name: "reclassify through the cache invalidates",
+			ttl:  time.Hour,
+			write: func(cache, _ AnimalStore) error {
+				_, err := cache.ReclassifyAnimals("mammal", "cat")
+				return err
+			},
+			wantName:  "Lion",
+			wantClass: "cat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewInMemoryAnimalStore(0)
+			if err := inner.CreateAnimal(lion); err != nil {
+				t.Fatal(err)
+			}
+			cache := NewCachingAnimalStore(inner, tt.ttl, 100)
+			if _, err := cache.GetAnimalByID(1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := cache.GetAllAnimals(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.write(cache, inner); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+
+			animal, err := cache.GetAnimalByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all, err := cache.GetAllAnimals()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if animal.Name != tt.wantName || animal.Class != tt.wantClass {
+				t.Errorf("animal = %q (%s), want %q (%s)", animal.Name, animal.Class, tt.wantName, tt.wantClass)
+			}
+			if len(all) != 1 || all[0].Name != tt.wantName || all[0].Class != tt.wantClass {
+				t.Errorf("list = %v, want only %q (%s)", all, tt.wantName, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestCachingAnimalStoreDeleteAndBound(t *testing.T) {
+	inner := NewInMemoryAnimalStore(0)
+	for id := 1; id <= 5; id++ {
+		if err := inner.CreateAnimal(Animal{ID: id, Name: "Animal", Class: "bird", Legs: 2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache := NewCachingAnimalStore(inner, time.Hour, 3)
+	for id := 1; id <= 5; id++ {
+		if _, err := cache.GetAnimalByID(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if size := len(cache.animals) + len(cache.lists); size > 3 {
+		t.Errorf("cache holds %d entries, want at most 3", size)
+	}
+
+	if err := cache.DeleteAnimal(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetAnimalByID(5); err == nil {
+		t.Error("deleted animal still served from the cache")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// cloneAnimalHandler handles POST requests that copy an existing animal into a new record.
+// The optional JSON body overrides fields of the copy with merge-patch semantics (null clears
+// a field); the new record always gets a fresh ID and fresh timestamps.
+func cloneAnimalHandler(store AnimalStore, prefix string, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		source, err := store.GetAnimalByID(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound) // 404 Not Found
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The body is optional: an empty one (io.EOF from the decoder) means no overrides. Its
+		// length is not checked up front, since a chunked body has none.
+		clone := *source
+		var overrides map[string]interface{}
+		err = decodeJSONBody(r.Body, &overrides, (*json.Decoder).UseNumber)
+		if err != nil && !errors.Is(err, io.EOF) {
+			writeInvalidBody(w, r, err)
+			return
+		}
+		if err == nil {
+			if errs := patchLegsErrors(overrides); len(errs) > 0 {
+				writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+				return
+			}
+			if clone, err = patchAnimal(clone, overrides); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		clone = normalizeAnimal(clone, false, cfg)
+		if errs := validateAnimal(clone, cfg); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		if clone, err = store.CreateAnimalAssigningID(clone, nil); err != nil {
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrCapacityExceeded) {
+				http.Error(w, "The store is full: no more animals can be created", http.StatusInsufficientStorage) // 507
+				return
+			}
+			http.Error(w, fmt.Sprintf("Could not store the clone: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Echo the stored record so the response includes the store-managed timestamps
+		if stored, err := store.GetAnimalByID(clone.ID); err == nil {
+			clone = *stored
+		}
+
+		w.Header().Set("Location", animalLocation(prefix, clone.ID))
+		writeAnimalResult(w, r, cfg.ETagMode, http.StatusCreated, clone) // 201 Created
+	}
+}
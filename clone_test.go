@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloneAnimal(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool // Sent without a Content-Length, as a chunked body is
+		wantStatus int
+		wantName   string
+		wantLegs   int
+	}{
+		{name: "no body", wantStatus: http.StatusCreated, wantName: "Lion", wantLegs: 4},
+		{name: "blank body", body: " \n", wantStatus: http.StatusCreated, wantName: "Lion", wantLegs: 4},
+		{name: "empty chunked body", chunked: true, wantStatus: http.StatusCreated, wantName: "Lion", wantLegs: 4},
+		{name: "overrides", body: `{"name": "Lioness"}`, wantStatus: http.StatusCreated, wantName: "Lioness", wantLegs: 4},
+		{name: "chunked overrides", body: `{"name": "Lioness", "legs": 3}`, chunked: true, wantStatus: http.StatusCreated, wantName: "Lioness", wantLegs: 3},
+		{name: "id is not copied", body: `{"id": 1}`, wantStatus: http.StatusCreated, wantName: "Lion", wantLegs: 4},
+		{name: "malformed body", body: `{"name": `, wantStatus: http.StatusBadRequest},
+		{name: "malformed chunked body", body: `{"name": `, chunked: true, wantStatus: http.StatusBadRequest},
+		{name: "fractional legs", body: `{"legs": 2.5}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid override", body: `{"name": null}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body) // Hides the length from httptest.NewRequest
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/animals/1/clone", body)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			rec := httptest.NewRecorder()
+			newTestAPI(store, testConfig()).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			clone := decodeAnimalResponse(t, rec)
+			if clone.ID == 1 || clone.Name != tt.wantName || clone.Legs != tt.wantLegs || clone.Class != "mammal" {
+				t.Errorf("clone = %+v, want a new ID, name %q, %d legs and class mammal", clone, tt.wantName, tt.wantLegs)
+			}
+			if got := rec.Header().Get("Location"); got != animalLocation("/v1", clone.ID) {
+				t.Errorf("Location = %q", got)
+			}
+		})
+	}
+}
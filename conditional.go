@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ETag modes: what the ETags of animals and lists are computed from.
+const (
+	etagWeak   = "weak"   // Tags from the version (IDs and updated_at): equal for semantically equivalent responses
+	etagStrong = "strong" // Tags from a hash of the exact response bytes: equal only for byte-identical responses
+)
+
+// strongETag returns a strong ETag for a response body, hashing every byte of it.
+func strongETag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// listETag returns the ETag of a page of the animal list whose serialized form is body. A weak
+// tag hashes only the version of the page: the ID and updated_at of every animal on it, the
+// total match count and the ?fields= projection, so any write that changes what the page shows
+// (or the X-Total-Count header) changes it, while formatting such as ?pretty=true does not. A
+// strong tag hashes body itself. Either way the tag is computed per request from what was
+// already loaded and needs no bookkeeping in the store.
+func listETag(animals []Animal, total int, fields []string, body []byte, mode string) string {
+	if mode == etagStrong {
+		return strongETag(body)
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n%s\n", total, strings.Join(fields, ","))
+	for _, animal := range animals {
+		fmt.Fprintf(hash, "%d %d\n", animal.ID, animal.UpdatedAt.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// idListETag returns the ETag of a page of an ?id_only=true list. A weak tag hashes the IDs of
+// the page and the total, which is all the body shows, so unlike listETag it survives updates
+// that change no ID. A strong tag hashes the body, as for full lists.
+func idListETag(ids []int, total int, body []byte, mode string) string {
+	if mode == etagStrong {
+		return strongETag(body)
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "ids %d\n", total)
+	for _, id := range ids {
+		fmt.Fprintf(hash, "%d\n", id)
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// animalVersion is the version of an animal that DeleteAnimalIfVersion compares: its updated_at
+// in Unix nanoseconds, which every write bumps.
+func animalVersion(animal Animal) int64 {
+	return animal.UpdatedAt.UnixNano()
+}
+
+// animalETag returns the ETag of a single animal. In weak mode it is a weak tag of the animal's
+// ID and version (see animalVersion), shared by all its representations just as the lists' weak
+// tags are, so If-Match, which compares strongly, never matches it. In strong mode it hashes the
+// animal's default representation: compact v1 JSON, as GET /animals/{id} serves it without
+// ?pretty=true or a profile.
+func animalETag(animal Animal, mode string) (string, error) {
+	if mode == etagStrong {
+		var body bytes.Buffer
+		if err := encodeJSONValue(&body, animal, false); err != nil {
+			return "", err
+		}
+		return strongETag(body.Bytes()), nil
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d %d", animal.ID, animalVersion(animal))
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
+
+// animalETags returns every ETag a response for the animal can carry, which are the tags an
+// If-Match naming it may hold. In weak mode that is its one weak tag, which only lets If-Match: *
+// hold. In strong mode it is
+// the tag of each representation writeAnimalBody serves: every response profile, compact and
+// with ?pretty=true, each encoded exactly as the response encodes it.
+func animalETags(animal Animal, mode string) ([]string, error) {
+	if mode != etagStrong {
+		etag, err := animalETag(animal, mode)
+		if err != nil {
+			return nil, err
+		}
+		return []string{etag}, nil
+	}
+	var etags []string
+	for _, profile := range slices.Sorted(maps.Keys(animalProfiles)) {
+		for _, pretty := range []bool{false, true} {
+			var body bytes.Buffer
+			if err := encodeJSONValue(&body, animalProfiles[profile](animal), pretty); err != nil {
+				return nil, err
+			}
+			etags = append(etags, strongETag(body.Bytes()))
+		}
+	}
+	return etags, nil
+}
+
+// responseETag returns the ETag of an animal response whose serialized form is body: the
+// animal's weak tag, or the strong tag of body itself.
+func responseETag(animal Animal, body []byte, mode string) (string, error) {
+	if mode == etagStrong {
+		return strongETag(body), nil
+	}
+	return animalETag(animal, mode)
+}
+
+// prefersMinimalReturn reports whether the request asks for return=minimal in its Prefer
+// header (RFC 7240), i.e. for a write response without the representation. Preferences are
+// matched case-insensitively and their parameters are ignored; return=representation, the
+// default, and unknown preferences leave the response as it is.
+func prefersMinimalReturn(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			name, value, _ := strings.Cut(strings.TrimSpace(token), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "return") &&
+				strings.EqualFold(strings.Trim(strings.TrimSpace(value), `"`), "minimal") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// weakETagMatch compares two ETags with the weak comparison of RFC 9110 (section 8.8.3.2):
+// they match if their opaque tags are equal, whether or not either is weak.
+func weakETagMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// strongETagMatch compares two ETags with the strong comparison of RFC 9110: they match only if
+// neither is weak and they are identical, so a weak tag never matches anything.
+func strongETagMatch(a, b string) bool {
+	return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+}
+
+// etagMatches reports whether the request's If-None-Match header lists the ETag (or is "*").
+// Comparison is weak, as RFC 9110 requires for If-None-Match: the W/ prefix is ignored.
+func etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || weakETagMatch(candidate, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchSatisfied reports whether the request's If-Match header, if any, holds for a resource
+// whose current representations carry etags (none if the resource does not exist). Comparison
+// is strong, as RFC 9110 requires for If-Match, so a weak tag never satisfies it; "*" holds for
+// any existing resource.
+func ifMatchSatisfied(r *http.Request, etags []string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && len(etags) > 0 {
+			return true
+		}
+		for _, etag := range etags {
+			if strongETagMatch(candidate, etag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ifMatchesAnimal reports whether the request's If-Match header holds for the current animal,
+// comparing against every tag a response for it can carry (see animalETags).
+func ifMatchesAnimal(r *http.Request, current Animal, mode string) bool {
+	etags, err := animalETags(current, mode)
+	return err == nil && ifMatchSatisfied(r, etags)
+}
+
+// checkIfMatch evaluates If-Match for a write to current (nil if the animal does not exist) and
+// answers 412 Precondition Failed when it does not hold, reporting whether the write may go on.
+// The check is separate from the write, so a concurrent write may still slip in between.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current *Animal, mode string) bool {
+	if r.Header.Get("If-Match") == "" {
+		return true
+	}
+	var etags []string
+	if current != nil {
+		var err error
+		if etags, err = animalETags(*current, mode); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+	}
+	if ifMatchSatisfied(r, etags) {
+		return true
+	}
+	http.Error(w, "If-Match does not match the animal's current ETag", http.StatusPreconditionFailed) // 412 Precondition Failed
+	return false
+}
+
+// ifMatchListsETags reports whether the request's If-Match header names at least one ETag,
+// rather than being absent or only "*".
+func ifMatchListsETags(r *http.Request) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-Match"), ",") {
+		if candidate = strings.TrimSpace(candidate); candidate != "" && candidate != "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether the request's If-Modified-Since header is at or after
+// latest, i.e. whether the client's copy predates no write. HTTP dates have one-second
+// precision, so latest is truncated before comparing. As RFC 9110 requires, the header is
+// ignored when If-None-Match is present, and an unparsable date is ignored too.
+func notModifiedSince(r *http.Request, latest time.Time) bool {
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !latest.Truncate(time.Second).After(since)
+}
+
+// writeCacheHeaders sets the caching headers of a list response: the ETag (unless it is
+// empty, for a 304 answered before the list was rendered), Cache-Control with the configured
+// max-age (no-cache when it is 0, so clients always revalidate), and Vary: Accept because the
+// same URL can also be served as NDJSON.
+func writeCacheHeaders(w http.ResponseWriter, etag string, maxAge time.Duration) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if seconds := int(maxAge / time.Second); seconds > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", seconds))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Add("Vary", "Accept")
+}
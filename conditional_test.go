@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIfMatchRoundTrip(t *testing.T) {
+	reads := []struct {
+		name    string
+		query   string
+		headers []string
+	}{
+		{"compact", "", nil},
+		{"pretty", "?pretty=true", nil},
+		{"v2 profile", "", []string{"Accept", "application/json;profile=v2"}},
+		{"pretty v2 profile", "?pretty=true", []string{"Accept", "application/json;profile=v2"}},
+	}
+	writes := []struct {
+		method     string
+		target     string
+		body       string
+		headers    []string
+		wantStatus int
+	}{
+		{http.MethodPut, "/v1/animals/1", `{"name": "Lion", "class": "mammal", "legs": 3}`, nil, http.StatusOK},
+		{http.MethodPatch, "/v1/animals/1", `{"legs": 3}`, []string{"Content-Type", "application/merge-patch+json"}, http.StatusOK},
+		{http.MethodDelete, "/v1/animals/1", "", nil, http.StatusNoContent},
+		{http.MethodPost, "/v1/animals/1/endangered", `{"endangered": true}`, nil, http.StatusOK},
+	}
+	for _, read := range reads {
+		for _, write := range writes {
+			t.Run(read.name+"/"+write.method+" "+write.target, func(t *testing.T) {
+				cfg := testConfig()
+				cfg.ETagMode = etagStrong
+				store := NewInMemoryAnimalStore(0)
+				if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+					t.Fatal(err)
+				}
+				api := newTestAPI(store, cfg)
+
+				get := serve(api, http.MethodGet, "/v1/animals/1"+read.query, "", read.headers...)
+				etag := get.Header().Get("ETag")
+				if get.Code != http.StatusOK || etag == "" {
+					t.Fatalf("GET status = %d, ETag %q", get.Code, etag)
+				}
+				if revalidated := serve(api, http.MethodGet, "/v1/animals/1"+read.query, "", append([]string{"If-None-Match", etag}, read.headers...)...); revalidated.Code != http.StatusNotModified {
+					t.Errorf("GET with If-None-Match: status = %d, want 304", revalidated.Code)
+				}
+
+				// Another writer changes the animal: the tag read before is now stale
+				if err := store.UpdateAnimal(1, Animal{Name: "Lion", Class: "mammal", Legs: 5}); err != nil {
+					t.Fatal(err)
+				}
+				stale := serve(api, write.method, write.target, write.body, append([]string{"If-Match", etag}, write.headers...)...)
+				if stale.Code != http.StatusPreconditionFailed {
+					t.Fatalf("write with a stale If-Match: status = %d, want 412 (%s)", stale.Code, stale.Body)
+				}
+
+				get = serve(api, http.MethodGet, "/v1/animals/1"+read.query, "", read.headers...)
+				etag = get.Header().Get("ETag")
+				current := serve(api, write.method, write.target, write.body, append([]string{"If-Match", etag}, write.headers...)...)
+				if current.Code != write.wantStatus {
+					t.Fatalf("write with the current If-Match %s: status = %d, want %d (%s)", etag, current.Code, write.wantStatus, current.Body)
+				}
+			})
+		}
+	}
+}
+
+func TestAnimalETagsPerRepresentation(t *testing.T) {
+	reads := [][]string{
+		{"", ""},
+		{"?pretty=true", ""},
+		{"", "application/json;profile=v2"},
+		{"?pretty=true", "application/json;profile=v2"},
+	}
+	for _, mode := range []string{etagWeak, etagStrong} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.ETagMode = mode
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			api := newTestAPI(store, cfg)
+
+			etags := make(map[string]bool)
+			for _, read := range reads {
+				var headers []string
+				if read[1] != "" {
+					headers = []string{"Accept", read[1]}
+				}
+				get := serve(api, http.MethodGet, "/v1/animals/1"+read[0], "", headers...)
+				etag := get.Header().Get("ETag")
+				// A strong tag promises byte-identical bodies, which the representations are not
+				if weak := strings.HasPrefix(etag, "W/"); weak != (mode == etagWeak) {
+					t.Errorf("%s %s: ETag = %s, want weak = %v", read[0], read[1], etag, mode == etagWeak)
+				}
+				if revalidated := serve(api, http.MethodGet, "/v1/animals/1"+read[0], "", append([]string{"If-None-Match", etag}, headers...)...); revalidated.Code != http.StatusNotModified {
+					t.Errorf("%s %s: GET with If-None-Match: status = %d, want 304", read[0], read[1], revalidated.Code)
+				}
+				etags[etag] = true
+			}
+			if want := map[string]int{etagWeak: 1, etagStrong: len(reads)}[mode]; len(etags) != want {
+				t.Errorf("%d distinct ETags for %d representations, want %d", len(etags), len(reads), want)
+			}
+		})
+	}
+}
+
+func TestIfMatchWeakMode(t *testing.T) {
+	writes := []struct {
+		method  string
+		target  string
+		body    string
+		headers []string
+	}{
+		{http.MethodPut, "/v1/animals/1", `{"name": "Lion", "class": "mammal", "legs": 3}`, nil},
+		{http.MethodPatch, "/v1/animals/1", `{"legs": 3}`, []string{"Content-Type", "application/merge-patch+json"}},
+		{http.MethodDelete, "/v1/animals/1", "", nil},
+		{http.MethodPost, "/v1/animals/1/endangered", `{"endangered": true}`, nil},
+	}
+	for _, write := range writes {
+		t.Run(write.method+" "+write.target, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			api := newTestAPI(store, testConfig())
+			etag := serve(api, http.MethodGet, "/v1/animals/1", "").Header().Get("ETag")
+
+			// The strong comparison of If-Match never matches the weak tag, not even the current one
+			if rec := serve(api, write.method, write.target, write.body, append([]string{"If-Match", etag}, write.headers...)...); rec.Code != http.StatusPreconditionFailed {
+				t.Errorf("If-Match %s: status = %d, want 412", etag, rec.Code)
+			}
+			if rec := serve(api, write.method, write.target, write.body, append([]string{"If-Match", "*"}, write.headers...)...); rec.Code >= 300 {
+				t.Errorf("If-Match *: status = %d, want success (%s)", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestIfMatchSatisfied(t *testing.T) {
+	etags := []string{`"a"`, `"b"`}
+	tests := []struct {
+		ifMatch string
+		etags   []string
+		want    bool
+	}{
+		{"", nil, true},
+		{`"a"`, etags, true},
+		{`"x", "b"`, etags, true},
+		{`"x"`, etags, false},
+		{`W/"a"`, etags, false}, // Strong comparison: a weak tag never matches
+		{`W/"a"`, []string{`W/"a"`}, false},
+		{"*", etags, true},
+		{"*", nil, false}, // "*" requires the animal to exist
+		{`"a"`, nil, false},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodPut, "/v1/animals/1", nil)
+		if tt.ifMatch != "" {
+			r.Header.Set("If-Match", tt.ifMatch)
+		}
+		if got := ifMatchSatisfied(r, tt.etags); got != tt.want {
+			t.Errorf("If-Match %s against %q = %v, want %v", tt.ifMatch, tt.etags, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentConditionalUpserts(t *testing.T) {
+	const writers = 16
+	for name, store := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.ETagMode = etagStrong
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			api := newTestAPI(store, cfg)
+			etag := serve(api, http.MethodGet, "/v1/animals/1", "").Header().Get("ETag")
+
+			// Every writer read the same version; only the first write may apply
+			statuses := make(chan int, writers)
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					body := fmt.Sprintf(`{"name": "Lion %d", "class": "mammal", "legs": 4}`, i)
+					statuses <- serve(api, http.MethodPut, "/v1/animals/1", body, "If-Match", etag).Code
+				}()
+			}
+			close(start)
+			wg.Wait()
+			close(statuses)
+
+			counts := make(map[int]int)
+			for status := range statuses {
+				counts[status]++
+			}
+			if counts[http.StatusOK] != 1 || counts[http.StatusPreconditionFailed] != writers-1 {
+				t.Errorf("statuses = %v, want one 200 and %d 412s", counts, writers-1)
+			}
+			if lion, _ := store.GetAnimalByID(1); lion == nil || !strings.HasPrefix(lion.Name, "Lion ") {
+				t.Errorf("stored %+v, want the winning write", lion)
+			}
+		})
+	}
+}
+
+func TestConditionalDelete(t *testing.T) {
+	tests := []struct {
+		name       string
+		strict     bool
+		target     string
+		ifMatch    string // "current" sends the animal's ETag and "stale" one from before a write
+		wantStatus int
+		wantWeak   int // In weak mode, whose tags If-Match never matches; 0 if the same
+	}{
+		{"current ETag", false, "/v1/animals/1", "current", http.StatusNoContent, http.StatusPreconditionFailed},
+		{"stale ETag", false, "/v1/animals/1", "stale", http.StatusPreconditionFailed, 0},
+		{"any of several", false, "/v1/animals/1", `"other", current`, http.StatusNoContent, http.StatusPreconditionFailed},
+		{"wildcard", false, "/v1/animals/1", "*", http.StatusNoContent, 0},
+		{"weak ETag", false, "/v1/animals/1", "W/current", http.StatusPreconditionFailed, 0},
+		{"missing animal", false, "/v1/animals/2", "current", http.StatusNotFound, 0},
+		{"unconditional", false, "/v1/animals/1", "", http.StatusNoContent, 0},
+		{"strict, current ETag", true, "/v1/animals/1", "current", http.StatusNoContent, http.StatusPreconditionFailed},
+		{"strict, stale ETag", true, "/v1/animals/1", "stale", http.StatusPreconditionFailed, 0},
+		{"strict, wildcard", true, "/v1/animals/1", "*", http.StatusNoContent, 0},
+		{"strict, unconditional", true, "/v1/animals/1", "", http.StatusPreconditionRequired, 0},
+	}
+	for _, mode := range []string{etagWeak, etagStrong} {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				cfg := testConfig()
+				cfg.ETagMode = mode
+				cfg.StrictDelete = tt.strict
+				store := NewInMemoryAnimalStore(0)
+				if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+					t.Fatal(err)
+				}
+				api := newTestAPI(store, cfg)
+				stale := serve(api, http.MethodGet, "/v1/animals/1", "").Header().Get("ETag")
+				if err := store.UpdateAnimal(1, Animal{Name: "Lion", Class: "mammal", Legs: 3}); err != nil {
+					t.Fatal(err)
+				}
+				current := serve(api, http.MethodGet, "/v1/animals/1", "").Header().Get("ETag")
+
+				var headers []string
+				if tt.ifMatch != "" {
+					ifMatch := strings.ReplaceAll(strings.ReplaceAll(tt.ifMatch, "current", current), "stale", stale)
+					headers = []string{"If-Match", ifMatch}
+				}
+				want := tt.wantStatus
+				if mode == etagWeak && tt.wantWeak != 0 {
+					want = tt.wantWeak
+				}
+				rec := serve(api, http.MethodDelete, tt.target, "", headers...)
+				if rec.Code != want {
+					t.Fatalf("status = %d, want %d (%s)", rec.Code, want, rec.Body)
+				}
+				_, err := store.GetAnimalByID(1)
+				if deleted := errors.Is(err, ErrNotFound); deleted != (want == http.StatusNoContent) {
+					t.Errorf("animal 1 deleted = %v after status %d", deleted, rec.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteAnimalIfVersion(t *testing.T) {
+	for name, store := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			read, err := store.GetAnimalByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			version := animalVersion(*read)
+
+			// Another writer changes the animal after it was read: the version read is stale
+			if err := store.UpdateAnimal(1, Animal{Name: "Lion", Class: "mammal", Legs: 3}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteAnimalIfVersion(1, version); !errors.Is(err, ErrVersionMismatch) {
+				t.Fatalf("delete of a stale version: error = %v, want ErrVersionMismatch", err)
+			}
+			if _, err := store.GetAnimalByID(1); err != nil {
+				t.Fatalf("a refused delete removed the animal: %v", err)
+			}
+
+			current, _ := store.GetAnimalByID(1)
+			if err := store.DeleteAnimalIfVersion(1, animalVersion(*current)); err != nil {
+				t.Fatalf("delete of the current version: %v", err)
+			}
+			if _, err := store.GetAnimalByID(1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("after the delete: error = %v, want ErrNotFound", err)
+			}
+			if err := store.DeleteAnimalIfVersion(1, animalVersion(*current)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("delete of a missing animal: error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings of the API server.
+// Every setting can be given as a command-line flag; the matching ANEKAZOO_* environment
+// variable supplies the default when the flag is omitted.
+type Config struct {
+	Storage        string // Storage backend: "memory" or "bolt"
+	BoltPath       string // Database file of the bolt backend
+	MaxAnimals     int    // Maximum number of animals the store holds; 0 means unlimited
+	CapacityPolicy string // What a full store does on create: "reject" (507) or "evict-lru"
+	Shards         int    // Number of independently locked shards of the memory backend; 1 uses the single-lock store
+	MaxInFlight    int    // Maximum number of requests served concurrently; 0 disables the limit
+	MaxQueryParams int    // Maximum number of query parameters of a request; 0 disables the limit
+	MaxQueryLength int    // Maximum length of a request's query string in bytes; 0 disables the limit
+	MaxBodyBytes   int    // Maximum size of a request body in bytes (413 beyond it); 0 disables the limit
+
+	RateLimitExempt *PathMatcher // Paths neither shed by MaxInFlight nor counted by the write limit
+
+	Tenants []string // Allowlist of tenant IDs, each served from a store of its own; empty disables tenancy
+
+	RequestTimeout time.Duration            // How long an API request may run before it is aborted with 503; 0 disables the limit
+	RouteTimeouts  map[string]time.Duration // Per-route overrides of RequestTimeout, keyed by path template
+	DeadlineHeader string                   // Request header carrying the caller's own deadline, capped by the timeout; empty ignores callers' deadlines
+
+	CORSOrigins []string      // Origins allowed to make cross-origin requests ("*" for any); empty disables CORS
+	CORSMethods []string      // Methods allowed in cross-origin requests, announced in preflight responses
+	CORSHeaders []string      // Request headers allowed in cross-origin requests, announced in preflight responses
+	CORSMaxAge  time.Duration // How long browsers may cache a preflight response (Access-Control-Max-Age)
+	CORSExempt  *PathMatcher  // Paths served without any CORS handling
+
+	StartupAttempts int           // How many times the store is pinged at startup before the server gives up
+	StartupTimeout  time.Duration // How long each startup ping may take
+
+	StoreRetryAttempts int           // Total tries of a store operation failing with a transient error; 1 disables retries
+	StoreRetryDelay    time.Duration // Wait before the first retry, doubling per retry
+	StoreRetryMaxDelay time.Duration // Upper bound on the wait between retries
+	StoreRetryJitter   float64       // Random shift of each wait, as a fraction of it
+
+	ShutdownDrainDelay time.Duration // How long /readyz fails before the server stops on SIGTERM, so load balancers drain it
+	TrailingSlash      string        // Treatment of paths with a trailing slash: "redirect" (308), "rewrite" or "strict" (404)
+	AssignIDs          bool          // Give animals created without an ID the next free one; off rejects them with 400
+	ETagMode           string        // What ETags are computed from: "weak" (version) or "strong" (exact bytes)
+	ReservationTTL     time.Duration // How long an ID reserved through POST /animals/reserve is held back
+	Seed               bool          // Store the seed dataset into an empty store at startup; also what the admin reset restores
+	SeedFile           string        // JSON or CSV file the seed dataset is loaded from; empty uses the built-in animals
+	SweepJitter        float64       // Random shift of each background sweep, as a fraction of its interval
+
+	MaintenanceWindows []maintenanceWindow // Scheduled windows during which the API answers 503 (or rejects writes)
+	MaintenanceExempt  *PathMatcher        // Paths served even during a maintenance window
+
+	WriteLimit  int           // Writes each client may make within WriteWindow before getting 429; 0 disables the guard
+	WriteWindow time.Duration // Length of the sliding window of the write limit
+
+	AdminEnabled      bool // Enables destructive admin operations (bulk delete, reset)
+	StrictContentType bool // Require Content-Type: application/json on request bodies of writes
+	StrictDelete      bool // Require If-Match on DELETE /animals/{id}, answering 428 without it
+	StrictProfiles    bool // Answer requests asking only for unknown Accept profiles with 406 instead of the default shape
+	ReadOnly          bool // Start in read-only mode (writes answered with 503); can be switched at runtime
+
+	DefaultLimit int  // Page size used when a list request has no ?limit=
+	MaxLimit     int  // Largest page size a client may request
+	StrictLimits bool // Reject limits above MaxLimit with 400 instead of clamping them
+
+	ListMaxAge time.Duration // Cache-Control max-age of list responses; 0 sends no-cache (always revalidate)
+
+	FuzzyMaxDistance int // Maximum Levenshtein distance for ?fuzzy= name matches
+
+	NameNormalization string // What writes do with a name before validating it: "raw", "collapse" (trim, collapse whitespace) or "nfc"
+	MaxNameLength     int    // Longest name a write may store, in characters (422 beyond it); 0 means no limit
+
+	EmptyClass     string   // What writes do with an empty class: "allow", "reject" (422) or "default" (use DefaultClass)
+	DefaultClass   string   // Class given to animals without one under the "default" empty-class policy
+	AllowedClasses []string // Classes a write may store, matched case-insensitively (422 otherwise); empty allows any class
+
+	EnforceLegRules bool               // Reject animals whose leg count breaks their class's rule (422)
+	LegRules        map[string]legRule // Leg bounds per lowercased class, parsed from the -leg-rules spec
+	DefaultLegs     map[string]int     // Legs given to animals of a lowercased class written without legs; empty disables defaulting
+
+	LatencyBuckets []float64 // Bucket bounds (seconds) of the HTTP latency histogram
+
+	PushgatewayURL string        // Prometheus Pushgateway the metrics are pushed to (e.g. http://localhost:9091); empty disables pushing
+	PushJob        string        // Job name the metrics are pushed under
+	PushInterval   time.Duration // How often the metrics are pushed; they are pushed once more on shutdown
+
+	OTLPEndpoint string // OTLP/HTTP trace collector URL (e.g. http://localhost:4318); empty disables tracing
+	ServiceName  string // Service name reported on traces
+	ServerTiming bool   // Add a Server-Timing header with the total and store time of each request
+
+	CacheTTL        time.Duration // How long cached store reads stay fresh; 0 disables the cache
+	CacheMaxEntries int           // Upper bound on cached entries (single animals and list results)
+	ListCacheTTL    time.Duration // How long serialized list responses are reused; 0 only coalesces concurrent identical requests
+
+	Audit            bool   // Record every mutation to the audit log
+	AuditFile        string // File the audit log is appended to as JSON lines; empty means stdout
+	AuditBufferSize  int    // Number of recent audit entries kept in memory for GET /admin/audit
+	AuditHistorySize int    // Number of audit entries kept per animal for GET /animals/{id}/history
+
+	Debug             bool     // Log request and response bodies of mutating requests; sensitive, off by default
+	DebugRedactFields []string // JSON fields whose values are replaced in the debug log
+	DebugMaxBody      int      // Maximum number of bytes of each body written to the debug log
+
+	Pprof bool // Serve the net/http/pprof profiling endpoints under /debug/pprof/ to localhost; off by default
+
+	ExampleHooks bool // Register the example mutation hooks of hooks.go: no dragons, and creates and deletes logged
+
+	GRPCAddr string // Address of the gRPC AnimalService, e.g. :9000; empty serves REST only
+
+	PollTimeout time.Duration // Longest a long poll of /animals/poll waits for a change before answering 204
+}
+
+// loadConfig parses command-line flags (falling back to environment variables) into a Config.
+func loadConfig() Config {
+	var cfg Config
+	flag.StringVar(&cfg.Storage, "storage", envString("ANEKAZOO_STORAGE", "memory"), "storage backend: memory or bolt")
+	flag.StringVar(&cfg.BoltPath, "bolt-path", envString("ANEKAZOO_BOLT_PATH", "anekazoo.db"), "database file of the bolt storage backend")
+	flag.IntVar(&cfg.MaxAnimals, "max-animals", envInt("ANEKAZOO_MAX_ANIMALS", 0), "maximum number of stored animals (0 means unlimited)")
+	flag.StringVar(&cfg.CapacityPolicy, "capacity-policy", envString("ANEKAZOO_CAPACITY_POLICY", capacityReject), "what a full store does on create: reject or evict-lru")
+	flag.IntVar(&cfg.Shards, "shards", envInt("ANEKAZOO_SHARDS", 1), "number of shards of the memory storage backend, each with its own lock (1 uses a single lock)")
+	tenants := flag.String("tenants", envString("ANEKAZOO_TENANTS", ""), "comma-separated tenant IDs; requests must name one in the X-Tenant-ID header (empty disables tenancy)")
+	flag.IntVar(&cfg.MaxInFlight, "max-in-flight", envInt("ANEKAZOO_MAX_IN_FLIGHT", 100), "maximum number of concurrently served requests (0 disables the limit)")
+	rateLimitExempt := flag.String("rate-limit-exempt", envString("ANEKAZOO_RATE_LIMIT_EXEMPT", ""), "comma-separated paths or globs exempt from load shedding and the write limit, e.g. /healthz,/readyz,/metrics")
+	flag.IntVar(&cfg.MaxQueryParams, "max-query-params", envInt("ANEKAZOO_MAX_QUERY_PARAMS", 100), "maximum number of query parameters of a request (0 disables the limit)")
+	flag.IntVar(&cfg.MaxQueryLength, "max-query-length", envInt("ANEKAZOO_MAX_QUERY_LENGTH", 8192), "maximum length of a request's query string in bytes (0 disables the limit)")
+	flag.IntVar(&cfg.MaxBodyBytes, "max-body-bytes", envInt("ANEKAZOO_MAX_BODY_BYTES", 1<<20), "maximum size of a request body in bytes, answered with 413 beyond it (0 disables the limit)")
+	flag.DurationVar(&cfg.RequestTimeout, "request-timeout", envDuration("ANEKAZOO_REQUEST_TIMEOUT", 30*time.Second), "how long an API request may run before it is aborted with 503 (0 disables the limit)")
+	routeTimeouts := flag.String("route-timeouts", envString("ANEKAZOO_ROUTE_TIMEOUTS", ""), "per-route request timeouts, e.g. /v1/animals/query=2s,/v1/admin/reset=1m")
+	flag.StringVar(&cfg.DeadlineHeader, "deadline-header", envString("ANEKAZOO_DEADLINE_HEADER", ""), "request header carrying the caller's deadline, e.g. X-Request-Deadline or x-envoy-expected-rq-timeout-ms (empty ignores callers' deadlines)")
+	corsOrigins := flag.String("cors-origins", envString("ANEKAZOO_CORS_ORIGINS", ""), "comma-separated origins allowed to make cross-origin requests, or * for any (empty disables CORS)")
+	corsMethods := flag.String("cors-methods", envString("ANEKAZOO_CORS_METHODS", defaultCORSMethods), "comma-separated methods allowed in cross-origin requests")
+	corsHeaders := flag.String("cors-headers", envString("ANEKAZOO_CORS_HEADERS", defaultCORSHeaders), "comma-separated request headers allowed in cross-origin requests")
+	corsExempt := flag.String("cors-exempt", envString("ANEKAZOO_CORS_EXEMPT", ""), "comma-separated paths or globs served without CORS handling, e.g. /metrics")
+	flag.DurationVar(&cfg.CORSMaxAge, "cors-max-age", envDuration("ANEKAZOO_CORS_MAX_AGE", 10*time.Minute), "how long browsers may cache a CORS preflight response")
+	flag.IntVar(&cfg.StartupAttempts, "startup-attempts", envInt("ANEKAZOO_STARTUP_ATTEMPTS", 5), "how many times the store is pinged at startup before the server exits")
+	flag.DurationVar(&cfg.StartupTimeout, "startup-timeout", envDuration("ANEKAZOO_STARTUP_TIMEOUT", 2*time.Second), "how long each startup ping of the store may take")
+	flag.IntVar(&cfg.StoreRetryAttempts, "store-retry-attempts", envInt("ANEKAZOO_STORE_RETRY_ATTEMPTS", 1), "total tries of a store operation failing with a transient error (1 disables retries)")
+	flag.DurationVar(&cfg.StoreRetryDelay, "store-retry-delay", envDuration("ANEKAZOO_STORE_RETRY_DELAY", 50*time.Millisecond), "wait before the first retry of a store operation, doubling per retry")
+	flag.DurationVar(&cfg.StoreRetryMaxDelay, "store-retry-max-delay", envDuration("ANEKAZOO_STORE_RETRY_MAX_DELAY", time.Second), "upper bound on the wait between retries of a store operation")
+	flag.Float64Var(&cfg.StoreRetryJitter, "store-retry-jitter", envFloat("ANEKAZOO_STORE_RETRY_JITTER", 0.2), "random shift of each retry wait, as a fraction of it (0 to <1)")
+	flag.DurationVar(&cfg.ShutdownDrainDelay, "shutdown-drain-delay", envDuration("ANEKAZOO_SHUTDOWN_DRAIN_DELAY", 0), "how long readiness fails before shutdown starts (e.g. 5s; 0 shuts down immediately)")
+	flag.StringVar(&cfg.TrailingSlash, "trailing-slash", envString("ANEKAZOO_TRAILING_SLASH", trailingSlashRedirect), "treatment of paths with a trailing slash: redirect, rewrite or strict")
+	flag.BoolVar(&cfg.AssignIDs, "assign-ids", envBool("ANEKAZOO_ASSIGN_IDS", true), "assign the next free ID to animals created without one (false rejects them)")
+	flag.StringVar(&cfg.ETagMode, "etag-mode", envString("ANEKAZOO_ETAG_MODE", etagWeak), "what ETags are computed from: weak (the version) or strong (the exact response bytes)")
+	flag.DurationVar(&cfg.ReservationTTL, "reservation-ttl", envDuration("ANEKAZOO_RESERVATION_TTL", 15*time.Minute), "how long a reserved ID is held back before it is released")
+	flag.BoolVar(&cfg.Seed, "seed", envBool("ANEKAZOO_SEED", true), "store the seed dataset into an empty store at startup (false starts empty)")
+	flag.StringVar(&cfg.SeedFile, "seed-file", envString("ANEKAZOO_SEED_FILE", ""), "JSON or CSV (.csv) file holding the seed dataset (empty uses the built-in animals)")
+	flag.Float64Var(&cfg.SweepJitter, "sweep-jitter", envFloat("ANEKAZOO_SWEEP_JITTER", 0.1), "random shift of each background sweep, as a fraction of its interval (0 to <1)")
+	maintenanceWindows := flag.String("maintenance-windows", envString("ANEKAZOO_MAINTENANCE_WINDOWS", ""), "scheduled maintenance windows, e.g. 2026-11-01T02:00:00Z/2026-11-01T04:00:00Z,sun 03:00-03:30=read-only")
+	maintenanceExempt := flag.String("maintenance-exempt", envString("ANEKAZOO_MAINTENANCE_EXEMPT", defaultMaintenanceExempt), "comma-separated paths served during maintenance windows")
+	flag.IntVar(&cfg.WriteLimit, "write-limit", envInt("ANEKAZOO_WRITE_LIMIT", 0), "writes each client may make within the write window before getting 429 (0 disables the limit)")
+	flag.DurationVar(&cfg.WriteWindow, "write-window", envDuration("ANEKAZOO_WRITE_WINDOW", time.Minute), "length of the sliding window of the write limit")
+	flag.BoolVar(&cfg.AdminEnabled, "admin", envBool("ANEKAZOO_ADMIN_ENABLED", false), "enable admin operations such as bulk delete and reset")
+	flag.BoolVar(&cfg.StrictContentType, "strict-content-type", envBool("ANEKAZOO_STRICT_CONTENT_TYPE", true), "reject write requests whose body is not application/json with 415")
+	flag.BoolVar(&cfg.StrictDelete, "strict-delete", envBool("ANEKAZOO_STRICT_DELETE", false), "require If-Match on deletes of single animals, rejecting deletes without it with 428")
+	flag.BoolVar(&cfg.StrictProfiles, "strict-profiles", envBool("ANEKAZOO_STRICT_PROFILES", false), "reject requests whose Accept asks only for unknown response profiles with 406")
+	flag.BoolVar(&cfg.ReadOnly, "read-only", envBool("ANEKAZOO_READ_ONLY", false), "start in read-only mode, rejecting writes with 503")
+	flag.IntVar(&cfg.DefaultLimit, "default-limit", envInt("ANEKAZOO_DEFAULT_LIMIT", 100), "page size used when a list request has no limit")
+	flag.IntVar(&cfg.MaxLimit, "max-limit", envInt("ANEKAZOO_MAX_LIMIT", 1000), "largest page size a client may request")
+	flag.BoolVar(&cfg.StrictLimits, "strict-limits", envBool("ANEKAZOO_STRICT_LIMITS", false), "reject limits above max-limit instead of clamping them")
+	flag.DurationVar(&cfg.ListMaxAge, "list-max-age", envDuration("ANEKAZOO_LIST_MAX_AGE", 0), "Cache-Control max-age of the animal list (0 means clients always revalidate)")
+	flag.IntVar(&cfg.FuzzyMaxDistance, "fuzzy-max-distance", envInt("ANEKAZOO_FUZZY_MAX_DISTANCE", 2), "maximum edit distance for fuzzy name matches")
+	flag.StringVar(&cfg.NameNormalization, "name-normalization", envString("ANEKAZOO_NAME_NORMALIZATION", nameCollapse), "what writes do with a name before validating it: raw, collapse (trim and collapse whitespace) or nfc (collapse and Unicode NFC)")
+	flag.IntVar(&cfg.MaxNameLength, "max-name-length", envInt("ANEKAZOO_MAX_NAME_LENGTH", 100), "longest name a write may store, in characters (0 means no limit)")
+	flag.StringVar(&cfg.EmptyClass, "empty-class", envString("ANEKAZOO_EMPTY_CLASS", emptyClassAllow), "what writes do with an empty class: allow, reject or default")
+	flag.StringVar(&cfg.DefaultClass, "default-class", envString("ANEKAZOO_DEFAULT_CLASS", "unknown"), "class given to animals without one when -empty-class is default")
+	allowedClasses := flag.String("allowed-classes", envString("ANEKAZOO_ALLOWED_CLASSES", ""), "comma-separated classes writes may store, e.g. mammal,bird,reptile (empty allows any class)")
+	flag.BoolVar(&cfg.EnforceLegRules, "enforce-leg-rules", envBool("ANEKAZOO_ENFORCE_LEG_RULES", false), "validate leg counts against the per-class leg rules")
+	legRules := flag.String("leg-rules", envString("ANEKAZOO_LEG_RULES", defaultLegRules), "per-class leg rules, e.g. bird=2,insect=6,spider=6-8")
+	defaultLegs := flag.String("default-legs", envString("ANEKAZOO_DEFAULT_LEGS", ""), "legs given per class to animals written without legs, e.g. bird=2,snake=0 (empty disables defaulting)")
+	latencyBuckets := flag.String("metrics-buckets", envString("ANEKAZOO_METRICS_BUCKETS", defaultLatencyBuckets), "comma-separated latency histogram buckets in seconds")
+	flag.StringVar(&cfg.PushgatewayURL, "pushgateway-url", envString("ANEKAZOO_PUSHGATEWAY_URL", ""), "Prometheus Pushgateway URL the metrics are pushed to (empty disables pushing)")
+	flag.StringVar(&cfg.PushJob, "push-job", envString("ANEKAZOO_PUSH_JOB", "anekazoo"), "job name the metrics are pushed to the Pushgateway under")
+	flag.DurationVar(&cfg.PushInterval, "push-interval", envDuration("ANEKAZOO_PUSH_INTERVAL", 15*time.Second), "how often the metrics are pushed to the Pushgateway")
+	flag.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", envString("OTEL_EXPORTER_OTLP_ENDPOINT", ""), "OTLP/HTTP collector URL for traces (empty disables tracing)")
+	flag.StringVar(&cfg.ServiceName, "service-name", envString("OTEL_SERVICE_NAME", "anekazoo"), "service name reported on traces")
+	flag.BoolVar(&cfg.ServerTiming, "server-timing", envBool("ANEKAZOO_SERVER_TIMING", false), "add a Server-Timing header with the total and store time of each request")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", envDuration("ANEKAZOO_CACHE_TTL", 0), "TTL of the read cache in front of the store (0 disables caching)")
+	flag.IntVar(&cfg.CacheMaxEntries, "cache-max-entries", envInt("ANEKAZOO_CACHE_MAX_ENTRIES", 1000), "maximum number of entries held by the read cache")
+	flag.DurationVar(&cfg.ListCacheTTL, "list-cache-ttl", envDuration("ANEKAZOO_LIST_CACHE_TTL", 0), "how long serialized list responses are reused (0 only coalesces concurrent identical requests)")
+	flag.BoolVar(&cfg.Audit, "audit", envBool("ANEKAZOO_AUDIT", false), "record every mutation to the audit log")
+	flag.StringVar(&cfg.AuditFile, "audit-file", envString("ANEKAZOO_AUDIT_FILE", ""), "file the audit log is appended to (empty means stdout)")
+	flag.IntVar(&cfg.AuditBufferSize, "audit-buffer", envInt("ANEKAZOO_AUDIT_BUFFER", 1000), "number of recent audit entries served by the audit endpoint")
+	flag.IntVar(&cfg.AuditHistorySize, "audit-history", envInt("ANEKAZOO_AUDIT_HISTORY", 100), "number of audit entries kept per animal for its history endpoint")
+	flag.BoolVar(&cfg.Debug, "debug", envBool("ANEKAZOO_DEBUG", false), "log request and response bodies of mutating requests (may expose sensitive data)")
+	redactFields := flag.String("debug-redact", envString("ANEKAZOO_DEBUG_REDACT", ""), "comma-separated JSON fields redacted in the debug log")
+	flag.IntVar(&cfg.DebugMaxBody, "debug-max-body", envInt("ANEKAZOO_DEBUG_MAX_BODY", 4096), "maximum number of bytes of each body in the debug log")
+	flag.BoolVar(&cfg.Pprof, "pprof", envBool("ANEKAZOO_PPROF", false), "serve the pprof profiling endpoints under /debug/pprof/ to localhost (not for production)")
+	flag.BoolVar(&cfg.ExampleHooks, "example-hooks", envBool("ANEKAZOO_EXAMPLE_HOOKS", false), "register the example mutation hooks, which veto the class dragon and log creates and deletes")
+	flag.StringVar(&cfg.GRPCAddr, "grpc-addr", envString("ANEKAZOO_GRPC_ADDR", ""), "address of the gRPC AnimalService, e.g. :9000 (empty serves REST only)")
+	flag.DurationVar(&cfg.PollTimeout, "poll-timeout", envDuration("ANEKAZOO_POLL_TIMEOUT", 30*time.Second), "longest a long poll waits for a change before answering 204 No Content")
+	flag.Parse()
+
+	rules, err := parseLegRules(*legRules)
+	if err != nil {
+		log.Fatalf("invalid -leg-rules: %v", err)
+	}
+	cfg.LegRules = rules
+
+	if cfg.DefaultLegs, err = parseDefaultLegs(*defaultLegs); err != nil {
+		log.Fatalf("invalid -default-legs: %v", err)
+	}
+
+	if cfg.RouteTimeouts, err = parseRouteTimeouts(*routeTimeouts); err != nil {
+		log.Fatalf("invalid -route-timeouts: %v", err)
+	}
+
+	buckets, err := parseBuckets(*latencyBuckets)
+	if err != nil {
+		log.Fatalf("invalid -metrics-buckets: %v", err)
+	}
+	cfg.LatencyBuckets = buckets
+
+	cfg.DebugRedactFields = splitList(*redactFields)
+
+	if cfg.MaintenanceWindows, err = parseMaintenanceWindows(*maintenanceWindows); err != nil {
+		log.Fatalf("invalid -maintenance-windows: %v", err)
+	}
+	if cfg.MaintenanceExempt, err = parsePathMatcher(*maintenanceExempt); err != nil {
+		log.Fatalf("invalid -maintenance-exempt: %v", err)
+	}
+	if cfg.CORSExempt, err = parsePathMatcher(*corsExempt); err != nil {
+		log.Fatalf("invalid -cors-exempt: %v", err)
+	}
+	if cfg.RateLimitExempt, err = parsePathMatcher(*rateLimitExempt); err != nil {
+		log.Fatalf("invalid -rate-limit-exempt: %v", err)
+	}
+
+	cfg.Tenants = splitList(*tenants)
+	for _, tenant := range cfg.Tenants {
+		if !tenantIDPattern.MatchString(tenant) {
+			log.Fatalf("invalid tenant ID %q in -tenants: expected 1 to 64 letters, digits, '-' or '_'", tenant)
+		}
+	}
+	if len(cfg.Tenants) > 0 && cfg.CacheTTL > 0 {
+		// The read cache is shared by every request and keyed by animal ID alone
+		log.Fatalf("-tenants cannot be combined with -cache-ttl")
+	}
+
+	cfg.CORSOrigins = splitList(*corsOrigins)
+	for _, method := range splitList(*corsMethods) {
+		cfg.CORSMethods = append(cfg.CORSMethods, strings.ToUpper(method))
+	}
+	cfg.CORSHeaders = splitList(*corsHeaders)
+	if cfg.StartupAttempts < 1 {
+		log.Fatalf("invalid -startup-attempts %d: must be at least 1", cfg.StartupAttempts)
+	}
+	if cfg.StartupTimeout <= 0 {
+		log.Fatalf("invalid -startup-timeout %s: must be positive", cfg.StartupTimeout)
+	}
+	if cfg.StoreRetryAttempts < 1 {
+		log.Fatalf("invalid -store-retry-attempts %d: must be at least 1", cfg.StoreRetryAttempts)
+	}
+	if cfg.StoreRetryDelay <= 0 || cfg.StoreRetryMaxDelay < cfg.StoreRetryDelay {
+		log.Fatalf("invalid -store-retry-delay %s and -store-retry-max-delay %s: the delay must be positive and not exceed the maximum", cfg.StoreRetryDelay, cfg.StoreRetryMaxDelay)
+	}
+	if cfg.StoreRetryJitter < 0 || cfg.StoreRetryJitter >= 1 {
+		log.Fatalf("invalid -store-retry-jitter %g: must be at least 0 and less than 1", cfg.StoreRetryJitter)
+	}
+	if cfg.WriteLimit < 0 {
+		log.Fatalf("invalid -write-limit %d: must not be negative", cfg.WriteLimit)
+	}
+	if cfg.WriteWindow <= 0 {
+		log.Fatalf("invalid -write-window %s: must be positive", cfg.WriteWindow)
+	}
+	if cfg.ReservationTTL <= 0 {
+		log.Fatalf("invalid -reservation-ttl %s: must be positive", cfg.ReservationTTL)
+	}
+	if cfg.SweepJitter < 0 || cfg.SweepJitter >= 1 {
+		log.Fatalf("invalid -sweep-jitter %g: must be at least 0 and less than 1", cfg.SweepJitter)
+	}
+	if cfg.PushgatewayURL != "" && cfg.PushInterval <= 0 {
+		log.Fatalf("invalid -push-interval %s: must be positive", cfg.PushInterval)
+	}
+	if cfg.PushgatewayURL != "" && strings.TrimSpace(cfg.PushJob) == "" {
+		log.Fatal("-push-job must not be empty when -pushgateway-url is set")
+	}
+	if cfg.MaxBodyBytes < 0 {
+		log.Fatalf("invalid -max-body-bytes %d: must not be negative", cfg.MaxBodyBytes)
+	}
+	if cfg.CORSMaxAge < 0 {
+		log.Fatalf("invalid -cors-max-age %s: must not be negative", cfg.CORSMaxAge)
+	}
+	if cfg.Debug {
+		log.Print("debug body logging is enabled: request and response bodies are written to the log")
+	}
+	if cfg.Pprof {
+		log.Print("profiling is enabled: pprof endpoints are served to localhost under /debug/pprof/")
+	}
+
+	if cfg.CapacityPolicy != capacityReject && cfg.CapacityPolicy != capacityEvictLRU {
+		log.Fatalf("invalid -capacity-policy %q: expected %s or %s", cfg.CapacityPolicy, capacityReject, capacityEvictLRU)
+	}
+	switch cfg.NameNormalization {
+	case nameRaw, nameCollapse, nameNFC:
+	default:
+		log.Fatalf("invalid -name-normalization %q: expected %s, %s or %s", cfg.NameNormalization, nameRaw, nameCollapse, nameNFC)
+	}
+	if cfg.PollTimeout <= 0 {
+		log.Fatalf("invalid -poll-timeout %s: must be positive", cfg.PollTimeout)
+	}
+	if cfg.MaxNameLength < 0 {
+		log.Fatalf("invalid -max-name-length %d: must not be negative", cfg.MaxNameLength)
+	}
+	switch cfg.EmptyClass {
+	case emptyClassAllow, emptyClassReject, emptyClassDefault:
+	default:
+		log.Fatalf("invalid -empty-class %q: expected %s, %s or %s", cfg.EmptyClass, emptyClassAllow, emptyClassReject, emptyClassDefault)
+	}
+	if cfg.EmptyClass == emptyClassDefault && strings.TrimSpace(cfg.DefaultClass) == "" {
+		log.Fatalf("-default-class must not be empty when -empty-class is %s", emptyClassDefault)
+	}
+	for _, class := range splitList(*allowedClasses) {
+		if _, ok := allowedClass(class, cfg); ok {
+			log.Fatalf("invalid -allowed-classes: %q is listed twice", class)
+		}
+		cfg.AllowedClasses = append(cfg.AllowedClasses, class)
+	}
+	if _, ok := allowedClass(cfg.DefaultClass, cfg); !ok && cfg.EmptyClass == emptyClassDefault {
+		log.Fatalf("-default-class %q must be one of -allowed-classes", cfg.DefaultClass)
+	}
+	if cfg.ETagMode != etagWeak && cfg.ETagMode != etagStrong {
+		log.Fatalf("invalid -etag-mode %q: expected %s or %s", cfg.ETagMode, etagWeak, etagStrong)
+	}
+	switch cfg.TrailingSlash {
+	case trailingSlashRedirect, trailingSlashRewrite, trailingSlashStrict:
+	default:
+		log.Fatalf("invalid -trailing-slash %q: expected %s, %s or %s", cfg.TrailingSlash, trailingSlashRedirect, trailingSlashRewrite, trailingSlashStrict)
+	}
+	if cfg.CapacityPolicy == capacityEvictLRU && cfg.CacheTTL > 0 {
+		// Cache hits would hide accesses from the LRU order, and evicted animals would stay cached
+		log.Fatalf("-capacity-policy %s cannot be combined with -cache-ttl", capacityEvictLRU)
+	}
+
+	if cfg.MaxLimit < 1 {
+		cfg.MaxLimit = 1
+	}
+	if cfg.DefaultLimit < 1 || cfg.DefaultLimit > cfg.MaxLimit {
+		cfg.DefaultLimit = cfg.MaxLimit
+	}
+	return cfg
+}
+
+// envString returns the value of the environment variable, or def if it is unset or empty.
+func envString(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// envBool returns the boolean value of the environment variable, or def if it is unset or invalid.
+func envBool(key string, def bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return def
+}
+
+// envInt returns the integer value of the environment variable, or def if it is unset or invalid.
+func envInt(key string, def int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return def
+}
+
+// envFloat returns the floating-point value of the environment variable, or def if it is unset or invalid.
+func envFloat(key string, def float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return def
+}
+
+// envDuration returns the duration value (e.g. "30s") of the environment variable, or def if it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return def
+}
+
+// splitList splits a comma-separated flag value into its trimmed, non-empty items.
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
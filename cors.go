@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default CORS lists: every method the API serves, and the request headers its clients send
+// beyond the CORS-safelisted ones.
+const (
+	defaultCORSMethods = "GET,HEAD,POST,PUT,PATCH,DELETE"
+	defaultCORSHeaders = "Content-Type,If-Match,If-None-Match,Prefer"
+)
+
+// corsExposedHeaders are the response headers browsers let cross-origin scripts read, beyond
+// the CORS-safelisted ones.
+var corsExposedHeaders = []string{"ETag", "Last-Modified", "Location", "Preference-Applied", "X-Dry-Run", "X-Limit", "X-Offset", "X-Total-Count", "X-Write-Limit", "X-Write-Remaining"}
+
+// cors answers CORS preflight requests and adds the CORS headers to the responses of other
+// cross-origin requests from cfg.CORSOrigins ("*" allows any origin). It wraps the whole router,
+// because a preflight OPTIONS request matches no route. A preflight is always answered with
+// 204 No Content and an Allow header listing cfg.CORSMethods; the Access-Control-* headers are
+// only added when the origin and the requested method are allowed, so the browser blocks
+// anything else. Access-Control-Max-Age lets browsers cache the result for cfg.CORSMaxAge
+// instead of repeating the preflight before every request. Without allowed origins the
+// middleware is a no-op.
+func cors(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(cfg.CORSOrigins) == 0 {
+			return next
+		}
+
+		origins := make(map[string]bool, len(cfg.CORSOrigins))
+		for _, origin := range cfg.CORSOrigins {
+			origins[origin] = true
+		}
+		methods := make(map[string]bool, len(cfg.CORSMethods))
+		for _, method := range cfg.CORSMethods {
+			methods[method] = true
+		}
+		allowMethods := strings.Join(cfg.CORSMethods, ", ")
+		allowHeaders := strings.Join(cfg.CORSHeaders, ", ")
+		exposeHeaders := strings.Join(corsExposedHeaders, ", ")
+		maxAge := strconv.Itoa(int(cfg.CORSMaxAge / time.Second))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || cfg.CORSExempt.Match(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			allowed := origins["*"] || origins[origin]
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || requested == "" {
+				if allowed {
+					header.Set("Access-Control-Allow-Origin", origin)
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Preflight: answered here, never passed to the router
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Allow", allowMethods)
+			if allowed && methods[strings.ToUpper(requested)] {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Methods", allowMethods)
+				if allowHeaders != "" {
+					header.Set("Access-Control-Allow-Headers", allowHeaders)
+				}
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		method      string
+		path        string
+		origin      string
+		requested   string // Access-Control-Request-Method; a preflight when set with OPTIONS
+		wantStatus  int
+		wantAllowed string // Access-Control-Allow-Origin; empty means absent
+		wantMethods bool   // Access-Control-Allow-Methods present
+		wantExpose  bool   // Access-Control-Expose-Headers present
+		wantReached bool   // Passed on to the router
+	}{
+		{name: "same-origin request", origins: []string{"https://zoo.example"}, method: http.MethodGet, path: "/v1/animals",
+			wantStatus: http.StatusOK, wantReached: true},
+		{name: "allowed origin", origins: []string{"https://zoo.example"}, method: http.MethodGet, path: "/v1/animals", origin: "https://zoo.example",
+			wantStatus: http.StatusOK, wantAllowed: "https://zoo.example", wantExpose: true, wantReached: true},
+		{name: "other origin", origins: []string{"https://zoo.example"}, method: http.MethodGet, path: "/v1/animals", origin: "https://evil.example",
+			wantStatus: http.StatusOK, wantReached: true},
+		{name: "any origin", origins: []string{"*"}, method: http.MethodGet, path: "/v1/animals", origin: "https://evil.example",
+			wantStatus: http.StatusOK, wantAllowed: "https://evil.example", wantExpose: true, wantReached: true},
+		{name: "preflight", origins: []string{"https://zoo.example"}, method: http.MethodOptions, path: "/v1/animals/1", origin: "https://zoo.example", requested: "DELETE",
+			wantStatus: http.StatusNoContent, wantAllowed: "https://zoo.example", wantMethods: true},
+		{name: "preflight, lower-case method", origins: []string{"https://zoo.example"}, method: http.MethodOptions, path: "/v1/animals/1", origin: "https://zoo.example", requested: "put",
+			wantStatus: http.StatusNoContent, wantAllowed: "https://zoo.example", wantMethods: true},
+		{name: "preflight for a method not allowed", origins: []string{"https://zoo.example"}, method: http.MethodOptions, path: "/v1/animals/1", origin: "https://zoo.example", requested: "TRACE",
+			wantStatus: http.StatusNoContent},
+		{name: "preflight from another origin", origins: []string{"https://zoo.example"}, method: http.MethodOptions, path: "/v1/animals/1", origin: "https://evil.example", requested: "DELETE",
+			wantStatus: http.StatusNoContent},
+		{name: "OPTIONS without a requested method", origins: []string{"https://zoo.example"}, method: http.MethodOptions, path: "/v1/animals", origin: "https://zoo.example",
+			wantStatus: http.StatusOK, wantAllowed: "https://zoo.example", wantExpose: true, wantReached: true},
+		{name: "exempt path", origins: []string{"*"}, method: http.MethodOptions, path: "/metrics", origin: "https://zoo.example", requested: "GET",
+			wantStatus: http.StatusOK, wantReached: true},
+		{name: "disabled", method: http.MethodOptions, path: "/v1/animals", origin: "https://zoo.example", requested: "GET",
+			wantStatus: http.StatusOK, wantReached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.CORSOrigins = tt.origins
+			cfg.CORSMethods = splitList(defaultCORSMethods)
+			cfg.CORSHeaders = splitList(defaultCORSHeaders)
+			cfg.CORSMaxAge = 10 * time.Minute
+			exempt, err := parsePathMatcher("/metrics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.CORSExempt = exempt
+
+			reached := false
+			handler := cors(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requested != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requested)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			header := rec.Header()
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if reached != tt.wantReached {
+				t.Errorf("reached the router = %v, want %v", reached, tt.wantReached)
+			}
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowed)
+			}
+			if got := header.Get("Access-Control-Allow-Methods") != ""; got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want present %v", header.Get("Access-Control-Allow-Methods"), tt.wantMethods)
+			}
+			if got := header.Get("Access-Control-Expose-Headers") != ""; got != tt.wantExpose {
+				t.Errorf("Access-Control-Expose-Headers = %q, want present %v", header.Get("Access-Control-Expose-Headers"), tt.wantExpose)
+			}
+			if tt.wantMethods && header.Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", header.Get("Access-Control-Max-Age"))
+			}
+			if tt.requested != "" && tt.wantStatus == http.StatusNoContent && header.Get("Allow") == "" {
+				t.Error("preflight answered without Allow")
+			}
+			// Responses to cross-origin requests differ by origin, so caches must key on it
+			handled := tt.origin != "" && len(tt.origins) > 0 && tt.path != "/metrics"
+			if varies := slices.Contains(header.Values("Vary"), "Origin"); varies != handled {
+				t.Errorf("Vary = %q, want Origin listed %v", header.Values("Vary"), handled)
+			}
+		})
+	}
+}
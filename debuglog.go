@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// debugCaptureLimit bounds how much of a body is held in memory for the debug log. Bodies
+// larger than this are not logged at all, since they could not be redacted reliably.
+const debugCaptureLimit = 1 << 20
+
+// redactedValue replaces the value of every redacted field in the debug log.
+const redactedValue = "[REDACTED]"
+
+// debugMethods are the mutating methods whose bodies the debug log records.
+var debugMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the rest.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+// Write records up to the limit and never fails, so it can sit behind an io.TeeReader.
+func (c *cappedBuffer) Write(b []byte) (int, error) {
+	c.total += len(b)
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(b[:min(room, len(b))])
+	}
+	return len(b), nil
+}
+
+// bodyRecorder passes the response through unchanged while keeping a copy of its body.
+type bodyRecorder struct {
+	statusRecorder
+	body *cappedBuffer
+}
+
+// Write copies the bytes and passes them on.
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
+
+// logBodies is router middleware that, when cfg.Debug is set, logs the request and response
+// bodies of mutating requests. Values of the fields in cfg.DebugRedactFields are replaced
+// (at any depth of a JSON body) and each logged body is cut to cfg.DebugMaxBody bytes. The
+// request body is teed as the handler reads it and the response is copied as it is written,
+// so nothing is delayed and streaming responses keep flushing. GET requests are never logged.
+func logBodies(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Debug {
+			return next
+		}
+
+		redact := make(map[string]bool, len(cfg.DebugRedactFields))
+		for _, field := range cfg.DebugRedactFields {
+			redact[strings.ToLower(field)] = true
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !debugMethods[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			request := &cappedBuffer{limit: debugCaptureLimit}
+			if r.Body != nil {
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.TeeReader(r.Body, request), r.Body}
+			}
+			recorder := &bodyRecorder{
+				statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK},
+				body:           &cappedBuffer{limit: debugCaptureLimit},
+			}
+
+			next.ServeHTTP(recorder, r)
+
+			log.Printf("debug: %s %s request=%s response=%d %s", r.Method, r.URL.RequestURI(),
+				formatDebugBody(request, redact, cfg.DebugMaxBody),
+				recorder.status, formatDebugBody(recorder.body, redact, cfg.DebugMaxBody))
+		})
+	}
+}
+
+// formatDebugBody renders a captured body for the debug log: redacted if it is JSON, and cut
+// to maxBytes. Bodies beyond the capture limit are only summarised.
+func formatDebugBody(body *cappedBuffer, redact map[string]bool, maxBytes int) string {
+	if body.total == 0 {
+		return "(empty)"
+	}
+	if body.total > body.limit {
+		return fmt.Sprintf("(%d bytes, too large to log)", body.total)
+	}
+
+	text := strings.TrimSpace(body.buf.String())
+	var parsed interface{}
+	if err := json.Unmarshal(body.buf.Bytes(), &parsed); err == nil {
+		if redacted, err := json.Marshal(redactFields(parsed, redact)); err == nil {
+			text = string(redacted)
+		}
+	} else if len(redact) > 0 {
+		// A body that is not JSON cannot be redacted, so it is left out rather than leaked
+		return fmt.Sprintf("(%d bytes, not JSON)", body.total)
+	}
+
+	if maxBytes > 0 && len(text) > maxBytes {
+		return fmt.Sprintf("%s...(truncated, %d bytes)", text[:maxBytes], len(text))
+	}
+	return text
+}
+
+// redactFields replaces the values of redacted keys (case-insensitive) throughout a decoded JSON value.
+func redactFields(value interface{}, redact map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactFields(field, redact)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactFields(item, redact)
+		}
+	}
+	return value
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// errEmptyBody is returned by decodeJSONBody for a request without a body.
+var errEmptyBody = errors.New("the request body is empty")
+
+// bodyError is a request body that is not the expected JSON. Line and Column locate the
+// offending byte (both 1-based, columns counted in bytes) when the decoder reported where it
+// stopped; they are 0 otherwise.
+type bodyError struct {
+	Line, Column int
+	err          error
+	message      string
+}
+
+// Error describes the problem and, when known, where it is, e.g. "invalid character 'x'
+// looking for beginning of object key string at line 3 column 12".
+func (e *bodyError) Error() string {
+	if e.Line == 0 {
+		return e.message
+	}
+	return fmt.Sprintf("%s at line %d column %d", e.message, e.Line, e.Column)
+}
+
+// Unwrap returns the decoder's own error.
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
+
+// decodeJSONBody reads the request body and decodes the JSON value at its start into v, like
+// json.NewDecoder(body).Decode(v) after applying the given decoder options (such as
+// (*json.Decoder).UseNumber). Malformed JSON and values of the wrong type are returned as a
+// *bodyError with the line and column of the error; errors reading the body are returned as
+// they are. The body is read in full first, so the position can be computed from it.
+func decodeJSONBody(body io.Reader, v any, options ...func(*json.Decoder)) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for _, option := range options {
+		option(decoder)
+	}
+	if err := decoder.Decode(v); err != nil {
+		return newBodyError(data, err)
+	}
+	return nil
+}
+
+// newBodyError locates a decode error of data. The decoder's offsets count the bytes read up
+// to and including the offending one; a truncated body is located just past its end.
+func newBodyError(data []byte, err error) *bodyError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &bodyError{err: err, message: errEmptyBody.Error()}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return locateBodyError(data, int64(len(data))+1, err, "unexpected end of JSON input")
+	case errors.As(err, &syntaxErr):
+		return locateBodyError(data, syntaxErr.Offset, err, syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		message := fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value)
+		if typeErr.Field != "" {
+			message = typeErr.Field + ": " + message
+		}
+		return locateBodyError(data, typeErr.Offset, err, message)
+	}
+	return &bodyError{err: err, message: strings.TrimPrefix(err.Error(), "json: ")}
+}
+
+// locateBodyError builds a bodyError for the byte at offset-1 of data, by counting the
+// newlines before it.
+func locateBodyError(data []byte, offset int64, err error, message string) *bodyError {
+	index := int(max(offset-1, 0))
+	if index > len(data) {
+		index = len(data)
+	}
+	before := data[:index]
+	return &bodyError{
+		Line:    bytes.Count(before, []byte("\n")) + 1,
+		Column:  index - bytes.LastIndexByte(before, '\n'),
+		err:     err,
+		message: message,
+	}
+}
+
+// jsonKind names the kind of JSON value a Go type is decoded from, e.g. "a number" for int.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return t.String()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// endangeredRequest is the body of the endangered endpoint, e.g. {"endangered": true}.
+type endangeredRequest struct {
+	Endangered *bool `json:"endangered"`
+}
+
+// parseEndangeredFilter parses the ?endangered= list parameter, returning nil when it is absent.
+func parseEndangeredFilter(r *http.Request) (*bool, error) {
+	raw := r.URL.Query().Get("endangered")
+	if raw == "" {
+		return nil, nil
+	}
+	endangered, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, errors.New("endangered must be true or false")
+	}
+	return &endangered, nil
+}
+
+// setEndangeredHandler handles POST requests that set only the endangered flag of an animal,
+// leaving every other field untouched, and answers with the updated animal. Unlike PUT it
+// cannot clobber a concurrent change to another field, and unlike PATCH it does not
+// revalidate the rest of the record.
+func setEndangeredHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		var req endangeredRequest
+		if err := decodeJSONBody(r.Body, &req); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+		if req.Endangered == nil {
+			writeValidationProblem(w, r, []FieldError{{Field: "endangered", Message: "endangered is required"}}) // 422 Unprocessable Entity
+			return
+		}
+
+		if r.Header.Get("If-Match") != "" {
+			current, err := store.GetAnimalByID(id)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					http.Error(w, err.Error(), http.StatusNotFound) // 404 Not Found
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !checkIfMatch(w, r, current, cfg.ETagMode) {
+				return
+			}
+		}
+
+		if err := store.SetEndangered(id, *req.Endangered); err != nil {
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound) // 404 Not Found
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		animal, err := store.GetAnimalByID(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAnimalResult(w, r, cfg.ETagMode, http.StatusOK, *animal)
+	}
+}
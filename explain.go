@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// explainInput is everything a list or query request asked for, as parsed by its handler.
+type explainInput struct {
+	Filter           AnimalFilter
+	Page             Page
+	Fields           []string
+	Fuzzy            string    // ?fuzzy= query of the list; empty if none
+	FuzzyMaxDistance int       // Distance the fuzzy search allows
+	ModifiedSince    time.Time // ?modified_since= of the list; zero if none
+}
+
+// explainStage is one step of the evaluation and how many animals are left after it.
+type explainStage struct {
+	Stage     string `json:"stage"`               // What was applied, e.g. "class" or "page"
+	Criterion string `json:"criterion,omitempty"` // The criterion as applied, e.g. "class in [mammal bird]"
+	Remaining int    `json:"remaining"`           // Animals left after this stage
+}
+
+// explainFilters are the effective criteria after parsing and normalization.
+type explainFilters struct {
+	Classes          []string   `json:"classes,omitempty"`
+	Endangered       *bool      `json:"endangered,omitempty"`
+	MinLegs          *int       `json:"min_legs,omitempty"`
+	MaxLegs          *int       `json:"max_legs,omitempty"`
+	NameContains     string     `json:"name_contains,omitempty"`
+	Expr             string     `json:"filter,omitempty"` // Canonical form of the ?filter= expression, fully parenthesized
+	Fuzzy            string     `json:"fuzzy,omitempty"`
+	FuzzyMaxDistance int        `json:"fuzzy_max_distance,omitempty"`
+	ModifiedSince    *time.Time `json:"modified_since,omitempty"`
+}
+
+// queryExplanation describes how a list or query request was parsed and evaluated.
+type queryExplanation struct {
+	Filters  explainFilters `json:"filters"`
+	Sort     []string       `json:"sort"`  // Sort keys as parsed, most significant first
+	Order    string         `json:"order"` // The ordering actually used, which fuzzy and modified_since override
+	Limit    int            `json:"limit"` // Limit after defaulting and clamping
+	Offset   int            `json:"offset"`
+	Fields   []string       `json:"fields,omitempty"` // ?fields= projection; absent means every field
+	Stages   []explainStage `json:"stages"`
+	Total    int            `json:"total"`    // Matching animals across all pages
+	Returned int            `json:"returned"` // Animals the page would contain
+}
+
+// explainResponse wraps the explanation, so it cannot be mistaken for a list of animals.
+type explainResponse struct {
+	Explain queryExplanation `json:"explain"`
+}
+
+// isExplain reports whether the request asks for ?explain=true.
+func isExplain(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("explain")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// explainQuery evaluates the request one criterion at a time and reports how many animals are
+// left after each, then how many the page keeps. It runs the same matching as the real request
+// but does it stage by stage, so it costs one pass over the candidates per criterion, and it
+// never serializes the animals.
+func explainQuery(store AnimalStore, in explainInput) (queryExplanation, error) {
+	f := in.Filter
+	explanation := queryExplanation{
+		Filters: explainFilters{
+			Classes:      f.Classes,
+			Endangered:   f.Endangered,
+			MinLegs:      f.MinLegs,
+			MaxLegs:      f.MaxLegs,
+			NameContains: f.NameContains,
+		},
+		Sort:   make([]string, len(f.Sort)),
+		Limit:  in.Page.Limit,
+		Offset: in.Page.Offset,
+		Fields: in.Fields,
+		Stages: []explainStage{},
+	}
+	for i, key := range f.Sort {
+		explanation.Sort[i] = key.String()
+	}
+	if f.Expr != nil {
+		explanation.Filters.Expr = f.Expr.String()
+	}
+
+	candidates, err := store.FilterAnimals(AnimalFilter{Sort: f.Sort})
+	if err != nil && !errors.Is(err, ErrEmpty) {
+		return explanation, err
+	}
+	explanation.Stages = append(explanation.Stages, explainStage{Stage: "all", Remaining: len(candidates)})
+
+	switch {
+	case !in.ModifiedSince.IsZero():
+		since := in.ModifiedSince
+		explanation.Filters.ModifiedSince = &since
+		explanation.Order = "updated_at, then id (sort is ignored)"
+		if candidates, err = store.GetAnimalsModifiedSince(since); err != nil && !errors.Is(err, ErrEmpty) {
+			return explanation, err
+		}
+		explanation.Stages = append(explanation.Stages, explainStage{
+			Stage: "modified_since", Criterion: "updated_at > " + since.Format(time.RFC3339Nano), Remaining: len(candidates),
+		})
+	case in.Fuzzy != "":
+		explanation.Filters.Fuzzy = in.Fuzzy
+		explanation.Filters.FuzzyMaxDistance = in.FuzzyMaxDistance
+		explanation.Order = "fuzzy distance, then id (sort is ignored)"
+		if candidates, err = store.FuzzySearch(in.Fuzzy, in.FuzzyMaxDistance); err != nil && !errors.Is(err, ErrEmpty) {
+			return explanation, err
+		}
+		explanation.Stages = append(explanation.Stages, explainStage{
+			Stage:     "fuzzy",
+			Criterion: fmt.Sprintf("name within %d edits of %q", in.FuzzyMaxDistance, in.Fuzzy),
+			Remaining: len(candidates),
+		})
+	case len(f.Sort) > 0:
+		explanation.Order = strings.Join(explanation.Sort, ", ") + ", then id"
+	default:
+		explanation.Order = "id"
+	}
+
+	// Each criterion applied on its own, in the order matches checks them
+	stages := []struct {
+		name      string
+		given     bool
+		criterion string
+		filter    AnimalFilter
+	}{
+		{"class", len(f.Classes) > 0, fmt.Sprintf("class in %v (case-insensitive)", f.Classes), AnimalFilter{Classes: f.Classes}},
+		{"endangered", f.Endangered != nil, "endangered = " + formatOptionalBool(f.Endangered), AnimalFilter{Endangered: f.Endangered}},
+		{"legs", f.MinLegs != nil || f.MaxLegs != nil, legsCriterion(f.MinLegs, f.MaxLegs), AnimalFilter{MinLegs: f.MinLegs, MaxLegs: f.MaxLegs}},
+		{"name_contains", f.NameContains != "", fmt.Sprintf("name contains %q (case-insensitive)", f.NameContains), AnimalFilter{NameContains: f.NameContains}},
+		{"filter", f.Expr != nil, explanation.Filters.Expr, AnimalFilter{Expr: f.Expr}},
+	}
+	for _, stage := range stages {
+		if !stage.given {
+			continue
+		}
+		kept := []Animal{}
+		for _, animal := range candidates {
+			if stage.filter.matches(animal) {
+				kept = append(kept, animal)
+			}
+		}
+		candidates = kept
+		explanation.Stages = append(explanation.Stages, explainStage{Stage: stage.name, Criterion: stage.criterion, Remaining: len(candidates)})
+	}
+
+	explanation.Total = len(candidates)
+	explanation.Returned = len(in.Page.apply(candidates))
+	explanation.Stages = append(explanation.Stages, explainStage{
+		Stage:     "page",
+		Criterion: fmt.Sprintf("offset %d, limit %d", in.Page.Offset, in.Page.Limit),
+		Remaining: explanation.Returned,
+	})
+	return explanation, nil
+}
+
+// formatOptionalBool renders a tri-state filter value.
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return "any"
+	}
+	return strconv.FormatBool(*value)
+}
+
+// legsCriterion renders the legs bounds of a filter, e.g. "2 <= legs <= 4" or "legs >= 2".
+func legsCriterion(min, max *int) string {
+	switch {
+	case min != nil && max != nil:
+		return fmt.Sprintf("%d <= legs <= %d", *min, *max)
+	case min != nil:
+		return fmt.Sprintf("legs >= %d", *min)
+	case max != nil:
+		return fmt.Sprintf("legs <= %d", *max)
+	}
+	return ""
+}
+
+// writeExplanation answers an explain request with the explanation instead of any animals.
+func writeExplanation(w http.ResponseWriter, r *http.Request, store AnimalStore, in explainInput) {
+	explanation, err := explainQuery(store, in)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, r, explainResponse{Explain: explanation})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// exportManifest describes the contents of an export archive.
+type exportManifest struct {
+	ExportedAt time.Time `json:"exported_at"` // When the export was produced (UTC)
+	Count      int       `json:"count"`       // Number of animals in animals.json
+}
+
+// exportArchive is a materialized export: the complete ZIP archive with the time it was
+// produced, its strong ETag and the data version it was built from.
+type exportArchive struct {
+	body       []byte
+	etag       string
+	exportedAt time.Time
+	version    time.Time // LatestModified of the store when the archive was built
+}
+
+// exportCache keeps the most recent export archive of each tenant ("" without tenancy), so
+// that requests for parts of it (a resumed download) get the very bytes the first request
+// started, until the data changes.
+type exportCache struct {
+	mu       sync.Mutex
+	archives map[string]*exportArchive // Tenant -> its latest archive
+}
+
+// get returns the archive of the tenant's store's current data, building it unless the cached
+// one is of the same version. Concurrent requests for a new version build it once; a build
+// abandoned because ctx is done is not cached.
+func (c *exportCache) get(ctx context.Context, store AnimalStore, tenant string) (*exportArchive, error) {
+	version, err := store.LatestModified()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached := c.archives[tenant]; cached != nil && cached.version.Equal(version) {
+		return cached, nil
+	}
+	archive, err := buildExportArchive(ctx, store, version)
+	if err != nil {
+		return nil, err
+	}
+	if c.archives == nil {
+		c.archives = make(map[string]*exportArchive)
+	}
+	c.archives[tenant] = archive
+	return archive, nil
+}
+
+// buildExportArchive writes the export of every animal, ordered by ID, into memory. The animals
+// are streamed from the store into the compressed archive, so only the archive is held in
+// memory, never a copy of the dataset.
+func buildExportArchive(ctx context.Context, store AnimalStore, version time.Time) (*exportArchive, error) {
+	now := time.Now().UTC().Truncate(time.Second) // Last-Modified has second precision
+	var body bytes.Buffer
+	archive := zip.NewWriter(&body)
+	count, err := writeZipAnimals(ctx, archive, now, store)
+	if err != nil {
+		return nil, fmt.Errorf("writing animals.json: %w", err)
+	}
+	if err := writeZipJSON(archive, "manifest.json", now, exportManifest{ExportedAt: now, Count: count}); err != nil {
+		return nil, fmt.Errorf("writing manifest.json: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("finishing archive: %w", err)
+	}
+	return &exportArchive{body: body.Bytes(), etag: strongETag(body.Bytes()), exportedAt: now, version: version}, nil
+}
+
+// exportAnimalsHandler handles GET requests for a full backup of the dataset: a ZIP archive
+// containing animals.json (every animal, ordered by ID) and manifest.json (export timestamp and
+// count). The archive is built in memory and kept in exports until the data changes, and served
+// with http.ServeContent, which answers Range requests with 206 Partial Content so interrupted
+// downloads can resume. The strong ETag and Last-Modified let If-Range detect an archive that
+// was rebuilt in between, in which case the whole new archive is sent.
+func exportAnimalsHandler(store AnimalStore, exports *exportCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		archive, err := exports.get(r.Context(), store, tenantFromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="animals-export-%s.zip"`, archive.exportedAt.Format("2006-01-02")))
+		w.Header().Set("ETag", archive.etag)
+		http.ServeContent(w, r, "", archive.exportedAt, bytes.NewReader(archive.body))
+	}
+}
+
+// writeZipAnimals adds animals.json to the archive, with every animal of the store ordered by
+// ID, and returns how many it wrote. The file is the indented JSON array writeZipJSON would
+// write for a slice of them, but each animal is encoded as the store hands it over. An empty
+// store still produces a valid (empty) backup.
+func writeZipAnimals(ctx context.Context, archive *zip.Writer, modified time.Time, store AnimalStore) (int, error) {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: "animals.json", Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = store.ForEach(ctx, func(animal Animal) error {
+		element, err := json.MarshalIndent(animal, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := ",\n  "
+		if count == 0 {
+			separator = "[\n  "
+		}
+		if _, err := io.WriteString(file, separator); err != nil {
+			return err
+		}
+		count++
+		_, err = file.Write(element)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	end := "\n]\n"
+	if count == 0 {
+		end = "[]\n"
+	}
+	_, err = io.WriteString(file, end)
+	return count, err
+}
+
+// writeZipJSON adds a file with the given name to the archive containing v encoded as indented JSON.
+func writeZipJSON(archive *zip.Writer, name string, modified time.Time, v interface{}) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
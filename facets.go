@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// facetSampleSize is how many animal names each facet carries.
+const facetSampleSize = 3
+
+// Facet summarizes the matching animals of one class, e.g. for a filter chip with a count.
+type Facet struct {
+	Class  string   `json:"class"`
+	Count  int      `json:"count"`
+	Sample []string `json:"sample"` // Names of up to facetSampleSize animals of the class, lowest IDs first
+}
+
+// facetCounter collects facets from animals fed to it one at a time, keeping only the
+// lowest-ID animals of each class for the sample, so a store can count while it scans.
+// Classes are told apart ignoring case, as the class filter does, and each facet is spelled
+// like its lowest-ID animal.
+type facetCounter struct {
+	counts  map[string]int
+	samples map[string][]Animal // Folded class -> its lowest-ID animals so far, ordered by ID
+}
+
+// foldClass returns the key under which classes that strings.EqualFold considers equal are
+// counted together: every rune is replaced by the smallest rune of its case-folding orbit.
+func foldClass(class string) string {
+	return strings.Map(func(r rune) rune {
+		smallest := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			smallest = min(smallest, f)
+		}
+		return smallest
+	}, class)
+}
+
+// newFacetCounter returns an empty counter.
+func newFacetCounter() *facetCounter {
+	return &facetCounter{counts: make(map[string]int), samples: make(map[string][]Animal)}
+}
+
+// add counts the animal and keeps it for the sample of its class if its ID is low enough.
+func (c *facetCounter) add(animal Animal) {
+	key := foldClass(animal.Class)
+	c.counts[key]++
+	sample := c.samples[key]
+	i := sort.Search(len(sample), func(i int) bool { return sample[i].ID > animal.ID })
+	if i == facetSampleSize {
+		return
+	}
+	sample = append(sample, Animal{})
+	copy(sample[i+1:], sample[i:])
+	sample[i] = animal
+	if len(sample) > facetSampleSize {
+		sample = sample[:facetSampleSize]
+	}
+	c.samples[key] = sample
+}
+
+// result returns the facets, largest count first and classes with equal counts by name.
+func (c *facetCounter) result() []Facet {
+	facets := make([]Facet, 0, len(c.counts))
+	for key, count := range c.counts {
+		sample := c.samples[key]
+		names := make([]string, len(sample))
+		for i, animal := range sample {
+			names[i] = animal.Name
+		}
+		facets = append(facets, Facet{Class: sample[0].Class, Count: count, Sample: names})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Class < facets[j].Class
+	})
+	return facets
+}
+
+// parseAggregateFilter reads the ?class=, ?endangered= and ?filter= parameters of the list
+// into a filter for the endpoints that aggregate over the matching animals.
+func parseAggregateFilter(r *http.Request) (AnimalFilter, error) {
+	filter := AnimalFilter{Classes: parseClassFilter(r)}
+	var err error
+	if filter.Endangered, err = parseEndangeredFilter(r); err != nil {
+		return AnimalFilter{}, err
+	}
+	if raw := r.URL.Query().Get("filter"); raw != "" {
+		if filter.Expr, err = ParseFilterExpr(raw); err != nil {
+			return AnimalFilter{}, err
+		}
+	}
+	return filter, nil
+}
+
+// facetsHandler handles GET requests for the class facets of the animals: for each class, how
+// many animals match and the names of a few of them. The ?class=, ?endangered= and ?filter=
+// parameters of the list narrow the animals first, so the counts reflect the current query;
+// ?class= therefore also limits the facets to the listed classes. An empty store, or a filter
+// that matches nothing, yields an empty array.
+func facetsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		filter, err := parseAggregateFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		facets, err := store.Facets(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, facets)
+	}
+}
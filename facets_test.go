@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFacetsFoldCase(t *testing.T) {
+	animals := []Animal{
+		{ID: 1, Name: "Lion", Class: "Mammal"},
+		{ID: 2, Name: "Tiger", Class: "mammal"},
+		{ID: 3, Name: "Bear", Class: "MAMMAL"},
+		{ID: 4, Name: "Wolf", Class: "mammal"},
+		{ID: 5, Name: "Eagle", Class: "bird"},
+		{ID: 6, Name: "Hawk", Class: "Bird"},
+		{ID: 7, Name: "Cobra", Class: "reptile"},
+	}
+	tests := []struct {
+		query string
+		want  []Facet
+	}{
+		{"", []Facet{
+			{Class: "Mammal", Count: 4, Sample: []string{"Lion", "Tiger", "Bear"}},
+			{Class: "bird", Count: 2, Sample: []string{"Eagle", "Hawk"}},
+			{Class: "reptile", Count: 1, Sample: []string{"Cobra"}},
+		}},
+		// The filter matches the same animals the facet counts
+		{"?class=mammal", []Facet{{Class: "Mammal", Count: 4, Sample: []string{"Lion", "Tiger", "Bear"}}}},
+		{"?class=BIRD", []Facet{{Class: "bird", Count: 2, Sample: []string{"Eagle", "Hawk"}}}},
+	}
+	for name, store := range testBackends(t, 0) {
+		for _, animal := range animals {
+			if err := store.CreateAnimal(animal); err != nil {
+				t.Fatal(err)
+			}
+		}
+		api := newTestAPI(store, testConfig())
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				rec := serve(api, http.MethodGet, "/v1/animals/facets"+tt.query, "")
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+				}
+				var facets []Facet
+				if err := json.Unmarshal(rec.Body.Bytes(), &facets); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(facets, tt.want) {
+					t.Errorf("facets = %+v, want %+v", facets, tt.want)
+				}
+
+				// Every facet counts exactly the animals its class lists
+				for _, facet := range facets {
+					rec := serve(api, http.MethodGet, "/v1/animals?class="+facet.Class, "")
+					if got := strings.Count(rec.Body.String(), `"id"`); got != facet.Count {
+						t.Errorf("listing class %s found %d animals, facet counts %d", facet.Class, got, facet.Count)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestFoldClass(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"mammal", "MAMMAL"},
+		{"Säugetier", "SÄUGETIER"},
+		{"kelvin", "Kelvin"}, // Kelvin sign
+		{"ſnake", "SNAKE"},   // Long s
+	}
+	for _, tt := range tests {
+		if !strings.EqualFold(tt.a, tt.b) {
+			t.Fatalf("%q and %q are not equal under strings.EqualFold", tt.a, tt.b)
+		}
+		if foldClass(tt.a) != foldClass(tt.b) {
+			t.Errorf("foldClass(%q) = %q, foldClass(%q) = %q, want them equal as the filter treats them", tt.a, foldClass(tt.a), tt.b, foldClass(tt.b))
+		}
+	}
+	if foldClass("mammal") == foldClass("mammals") {
+		t.Error("different classes fold alike")
+	}
+}
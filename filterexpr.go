@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterFields maps the fields usable in a ?filter= expression to whether they are numeric.
+var filterFields = map[string]bool{"id": true, "name": false, "class": false, "legs": true}
+
+// FilterExpr is a parsed ?filter= expression, e.g. legs>2 AND class=mammal.
+type FilterExpr interface {
+	eval(animal Animal) bool
+	String() string // Canonical form, used in cache keys
+}
+
+// FilterSyntaxError reports an invalid ?filter= expression and where it went wrong.
+type FilterSyntaxError struct {
+	Pos int // 1-based position of the offending token in the expression
+	Msg string
+}
+
+// Error renders the message together with the position.
+func (e *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter: %s at position %d", e.Msg, e.Pos)
+}
+
+// filterAnd matches when both sides match.
+type filterAnd struct{ left, right FilterExpr }
+
+func (e filterAnd) eval(animal Animal) bool { return e.left.eval(animal) && e.right.eval(animal) }
+func (e filterAnd) String() string          { return "(" + e.left.String() + " AND " + e.right.String() + ")" }
+
+// filterOr matches when either side matches.
+type filterOr struct{ left, right FilterExpr }
+
+func (e filterOr) eval(animal Animal) bool { return e.left.eval(animal) || e.right.eval(animal) }
+func (e filterOr) String() string          { return "(" + e.left.String() + " OR " + e.right.String() + ")" }
+
+// filterComparison compares one field of the animal with a literal.
+// String fields compare case-insensitively; numeric fields compare as integers.
+type filterComparison struct {
+	field string
+	op    string
+	text  string // Lower-cased literal for string fields
+	num   int    // Literal for numeric fields
+}
+
+func (c filterComparison) eval(animal Animal) bool {
+	if filterFields[c.field] {
+		value := animal.Legs
+		if c.field == "id" {
+			value = animal.ID
+		}
+		return compareOrdered(value, c.num, c.op)
+	}
+	value := animal.Name
+	if c.field == "class" {
+		value = animal.Class
+	}
+	return compareOrdered(strings.ToLower(value), c.text, c.op)
+}
+
+func (c filterComparison) String() string {
+	if filterFields[c.field] {
+		return c.field + c.op + strconv.Itoa(c.num)
+	}
+	return c.field + c.op + strconv.Quote(c.text)
+}
+
+// compareOrdered applies a comparison operator to two values.
+func compareOrdered[T int | string](a, b T, op string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case ">":
+		return a > b
+	case "<=":
+		return a <= b
+	default: // ">="
+		return a >= b
+	}
+}
+
+// Token kinds produced by the filter tokenizer.
+const (
+	tokenEnd = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+// filterToken is a lexical token and its 1-based position in the expression.
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// tokenizeFilter splits an expression into words, quoted strings, operators and parentheses.
+func tokenizeFilter(input string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{tokenLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{tokenRParen, ")", pos})
+			i++
+		case r == '=' || r == '<' || r == '>' || r == '!':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &FilterSyntaxError{Pos: pos, Msg: `expected "!="`}
+			}
+			tokens = append(tokens, filterToken{tokenOp, op, pos})
+			i += len(op)
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &FilterSyntaxError{Pos: pos, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, filterToken{tokenString, string(runes[i+1 : end]), pos})
+			i = end + 1
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || strings.ContainsRune("_-.", runes[end])) {
+				end++
+			}
+			tokens = append(tokens, filterToken{tokenWord, string(runes[i:end]), pos})
+			i = end
+		default:
+			return nil, &FilterSyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(tokens, filterToken{tokenEnd, "", len(runes) + 1}), nil
+}
+
+// filterParser is a recursive-descent parser over the tokens of an expression:
+//
+//	expr       = and { "OR" and }
+//	and        = primary { "AND" primary }
+//	primary    = "(" expr ")" | comparison
+//	comparison = field op value
+type filterParser struct {
+	tokens []filterToken
+	next   int
+}
+
+// ParseFilterExpr parses a ?filter= expression such as `legs>2 AND class=mammal`.
+// AND binds tighter than OR; keywords are case-insensitive and parentheses group.
+func ParseFilterExpr(input string) (FilterExpr, error) {
+	tokens, err := tokenizeFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, &FilterSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return expr, nil
+}
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.next] }
+
+func (p *filterParser) advance() filterToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEnd {
+		p.next++
+	}
+	return tok
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so.
+func (p *filterParser) keyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (FilterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (FilterExpr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parsePrimary() (FilterExpr, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, &FilterSyntaxError{Pos: closing.pos, Msg: `expected ")"`}
+		}
+		return expr, nil
+	case tokenWord:
+		return p.parseComparison(tok)
+	case tokenEnd:
+		return nil, &FilterSyntaxError{Pos: tok.pos, Msg: "unexpected end of expression"}
+	default:
+		return nil, &FilterSyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("expected a field name, got %q", tok.text)}
+	}
+}
+
+func (p *filterParser) parseComparison(fieldTok filterToken) (FilterExpr, error) {
+	field := strings.ToLower(fieldTok.text)
+	numeric, known := filterFields[field]
+	if !known {
+		return nil, &FilterSyntaxError{Pos: fieldTok.pos, Msg: fmt.Sprintf("unknown field %q (expected id, name, class or legs)", fieldTok.text)}
+	}
+
+	opTok := p.advance()
+	if opTok.kind != tokenOp {
+		return nil, &FilterSyntaxError{Pos: opTok.pos, Msg: "expected a comparison operator (=, !=, <, >, <=, >=)"}
+	}
+
+	valueTok := p.advance()
+	if valueTok.kind != tokenWord && valueTok.kind != tokenString {
+		return nil, &FilterSyntaxError{Pos: valueTok.pos, Msg: "expected a value"}
+	}
+
+	comparison := filterComparison{field: field, op: opTok.text}
+	if numeric {
+		num, err := strconv.Atoi(valueTok.text)
+		if err != nil {
+			return nil, &FilterSyntaxError{Pos: valueTok.pos, Msg: fmt.Sprintf("%s must be compared with an integer, got %q", field, valueTok.text)}
+		}
+		comparison.num = num
+	} else {
+		comparison.text = strings.ToLower(valueTok.text)
+	}
+	return comparison, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// levenshtein returns the edit distance between a and b: the minimum number of
+// single-rune insertions, deletions and substitutions turning one into the other.
+// It runs in O(len(a)*len(b)) time using two rows of memory.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// fuzzyMatch pairs an animal with its edit distance from the search query.
+type fuzzyMatch struct {
+	animal   Animal
+	distance int
+}
+
+// rankByDistance keeps the animals whose lowercased name is within maxDistance edits of the
+// query and orders them by ascending distance, breaking ties by ID.
+func rankByDistance(animals []Animal, query string, maxDistance int) []Animal {
+	query = strings.ToLower(query)
+	var matches []fuzzyMatch
+	for _, animal := range animals {
+		if d := levenshtein(query, strings.ToLower(animal.Name)); d <= maxDistance {
+			matches = append(matches, fuzzyMatch{animal: animal, distance: d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].animal.ID < matches[j].animal.ID
+	})
+
+	ranked := make([]Animal, len(matches))
+	for i, m := range matches {
+		ranked[i] = m.animal
+	}
+	return ranked
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// geoJSONContentType is the media type of GeoJSON (RFC 7946).
+const geoJSONContentType = "application/geo+json"
+
+// geoJSONPoint is a GeoJSON Point geometry. RFC 7946 orders the coordinates longitude first.
+type geoJSONPoint struct {
+	Type        string     `json:"type"` // Always "Point"
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// geoJSONFeature is one animal as a GeoJSON Feature: its location as the geometry and its
+// other fields, including the ID, as the properties.
+type geoJSONFeature struct {
+	Type       string       `json:"type"` // Always "Feature"
+	ID         int          `json:"id"`
+	Geometry   geoJSONPoint `json:"geometry"`
+	Properties Animal       `json:"properties"` // The animal with its coordinates left out
+}
+
+// geoJSONFeatureCollection is the GeoJSON form of the animal list.
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"` // Always "FeatureCollection"
+	Features []geoJSONFeature `json:"features"`
+}
+
+// located returns the animals that have both coordinates, in their original order.
+func located(animals []Animal) []Animal {
+	kept := []Animal{}
+	for _, animal := range animals {
+		if animal.Latitude != nil && animal.Longitude != nil {
+			kept = append(kept, animal)
+		}
+	}
+	return kept
+}
+
+// toGeoJSON converts animals with coordinates into a FeatureCollection of Point features.
+func toGeoJSON(animals []Animal) geoJSONFeatureCollection {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(animals))}
+	for _, animal := range animals {
+		point := geoJSONPoint{Type: "Point", Coordinates: [2]float64{*animal.Longitude, *animal.Latitude}}
+		properties := animal
+		properties.Latitude, properties.Longitude = nil, nil
+		collection.Features = append(collection.Features, geoJSONFeature{Type: "Feature", ID: animal.ID, Geometry: point, Properties: properties})
+	}
+	return collection
+}
+
+// writeGeoJSON answers a list request that accepts application/geo+json with the matching
+// animals as a FeatureCollection. Animals without coordinates are left out before paging, so
+// pages and X-Total-Count only count animals that are on the map.
+func writeGeoJSON(w http.ResponseWriter, r *http.Request, animals []Animal, page Page) {
+	animals = located(animals)
+
+	var body bytes.Buffer
+	if err := encodeJSON(&body, r, toGeoJSON(page.apply(animals))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", geoJSONContentType)
+	w.Header().Set("Vary", "Accept")
+	writePageHeaders(w, page, len(animals))
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// graphqlRoute names the GraphQL endpoint. It is let through in read-only mode, since most
+// operations are queries sent with POST; mutations check read-only mode themselves.
+const graphqlRoute = "graphql"
+
+// graphqlSchema is the schema served by the GraphQL endpoint, in SDL.
+//
+//go:embed schema.graphql
+var graphqlSchema string
+
+// Error codes of GraphQL errors, in extensions.code, with the REST status each stands for.
+const (
+	gqlCodeParseFailed      = "GRAPHQL_PARSE_FAILED"      // 400: the document is not valid GraphQL
+	gqlCodeValidationFailed = "GRAPHQL_VALIDATION_FAILED" // 400: the document does not fit the schema
+	gqlCodeBadUserInput     = "BAD_USER_INPUT"            // 400 / 422: invalid arguments or animal
+	gqlCodeNotFound         = "NOT_FOUND"                 // 404
+	gqlCodeAlreadyExists    = "ALREADY_EXISTS"            // 409
+	gqlCodeVetoed           = "VETOED"                    // 422: rejected by a hook
+	gqlCodeCapacity         = "CAPACITY_EXCEEDED"         // 507
+	gqlCodeReadOnly         = "READ_ONLY"                 // 503
+	gqlCodeInternal         = "INTERNAL_SERVER_ERROR"     // 500
+)
+
+// graphqlRequest is a GraphQL request, as the JSON body of a POST or the parameters of a GET.
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// fieldError is an error raised while resolving a field: its value becomes null and the error
+// is reported with the field's path and, through Extensions, its code.
+type fieldError struct {
+	message string
+	code    string
+	errs    []FieldError
+}
+
+// Error returns the message reported to the client.
+func (e *fieldError) Error() string { return e.message }
+
+// Extensions classifies the error in the response. Validation failures of an animal list the
+// offending fields like the errors member of the REST problem document.
+func (e *fieldError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+	if len(e.errs) > 0 {
+		extensions["errors"] = e.errs
+	}
+	return extensions
+}
+
+// badInput is a fieldError for an invalid argument.
+func badInput(format string, args ...interface{}) *fieldError {
+	return &fieldError{message: fmt.Sprintf(format, args...), code: gqlCodeBadUserInput}
+}
+
+// gqlStoreError maps a store error to the GraphQL error REST clients get as an HTTP status.
+func gqlStoreError(err error) *fieldError {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &fieldError{message: "animal not found", code: gqlCodeNotFound}
+	case errors.Is(err, ErrAlreadyExists):
+		return &fieldError{message: err.Error(), code: gqlCodeAlreadyExists}
+	case errors.Is(err, ErrVetoed):
+		return &fieldError{message: strings.TrimPrefix(err.Error(), ErrVetoed.Error()+": "), code: gqlCodeVetoed}
+	case errors.Is(err, ErrCapacityExceeded):
+		return &fieldError{message: "the store is full: no more animals can be created", code: gqlCodeCapacity}
+	default:
+		return &fieldError{message: err.Error(), code: gqlCodeInternal}
+	}
+}
+
+// errMutationOverGET fails the mutations of a GET request, which the handler answers with 405.
+var errMutationOverGET = &fieldError{message: "mutations must be sent with POST", code: gqlCodeBadUserInput}
+
+// graphqlCall is the state of one request its resolvers share with the handler. Only mutation
+// resolvers change it, and the executor runs those one after the other.
+type graphqlCall struct {
+	get     bool // Sent with GET, which may only run queries
+	mutated bool // A mutation ran, or was refused for being sent with GET
+}
+
+// graphqlCallKey stores the *graphqlCall of a request in its context.
+type graphqlCallKey struct{}
+
+// gqlAnimal resolves the fields of the Animal type.
+type gqlAnimal struct{ animal Animal }
+
+func (a *gqlAnimal) ID() int32           { return int32(a.animal.ID) }
+func (a *gqlAnimal) Name() string        { return a.animal.Name }
+func (a *gqlAnimal) Class() string       { return a.animal.Class }
+func (a *gqlAnimal) Legs() int32         { return int32(a.animal.Legs) }
+func (a *gqlAnimal) Endangered() bool    { return a.animal.Endangered }
+func (a *gqlAnimal) Latitude() *float64  { return a.animal.Latitude }
+func (a *gqlAnimal) Longitude() *float64 { return a.animal.Longitude }
+func (a *gqlAnimal) CreatedAt() string   { return a.animal.CreatedAt.Format(time.RFC3339Nano) }
+func (a *gqlAnimal) UpdatedAt() string   { return a.animal.UpdatedAt.Format(time.RFC3339Nano) }
+
+// PhotoURL is null for an animal without a photo.
+func (a *gqlAnimal) PhotoURL() *string {
+	if a.animal.PhotoURL == "" {
+		return nil
+	}
+	return &a.animal.PhotoURL
+}
+
+// gqlAnimalPage resolves the fields of the AnimalPage type.
+type gqlAnimalPage struct {
+	animals              []Animal
+	total, limit, offset int
+}
+
+func (p *gqlAnimalPage) Total() int32  { return int32(p.total) }
+func (p *gqlAnimalPage) Limit() int32  { return int32(p.limit) }
+func (p *gqlAnimalPage) Offset() int32 { return int32(p.offset) }
+
+// Animals resolves the animals of the page, in their order.
+func (p *gqlAnimalPage) Animals() []*gqlAnimal {
+	animals := make([]*gqlAnimal, len(p.animals))
+	for i, animal := range p.animals {
+		animals[i] = &gqlAnimal{animal}
+	}
+	return animals
+}
+
+// gqlAnimalFilter is the AnimalFilter input type.
+type gqlAnimalFilter struct {
+	Classes      *[]string
+	MinLegs      *int32
+	MaxLegs      *int32
+	NameContains *string
+}
+
+// gqlAnimalInput is the AnimalInput input type.
+type gqlAnimalInput struct {
+	ID         *int32
+	Name       string
+	Class      *string
+	Legs       *int32
+	PhotoURL   *string
+	Endangered *bool
+	Latitude   *float64
+	Longitude  *float64
+}
+
+// animal converts the input to an animal, and reports whether it left out legs, so the class's
+// default can apply.
+func (in gqlAnimalInput) animal() (animal Animal, legsOmitted bool) {
+	animal.Name = in.Name
+	if in.ID != nil {
+		animal.ID = int(*in.ID)
+	}
+	if in.Class != nil {
+		animal.Class = *in.Class
+	}
+	if in.Legs != nil {
+		animal.Legs = int(*in.Legs)
+	}
+	if in.PhotoURL != nil {
+		animal.PhotoURL = *in.PhotoURL
+	}
+	if in.Endangered != nil {
+		animal.Endangered = *in.Endangered
+	}
+	animal.Latitude, animal.Longitude = in.Latitude, in.Longitude
+	return animal, in.Legs == nil
+}
+
+// graphqlResolver is the root resolver of schema.graphql: the fields of Query and Mutation. It
+// serves the store bound to each request's context, so tenants and request tracing apply.
+type graphqlResolver struct {
+	store    AnimalStore
+	cfg      Config
+	readOnly *ReadOnlyMode
+}
+
+// storeFor returns the store bound to the request's context, like requestScoped does for REST.
+func (q *graphqlResolver) storeFor(ctx context.Context) AnimalStore {
+	return bindContext(q.store, ctx)
+}
+
+// gqlAnimalID checks the id argument of a field, which must be positive like a path ID.
+func gqlAnimalID(id int32) (int, error) {
+	if id <= 0 {
+		return 0, badInput("%s", errInvalidID.Error())
+	}
+	return int(id), nil
+}
+
+// Animal resolves Query.animal: the animal with the ID, or null if there is none.
+func (q *graphqlResolver) Animal(ctx context.Context, args struct{ ID int32 }) (*gqlAnimal, error) {
+	id, err := gqlAnimalID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	animal, err := q.storeFor(ctx).GetAnimalByID(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gqlStoreError(err)
+	}
+	return &gqlAnimal{*animal}, nil
+}
+
+// Animals resolves Query.animals: a page of the matching animals, with the criteria and limits
+// of the query endpoint. An empty store is an empty page rather than an error.
+func (q *graphqlResolver) Animals(ctx context.Context, args struct {
+	Filter *gqlAnimalFilter
+	Sort   *[]string
+	Limit  *int32
+	Offset *int32
+}) (*gqlAnimalPage, error) {
+	var query queryRequest
+	if f := args.Filter; f != nil {
+		if f.Classes != nil {
+			query.Classes = *f.Classes
+		}
+		query.Legs = legsRange{Min: optionalInt(f.MinLegs), Max: optionalInt(f.MaxLegs)}
+		if f.NameContains != nil {
+			query.NameContains = *f.NameContains
+		}
+	}
+	if args.Sort != nil {
+		query.Sort = *args.Sort
+	}
+	query.Limit = optionalInt(args.Limit)
+	if args.Offset != nil {
+		query.Offset = int(*args.Offset)
+	}
+	qry, err := query.toQuery(q.cfg)
+	if err != nil {
+		return nil, badInput("%s", err.Error())
+	}
+
+	animals, total, err := q.storeFor(ctx).Query(qry)
+	if err != nil && !errors.Is(err, ErrEmpty) {
+		return nil, gqlStoreError(err)
+	}
+	return &gqlAnimalPage{animals: animals, total: total, limit: qry.Page.Limit, offset: qry.Page.Offset}, nil
+}
+
+// mutation checks that a mutation may run: the request was sent with POST and the API is not
+// in read-only mode. Either way it marks the request as mutating.
+func (q *graphqlResolver) mutation(ctx context.Context) error {
+	call, _ := ctx.Value(graphqlCallKey{}).(*graphqlCall)
+	if call != nil {
+		call.mutated = true
+		if call.get {
+			return errMutationOverGET
+		}
+	}
+	if q.readOnly.Enabled() {
+		return &fieldError{message: "the API is in read-only mode for maintenance", code: gqlCodeReadOnly}
+	}
+	return nil
+}
+
+// CreateAnimal resolves Mutation.createAnimal. Without an id in its input the animal gets the
+// next free ID, when cfg.AssignIDs allows it.
+func (q *graphqlResolver) CreateAnimal(ctx context.Context, args struct{ Input gqlAnimalInput }) (*gqlAnimal, error) {
+	if err := q.mutation(ctx); err != nil {
+		return nil, err
+	}
+	animal, legsOmitted := args.Input.animal()
+	assignID := animal.ID == 0
+	if assignID && !q.cfg.AssignIDs {
+		return nil, badInput("animal ID is required for creation")
+	}
+	if animal.ID < 0 {
+		return nil, badInput("%s", errInvalidID.Error())
+	}
+	animal, err := q.checkAnimal(animal, legsOmitted)
+	if err != nil {
+		return nil, err
+	}
+	store := q.storeFor(ctx)
+	if assignID {
+		animal, err = store.CreateAnimalAssigningID(animal, nil)
+	} else {
+		err = store.CreateAnimal(animal)
+	}
+	if err != nil {
+		return nil, gqlStoreError(err)
+	}
+	return &gqlAnimal{readBack(store, animal)}, nil
+}
+
+// UpdateAnimal resolves Mutation.updateAnimal, ignoring the id of the input.
+func (q *graphqlResolver) UpdateAnimal(ctx context.Context, args struct {
+	ID    int32
+	Input gqlAnimalInput
+}) (*gqlAnimal, error) {
+	if err := q.mutation(ctx); err != nil {
+		return nil, err
+	}
+	id, err := gqlAnimalID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	animal, legsOmitted := args.Input.animal()
+	animal.ID = id
+	if animal, err = q.checkAnimal(animal, legsOmitted); err != nil {
+		return nil, err
+	}
+	store := q.storeFor(ctx)
+	if err := store.UpdateAnimal(id, animal); err != nil {
+		return nil, gqlStoreError(err)
+	}
+	return &gqlAnimal{readBack(store, animal)}, nil
+}
+
+// DeleteAnimal resolves Mutation.deleteAnimal.
+func (q *graphqlResolver) DeleteAnimal(ctx context.Context, args struct{ ID int32 }) (*bool, error) {
+	if err := q.mutation(ctx); err != nil {
+		return nil, err
+	}
+	id, err := gqlAnimalID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.storeFor(ctx).DeleteAnimal(id); err != nil {
+		return nil, gqlStoreError(err)
+	}
+	deleted := true
+	return &deleted, nil
+}
+
+// checkAnimal normalizes and validates the animal of a write like the REST handlers do.
+func (q *graphqlResolver) checkAnimal(animal Animal, legsOmitted bool) (Animal, error) {
+	animal = normalizeAnimal(animal, legsOmitted, q.cfg)
+	if errs := validateAnimal(animal, q.cfg); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, fieldErr := range errs {
+			messages[i] = fieldErr.Field + ": " + fieldErr.Message
+		}
+		return animal, &fieldError{message: "validation failed: " + strings.Join(messages, "; "), code: gqlCodeBadUserInput, errs: errs}
+	}
+	return animal, nil
+}
+
+// readBack returns the animal as the store now holds it, with its store-managed timestamps, or
+// the written animal if it cannot be read back.
+func readBack(store AnimalStore, animal Animal) Animal {
+	if stored, err := store.GetAnimalByID(animal.ID); err == nil {
+		return *stored
+	}
+	return animal
+}
+
+// graphqlHandler serves GraphQL requests: POST with a JSON body of query, operationName and
+// variables, or GET with them as parameters (variables as JSON), for queries only. The schema
+// is parsed once from schema.graphql and executed by graph-gophers/graphql-go, which supports
+// the whole query language, including fragments, directives and introspection. Documents that
+// do not parse or do not fit the schema are answered with 400 and only errors; otherwise the
+// response is 200 with the data, and the errors of the fields that failed, whose value is then
+// null. Mutations are recorded in changes, since the route is left out of recordChanges.
+func graphqlHandler(store AnimalStore, cfg Config, readOnly *ReadOnlyMode, changes *ChangeLog) http.HandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{store: store, cfg: cfg, readOnly: readOnly})
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req graphqlRequest
+		if r.Method == http.MethodGet {
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if raw := r.URL.Query().Get("variables"); raw != "" {
+				if err := decodeJSONBody(strings.NewReader(raw), &req.Variables); err != nil {
+					writeGraphQLError(w, r, http.StatusBadRequest, gqlCodeBadUserInput, "invalid variables: "+err.Error())
+					return
+				}
+			}
+		} else if err := decodeJSONBody(r.Body, &req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeGraphQLError(w, r, http.StatusRequestEntityTooLarge, gqlCodeBadUserInput, fmt.Sprintf("the request body is larger than %d bytes", tooLarge.Limit))
+				return
+			}
+			writeGraphQLError(w, r, http.StatusBadRequest, gqlCodeBadUserInput, "invalid request body: "+err.Error())
+			return
+		}
+		if strings.TrimSpace(req.Query) == "" {
+			writeGraphQLError(w, r, http.StatusBadRequest, gqlCodeBadUserInput, "query is required")
+			return
+		}
+
+		call := &graphqlCall{get: r.Method == http.MethodGet}
+		started := time.Now().UTC()
+		resp := schema.Exec(context.WithValue(r.Context(), graphqlCallKey{}, call), req.Query, req.OperationName, req.Variables)
+		if call.get && call.mutated {
+			w.Header().Set("Allow", http.MethodPost)
+			writeGraphQLError(w, r, http.StatusMethodNotAllowed, gqlCodeBadUserInput, errMutationOverGET.Error())
+			return
+		}
+		if call.mutated {
+			changes.Record(tenantFromContext(r.Context()), started)
+		}
+
+		// Without data the document failed before it ran: it does not parse or fit the schema.
+		// An error of a run without a path is an argument that could not be coerced, e.g. from
+		// a variable of the wrong type; the resolvers raise fieldErrors, which carry their code.
+		for _, err := range resp.Errors {
+			if err.Extensions != nil {
+				continue
+			}
+			code := gqlCodeInternal
+			switch {
+			case resp.Data == nil && errors.Is(err, gqlerrors.ErrSyntax):
+				code = gqlCodeParseFailed
+			case resp.Data == nil:
+				code = gqlCodeValidationFailed
+			case len(err.Path) == 0:
+				code = gqlCodeBadUserInput
+			}
+			err.Extensions = map[string]interface{}{"code": code}
+		}
+		if resp.Data == nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		respondJSON(w, r, resp)
+	}
+}
+
+// writeGraphQLError answers a request that failed before it ran with a single error.
+func writeGraphQLError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.WriteHeader(status)
+	respondJSON(w, r, graphql.Response{Errors: []*gqlerrors.QueryError{{Message: message, Extensions: map[string]interface{}{"code": code}}}})
+}
+
+// graphqlSchemaHandler serves the schema in SDL, for client code generators.
+func graphqlSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, graphqlSchema)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graphqlResult is a decoded GraphQL response.
+type graphqlResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string        `json:"message"`
+		Path       []interface{} `json:"path"`
+		Extensions struct {
+			Code   string       `json:"code"`
+			Errors []FieldError `json:"errors"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// execGraphQL sends a GraphQL request with ctx to the handler and decodes the response.
+func execGraphQL(t *testing.T, ctx context.Context, handler http.HandlerFunc, query string, variables map[string]interface{}) (int, graphqlResult) {
+	t.Helper()
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var result graphqlResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, result
+}
+
+// postGraphQL sends a GraphQL request to the handler and decodes the response.
+func postGraphQL(t *testing.T, handler http.HandlerFunc, query string, variables map[string]interface{}) (int, graphqlResult) {
+	t.Helper()
+	return execGraphQL(t, context.Background(), handler, query, variables)
+}
+
+// checkGraphQLData fails the test unless the request succeeded with the given root fields.
+func checkGraphQLData(t *testing.T, status int, result graphqlResult, want map[string]string) {
+	t.Helper()
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status %d, errors %+v", status, result.Errors)
+	}
+	for field, want := range want {
+		if got := string(result.Data[field]); got != want {
+			t.Errorf("%s = %s, want %s", field, got, want)
+		}
+	}
+}
+
+func TestGraphQLQueryAndMutation(t *testing.T) {
+	store := NewInMemoryAnimalStore(0)
+	for _, animal := range seedAnimals() {
+		if err := store.CreateAnimal(animal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := graphqlHandler(store, testConfig(), NewReadOnlyMode(false), NewChangeLog())
+
+	status, result := postGraphQL(t, handler, `mutation Add($name: String!) {
+		created: createAnimal(input: {id: 42, name: $name, class: "bird", legs: 2}) { id name __typename }
+	}`, map[string]interface{}{"name": "  Blue   Jay "})
+	checkGraphQLData(t, status, result, map[string]string{"created": `{"id":42,"name":"Blue Jay","__typename":"Animal"}`})
+	if _, err := store.GetAnimalByID(42); err != nil {
+		t.Errorf("created animal not stored: %v", err)
+	}
+
+	status, result = postGraphQL(t, handler, `{
+		animal(id: 42) { name legs photoUrl latitude }
+		missing: animal(id: 999) { name }
+		animals(filter: {classes: ["BIRD"]}, sort: ["-id"], limit: 1) { total limit offset animals { id } }
+	}`, nil)
+	checkGraphQLData(t, status, result, map[string]string{
+		"animal":  `{"name":"Blue Jay","legs":2,"photoUrl":null,"latitude":null}`,
+		"missing": `null`,
+		"animals": `{"total":2,"limit":1,"offset":0,"animals":[{"id":42}]}`,
+	})
+}
+
+func TestGraphQLMutationsRunInOrder(t *testing.T) {
+	cfg := testConfig()
+	defaults, err := parseDefaultLegs("bird=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DefaultLegs = defaults
+	store := NewInMemoryAnimalStore(0)
+	handler := graphqlHandler(store, cfg, NewReadOnlyMode(false), NewChangeLog())
+
+	status, result := postGraphQL(t, handler, `mutation {
+		first: createAnimal(input: {name: "Eagle", class: "bird", latitude: -6.2, longitude: 106.8}) { id legs latitude longitude }
+		second: createAnimal(input: {name: "Lion", class: "mammal", legs: 4, photoUrl: "https://example.com/lion.jpg", endangered: true}) { id photoUrl endangered }
+		updated: updateAnimal(id: 1, input: {id: 7, name: "Hawk", class: "bird"}) { id name legs }
+		deleted: deleteAnimal(id: 2)
+		after: createAnimal(input: {name: "Tiger", class: "mammal"}) { id name }
+	}`, nil)
+	checkGraphQLData(t, status, result, map[string]string{
+		"first":   `{"id":1,"legs":2,"latitude":-6.2,"longitude":106.8}`,
+		"second":  `{"id":2,"photoUrl":"https://example.com/lion.jpg","endangered":true}`,
+		"updated": `{"id":1,"name":"Hawk","legs":2}`,
+		"deleted": `true`,
+		"after":   `{"id":2,"name":"Tiger"}`, // Runs after the delete freed ID 2
+	})
+}
+
+func TestGraphQLLanguage(t *testing.T) {
+	store := NewInMemoryAnimalStore(0)
+	for _, animal := range []Animal{
+		{ID: 1, Name: "Lion", Class: "mammal", Legs: 4},
+		{ID: 2, Name: "Eagle", Class: "bird", Legs: 2},
+	} {
+		if err := store.CreateAnimal(animal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := graphqlHandler(store, testConfig(), NewReadOnlyMode(false), NewChangeLog())
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      map[string]string
+	}{
+		{"fragment", `query { a: animal(id: 1) { ...names } b: animal(id: 2) { ...names legs } }
+			fragment names on Animal { id name }`, nil,
+			map[string]string{"a": `{"id":1,"name":"Lion"}`, "b": `{"id":2,"name":"Eagle","legs":2}`}},
+		{"inline fragment", `{ animal(id: 1) { ... on Animal { class } } }`, nil,
+			map[string]string{"animal": `{"class":"mammal"}`}},
+		{"directives", `query ($full: Boolean!) { animal(id: 1) { name legs @include(if: $full) class @skip(if: $full) } }`,
+			map[string]interface{}{"full": true},
+			map[string]string{"animal": `{"name":"Lion","legs":4}`}},
+		{"variable defaults", `query ($id: Int = 2, $filter: AnimalFilter = {minLegs: 3}) { animal(id: $id) { name } animals(filter: $filter) { total } }`, nil,
+			map[string]string{"animal": `{"name":"Eagle"}`, "animals": `{"total":1}`}},
+		{"input variables", `query ($filter: AnimalFilter, $sort: [String!]) { animals(filter: $filter, sort: $sort) { animals { name } } }`,
+			map[string]interface{}{"filter": map[string]interface{}{"nameContains": "L", "maxLegs": 4}, "sort": []interface{}{"-name"}},
+			map[string]string{"animals": `{"animals":[{"name":"Lion"},{"name":"Eagle"}]}`}},
+		{"typename", `{ __typename animals(limit: 1) { __typename } }`, nil,
+			map[string]string{"__typename": `"Query"`, "animals": `{"__typename":"AnimalPage"}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, result := postGraphQL(t, handler, tt.query, tt.variables)
+			checkGraphQLData(t, status, result, tt.want)
+		})
+	}
+
+	// Of several operations, operationName picks the one to run
+	body := `{"query": "query A { animal(id: 1) { name } } query B { animal(id: 2) { name } }", "operationName": "B"}`
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(body)))
+	if want := `{"data":{"animal":{"name":"Eagle"}}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("operation B = %s, want %s", rec.Body, want)
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	handler := graphqlHandler(NewInMemoryAnimalStore(0), testConfig(), NewReadOnlyMode(false), NewChangeLog())
+	status, result := postGraphQL(t, handler, `{
+		__schema { queryType { name } mutationType { name } }
+		__type(name: "Animal") { kind fields { name } }
+	}`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("status %d, errors %+v", status, result.Errors)
+	}
+	if got, want := string(result.Data["__schema"]), `{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"}}`; got != want {
+		t.Errorf("__schema = %s, want %s", got, want)
+	}
+	var animalType struct {
+		Kind   string
+		Fields []struct{ Name string }
+	}
+	if err := json.Unmarshal(result.Data["__type"], &animalType); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, field := range animalType.Fields {
+		fields = append(fields, field.Name)
+	}
+	want := []string{"id", "name", "class", "legs", "photoUrl", "endangered", "latitude", "longitude", "createdAt", "updatedAt"}
+	if animalType.Kind != "OBJECT" || !reflect.DeepEqual(fields, want) {
+		t.Errorf("Animal = %s %v, want OBJECT %v", animalType.Kind, fields, want)
+	}
+
+	// The full query GraphiQL and code generators send
+	status, result = postGraphQL(t, handler, `query IntrospectionQuery {
+		__schema {
+			types { kind name fields(includeDeprecated: true) { name args { name type { kind name ofType { kind name } } } } inputFields { name } }
+			directives { name locations }
+		}
+	}`, nil)
+	if status != http.StatusOK || len(result.Errors) > 0 {
+		t.Fatalf("introspection query: status %d, errors %+v", status, result.Errors)
+	}
+	for _, name := range []string{`"AnimalPage"`, `"AnimalFilter"`, `"AnimalInput"`, `"nameContains"`, `"include"`} {
+		if !strings.Contains(string(result.Data["__schema"]), name) {
+			t.Errorf("__schema lacks %s", name)
+		}
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]interface{}
+		readOnly   bool
+		wantStatus int
+		wantCode   string
+	}{
+		{"syntax error", `{ animal(id: 1) { name `, nil, false, http.StatusBadRequest, gqlCodeParseFailed},
+		{"unknown field", `{ animal(id: 1) { wings } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"unknown argument", `{ animal(id: 1, name: "Lion") { name } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"missing argument", `{ animal { name } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"missing selection", `{ animal(id: 1) }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"unknown fragment", `{ animal(id: 1) { ...f } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"unknown input field", `mutation { createAnimal(input: {name: "Lion", wings: 2}) { id } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"missing input name", `mutation { createAnimal(input: {class: "mammal"}) { id } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"int out of range", `{ animal(id: 3000000000) { name } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"variable of the wrong type", `query ($id: Int!) { animal(id: $id) { name } }`, map[string]interface{}{"id": "one"}, false, http.StatusOK, gqlCodeBadUserInput},
+		{"missing variable", `query ($id: Int!) { animal(id: $id) { name } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"several operations without a name", `query A { animal(id: 1) { name } } query B { animal(id: 1) { id } }`, nil, false, http.StatusBadRequest, gqlCodeValidationFailed},
+		{"invalid id", `{ animal(id: 0) { name } }`, nil, false, http.StatusOK, gqlCodeBadUserInput},
+		{"invalid sort", `{ animals(sort: ["weight"]) { total } }`, nil, false, http.StatusOK, gqlCodeBadUserInput},
+		{"validation", `mutation { createAnimal(input: {name: "", legs: -1}) { id } }`, nil, false, http.StatusOK, gqlCodeBadUserInput},
+		{"negative id", `mutation { createAnimal(input: {id: -1, name: "Tiger"}) { id } }`, nil, false, http.StatusOK, gqlCodeBadUserInput},
+		{"duplicate", `mutation { createAnimal(input: {id: 1, name: "Lion"}) { id } }`, nil, false, http.StatusOK, gqlCodeAlreadyExists},
+		{"update missing", `mutation { updateAnimal(id: 99, input: {name: "Ghost"}) { id } }`, nil, false, http.StatusOK, gqlCodeNotFound},
+		{"delete missing", `mutation { deleteAnimal(id: 99) }`, nil, false, http.StatusOK, gqlCodeNotFound},
+		{"read-only", `mutation { deleteAnimal(id: 1) }`, nil, true, http.StatusOK, gqlCodeReadOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			handler := graphqlHandler(store, testConfig(), NewReadOnlyMode(tt.readOnly), NewChangeLog())
+
+			status, result := postGraphQL(t, handler, tt.query, tt.variables)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if len(result.Errors) == 0 {
+				t.Fatalf("no errors, want one with code %s", tt.wantCode)
+			}
+			if got := result.Errors[0].Extensions.Code; got != tt.wantCode {
+				t.Errorf("code = %s, want %s (%s)", got, tt.wantCode, result.Errors[0].Message)
+			}
+			if tt.wantStatus == http.StatusBadRequest && result.Data != nil {
+				t.Errorf("data = %v, want none for a document that did not run", result.Data)
+			}
+			if lion, err := store.GetAnimalByID(1); err != nil || lion.Name != "Lion" {
+				t.Errorf("a failed operation changed the store: %+v, %v", lion, err)
+			}
+		})
+	}
+}
+
+func TestGraphQLValidationDetails(t *testing.T) {
+	handler := graphqlHandler(NewInMemoryAnimalStore(0), testConfig(), NewReadOnlyMode(false), NewChangeLog())
+	status, result := postGraphQL(t, handler, `mutation { bad: createAnimal(input: {name: "", class: "bird", legs: -1}) { id } }`, nil)
+	if status != http.StatusOK || len(result.Errors) != 1 {
+		t.Fatalf("status %d, errors %+v, want one field error", status, result.Errors)
+	}
+	if got := string(result.Data["bad"]); got != "null" {
+		t.Errorf("bad = %s, want null", got)
+	}
+	gqlErr := result.Errors[0]
+	if !reflect.DeepEqual(gqlErr.Path, []interface{}{"bad"}) {
+		t.Errorf("path = %v, want [bad]", gqlErr.Path)
+	}
+	fields := make(map[string]bool)
+	for _, fieldErr := range gqlErr.Extensions.Errors {
+		fields[fieldErr.Field] = true
+	}
+	if !fields["name"] || !fields["legs"] {
+		t.Errorf("extensions.errors = %+v, want name and legs", gqlErr.Extensions.Errors)
+	}
+}
+
+func TestGraphQLOverGET(t *testing.T) {
+	store := NewInMemoryAnimalStore(0)
+	if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+		t.Fatal(err)
+	}
+	changes := NewChangeLog()
+	handler := graphqlHandler(store, testConfig(), NewReadOnlyMode(false), changes)
+	get := func(query, variables string) *httptest.ResponseRecorder {
+		params := url.Values{"query": {query}}
+		if variables != "" {
+			params.Set("variables", variables)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/v1/graphql?"+params.Encode(), nil))
+		return rec
+	}
+
+	rec := get(`query ($id: Int!) { animal(id: $id) { name } }`, `{"id": 1}`)
+	if want := `{"data":{"animal":{"name":"Lion"}}}`; rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("query: status %d, body %s, want %s", rec.Code, rec.Body, want)
+	}
+
+	version := changes.version
+	rec = get(`mutation { deleteAnimal(id: 1) }`, "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("mutation: status %d, Allow %q, want %d allowing POST", rec.Code, rec.Header().Get("Allow"), http.StatusMethodNotAllowed)
+	}
+	if _, err := store.GetAnimalByID(1); err != nil {
+		t.Errorf("a mutation sent with GET ran: %v", err)
+	}
+	if changes.version != version {
+		t.Error("a mutation sent with GET was recorded as a change")
+	}
+
+	if rec := get(`{ animal(id: 1) { name } }`, `{"id":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid variables: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGraphQLRecordsMutations(t *testing.T) {
+	changes := NewChangeLog()
+	handler := graphqlHandler(NewInMemoryAnimalStore(0), testConfig(), NewReadOnlyMode(false), changes)
+	tests := []struct {
+		query  string
+		change bool
+	}{
+		{`{ animals { total } }`, false},
+		{`mutation { createAnimal(input: {name: "Lion", class: "mammal"}) { id } }`, true},
+		{`mutation { deleteAnimal(id: 99) }`, true}, // Counts even though its field failed
+		{`mutation { createAnimal(input: {wings: 2}) { id } }`, false},
+	}
+	for _, tt := range tests {
+		version := changes.version
+		postGraphQL(t, handler, tt.query, nil)
+		if changed := changes.version != version; changed != tt.change {
+			t.Errorf("%s: recorded a change = %v, want %v", tt.query, changed, tt.change)
+		}
+	}
+}
+
+func TestGraphQLTenants(t *testing.T) {
+	store := NewTenantAnimalStore(map[string]AnimalStore{
+		"north": NewInMemoryAnimalStore(0),
+		"south": NewInMemoryAnimalStore(0),
+	})
+	handler := graphqlHandler(store, testConfig(), NewReadOnlyMode(false), NewChangeLog())
+	north := withTenant(context.Background(), "north")
+	south := withTenant(context.Background(), "south")
+
+	status, result := execGraphQL(t, north, handler, `mutation { createAnimal(input: {name: "Lion", class: "mammal"}) { id } }`, nil)
+	checkGraphQLData(t, status, result, map[string]string{"createAnimal": `{"id":1}`})
+	status, result = execGraphQL(t, north, handler, `{ animal(id: 1) { name } }`, nil)
+	checkGraphQLData(t, status, result, map[string]string{"animal": `{"name":"Lion"}`})
+	status, result = execGraphQL(t, south, handler, `{ animal(id: 1) { name } animals { total } }`, nil)
+	checkGraphQLData(t, status, result, map[string]string{"animal": `null`, "animals": `{"total":0}`})
+}
+
+func TestGraphQLSchemaEndpoint(t *testing.T) {
+	rec := serve(newTestAPI(NewInMemoryAnimalStore(0), testConfig()), http.MethodGet, "/v1/graphql/schema", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != graphqlSchema {
+		t.Errorf("status %d, body %q, want the SDL", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"AnekaZoo/animalpb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// animalServer implements the gRPC AnimalService (see animalpb/animal.proto) on the same store
+// as the REST API. Writes are normalized and validated like their REST counterparts, honour
+// read-only mode, invalidate the list cache and are recorded in the change log for the long
+// polls, so both APIs always serve the same animals.
+type animalServer struct {
+	animalpb.UnimplementedAnimalServiceServer
+	store     AnimalStore
+	cfg       Config
+	readOnly  *ReadOnlyMode
+	listCache *ListResponseCache
+	changes   *ChangeLog
+}
+
+// newGRPCServer creates the gRPC server of the AnimalService. With tenants, every call must name
+// its tenant in the x-tenant-id metadata, like the X-Tenant-ID header of REST requests.
+func newGRPCServer(store AnimalStore, cfg Config, readOnly *ReadOnlyMode, listCache *ListResponseCache, changes *ChangeLog) *grpc.Server {
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcRequestContext(cfg.Tenants)))
+	animalpb.RegisterAnimalServiceServer(server, &animalServer{store: store, cfg: cfg, readOnly: readOnly, listCache: listCache, changes: changes})
+	return server
+}
+
+// grpcRequestContext is the unary interceptor that gives a call the request context a REST
+// request gets from the router middleware: its tenant (see requireTenant), answering
+// INVALID_ARGUMENT for a missing or unknown one, and the client's identity for the audit log.
+func grpcRequestContext(tenants []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if len(tenants) > 0 {
+			var tenant string
+			if values := metadata.ValueFromIncomingContext(ctx, tenantHeader); len(values) > 0 {
+				tenant = strings.TrimSpace(values[0])
+			}
+			if tenant == "" {
+				return nil, status.Error(codes.InvalidArgument, "missing "+strings.ToLower(tenantHeader)+" metadata: every call must name its tenant")
+			}
+			if !slices.Contains(tenants, tenant) {
+				return nil, status.Errorf(codes.InvalidArgument, "unknown tenant %q", tenant)
+			}
+			ctx = withTenant(ctx, tenant)
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			client := p.Addr.String()
+			if host, _, err := net.SplitHostPort(client); err == nil {
+				client = host
+			}
+			ctx = context.WithValue(ctx, clientIdentityKey{}, client)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// storeFor returns the store bound to the call's context, like requestScoped does for REST.
+func (s *animalServer) storeFor(ctx context.Context) AnimalStore {
+	return bindContext(s.store, ctx)
+}
+
+// checkWritable answers writes with UNAVAILABLE while read-only mode is on.
+func (s *animalServer) checkWritable() error {
+	if s.readOnly.Enabled() {
+		return status.Error(codes.Unavailable, "the API is in read-only mode for maintenance")
+	}
+	return nil
+}
+
+// GetAnimal returns the animal with the requested ID.
+func (s *animalServer) GetAnimal(ctx context.Context, req *animalpb.GetAnimalRequest) (*animalpb.Animal, error) {
+	id, err := grpcAnimalID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	animal, err := s.storeFor(ctx).GetAnimalByID(id)
+	if err != nil {
+		return nil, grpcStoreError(err)
+	}
+	return animalToProto(*animal), nil
+}
+
+// ListAnimals returns a page of the matching animals, with the criteria and limits of the
+// query endpoint. An empty store is an empty page rather than an error.
+func (s *animalServer) ListAnimals(ctx context.Context, req *animalpb.ListAnimalsRequest) (*animalpb.ListAnimalsResponse, error) {
+	query := queryRequest{
+		Classes:      req.GetClasses(),
+		Legs:         legsRange{Min: optionalInt(req.MinLegs), Max: optionalInt(req.MaxLegs)},
+		NameContains: req.GetNameContains(),
+		Sort:         req.GetSort(),
+		Limit:        optionalInt(req.Limit),
+		Offset:       int(req.GetOffset()),
+	}
+	q, err := query.toQuery(s.cfg)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	animals, total, err := s.storeFor(ctx).Query(q)
+	if err != nil && !errors.Is(err, ErrEmpty) {
+		return nil, grpcStoreError(err)
+	}
+	resp := &animalpb.ListAnimalsResponse{Total: int32(total), Limit: int32(q.Page.Limit), Offset: int32(q.Page.Offset)}
+	for _, animal := range animals {
+		resp.Animals = append(resp.Animals, animalToProto(animal))
+	}
+	return resp, nil
+}
+
+// CreateAnimal creates the animal, assigning it the next free ID when it has none and
+// cfg.AssignIDs allows it, and returns it as stored.
+func (s *animalServer) CreateAnimal(ctx context.Context, req *animalpb.CreateAnimalRequest) (*animalpb.Animal, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if req.GetAnimal() == nil {
+		return nil, status.Error(codes.InvalidArgument, "animal is required")
+	}
+	animal := animalFromProto(req.GetAnimal())
+	assignID := animal.ID == 0
+	if assignID && !s.cfg.AssignIDs {
+		return nil, status.Error(codes.InvalidArgument, "animal ID is required for creation")
+	}
+	if animal.ID < 0 {
+		return nil, status.Error(codes.InvalidArgument, errInvalidID.Error())
+	}
+	animal = normalizeAnimal(animal, false, s.cfg)
+	if errs := validateAnimal(animal, s.cfg); len(errs) > 0 {
+		return nil, grpcValidationError(errs)
+	}
+
+	started := time.Now().UTC()
+	store := s.storeFor(ctx)
+	var err error
+	if assignID {
+		animal, err = store.CreateAnimalAssigningID(animal, nil)
+	} else {
+		err = store.CreateAnimal(animal)
+	}
+	if err != nil {
+		return nil, grpcStoreError(err)
+	}
+	s.written(ctx, started)
+	return storedAnimal(store, animal), nil
+}
+
+// UpdateAnimal replaces the existing animal with the requested ID and returns it as stored.
+func (s *animalServer) UpdateAnimal(ctx context.Context, req *animalpb.UpdateAnimalRequest) (*animalpb.Animal, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	id, err := grpcAnimalID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetAnimal() == nil {
+		return nil, status.Error(codes.InvalidArgument, "animal is required")
+	}
+	animal := animalFromProto(req.GetAnimal())
+	animal.ID = id
+	animal = normalizeAnimal(animal, false, s.cfg)
+	if errs := validateAnimal(animal, s.cfg); len(errs) > 0 {
+		return nil, grpcValidationError(errs)
+	}
+
+	started := time.Now().UTC()
+	store := s.storeFor(ctx)
+	if err := store.UpdateAnimal(id, animal); err != nil {
+		return nil, grpcStoreError(err)
+	}
+	s.written(ctx, started)
+	return storedAnimal(store, animal), nil
+}
+
+// DeleteAnimal deletes the animal with the requested ID.
+func (s *animalServer) DeleteAnimal(ctx context.Context, req *animalpb.DeleteAnimalRequest) (*emptypb.Empty, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	id, err := grpcAnimalID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now().UTC()
+	if err := s.storeFor(ctx).DeleteAnimal(id); err != nil {
+		return nil, grpcStoreError(err)
+	}
+	s.written(ctx, started)
+	return &emptypb.Empty{}, nil
+}
+
+// written invalidates the list cache after a write that started at started and records the write
+// in the change log, like the REST middleware does for REST writes.
+func (s *animalServer) written(ctx context.Context, started time.Time) {
+	s.listCache.invalidate()
+	s.changes.Record(tenantFromContext(ctx), started)
+}
+
+// storedAnimal returns the animal as the store now holds it, with its store-managed
+// timestamps, or the written animal if it cannot be read back.
+func storedAnimal(store AnimalStore, animal Animal) *animalpb.Animal {
+	if stored, err := store.GetAnimalByID(animal.ID); err == nil {
+		animal = *stored
+	}
+	return animalToProto(animal)
+}
+
+// grpcAnimalID checks an animal ID of a request, which must be positive like a path ID.
+func grpcAnimalID(id int64) (int, error) {
+	if id <= 0 || id > int64(^uint(0)>>1) {
+		return 0, status.Error(codes.InvalidArgument, errInvalidID.Error())
+	}
+	return int(id), nil
+}
+
+// grpcStoreError maps a store error to the gRPC status REST clients get as an HTTP status:
+// NOT_FOUND (404), ALREADY_EXISTS (409), FAILED_PRECONDITION for a veto or a version mismatch
+// (422, 412), RESOURCE_EXHAUSTED for a full store (507) and INTERNAL for anything else.
+func grpcStoreError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrVetoed), errors.Is(err, ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrCapacityExceeded):
+		return status.Error(codes.ResourceExhausted, "the store is full: no more animals can be created")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// grpcValidationError reports validation failures as INVALID_ARGUMENT, with one field
+// violation per FieldError in a BadRequest detail, the gRPC counterpart of the problem
+// document's errors member.
+func grpcValidationError(errs []FieldError) error {
+	messages := make([]string, len(errs))
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Field + ": " + e.Message
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Message}
+	}
+	st := status.New(codes.InvalidArgument, "validation failed: "+strings.Join(messages, "; "))
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// animalToProto converts an animal to its protobuf message.
+func animalToProto(animal Animal) *animalpb.Animal {
+	return &animalpb.Animal{
+		Id:         int64(animal.ID),
+		Name:       animal.Name,
+		Class:      animal.Class,
+		Legs:       int32(animal.Legs),
+		PhotoUrl:   animal.PhotoURL,
+		Endangered: animal.Endangered,
+		Latitude:   animal.Latitude,
+		Longitude:  animal.Longitude,
+		CreatedAt:  timestamppb.New(animal.CreatedAt),
+		UpdatedAt:  timestamppb.New(animal.UpdatedAt),
+	}
+}
+
+// animalFromProto converts a protobuf animal of a write. The timestamps are managed by the
+// store and ignored.
+func animalFromProto(msg *animalpb.Animal) Animal {
+	return Animal{
+		ID:         int(msg.GetId()),
+		Name:       msg.GetName(),
+		Class:      msg.GetClass(),
+		Legs:       int(msg.GetLegs()),
+		PhotoURL:   msg.GetPhotoUrl(),
+		Endangered: msg.GetEndangered(),
+		Latitude:   msg.Latitude,
+		Longitude:  msg.Longitude,
+	}
+}
+
+// optionalInt converts an optional protobuf int32 to the *int of the query types.
+func optionalInt(value *int32) *int {
+	if value == nil {
+		return nil
+	}
+	converted := int(*value)
+	return &converted
+}
+
+// serveGRPC serves the AnimalService on addr until stopGRPC is called. A listen failure is
+// returned right away, so the server fails fast on a taken port.
+func serveGRPC(server *grpc.Server, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for gRPC on %s: %w", addr, err)
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("gRPC server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopGRPC stops the gRPC server gracefully, letting in-flight calls finish, and cancels the
+// calls still running when ctx is done.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+	}
+}
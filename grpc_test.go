@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"AnekaZoo/animalpb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestGRPCClient serves the AnimalService on store with cfg over an in-memory connection and
+// returns a client of it. The server and the connection are closed by the test.
+func newTestGRPCClient(t *testing.T, store AnimalStore, cfg Config, readOnly *ReadOnlyMode) animalpb.AnimalServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(store, cfg, readOnly, NewListResponseCache(0), NewChangeLog())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return animalpb.NewAnimalServiceClient(conn)
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	for name, store := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			client := newTestGRPCClient(t, store, testConfig(), NewReadOnlyMode(false))
+			ctx := context.Background()
+
+			created, err := client.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Name: "  Lion ", Class: "mammal", Legs: 4}})
+			if err != nil {
+				t.Fatalf("CreateAnimal: %v", err)
+			}
+			if created.GetId() != 1 || created.GetName() != "Lion" {
+				t.Errorf("created = %v, want ID 1 assigned and the name normalized", created)
+			}
+			if created.GetCreatedAt() == nil || created.GetCreatedAt().AsTime().IsZero() {
+				t.Errorf("created_at = %v, want the store's timestamp", created.GetCreatedAt())
+			}
+			if _, err := client.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Id: 5, Name: "Eagle", Class: "bird", Legs: 2}}); err != nil {
+				t.Fatalf("CreateAnimal with an ID: %v", err)
+			}
+
+			got, err := client.GetAnimal(ctx, &animalpb.GetAnimalRequest{Id: 1})
+			if err != nil {
+				t.Fatalf("GetAnimal: %v", err)
+			}
+			if !proto.Equal(got, created) {
+				t.Errorf("GetAnimal = %v, want %v", got, created)
+			}
+
+			list, err := client.ListAnimals(ctx, &animalpb.ListAnimalsRequest{Sort: []string{"-legs"}, Limit: proto.Int32(1)})
+			if err != nil {
+				t.Fatalf("ListAnimals: %v", err)
+			}
+			if list.GetTotal() != 2 || list.GetLimit() != 1 || len(list.GetAnimals()) != 1 || list.GetAnimals()[0].GetName() != "Lion" {
+				t.Errorf("ListAnimals = %v, want the first of 2 by legs descending", list)
+			}
+			list, err = client.ListAnimals(ctx, &animalpb.ListAnimalsRequest{Classes: []string{"bird"}})
+			if err != nil {
+				t.Fatalf("ListAnimals by class: %v", err)
+			}
+			if list.GetTotal() != 1 || list.GetAnimals()[0].GetId() != 5 {
+				t.Errorf("ListAnimals by class = %v, want the eagle", list)
+			}
+
+			updated, err := client.UpdateAnimal(ctx, &animalpb.UpdateAnimalRequest{Id: 1, Animal: &animalpb.Animal{Id: 9, Name: "Lion", Class: "mammal", Legs: 3}})
+			if err != nil {
+				t.Fatalf("UpdateAnimal: %v", err)
+			}
+			if updated.GetId() != 1 || updated.GetLegs() != 3 {
+				t.Errorf("updated = %v, want animal 1 with 3 legs", updated)
+			}
+			if !updated.GetCreatedAt().AsTime().Equal(created.GetCreatedAt().AsTime()) {
+				t.Errorf("created_at changed from %v to %v on update", created.GetCreatedAt().AsTime(), updated.GetCreatedAt().AsTime())
+			}
+
+			// The REST API serves the same store
+			rec := serve(newTestAPI(store, testConfig()), http.MethodGet, "/v1/animals/1", "")
+			if animal := decodeAnimalResponse(t, rec); animal.Legs != 3 {
+				t.Errorf("REST animal = %+v, want the gRPC update", animal)
+			}
+
+			if _, err := client.DeleteAnimal(ctx, &animalpb.DeleteAnimalRequest{Id: 1}); err != nil {
+				t.Fatalf("DeleteAnimal: %v", err)
+			}
+			if _, err := client.GetAnimal(ctx, &animalpb.GetAnimalRequest{Id: 1}); status.Code(err) != codes.NotFound {
+				t.Errorf("GetAnimal after delete: %v, want NotFound", err)
+			}
+		})
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	lion := &animalpb.Animal{Id: 1, Name: "Lion", Class: "mammal", Legs: 4}
+	tests := []struct {
+		name     string
+		call     func(ctx context.Context, client animalpb.AnimalServiceClient) error
+		readOnly bool
+		want     codes.Code
+	}{
+		{"get missing", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.GetAnimal(ctx, &animalpb.GetAnimalRequest{Id: 2})
+			return err
+		}, false, codes.NotFound},
+		{"get invalid ID", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.GetAnimal(ctx, &animalpb.GetAnimalRequest{Id: 0})
+			return err
+		}, false, codes.InvalidArgument},
+		{"create existing", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{Animal: lion})
+			return err
+		}, false, codes.AlreadyExists},
+		{"create without animal", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{})
+			return err
+		}, false, codes.InvalidArgument},
+		{"create negative ID", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Id: -1, Name: "Tiger", Class: "mammal"}})
+			return err
+		}, false, codes.InvalidArgument},
+		{"update missing", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.UpdateAnimal(ctx, &animalpb.UpdateAnimalRequest{Id: 2, Animal: lion})
+			return err
+		}, false, codes.NotFound},
+		{"delete missing", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.DeleteAnimal(ctx, &animalpb.DeleteAnimalRequest{Id: 2})
+			return err
+		}, false, codes.NotFound},
+		{"list invalid sort", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.ListAnimals(ctx, &animalpb.ListAnimalsRequest{Sort: []string{"weight"}})
+			return err
+		}, false, codes.InvalidArgument},
+		{"create in read-only mode", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.CreateAnimal(ctx, &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Name: "Tiger", Class: "mammal"}})
+			return err
+		}, true, codes.Unavailable},
+		{"read in read-only mode", func(ctx context.Context, c animalpb.AnimalServiceClient) error {
+			_, err := c.GetAnimal(ctx, &animalpb.GetAnimalRequest{Id: 1})
+			return err
+		}, true, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(animalFromProto(lion)); err != nil {
+				t.Fatal(err)
+			}
+			client := newTestGRPCClient(t, store, testConfig(), NewReadOnlyMode(tt.readOnly))
+			if err := tt.call(context.Background(), client); status.Code(err) != tt.want {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCValidationDetails(t *testing.T) {
+	client := newTestGRPCClient(t, NewInMemoryAnimalStore(0), testConfig(), NewReadOnlyMode(false))
+	_, err := client.CreateAnimal(context.Background(), &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Name: "", Class: "bird", Legs: -1}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("error = %v, want InvalidArgument", err)
+	}
+	fields := make(map[string]bool)
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields[violation.GetField()] = true
+			}
+		}
+	}
+	if !fields["name"] || !fields["legs"] {
+		t.Errorf("field violations = %v, want name and legs", fields)
+	}
+}
+
+func TestGRPCTenants(t *testing.T) {
+	cfg := testConfig()
+	cfg.Tenants = []string{"north", "south"}
+	store := NewTenantAnimalStore(map[string]AnimalStore{
+		"north": NewInMemoryAnimalStore(0),
+		"south": NewInMemoryAnimalStore(0),
+	})
+	client := newTestGRPCClient(t, store, cfg, NewReadOnlyMode(false))
+	north := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "north")
+	south := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", " south ")
+
+	if _, err := client.CreateAnimal(north, &animalpb.CreateAnimalRequest{Animal: &animalpb.Animal{Name: "Lion", Class: "mammal", Legs: 4}}); err != nil {
+		t.Fatalf("CreateAnimal for north: %v", err)
+	}
+	if _, err := client.GetAnimal(north, &animalpb.GetAnimalRequest{Id: 1}); err != nil {
+		t.Errorf("GetAnimal for north: %v", err)
+	}
+	if _, err := client.GetAnimal(south, &animalpb.GetAnimalRequest{Id: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetAnimal for south: %v, want NotFound in a store of its own", err)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"missing": context.Background(),
+		"blank":   metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", " "),
+		"unknown": metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "east"),
+	} {
+		if _, err := client.ListAnimals(ctx, &animalpb.ListAnimalsRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s tenant: %v, want InvalidArgument", name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// AuditHistory is an audit sink that keeps the entries of each animal, so the history of one
+// animal can be looked up by its ID. Each animal keeps its last size entries; older ones are
+// dropped. Histories outlive the animals, so a deleted animal still shows how it ended.
+// Entries without an animal (replace_all) are not kept. With tenancy each tenant has histories
+// of its own.
+type AuditHistory struct {
+	mu      sync.Mutex
+	size    int
+	entries map[historyKey][]AuditEntry // Animal -> its entries, oldest first
+}
+
+// historyKey identifies an animal across tenants.
+type historyKey struct {
+	tenant string
+	id     int
+}
+
+// NewAuditHistory creates a history keeping the last size entries (at least 1) of each animal.
+func NewAuditHistory(size int) *AuditHistory {
+	if size < 1 {
+		size = 1
+	}
+	return &AuditHistory{size: size, entries: make(map[historyKey][]AuditEntry)}
+}
+
+// Record appends the entry to the history of its animal, dropping that animal's oldest entry
+// once it has size of them.
+func (h *AuditHistory) Record(entry AuditEntry) error {
+	if entry.AnimalID == 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := historyKey{tenant: entry.Tenant, id: entry.AnimalID}
+	history := h.entries[key]
+	if len(history) == h.size {
+		history = append(history[:0:0], history[1:]...)
+	}
+	h.entries[key] = append(history, entry)
+	return nil
+}
+
+// History returns a copy of the entries of the tenant's animal ("" without tenancy), oldest
+// first, or nil if none were kept.
+func (h *AuditHistory) History(tenant string, id int) []AuditEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]AuditEntry(nil), h.entries[historyKey{tenant: tenant, id: id}]...)
+}
+
+// animalHistoryHandler handles GET requests for the change history of one animal: its audit
+// entries (create, each update, delete) in chronological order, with the snapshots and field
+// changes of each. ?limit= and ?offset= page through the entries like the animal list. An ID
+// without any history gets 404 unless the animal exists, e.g. because it was stored before
+// auditing was enabled, in which case the history is empty.
+func animalHistoryHandler(store AnimalStore, history *AuditHistory, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+		page, err := parsePage(r, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries := history.History(tenantFromContext(r.Context()), id)
+		if len(entries) == 0 {
+			if _, err := store.GetAnimalByID(id); err != nil {
+				if errors.Is(err, ErrNotFound) {
+					http.Error(w, "No history found for this animal", http.StatusNotFound)
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		paged := []AuditEntry{}
+		if page.Offset < len(entries) {
+			paged = entries[page.Offset:min(page.Offset+page.Limit, len(entries))]
+		}
+		writePageHeaders(w, page, len(entries))
+		respondJSON(w, r, auditLogResponse{Entries: paged})
+	}
+}
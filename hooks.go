@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BeforeCreateHook runs before an animal is created. It may modify the animal, which is stored
+// as the hook leaves it, or veto the create by returning an error.
+type BeforeCreateHook func(ctx context.Context, animal *Animal) error
+
+// AfterCreateHook runs after an animal has been created, with the animal as it was stored.
+type AfterCreateHook func(ctx context.Context, animal Animal)
+
+// BeforeUpdateHook runs before an existing animal is overwritten. It sees the stored record and
+// the new one, may modify the new one, or veto the update by returning an error.
+type BeforeUpdateHook func(ctx context.Context, current Animal, animal *Animal) error
+
+// AfterDeleteHook runs after an animal has been deleted, with the ID it had.
+type AfterDeleteHook func(ctx context.Context, id int)
+
+// Hooks holds the callbacks run around store mutations, in registration order. They are
+// registered at startup (see registerHooks) and must not be changed once the server runs.
+type Hooks struct {
+	BeforeCreate []BeforeCreateHook
+	AfterCreate  []AfterCreateHook
+	BeforeUpdate []BeforeUpdateHook
+	AfterDelete  []AfterDeleteHook
+}
+
+// registerHooks is where deployments add their custom mutation logic, e.g. to enrich animals
+// or publish changes to a message queue, without touching the handlers. With -example-hooks it
+// registers the example hooks below, which show one hook of each kind.
+func registerHooks(hooks *Hooks, cfg Config) {
+	if cfg.ExampleHooks {
+		registerExampleHooks(hooks, log.Default())
+	}
+}
+
+// registerExampleHooks adds hooks that refuse to store dragons, whether created or updated into
+// one, and log every create and delete to logger.
+func registerExampleHooks(hooks *Hooks, logger *log.Logger) {
+	vetoDragons := func(animal *Animal) error {
+		if strings.EqualFold(strings.TrimSpace(animal.Class), "dragon") {
+			return errors.New("dragons are not real")
+		}
+		return nil
+	}
+	hooks.BeforeCreate = append(hooks.BeforeCreate, func(ctx context.Context, animal *Animal) error {
+		return vetoDragons(animal)
+	})
+	hooks.BeforeUpdate = append(hooks.BeforeUpdate, func(ctx context.Context, current Animal, animal *Animal) error {
+		return vetoDragons(animal)
+	})
+	hooks.AfterCreate = append(hooks.AfterCreate, func(ctx context.Context, animal Animal) {
+		logger.Printf("hook: created animal %d (%s)", animal.ID, animal.Name)
+	})
+	hooks.AfterDelete = append(hooks.AfterDelete, func(ctx context.Context, id int) {
+		logger.Printf("hook: deleted animal %d", id)
+	})
+}
+
+// empty reports whether no hook is registered at all.
+func (h *Hooks) empty() bool {
+	return len(h.BeforeCreate) == 0 && len(h.AfterCreate) == 0 && len(h.BeforeUpdate) == 0 && len(h.AfterDelete) == 0
+}
+
+// beforeCreate runs the BeforeCreate hooks in order, stopping at the first veto.
+func (h *Hooks) beforeCreate(ctx context.Context, animal *Animal) error {
+	for _, hook := range h.BeforeCreate {
+		if err := hook(ctx, animal); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+// beforeUpdate runs the BeforeUpdate hooks in order, stopping at the first veto.
+func (h *Hooks) beforeUpdate(ctx context.Context, current Animal, animal *Animal) error {
+	for _, hook := range h.BeforeUpdate {
+		if err := hook(ctx, current, animal); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+// HookedAnimalStore is an AnimalStore decorator that runs the registered Hooks around every
+// mutation. A vetoing Before-hook aborts the mutation with an error wrapping ErrVetoed (and the
+// hook's own error), and nothing is written. After-hooks run only once the mutation succeeded;
+// inside a transaction they are held back until it commits. Reads pass through unchanged. Bound
+// to a request via WithContext, hooks receive the request's context.
+//
+// Bulk operations run the hooks per animal: ReplaceAllAnimals treats every incoming animal as a
+// create, ReclassifyAnimals every moved animal as an update, and UpsertAnimals every animal
+// as whichever of the two it turns out to be. The animals ReplaceAllAnimals discards are not
+// reported to AfterDelete hooks.
+type HookedAnimalStore struct {
+	inner   AnimalStore
+	hooks   *Hooks
+	ctx     context.Context
+	pending *[]func() // After-hooks waiting for the enclosing transaction to commit; nil outside one
+}
+
+// NewHookedAnimalStore wraps inner so that hooks run around its mutations.
+func NewHookedAnimalStore(inner AnimalStore, hooks *Hooks) *HookedAnimalStore {
+	return &HookedAnimalStore{inner: inner, hooks: hooks, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the store whose hooks receive ctx.
+func (h *HookedAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &HookedAnimalStore{inner: bindContext(h.inner, ctx), hooks: h.hooks, ctx: ctx, pending: h.pending}
+}
+
+// after runs fn now, or when the enclosing transaction commits.
+func (h *HookedAnimalStore) after(fn func()) {
+	if h.pending != nil {
+		*h.pending = append(*h.pending, fn)
+		return
+	}
+	fn()
+}
+
+// afterCreate runs the AfterCreate hooks for the stored record of the animal.
+func (h *HookedAnimalStore) afterCreate(animal Animal) {
+	if len(h.hooks.AfterCreate) == 0 {
+		return
+	}
+	if animal.ID != 0 {
+		if stored, err := h.inner.GetAnimalByID(animal.ID); err == nil {
+			animal = *stored
+		}
+	}
+	h.after(func() {
+		for _, hook := range h.hooks.AfterCreate {
+			hook(h.ctx, animal)
+		}
+	})
+}
+
+// afterDelete runs the AfterDelete hooks for the deleted IDs.
+func (h *HookedAnimalStore) afterDelete(ids ...int) {
+	if len(h.hooks.AfterDelete) == 0 {
+		return
+	}
+	h.after(func() {
+		for _, id := range ids {
+			for _, hook := range h.hooks.AfterDelete {
+				hook(h.ctx, id)
+			}
+		}
+	})
+}
+
+// GetAllAnimals passes through to the underlying store.
+func (h *HookedAnimalStore) GetAllAnimals() ([]Animal, error) {
+	return h.inner.GetAllAnimals()
+}
+
+// FilterAnimals passes through to the underlying store.
+func (h *HookedAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	return h.inner.FilterAnimals(filter)
+}
+
+// GetAnimalIDs passes through to the underlying store.
+func (h *HookedAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	return h.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals passes through to the underlying store.
+func (h *HookedAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	return h.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach passes through to the underlying store.
+func (h *HookedAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return h.inner.ForEach(ctx, fn)
+}
+
+// Facets passes through to the underlying store.
+func (h *HookedAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	return h.inner.Facets(filter)
+}
+
+// Analytics passes through to the underlying store.
+func (h *HookedAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	return h.inner.Analytics(filter)
+}
+
+// GroupAnimalsByClass passes through to the underlying store.
+func (h *HookedAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	return h.inner.GroupAnimalsByClass(filter)
+}
+
+// FuzzySearch passes through to the underlying store.
+func (h *HookedAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	return h.inner.FuzzySearch(query, maxDistance)
+}
+
+// Query passes through to the underlying store.
+func (h *HookedAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	return h.inner.Query(q)
+}
+
+// SampleAnimals passes through to the underlying store.
+func (h *HookedAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	return h.inner.SampleAnimals(filter, n)
+}
+
+// GetAnimalByID passes through to the underlying store.
+func (h *HookedAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	return h.inner.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs passes through to the underlying store.
+func (h *HookedAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	return h.inner.GetAnimalsByIDs(ids)
+}
+
+// GetAnimalsModifiedSince passes through to the underlying store.
+func (h *HookedAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	return h.inner.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified passes through to the underlying store.
+func (h *HookedAnimalStore) LatestModified() (time.Time, error) {
+	return h.inner.LatestModified()
+}
+
+// ReserveID passes through to the underlying store.
+func (h *HookedAnimalStore) ReserveID() (int, time.Time, error) {
+	return h.inner.ReserveID()
+}
+
+// NextID passes through to the underlying store.
+func (h *HookedAnimalStore) NextID() (int, error) {
+	return h.inner.NextID()
+}
+
+// CreateAnimal runs the BeforeCreate hooks, creates the animal they produced and runs the AfterCreate hooks.
+func (h *HookedAnimalStore) CreateAnimal(animal Animal) error {
+	if err := h.hooks.beforeCreate(h.ctx, &animal); err != nil {
+		return err
+	}
+	if err := h.inner.CreateAnimal(animal); err != nil {
+		return err
+	}
+	h.afterCreate(animal)
+	return nil
+}
+
+// CreateAnimalAssigningID runs the BeforeCreate hooks, creates the animal they produced under a
+// new ID and runs the AfterCreate hooks. The hooks before the write see the animal without an ID.
+func (h *HookedAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	if err := h.hooks.beforeCreate(h.ctx, &animal); err != nil {
+		return animal, err
+	}
+	created, err := h.inner.CreateAnimalAssigningID(animal, skip)
+	if err != nil {
+		return created, err
+	}
+	h.afterCreate(created)
+	return created, nil
+}
+
+// UpdateAnimal runs the BeforeUpdate hooks and updates the animal they produced. An animal that
+// does not exist is left to the underlying store to report.
+func (h *HookedAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	if len(h.hooks.BeforeUpdate) > 0 {
+		current, err := h.inner.GetAnimalByID(id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			if err := h.hooks.beforeUpdate(h.ctx, *current, &animal); err != nil {
+				return err
+			}
+		}
+	}
+	return h.inner.UpdateAnimal(id, animal)
+}
+
+// UpsertAnimal runs the update hooks if the animal exists and the create hooks otherwise.
+// The existence check is separate from the upsert, so outside a transaction a concurrent create
+// or delete may slip in between.
+func (h *HookedAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	current, err := h.inner.GetAnimalByID(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	exists := err == nil
+	if exists {
+		err = h.hooks.beforeUpdate(h.ctx, *current, &animal)
+	} else {
+		err = h.hooks.beforeCreate(h.ctx, &animal)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := h.inner.UpsertAnimal(id, animal); err != nil {
+		return err
+	}
+	if !exists {
+		animal.ID = id
+		h.afterCreate(animal)
+	}
+	return nil
+}
+
+// UpsertAnimalIf runs the hooks like UpsertAnimal and then upserts conditionally. The hooks see
+// the animal as it was read before the write, so when the condition then fails because of a
+// concurrent write, they have run for a write that did not happen.
+func (h *HookedAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	current, err := h.inner.GetAnimalByID(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if err == nil {
+		err = h.hooks.beforeUpdate(h.ctx, *current, &animal)
+	} else {
+		err = h.hooks.beforeCreate(h.ctx, &animal)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	created, err := h.inner.UpsertAnimalIf(id, animal, matches)
+	if err != nil {
+		return false, err
+	}
+	if created {
+		animal.ID = id
+		h.afterCreate(animal)
+	}
+	return created, nil
+}
+
+// MergeAnimals passes the merged target through the BeforeUpdate hooks, as computed from the
+// records the store holds at the time, and runs the AfterDelete hooks for the source. With
+// BeforeUpdate hooks the merge runs in a transaction, so a veto leaves both animals unchanged.
+func (h *HookedAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	if len(h.hooks.BeforeUpdate) == 0 {
+		merged, err := h.inner.MergeAnimals(sourceID, targetID, merge)
+		if err != nil {
+			return Animal{}, err
+		}
+		h.afterDelete(sourceID)
+		return merged, nil
+	}
+
+	var merged Animal
+	err := h.inner.WithTransaction(h.ctx, func(tx AnimalStore) error {
+		var hookErr error
+		stored, err := tx.MergeAnimals(sourceID, targetID, func(source, target Animal) Animal {
+			animal := merge(source, target)
+			hookErr = h.hooks.beforeUpdate(h.ctx, target, &animal)
+			return animal
+		})
+		if err != nil {
+			return err
+		}
+		merged = stored
+		return hookErr // A veto rolls the merge back
+	})
+	if err != nil {
+		return Animal{}, err
+	}
+	h.afterDelete(sourceID)
+	return merged, nil
+}
+
+// UpsertAnimals passes every animal through the update hooks if it exists and the create hooks
+// otherwise, then upserts the animals the hooks produced and runs the AfterCreate hooks for the
+// created ones. A veto of any animal leaves them all unwritten. As with UpsertAnimal, outside a
+// transaction the existence checks are separate from the upsert.
+func (h *HookedAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	ids := make([]int, len(animals))
+	for i, animal := range animals {
+		ids[i] = animal.ID
+	}
+	existing, _, err := h.inner.GetAnimalsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[int]Animal, len(existing))
+	for _, animal := range existing {
+		current[animal.ID] = animal
+	}
+
+	hooked := make([]Animal, len(animals))
+	copy(hooked, animals)
+	for i := range hooked {
+		if animal, exists := current[hooked[i].ID]; exists {
+			err = h.hooks.beforeUpdate(h.ctx, animal, &hooked[i])
+		} else {
+			err = h.hooks.beforeCreate(h.ctx, &hooked[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+		hooked[i].ID = ids[i] // Hooks may not move an animal to another ID
+	}
+
+	results, err := h.inner.UpsertAnimals(hooked)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if result.Result == batchCreated {
+			h.afterCreate(hooked[i])
+		}
+	}
+	return results, nil
+}
+
+// DeleteAnimal deletes the animal and runs the AfterDelete hooks.
+func (h *HookedAnimalStore) DeleteAnimal(id int) error {
+	if err := h.inner.DeleteAnimal(id); err != nil {
+		return err
+	}
+	h.afterDelete(id)
+	return nil
+}
+
+// DeleteAnimalIfVersion deletes the animal conditionally and runs the AfterDelete hooks if it was deleted.
+func (h *HookedAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	if err := h.inner.DeleteAnimalIfVersion(id, version); err != nil {
+		return err
+	}
+	h.afterDelete(id)
+	return nil
+}
+
+// DeleteAnimals deletes the animals and runs the AfterDelete hooks for each removed one.
+func (h *HookedAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	deleted, notFound, err := h.inner.DeleteAnimals(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.afterDelete(deleted...)
+	return deleted, notFound, nil
+}
+
+// SetEndangered sets the flag. With BeforeUpdate hooks the flagged animal is passed through them
+// and stored as an update, since a hook may change other fields too.
+func (h *HookedAnimalStore) SetEndangered(id int, endangered bool) error {
+	if len(h.hooks.BeforeUpdate) == 0 {
+		return h.inner.SetEndangered(id, endangered)
+	}
+	current, err := h.inner.GetAnimalByID(id)
+	if err != nil {
+		return err
+	}
+	animal := *current
+	animal.Endangered = endangered
+	if err := h.hooks.beforeUpdate(h.ctx, *current, &animal); err != nil {
+		return err
+	}
+	return h.inner.UpdateAnimal(id, animal)
+}
+
+// ReclassifyAnimals moves the animals to the new class. Without BeforeUpdate hooks this is the
+// underlying store's single operation; with them every moved animal is passed through the hooks
+// and updated on its own, all in one transaction, so a veto leaves every animal unchanged.
+func (h *HookedAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	if len(h.hooks.BeforeUpdate) == 0 {
+		return h.inner.ReclassifyAnimals(from, to)
+	}
+
+	changed := 0
+	err := h.inner.WithTransaction(h.ctx, func(tx AnimalStore) error {
+		moving, err := tx.FilterAnimals(AnimalFilter{Classes: []string{from}})
+		if err != nil && !errors.Is(err, ErrEmpty) {
+			return err
+		}
+		for _, current := range moving {
+			if current.Class == to {
+				continue
+			}
+			animal := current
+			animal.Class = to
+			if err := h.hooks.beforeUpdate(h.ctx, current, &animal); err != nil {
+				return err
+			}
+			if err := tx.UpdateAnimal(current.ID, animal); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// ReplaceAllAnimals runs the BeforeCreate hooks for every incoming animal, replaces the dataset
+// with the animals they produced and runs the AfterCreate hooks for each.
+func (h *HookedAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	if len(h.hooks.BeforeCreate) > 0 {
+		hooked := make([]Animal, len(animals))
+		copy(hooked, animals)
+		for i := range hooked {
+			if err := h.hooks.beforeCreate(h.ctx, &hooked[i]); err != nil {
+				return err
+			}
+		}
+		animals = hooked
+	}
+	if err := h.inner.ReplaceAllAnimals(animals); err != nil {
+		return err
+	}
+	for _, animal := range animals {
+		h.afterCreate(animal)
+	}
+	return nil
+}
+
+// WithTransaction runs fn with hooks around its mutations. A veto fails fn with the hook's error,
+// which rolls the transaction back; After-hooks run only once the transaction has committed.
+func (h *HookedAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	var pending []func()
+	err := h.inner.WithTransaction(ctx, func(tx AnimalStore) error {
+		return fn(&HookedAnimalStore{inner: tx, hooks: h.hooks, ctx: h.ctx, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+	for _, run := range pending {
+		run()
+	}
+	return nil
+}
+
+// writeVetoed reports a mutation vetoed by a hook as 422 Unprocessable Entity, with the hook's
+// message as the detail. It reports whether err was a veto, so callers can fall through otherwise.
+func writeVetoed(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, ErrVetoed) {
+		return false
+	}
+	writeProblem(w, r, ProblemDetails{
+		Type:   problemTypeVetoed,
+		Title:  "Rejected by a hook",
+		Status: http.StatusUnprocessableEntity,
+		Detail: strings.TrimPrefix(err.Error(), ErrVetoed.Error()+": "),
+	})
+	return true
+}
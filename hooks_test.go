@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExampleHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantLog    string // Logged by the After-hooks; empty means nothing
+		wantClass  string // Class of animal 1 afterwards
+	}{
+		{"create", http.MethodPost, "/v1/animals", `{"id": 2, "name": "Eagle", "class": "bird", "legs": 2}`, http.StatusCreated, "hook: created animal 2 (Eagle)", "mammal"},
+		{"create vetoed", http.MethodPost, "/v1/animals", `{"id": 2, "name": "Smaug", "class": "Dragon", "legs": 4}`, http.StatusUnprocessableEntity, "", "mammal"},
+		{"upsert creates", http.MethodPut, "/v1/animals/2", `{"name": "Eagle", "class": "bird", "legs": 2}`, http.StatusCreated, "hook: created animal 2 (Eagle)", "mammal"},
+		{"update", http.MethodPut, "/v1/animals/1", `{"name": "Lion", "class": "cat", "legs": 4}`, http.StatusOK, "", "cat"},
+		{"update vetoed", http.MethodPut, "/v1/animals/1", `{"name": "Lion", "class": "dragon", "legs": 4}`, http.StatusUnprocessableEntity, "", "mammal"},
+		{"reclassify vetoed", http.MethodPost, "/v1/animals/reclassify", `{"from": "mammal", "to": "dragon"}`, http.StatusUnprocessableEntity, "", "mammal"},
+		{"delete", http.MethodDelete, "/v1/animals/1", "", http.StatusNoContent, "hook: deleted animal 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged bytes.Buffer
+			hooks := &Hooks{}
+			registerExampleHooks(hooks, log.New(&logged, "", 0))
+			backend := NewInMemoryAnimalStore(0)
+			if err := backend.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := serve(newTestAPI(NewHookedAnimalStore(backend, hooks), testConfig()), tt.method, tt.target, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity && !strings.Contains(rec.Body.String(), "dragons are not real") {
+				t.Errorf("body = %s, want the hook's veto as the detail", rec.Body)
+			}
+			if got := strings.TrimSpace(logged.String()); got != tt.wantLog {
+				t.Errorf("logged %q, want %q", got, tt.wantLog)
+			}
+			if lion, err := backend.GetAnimalByID(1); tt.wantClass == "" {
+				if err == nil {
+					t.Error("animal 1 was not deleted")
+				}
+			} else if err != nil || lion.Class != tt.wantClass {
+				t.Errorf("animal 1 = %+v, %v, want class %q", lion, err, tt.wantClass)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity {
+				if _, err := backend.GetAnimalByID(2); err == nil {
+					t.Error("a vetoed create was stored")
+				}
+			}
+		})
+	}
+}
+
+func TestHookOrder(t *testing.T) {
+	var calls []string
+	veto := errors.New("no")
+	hooks := &Hooks{
+		BeforeCreate: []BeforeCreateHook{
+			func(ctx context.Context, animal *Animal) error {
+				calls = append(calls, "before "+animal.Name)
+				animal.Name = strings.ToUpper(animal.Name) // Stored as the hook leaves it
+				return nil
+			},
+			func(ctx context.Context, animal *Animal) error {
+				if animal.Class == "dragon" {
+					return veto
+				}
+				return nil
+			},
+		},
+		AfterCreate: []AfterCreateHook{
+			func(ctx context.Context, animal Animal) { calls = append(calls, "after "+animal.Name) },
+		},
+	}
+	store := NewHookedAnimalStore(NewInMemoryAnimalStore(0), hooks)
+
+	if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, ", "); got != "before Lion, after LION" {
+		t.Errorf("calls = %q, want the Before-hooks before the write and the After-hooks after it", got)
+	}
+	if lion, _ := store.GetAnimalByID(1); lion == nil || lion.Name != "LION" {
+		t.Errorf("stored %+v, want the name the hook set", lion)
+	}
+
+	calls = nil
+	err := store.CreateAnimal(Animal{ID: 2, Name: "Smaug", Class: "dragon"})
+	if !errors.Is(err, ErrVetoed) || !errors.Is(err, veto) {
+		t.Errorf("vetoed create error = %v, want ErrVetoed wrapping the hook's error", err)
+	}
+	if got := strings.Join(calls, ", "); got != "before Smaug" {
+		t.Errorf("calls = %q, want no After-hook for a vetoed create", got)
+	}
+	if _, err := store.GetAnimalByID(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("vetoed animal: error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// idListResponse is the body of a list request with ?id_only=true, e.g. {"ids": [1, 2, 3]}.
+type idListResponse struct {
+	IDs []int `json:"ids"`
+}
+
+// parseIDOnly parses the ?id_only= list parameter, which is false when absent.
+func parseIDOnly(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("id_only")
+	if value == "" {
+		return false, nil
+	}
+	idOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("id_only must be true or false")
+	}
+	return idOnly, nil
+}
+
+// sortAnimalIDs orders ids by the given keys, looking each animal up by ID, with the same
+// ID-order tie-break as sortAnimals. Without keys it only needs the IDs themselves.
+func sortAnimalIDs(ids []int, keys []SortKey, lookup func(id int) Animal) {
+	sort.Ints(ids)
+	if len(keys) == 0 {
+		return
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return compareAnimals(lookup(ids[i]), lookup(ids[j]), keys) < 0 })
+}
+
+// animalIDs returns the IDs of the animals, in their order.
+func animalIDs(animals []Animal) []int {
+	ids := make([]int, len(animals))
+	for i, animal := range animals {
+		ids[i] = animal.ID
+	}
+	return ids
+}
+
+// loadListIDs returns the IDs of a list request's animals, in the order loadListAnimals
+// returns them. A plain filter asks the store for the IDs alone; ?modified_since= and ?fuzzy=
+// need the animals to select and rank them, so their IDs are taken from the full records.
+func loadListIDs(store AnimalStore, cfg Config, r *http.Request, filter AnimalFilter, modifiedSince time.Time) ([]int, error) {
+	if !modifiedSince.IsZero() || r.URL.Query().Get("fuzzy") != "" {
+		animals, err := loadListAnimals(store, cfg, r, filter, modifiedSince)
+		if err != nil {
+			return nil, err
+		}
+		return animalIDs(animals), nil
+	}
+	return store.GetAnimalIDs(filter)
+}
+
+// renderIDList renders the page of a list request's IDs for the list cache.
+func renderIDList(store AnimalStore, cfg Config, r *http.Request, filter AnimalFilter, modifiedSince time.Time, page Page) (renderedList, error) {
+	ids, err := loadListIDs(store, cfg, r, filter, modifiedSince)
+	if err != nil {
+		return renderedList{}, err
+	}
+
+	paged := pageOf(page, ids)
+	var body bytes.Buffer
+	if err := encodeJSON(&body, r, idListResponse{IDs: paged}); err != nil {
+		return renderedList{}, err
+	}
+	return renderedList{body: body.Bytes(), etag: idListETag(paged, len(ids), body.Bytes(), cfg.ETagMode), total: len(ids)}, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storeMetrics are the Prometheus collectors shared by an InstrumentedAnimalStore and the views
+// it hands to transactions.
+type storeMetrics struct {
+	operations *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+}
+
+// InstrumentedAnimalStore is an AnimalStore decorator that records, for every store method, how
+// often it was called, how often it failed and how long it took, as Prometheus metrics labelled
+// with the method name and a result of "success" or "error". Any error counts, including
+// expected ones such as ErrNotFound. Unlike the HTTP metrics these describe the store alone,
+// whichever backend it is and whichever endpoint called it. Operations made inside a
+// transaction are recorded too, as is the transaction as a whole.
+type InstrumentedAnimalStore struct {
+	inner   AnimalStore
+	metrics *storeMetrics
+}
+
+// newStoreMetrics creates the store collectors, using the given latency histogram buckets (in
+// seconds), and registers them with registerer. Every store instrumented with them records into
+// the same series, e.g. the backends of all tenants.
+func newStoreMetrics(registerer prometheus.Registerer, buckets []float64) *storeMetrics {
+	metrics := &storeMetrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anekazoo_store_operations_total",
+			Help: "Number of store operations, by method and result (success or error).",
+		}, []string{"method", "result"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "anekazoo_store_operation_duration_seconds",
+			Help:    "Store operation latency in seconds, by method and result (success or error).",
+			Buckets: buckets,
+		}, []string{"method", "result"}),
+	}
+	registerer.MustRegister(metrics.operations, metrics.latency)
+	return metrics
+}
+
+// instrument wraps inner in a store recording into these metrics.
+func (m *storeMetrics) instrument(inner AnimalStore) *InstrumentedAnimalStore {
+	return &InstrumentedAnimalStore{inner: inner, metrics: m}
+}
+
+// WithContext returns a copy of the store whose inner store is bound to ctx.
+func (s *InstrumentedAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &InstrumentedAnimalStore{inner: bindContext(s.inner, ctx), metrics: s.metrics}
+}
+
+// observe records one call of method that started at start and returned err.
+func (s *InstrumentedAnimalStore) observe(method string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	s.metrics.operations.WithLabelValues(method, result).Inc()
+	s.metrics.latency.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+// GetAllAnimals records the underlying GetAllAnimals.
+func (s *InstrumentedAnimalStore) GetAllAnimals() (animals []Animal, err error) {
+	defer func(start time.Time) { s.observe("GetAllAnimals", start, err) }(time.Now())
+	return s.inner.GetAllAnimals()
+}
+
+// FilterAnimals records the underlying FilterAnimals.
+func (s *InstrumentedAnimalStore) FilterAnimals(filter AnimalFilter) (animals []Animal, err error) {
+	defer func(start time.Time) { s.observe("FilterAnimals", start, err) }(time.Now())
+	return s.inner.FilterAnimals(filter)
+}
+
+// GetAnimalIDs records the underlying GetAnimalIDs.
+func (s *InstrumentedAnimalStore) GetAnimalIDs(filter AnimalFilter) (ids []int, err error) {
+	defer func(start time.Time) { s.observe("GetAnimalIDs", start, err) }(time.Now())
+	return s.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals records the underlying StreamAnimals. Its latency covers the whole stream,
+// including the time fn spends writing the response.
+func (s *InstrumentedAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) (err error) {
+	defer func(start time.Time) { s.observe("StreamAnimals", start, err) }(time.Now())
+	return s.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach records the underlying ForEach. Like that of StreamAnimals, its latency includes the
+// time fn takes.
+func (s *InstrumentedAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) (err error) {
+	defer func(start time.Time) { s.observe("ForEach", start, err) }(time.Now())
+	return s.inner.ForEach(ctx, fn)
+}
+
+// GroupAnimalsByClass records the underlying GroupAnimalsByClass.
+func (s *InstrumentedAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (groups map[string][]Animal, err error) {
+	defer func(start time.Time) { s.observe("GroupAnimalsByClass", start, err) }(time.Now())
+	return s.inner.GroupAnimalsByClass(filter)
+}
+
+// Facets records the underlying Facets.
+func (s *InstrumentedAnimalStore) Facets(filter AnimalFilter) (facets []Facet, err error) {
+	defer func(start time.Time) { s.observe("Facets", start, err) }(time.Now())
+	return s.inner.Facets(filter)
+}
+
+// Analytics records the underlying Analytics.
+func (s *InstrumentedAnimalStore) Analytics(filter AnimalFilter) (result AnalyticsResult, err error) {
+	defer func(start time.Time) { s.observe("Analytics", start, err) }(time.Now())
+	return s.inner.Analytics(filter)
+}
+
+// FuzzySearch records the underlying FuzzySearch.
+func (s *InstrumentedAnimalStore) FuzzySearch(query string, maxDistance int) (animals []Animal, err error) {
+	defer func(start time.Time) { s.observe("FuzzySearch", start, err) }(time.Now())
+	return s.inner.FuzzySearch(query, maxDistance)
+}
+
+// SampleAnimals records the underlying SampleAnimals.
+func (s *InstrumentedAnimalStore) SampleAnimals(filter AnimalFilter, n int) (animals []Animal, err error) {
+	defer func(start time.Time) { s.observe("SampleAnimals", start, err) }(time.Now())
+	return s.inner.SampleAnimals(filter, n)
+}
+
+// Query records the underlying Query.
+func (s *InstrumentedAnimalStore) Query(q AnimalQuery) (page []Animal, total int, err error) {
+	defer func(start time.Time) { s.observe("Query", start, err) }(time.Now())
+	return s.inner.Query(q)
+}
+
+// GetAnimalByID records the underlying GetAnimalByID.
+func (s *InstrumentedAnimalStore) GetAnimalByID(id int) (animal *Animal, err error) {
+	defer func(start time.Time) { s.observe("GetAnimalByID", start, err) }(time.Now())
+	return s.inner.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs records the underlying GetAnimalsByIDs.
+func (s *InstrumentedAnimalStore) GetAnimalsByIDs(ids []int) (found []Animal, missing []int, err error) {
+	defer func(start time.Time) { s.observe("GetAnimalsByIDs", start, err) }(time.Now())
+	return s.inner.GetAnimalsByIDs(ids)
+}
+
+// GetAnimalsModifiedSince records the underlying GetAnimalsModifiedSince.
+func (s *InstrumentedAnimalStore) GetAnimalsModifiedSince(t time.Time) (animals []Animal, err error) {
+	defer func(start time.Time) { s.observe("GetAnimalsModifiedSince", start, err) }(time.Now())
+	return s.inner.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified records the underlying LatestModified.
+func (s *InstrumentedAnimalStore) LatestModified() (latest time.Time, err error) {
+	defer func(start time.Time) { s.observe("LatestModified", start, err) }(time.Now())
+	return s.inner.LatestModified()
+}
+
+// NextID records the underlying NextID.
+func (s *InstrumentedAnimalStore) NextID() (next int, err error) {
+	defer func(start time.Time) { s.observe("NextID", start, err) }(time.Now())
+	return s.inner.NextID()
+}
+
+// ReserveID records the underlying ReserveID.
+func (s *InstrumentedAnimalStore) ReserveID() (id int, expiresAt time.Time, err error) {
+	defer func(start time.Time) { s.observe("ReserveID", start, err) }(time.Now())
+	return s.inner.ReserveID()
+}
+
+// CreateAnimal records the underlying CreateAnimal.
+func (s *InstrumentedAnimalStore) CreateAnimal(animal Animal) (err error) {
+	defer func(start time.Time) { s.observe("CreateAnimal", start, err) }(time.Now())
+	return s.inner.CreateAnimal(animal)
+}
+
+// CreateAnimalAssigningID records the underlying CreateAnimalAssigningID.
+func (s *InstrumentedAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (created Animal, err error) {
+	defer func(start time.Time) { s.observe("CreateAnimalAssigningID", start, err) }(time.Now())
+	return s.inner.CreateAnimalAssigningID(animal, skip)
+}
+
+// UpdateAnimal records the underlying UpdateAnimal.
+func (s *InstrumentedAnimalStore) UpdateAnimal(id int, animal Animal) (err error) {
+	defer func(start time.Time) { s.observe("UpdateAnimal", start, err) }(time.Now())
+	return s.inner.UpdateAnimal(id, animal)
+}
+
+// UpsertAnimal records the underlying UpsertAnimal.
+func (s *InstrumentedAnimalStore) UpsertAnimal(id int, animal Animal) (err error) {
+	defer func(start time.Time) { s.observe("UpsertAnimal", start, err) }(time.Now())
+	return s.inner.UpsertAnimal(id, animal)
+}
+
+// UpsertAnimalIf records the underlying UpsertAnimalIf.
+func (s *InstrumentedAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (created bool, err error) {
+	defer func(start time.Time) { s.observe("UpsertAnimalIf", start, err) }(time.Now())
+	return s.inner.UpsertAnimalIf(id, animal, matches)
+}
+
+// MergeAnimals records the underlying MergeAnimals.
+func (s *InstrumentedAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (merged Animal, err error) {
+	defer func(start time.Time) { s.observe("MergeAnimals", start, err) }(time.Now())
+	return s.inner.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals records the underlying UpsertAnimals.
+func (s *InstrumentedAnimalStore) UpsertAnimals(animals []Animal) (results []BatchResult, err error) {
+	defer func(start time.Time) { s.observe("UpsertAnimals", start, err) }(time.Now())
+	return s.inner.UpsertAnimals(animals)
+}
+
+// DeleteAnimal records the underlying DeleteAnimal.
+func (s *InstrumentedAnimalStore) DeleteAnimal(id int) (err error) {
+	defer func(start time.Time) { s.observe("DeleteAnimal", start, err) }(time.Now())
+	return s.inner.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion records the underlying DeleteAnimalIfVersion.
+func (s *InstrumentedAnimalStore) DeleteAnimalIfVersion(id int, version int64) (err error) {
+	defer func(start time.Time) { s.observe("DeleteAnimalIfVersion", start, err) }(time.Now())
+	return s.inner.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals records the underlying DeleteAnimals.
+func (s *InstrumentedAnimalStore) DeleteAnimals(ids []int) (deleted []int, notFound []int, err error) {
+	defer func(start time.Time) { s.observe("DeleteAnimals", start, err) }(time.Now())
+	return s.inner.DeleteAnimals(ids)
+}
+
+// ReplaceAllAnimals records the underlying ReplaceAllAnimals.
+func (s *InstrumentedAnimalStore) ReplaceAllAnimals(animals []Animal) (err error) {
+	defer func(start time.Time) { s.observe("ReplaceAllAnimals", start, err) }(time.Now())
+	return s.inner.ReplaceAllAnimals(animals)
+}
+
+// ReclassifyAnimals records the underlying ReclassifyAnimals.
+func (s *InstrumentedAnimalStore) ReclassifyAnimals(from, to string) (changed int, err error) {
+	defer func(start time.Time) { s.observe("ReclassifyAnimals", start, err) }(time.Now())
+	return s.inner.ReclassifyAnimals(from, to)
+}
+
+// SetEndangered records the underlying SetEndangered.
+func (s *InstrumentedAnimalStore) SetEndangered(id int, endangered bool) (err error) {
+	defer func(start time.Time) { s.observe("SetEndangered", start, err) }(time.Now())
+	return s.inner.SetEndangered(id, endangered)
+}
+
+// WithTransaction records the transaction as a whole, and hands fn an instrumented view so the
+// operations inside it are recorded as well.
+func (s *InstrumentedAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) (err error) {
+	defer func(start time.Time) { s.observe("WithTransaction", start, err) }(time.Now())
+	return s.inner.WithTransaction(ctx, func(tx AnimalStore) error {
+		return fn(&InstrumentedAnimalStore{inner: tx, metrics: s.metrics})
+	})
+}
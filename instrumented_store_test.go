@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedAnimalStore(t *testing.T) {
+	metrics := newStoreMetrics(prometheus.NewRegistry(), prometheus.DefBuckets)
+	store := metrics.instrument(NewInMemoryAnimalStore(0))
+
+	if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := store.GetAnimalByID(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.GetAnimalByID(2); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetAnimalByID(2) error = %v, want ErrNotFound", err)
+	}
+	err := store.WithTransaction(context.Background(), func(tx AnimalStore) error {
+		return tx.UpdateAnimal(1, Animal{Name: "Lion", Class: "mammal", Legs: 3})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		result string
+		want   float64
+	}{
+		{"CreateAnimal", "success", 1},
+		{"GetAnimalByID", "success", 3},
+		{"GetAnimalByID", "error", 1},  // Expected errors such as ErrNotFound count too
+		{"UpdateAnimal", "success", 1}, // Recorded inside the transaction
+		{"WithTransaction", "success", 1},
+		{"DeleteAnimal", "success", 0},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(metrics.operations.WithLabelValues(tt.method, tt.result)); got != tt.want {
+			t.Errorf("%s %s operations = %v, want %v", tt.method, tt.result, got, tt.want)
+		}
+	}
+	// One latency series per method and result that was called
+	if got := testutil.CollectAndCount(metrics.latency); got != 5 {
+		t.Errorf("latency series = %d, want 5", got)
+	}
+}
+
+func TestInstrumentedAnimalStoreSharesMetrics(t *testing.T) {
+	metrics := newStoreMetrics(prometheus.NewRegistry(), prometheus.DefBuckets)
+	first := metrics.instrument(NewInMemoryAnimalStore(0))
+	second := metrics.instrument(NewInMemoryAnimalStore(0)).WithContext(context.Background())
+
+	if err := first.CreateAnimal(Animal{ID: 1, Name: "Lion"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.CreateAnimal(Animal{ID: 1, Name: "Tiger"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(metrics.operations.WithLabelValues("CreateAnimal", "success")); got != 2 {
+		t.Errorf("CreateAnimal operations = %v, want both stores recorded in the same series", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errStopIteration is returned by a ForEach callback to end the iteration early once it has
+// what it needs. The helpers below translate it back into success.
+var errStopIteration = errors.New("stop iteration")
+
+// isEmpty reports whether the store holds no animals, looking at one animal at most.
+func isEmpty(ctx context.Context, store AnimalStore) (bool, error) {
+	empty := true
+	err := store.ForEach(ctx, func(Animal) error {
+		empty = false
+		return errStopIteration
+	})
+	if err != nil && !errors.Is(err, errStopIteration) {
+		return false, err
+	}
+	return empty, nil
+}
+
+// forEachMatching hands every animal matching the filter to fn, one at a time and in the
+// filter's sort order, without building a slice of them, for callers that process animals one
+// by one (e.g. the NDJSON list). Without sort keys animals come in ID order straight from
+// ForEach; sort keys need StreamAnimals, with ctx checked before each animal. Either way it
+// stops with ctx's error as soon as ctx is done, e.g. when the client goes away, and with fn's
+// first error. Like StreamAnimals it returns ErrEmpty, without calling fn, when the store is
+// empty.
+func forEachMatching(ctx context.Context, store AnimalStore, filter AnimalFilter, fn func(Animal) error) error {
+	if len(filter.Sort) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return store.StreamAnimals(filter, func(animal Animal) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(animal)
+		})
+	}
+
+	seen := false
+	err := store.ForEach(ctx, func(animal Animal) error {
+		seen = true
+		if !filter.matches(animal) {
+			return nil
+		}
+		return fn(animal)
+	})
+	if err == nil && !seen {
+		return ErrEmpty
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// iterationStores are every backend, plus a memory store behind a few decorators, for the
+// ForEach tests.
+func iterationStores(t *testing.T) map[string]AnimalStore {
+	stores := testBackends(t, 0)
+	stores["decorated"] = NewRetryingAnimalStore(NewReservingAnimalStore(NewHookedAnimalStore(NewInMemoryAnimalStore(0), &Hooks{}), time.Minute), 3, time.Millisecond, time.Millisecond, 0)
+	return stores
+}
+
+func TestForEach(t *testing.T) {
+	stop := errors.New("stop")
+	for name, store := range iterationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.ForEach(ctx, func(Animal) error { t.Error("fn called for an empty store"); return nil }); err != nil {
+				t.Errorf("empty store: error = %v, want nil", err)
+			}
+			for _, id := range []int{5, 2, 9, 1, 7} { // Out of order, so ID order must be established
+				if err := store.CreateAnimal(Animal{ID: id, Name: fmt.Sprintf("Animal %d", id), Class: "mammal"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var ids []int
+			if err := store.ForEach(ctx, func(animal Animal) error { ids = append(ids, animal.ID); return nil }); err != nil {
+				t.Fatal(err)
+			}
+			if want := []int{1, 2, 5, 7, 9}; !slices.Equal(ids, want) {
+				t.Errorf("visited %v, want %v", ids, want)
+			}
+
+			// An error from fn ends the iteration at once and is returned as it is
+			ids = nil
+			err := store.ForEach(ctx, func(animal Animal) error {
+				ids = append(ids, animal.ID)
+				if len(ids) == 2 {
+					return stop
+				}
+				return nil
+			})
+			if !errors.Is(err, stop) || !slices.Equal(ids, []int{1, 2}) {
+				t.Errorf("stopping after two: error = %v, visited %v; want %v after 1 and 2", err, ids, stop)
+			}
+
+			// So does a context that is done, e.g. because the client went away
+			ids = nil
+			cancelled, cancel := context.WithCancel(ctx)
+			err = store.ForEach(cancelled, func(animal Animal) error {
+				ids = append(ids, animal.ID)
+				if len(ids) == 3 {
+					cancel()
+				}
+				return nil
+			})
+			if !errors.Is(err, context.Canceled) || len(ids) != 3 {
+				t.Errorf("cancelling after three: error = %v, visited %v; want context.Canceled after three", err, ids)
+			}
+		})
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	for name, store := range iterationStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if empty, err := isEmpty(context.Background(), store); err != nil || !empty {
+				t.Errorf("new store: isEmpty = %v, %v, want true", empty, err)
+			}
+			for id := 1; id <= 3; id++ {
+				if err := store.CreateAnimal(Animal{ID: id, Name: "Lion"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if empty, err := isEmpty(context.Background(), store); err != nil || empty {
+				t.Errorf("filled store: isEmpty = %v, %v, want false", empty, err)
+			}
+		})
+	}
+}
+
+func TestForEachMatching(t *testing.T) {
+	store := NewShardedAnimalStore(4, 0)
+	ctx := context.Background()
+	if err := forEachMatching(ctx, store, AnimalFilter{}, func(Animal) error { return nil }); !errors.Is(err, ErrEmpty) {
+		t.Errorf("empty store: error = %v, want ErrEmpty", err)
+	}
+	for id, class := range []string{"bird", "mammal", "bird", "reptile", "bird"} {
+		if err := store.CreateAnimal(Animal{ID: id + 1, Name: fmt.Sprintf("Animal %d", id+1), Class: class}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sortDesc := []SortKey{{Field: "name", Desc: true}}
+	tests := []struct {
+		name   string
+		filter AnimalFilter
+		want   []int
+	}{
+		{"everything", AnimalFilter{}, []int{1, 2, 3, 4, 5}},
+		{"filtered", AnimalFilter{Classes: []string{"bird"}}, []int{1, 3, 5}},
+		{"filtered and sorted", AnimalFilter{Classes: []string{"bird"}, Sort: sortDesc}, []int{5, 3, 1}},
+		{"nothing matches", AnimalFilter{Classes: []string{"fish"}}, nil},
+	}
+	for _, tt := range tests {
+		var ids []int
+		if err := forEachMatching(ctx, store, tt.filter, func(animal Animal) error { ids = append(ids, animal.ID); return nil }); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: visited %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
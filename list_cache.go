@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxListCacheEntries bounds the number of cached list responses, since every distinct query
+// string is its own entry.
+const maxListCacheEntries = 1000
+
+// renderedList is a fully serialized list response: the body plus what its headers need.
+type renderedList struct {
+	body  []byte
+	etag  string
+	total int // Number of matching animals, for X-Total-Count
+}
+
+// cachedRenderedList is a rendered list response and when it stops being served.
+type cachedRenderedList struct {
+	renderedList
+	expiresAt time.Time
+}
+
+// ListResponseCache sits in front of the JSON list endpoint. Concurrent identical requests are
+// coalesced with singleflight, so a burst of them loads and serializes the list once and shares
+// the bytes. With a positive TTL the serialized response is also kept for that long. Requests
+// are identical when their query strings are, parameter order aside. Every write request
+// invalidates the cache (see invalidateOnWrite), so a response never predates the last write.
+type ListResponseCache struct {
+	ttl   time.Duration
+	group singleflight.Group
+
+	mu         sync.Mutex
+	entries    map[string]cachedRenderedList
+	generation uint64 // Bumped on every invalidation; part of the flight key so later requests never join an older flight
+}
+
+// NewListResponseCache creates a cache keeping responses for ttl; 0 only coalesces concurrent requests.
+func NewListResponseCache(ttl time.Duration) *ListResponseCache {
+	return &ListResponseCache{ttl: ttl, entries: make(map[string]cachedRenderedList)}
+}
+
+// get returns the response for key, from the cache if it is still fresh, or by calling render
+// (once for all concurrent callers with the same key). Errors are shared but never cached.
+func (c *ListResponseCache) get(key string, render func() (renderedList, error)) (renderedList, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.renderedList, nil
+	}
+
+	result, err, _ := c.group.Do(strconv.FormatUint(generation, 10)+"|"+key, func() (interface{}, error) {
+		rendered, err := render()
+		if err == nil && c.ttl > 0 {
+			c.store(key, rendered, generation)
+		}
+		return rendered, err
+	})
+	if err != nil {
+		return renderedList{}, err
+	}
+	return result.(renderedList), nil
+}
+
+// store caches a response rendered at the given generation, unless a write has invalidated
+// the cache since. Expired entries are pruned when the cache is full.
+func (c *ListResponseCache) store(key string, rendered renderedList, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := time.Now()
+	if len(c.entries) >= maxListCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxListCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cachedRenderedList{renderedList: rendered, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidate drops every cached response.
+func (c *ListResponseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[string]cachedRenderedList)
+}
+
+// invalidateOnWrite is router middleware that invalidates the list cache around every request
+// that is not a GET or HEAD: before it, so no response rendered during the write is cached, and
+// after it, so requests made after the write never get a response loaded before it.
+func invalidateOnWrite(cache *ListResponseCache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cache.invalidate()
+			defer cache.invalidate()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
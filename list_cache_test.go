@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts how often the list is loaded from the store.
+type countingStore struct {
+	AnimalStore
+	loads atomic.Int64
+}
+
+func (s *countingStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	s.loads.Add(1)
+	return s.AnimalStore.FilterAnimals(filter)
+}
+
+// BenchmarkConcurrentIdenticalLists measures a thundering herd of identical list requests
+// without any sharing, with concurrent requests coalesced, and with the serialized response
+// cached. loads/op is how often the list was loaded and serialized per request.
+func BenchmarkConcurrentIdenticalLists(b *testing.B) {
+	variants := []struct {
+		name    string
+		handler func(store AnimalStore, cfg Config) http.Handler
+	}{
+		{"uncached", func(store AnimalStore, cfg Config) http.Handler {
+			// A fresh cache per request shares nothing, like a list endpoint without the cache
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				getAnimalsHandler(store, cfg, NewListResponseCache(0)).ServeHTTP(w, r)
+			})
+		}},
+		{"coalesced", func(store AnimalStore, cfg Config) http.Handler {
+			return getAnimalsHandler(store, cfg, NewListResponseCache(0))
+		}},
+		{"cached", func(store AnimalStore, cfg Config) http.Handler {
+			return getAnimalsHandler(store, cfg, NewListResponseCache(time.Minute))
+		}},
+	}
+	for _, v := range variants {
+		b.Run(v.name, func(b *testing.B) {
+			backend := NewInMemoryAnimalStore(0)
+			fillStore(b, backend, 1000)
+			store := &countingStore{AnimalStore: backend}
+			handler := v.handler(store, testConfig())
+
+			b.SetParallelism(16) // Goroutines per CPU: enough for requests to overlap
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/animals?class=mammal&limit=1000", nil))
+					if rec.Code != http.StatusOK {
+						b.Errorf("status = %d", rec.Code)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(store.loads.Load())/float64(b.N), "loads/op")
+		})
+	}
+}
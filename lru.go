@@ -0,0 +1,107 @@
+package main
+
+import "container/list"
+
+// Capacity policies: what a full store does when another animal is created.
+const (
+	capacityReject   = "reject"    // Fail the create with ErrCapacityExceeded
+	capacityEvictLRU = "evict-lru" // Evict the least recently accessed animal to make room
+)
+
+// lruTracker records the access order of animal IDs, most recently accessed first,
+// for InMemoryAnimalStore's evict-lru capacity policy.
+type lruTracker struct {
+	order    *list.List            // Front is the most recently accessed ID
+	elements map[int]*list.Element // Position of each tracked ID in order
+}
+
+// NewEvictingInMemoryAnimalStore creates an InMemoryAnimalStore that, once it holds maxAnimals
+// animals, makes room for a new one by evicting the least recently accessed animal instead of
+// failing the create. Reading an animal by ID and writing it count as access; listing does not.
+// This turns the store into a bounded cache, so it is only suitable when losing data is acceptable.
+func NewEvictingInMemoryAnimalStore(maxAnimals int) *InMemoryAnimalStore {
+	s := NewInMemoryAnimalStore(maxAnimals)
+	s.lru = &lruTracker{order: list.New(), elements: make(map[int]*list.Element)}
+	return s
+}
+
+// touch marks the animal as the most recently accessed. Callers must hold s.animals.mu (a read
+// lock suffices, since the tracker has its own lock).
+func (s *InMemoryAnimalStore) touch(ids ...int) {
+	if s.lru == nil {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	for _, id := range ids {
+		if element, ok := s.lru.elements[id]; ok {
+			s.lru.order.MoveToFront(element)
+		} else {
+			s.lru.elements[id] = s.lru.order.PushFront(id)
+		}
+	}
+}
+
+// forget stops tracking removed animals. Callers must hold s.animals.mu exclusively.
+func (s *InMemoryAnimalStore) forget(ids ...int) {
+	if s.lru == nil {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	for _, id := range ids {
+		if element, ok := s.lru.elements[id]; ok {
+			s.lru.order.Remove(element)
+			delete(s.lru.elements, id)
+		}
+	}
+}
+
+// makeRoom is called when the store is full and another animal is about to be created. With
+// the evict-lru policy it deletes the least recently accessed animal; otherwise it returns
+// ErrCapacityExceeded. Callers must hold s.animals.mu exclusively.
+func (s *InMemoryAnimalStore) makeRoom() error {
+	if s.lru == nil {
+		return ErrCapacityExceeded
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	oldest := s.lru.order.Back()
+	if oldest == nil {
+		return ErrCapacityExceeded
+	}
+	id := oldest.Value.(int)
+	s.lru.order.Remove(oldest)
+	delete(s.lru.elements, id)
+	delete(s.animals.items, id)
+	return nil
+}
+
+// syncLRU brings the tracker in line with the current animals after they were replaced
+// wholesale: IDs that are gone are dropped, and new IDs are added as most recently accessed
+// (in ID order, so the result is deterministic). Callers must hold s.animals.mu exclusively.
+func (s *InMemoryAnimalStore) syncLRU() {
+	if s.lru == nil {
+		return
+	}
+	var gone []int
+	s.lruMu.Lock()
+	for id := range s.lru.elements {
+		if _, ok := s.animals.items[id]; !ok {
+			gone = append(gone, id)
+		}
+	}
+	var added []Animal
+	for id, animal := range s.animals.items {
+		if _, ok := s.lru.elements[id]; !ok {
+			added = append(added, animal)
+		}
+	}
+	s.lruMu.Unlock()
+
+	s.forget(gone...)
+	sortAnimals(added, nil)
+	for _, animal := range added {
+		s.touch(animal.ID)
+	}
+}
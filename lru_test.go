@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvictLRU(t *testing.T) {
+	tests := []struct {
+		name   string
+		access func(store *InMemoryAnimalStore) error // Run on a full store holding 1, 2 and 3, created in that order
+		want   []int                                  // IDs left after animal 4 is created
+	}{
+		{
+			name:   "oldest write is evicted",
+			access: func(*InMemoryAnimalStore) error { return nil },
+			want:   []int{2, 3, 4},
+		},
+		{
+			name: "read by ID counts as access",
+			access: func(store *InMemoryAnimalStore) error {
+				_, err := store.GetAnimalByID(1)
+				return err
+			},
+			want: []int{1, 3, 4},
+		},
+		{
+			name: "batch get counts as access",
+			access: func(store *InMemoryAnimalStore) error {
+				_, _, err := store.GetAnimalsByIDs([]int{2, 1})
+				return err
+			},
+			want: []int{1, 2, 4},
+		},
+		{
+			name:   "update counts as access",
+			access: func(store *InMemoryAnimalStore) error { return store.UpdateAnimal(1, Animal{Name: "Changed"}) },
+			want:   []int{1, 3, 4},
+		},
+		{
+			name: "listing does not count",
+			access: func(store *InMemoryAnimalStore) error {
+				_, err := store.FilterAnimals(AnimalFilter{})
+				return err
+			},
+			want: []int{2, 3, 4},
+		},
+		{
+			name:   "deleted animal is not evicted again",
+			access: func(store *InMemoryAnimalStore) error { return store.DeleteAnimal(2) },
+			want:   []int{1, 3, 4},
+		},
+		{
+			name: "replaced animals are tracked",
+			access: func(store *InMemoryAnimalStore) error {
+				return store.ReplaceAllAnimals([]Animal{{ID: 7, Name: "A"}, {ID: 8, Name: "B"}, {ID: 9, Name: "C"}})
+			},
+			want: []int{4, 8, 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewEvictingInMemoryAnimalStore(3)
+			for id := 1; id <= 3; id++ {
+				if err := store.CreateAnimal(Animal{ID: id, Name: "Animal"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.access(store); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateAnimal(Animal{ID: 4, Name: "Newcomer"}); err != nil {
+				t.Fatalf("create in a full evicting store: %v", err)
+			}
+
+			ids, err := store.GetAnimalIDs(AnimalFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("IDs = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("IDs = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectWithoutEviction(t *testing.T) {
+	store := NewInMemoryAnimalStore(1)
+	if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateAnimal(Animal{ID: 2, Name: "Tiger"}); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("create in a full store: error = %v, want ErrCapacityExceeded", err)
+	}
+	if _, err := store.GetAnimalByID(1); err != nil {
+		t.Errorf("stored animal was evicted: %v", err)
+	}
+}
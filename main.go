@@ -1,14 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"sync" // For thread-safe in-memory store
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"google.golang.org/grpc"
 )
 
 // Animal represents the structure of an animal entry.
@@ -17,280 +31,2182 @@ type Animal struct {
 	Name  string `json:"name"`  // Name of the animal (e.g., "lion")
 	Class string `json:"class"` // Class of the animal (e.g., "mammal")
 	Legs  int    `json:"legs"`  // Number of legs the animal has
+
+	PhotoURL   string `json:"photo_url,omitempty"` // Absolute http(s) URL of a photo, e.g. for thumbnails; optional
+	Endangered bool   `json:"endangered"`          // Whether the species is endangered; false for records stored before the field existed
+
+	Latitude  *float64 `json:"latitude,omitempty"`  // Latitude of the habitat in degrees (-90 to 90); optional, but only together with Longitude
+	Longitude *float64 `json:"longitude,omitempty"` // Longitude of the habitat in degrees (-180 to 180)
+
+	CreatedAt time.Time `json:"created_at"` // When the animal was first stored (managed by the store)
+	UpdatedAt time.Time `json:"updated_at"` // When the animal was last written (managed by the store)
+}
+
+// AnimalFilter describes the criteria used to narrow down a list of animals.
+// The zero value matches every animal.
+type AnimalFilter struct {
+	Classes      []string   // Match animals in any of these classes (OR semantics, case-insensitive); empty means no class filter
+	MinLegs      *int       // Match animals with at least this many legs; nil means no lower bound
+	MaxLegs      *int       // Match animals with at most this many legs; nil means no upper bound
+	NameContains string     // Match animals whose name contains this, case-insensitive; empty means no name filter
+	Endangered   *bool      // Match only endangered (true) or only non-endangered (false) animals; nil means both
+	Sort         []SortKey  // Ordering of the results, most significant key first; ties (and an empty list) fall back to ID order
+	Expr         FilterExpr // Parsed ?filter= expression the animals must satisfy; nil means no expression
+}
+
+// key returns a string that uniquely identifies the filter, e.g. for use as a cache key.
+func (f AnimalFilter) key() string {
+	keys := make([]string, len(f.Sort))
+	for i, k := range f.Sort {
+		keys[i] = k.String()
+	}
+	key := "class=" + strings.Join(f.Classes, ",") + "&sort=" + strings.Join(keys, ",")
+	if f.MinLegs != nil {
+		key += "&min_legs=" + strconv.Itoa(*f.MinLegs)
+	}
+	if f.MaxLegs != nil {
+		key += "&max_legs=" + strconv.Itoa(*f.MaxLegs)
+	}
+	if f.NameContains != "" {
+		key += "&name_contains=" + strconv.Quote(f.NameContains)
+	}
+	if f.Endangered != nil {
+		key += "&endangered=" + strconv.FormatBool(*f.Endangered)
+	}
+	if f.Expr != nil {
+		key += "&filter=" + f.Expr.String()
+	}
+	return key
+}
+
+// matchesAll reports whether the filter has no criteria, so every animal matches it. Sort keys
+// only order the matches and do not count.
+func (f AnimalFilter) matchesAll() bool {
+	return len(f.Classes) == 0 && f.MinLegs == nil && f.MaxLegs == nil && f.NameContains == "" && f.Endangered == nil && f.Expr == nil
+}
+
+// matches reports whether the animal satisfies every criterion of the filter.
+func (f AnimalFilter) matches(animal Animal) bool {
+	if len(f.Classes) > 0 {
+		found := false
+		for _, class := range f.Classes {
+			if strings.EqualFold(animal.Class, class) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if (f.MinLegs != nil && animal.Legs < *f.MinLegs) || (f.MaxLegs != nil && animal.Legs > *f.MaxLegs) {
+		return false
+	}
+	if f.NameContains != "" && !strings.Contains(strings.ToLower(animal.Name), strings.ToLower(f.NameContains)) {
+		return false
+	}
+	if f.Endangered != nil && animal.Endangered != *f.Endangered {
+		return false
+	}
+	if f.Expr != nil && !f.Expr.eval(animal) {
+		return false
+	}
+	return true
+}
+
+// SortKey is a single component of a sort specification.
+type SortKey struct {
+	Field string // One of the sortableFields
+	Desc  bool   // Sort in descending order
+}
+
+// String renders the key in the ?sort= syntax, e.g. "-legs".
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Field
+	}
+	return k.Field
+}
+
+// sortableFields maps each supported sort key to a comparison returning <0, 0 or >0.
+var sortableFields = map[string]func(a, b Animal) int{
+	"id":    func(a, b Animal) int { return a.ID - b.ID },
+	"name":  func(a, b Animal) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"class": func(a, b Animal) int { return strings.Compare(strings.ToLower(a.Class), strings.ToLower(b.Class)) },
+	"legs":  func(a, b Animal) int { return a.Legs - b.Legs },
+}
+
+// parseSortSpec parses a comma-separated sort specification such as "class,-legs".
+// A leading minus sign sorts that key in descending order. Unknown or empty keys are rejected.
+func parseSortSpec(spec string) ([]SortKey, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var keys []SortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := sortableFields[key.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort key %q (supported: id, name, class, legs)", part)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sortAnimals orders animals by the given keys using a stable sort over an ID-ordered slice,
+// so animals that compare equal on every key stay in ID order.
+func sortAnimals(animals []Animal, keys []SortKey) {
+	sort.Slice(animals, func(i, j int) bool { return animals[i].ID < animals[j].ID })
+	if len(keys) == 0 {
+		return
+	}
+	sort.SliceStable(animals, func(i, j int) bool { return compareAnimals(animals[i], animals[j], keys) < 0 })
+}
+
+// sortByUpdatedAt orders animals by when they were last written, oldest first (then by ID).
+func sortByUpdatedAt(animals []Animal) {
+	sort.Slice(animals, func(i, j int) bool {
+		if !animals[i].UpdatedAt.Equal(animals[j].UpdatedAt) {
+			return animals[i].UpdatedAt.Before(animals[j].UpdatedAt)
+		}
+		return animals[i].ID < animals[j].ID
+	})
+}
+
+// compareAnimals compares two animals by the given sort keys, returning <0, 0 or >0.
+func compareAnimals(a, b Animal, keys []SortKey) int {
+	for _, key := range keys {
+		c := sortableFields[key.Field](a, b)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
 }
 
 // AnimalStore defines the interface for animal data operations.
 // This abstraction makes it easier to switch between different storage implementations (e.g., in-memory, database).
 type AnimalStore interface {
 	GetAllAnimals() ([]Animal, error)
+	FilterAnimals(filter AnimalFilter) ([]Animal, error)                  // Returns the animals matching the filter, in the filter's sort order
+	GetAnimalIDs(filter AnimalFilter) ([]int, error)                      // Like FilterAnimals, but only the IDs, without copying (or decoding) whole records
+	StreamAnimals(filter AnimalFilter, fn func(Animal) error) error       // Like FilterAnimals, but hands animals to fn one at a time; stops at fn's first error
+	ForEach(ctx context.Context, fn func(Animal) error) error             // Hands every animal to fn in ID order without building a slice; stops at fn's first error or when ctx is done
+	GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) // Buckets matching animals by class, each bucket ordered by ID
+	Facets(filter AnimalFilter) ([]Facet, error)                          // Per-class counts (and a few names) of matching animals, largest count first
+	Analytics(filter AnimalFilter) (AnalyticsResult, error)               // Count, min/max/average legs and per-class counts of matching animals, in one pass
+	FuzzySearch(query string, maxDistance int) ([]Animal, error)          // Animals whose name is within maxDistance edits of query, closest first
+	SampleAnimals(filter AnimalFilter, n int) ([]Animal, error)           // Up to n distinct matching animals chosen uniformly at random, in random order
+	Query(q AnimalQuery) (page []Animal, total int, err error)            // The requested page of matching animals, and how many match in all
 	GetAnimalByID(id int) (*Animal, error)
+	GetAnimalsByIDs(ids []int) (found []Animal, missing []int, err error) // Bulk lookup: the animals that exist and the IDs that don't
+	GetAnimalsModifiedSince(t time.Time) ([]Animal, error)                // Animals with UpdatedAt after t, oldest change first; for incremental sync
+	LatestModified() (time.Time, error)                                   // When the data last changed, without scanning it; zero for an empty store
+	NextID() (int, error)                                                 // Advisory: an ID that is currently free (max ID + 1) and not reserved
+	ReserveID() (id int, expiresAt time.Time, err error)                  // Holds back a free ID for a later create; only ReservingAnimalStore keeps reservations
 	CreateAnimal(animal Animal) error
-	UpdateAnimal(id int, animal Animal) error // For PUT: updates if exists
-	UpsertAnimal(id int, animal Animal) error // For PUT: creates if not exists, updates if exists
+	UpdateAnimal(id int, animal Animal) error              // For PUT: updates if exists
+	UpsertAnimal(id int, animal Animal) error              // For PUT: creates if not exists, updates if exists
+	UpsertAnimals(animals []Animal) ([]BatchResult, error) // Atomically upserts each animal under its own ID; one result per animal, in order
 	DeleteAnimal(id int) error
+	DeleteAnimals(ids []int) (deleted []int, notFound []int, err error) // Bulk delete: reports which IDs were removed and which were missing
+	ReplaceAllAnimals(animals []Animal) error                           // Atomically replaces the whole dataset
+	ReclassifyAnimals(from, to string) (int, error)                     // Atomically moves every animal of class from (case-insensitive) to class to; returns how many changed
+	SetEndangered(id int, endangered bool) error                        // Sets only the Endangered flag; ErrNotFound if the animal does not exist
+
+	// CreateAnimalAssigningID stores the animal under the next free ID, the highest stored ID
+	// plus one moved past every ID skip reports (e.g. reserved ones; nil skips none), and
+	// returns it as stored, with that ID and its timestamps. The ID is chosen under the same
+	// lock or transaction as the insert, so concurrent creates never pick the same one.
+	CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error)
+
+	// UpsertAnimalIf is UpsertAnimal with a precondition for concurrent writers: an existing
+	// animal is only overwritten if matches accepts its current state, e.g. its version, and the
+	// check is atomic with the write. It fails with ErrVersionMismatch otherwise. A missing animal
+	// is created without consulting matches, and a nil matches accepts everything.
+	UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (created bool, err error)
+
+	// DeleteAnimalIfVersion is DeleteAnimal for a known version of the animal: it is only
+	// deleted while animalVersion of the stored animal equals version, checked atomically with
+	// the delete, and the call fails with ErrVersionMismatch otherwise. A missing animal is
+	// ErrNotFound.
+	DeleteAnimalIfVersion(id int, version int64) error
+
+	// MergeAnimals folds a duplicate into another animal atomically: the target is overwritten
+	// with merge(source, target), keeping its ID and CreatedAt, and the source is deleted. It
+	// returns the merged animal as stored, ErrNotFound if either animal does not exist, and
+	// ErrSameAnimal if both IDs are the same.
+	MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error)
+
+	// WithTransaction runs fn against a transactional view of the store. Changes made through
+	// that view are committed together when fn returns nil and discarded when it returns an
+	// error (or ctx is cancelled). fn must only use the store it is given, never the outer one.
+	WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error
+}
+
+// contextBinder is implemented by context-aware store decorators (e.g. tracing) that can be
+// bound to a request's context without changing the AnimalStore method signatures.
+type contextBinder interface {
+	WithContext(ctx context.Context) AnimalStore
 }
 
-// InMemoryAnimalStore implements AnimalStore using a map in memory.
+// bindContext returns the store bound to ctx if it is context-aware, or the store itself otherwise.
+func bindContext(store AnimalStore, ctx context.Context) AnimalStore {
+	if binder, ok := store.(contextBinder); ok {
+		return binder.WithContext(ctx)
+	}
+	return store
+}
+
+// Errors returned by AnimalStore implementations, usually wrapped with the animal ID for context.
+// Callers test for them with errors.Is rather than by matching error messages.
+var (
+	ErrNotFound         = errors.New("not found")               // No animal has the requested ID
+	ErrEmpty            = errors.New("no animals found")        // The store holds no animals at all
+	ErrAlreadyExists    = errors.New("already exists")          // A create collided with an existing ID
+	ErrCapacityExceeded = errors.New("store capacity exceeded") // A create would grow a store beyond its configured maximum size
+	ErrVetoed           = errors.New("vetoed by hook")          // A Before-hook rejected the mutation (see HookedAnimalStore)
+	ErrVersionMismatch  = errors.New("version mismatch")        // A conditional write found the animal at another version than expected
+	ErrSameAnimal       = errors.New("merge into itself")       // A merge named the same animal as source and target
+)
+
+// errInvalidID is returned by parseID for a path ID that is not a positive integer.
+var errInvalidID = errors.New("animal ID must be a positive integer")
+
+// InMemoryAnimalStore implements AnimalStore on a MemoryStore of animals keyed by ID, adding
+// the animal-specific rules: capacity, timestamps, ID assignment and LRU tracking. The
+// MemoryStore is a named field rather than embedded, so its plain CRUD methods, which know
+// nothing of those rules, are not part of the animal store's method set.
 type InMemoryAnimalStore struct {
-	animals map[int]Animal // Stores animals by their ID
-	mu      sync.Mutex     // Mutex to protect access to the animals map for thread safety
-	nextID  int            // For auto-generating IDs if needed (though problem implies ID comes from payload)
+	animals    *MemoryStore[int, Animal] // The animals by ID, and the lock protecting them
+	nextID     int                       // For auto-generating IDs if needed (though problem implies ID comes from payload)
+	maxAnimals int                       // Maximum number of stored animals; 0 means unlimited
+
+	lru   *lruTracker // Access order for the evict-lru capacity policy; nil means creates fail when full
+	lruMu sync.Mutex  // Protects lru, which reads update while holding only the read lock
+
+	modified watermark // When a write last changed the data
 }
 
-// NewInMemoryAnimalStore creates and initializes a new InMemoryAnimalStore.
-func NewInMemoryAnimalStore() *InMemoryAnimalStore {
+// watermark records when a store's data last changed, so LatestModified needs no scan. It only
+// moves forward and is safe for concurrent use. Deletes advance it too, although they leave no
+// UpdatedAt behind, so it may be later than every stored animal's UpdatedAt.
+type watermark struct {
+	nanos atomic.Int64 // Unix nanoseconds; 0 means never
+}
+
+// advance moves the watermark to t unless it is already later.
+func (m *watermark) advance(t time.Time) {
+	for {
+		current := m.nanos.Load()
+		if t.UnixNano() <= current || m.nanos.CompareAndSwap(current, t.UnixNano()) {
+			return
+		}
+	}
+}
+
+// time returns the watermark, or the zero time if no write has happened yet.
+func (m *watermark) time() time.Time {
+	if nanos := m.nanos.Load(); nanos != 0 {
+		return time.Unix(0, nanos).UTC()
+	}
+	return time.Time{}
+}
+
+// NewInMemoryAnimalStore creates and initializes a new InMemoryAnimalStore holding at most
+// maxAnimals animals (0 means unlimited).
+func NewInMemoryAnimalStore(maxAnimals int) *InMemoryAnimalStore {
 	return &InMemoryAnimalStore{
-		animals: make(map[int]Animal),
-		nextID:  1, // Start ID from 1
+		animals:    NewMemoryStore(animalID),
+		nextID:     1, // Start ID from 1
+		maxAnimals: maxAnimals,
 	}
 }
 
+// animalID is the key function of animal MemoryStores.
+func animalID(animal Animal) int { return animal.ID }
+
+// full reports whether the store has reached its capacity. Callers must hold s.animals.mu.
+func (s *InMemoryAnimalStore) full() bool {
+	return s.maxAnimals > 0 && len(s.animals.items) >= s.maxAnimals
+}
+
 // GetAllAnimals retrieves all animals from the store.
 func (s *InMemoryAnimalStore) GetAllAnimals() ([]Animal, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	all := s.animals.Values()
+	if len(all) == 0 {
+		return nil, ErrEmpty // Indicate no animals exist
+	}
+	return all, nil
+}
 
-	if len(s.animals) == 0 {
-		return nil, fmt.Errorf("no animals found") // Indicate no animals exist
+// FilterAnimals retrieves all animals matching the filter, ordered by its sort keys (ID by default).
+// Like GetAllAnimals it returns an error when the store is empty; a filter that
+// matches nothing yields an empty slice instead.
+func (s *InMemoryAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	if len(s.animals.items) == 0 {
+		return nil, ErrEmpty
 	}
 
-	var all []Animal
-	for _, animal := range s.animals {
-		all = append(all, animal)
+	matched := []Animal{}
+	for _, animal := range s.animals.items {
+		if filter.matches(animal) {
+			matched = append(matched, animal)
+		}
 	}
-	return all, nil
+	sortAnimals(matched, filter.Sort)
+	return matched, nil
 }
 
-// GetAnimalByID retrieves a single animal by its ID.
-func (s *InMemoryAnimalStore) GetAnimalByID(id int) (*Animal, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetAnimalIDs returns the IDs of the animals matching the filter, in the filter's sort order,
+// without copying any animal. Like FilterAnimals it returns ErrEmpty when the store is empty.
+func (s *InMemoryAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
 
-	animal, ok := s.animals[id]
-	if !ok {
-		return nil, fmt.Errorf("animal with ID %d not found", id)
+	if len(s.animals.items) == 0 {
+		return nil, ErrEmpty
 	}
-	return &animal, nil
+
+	ids := []int{}
+	for id, animal := range s.animals.items {
+		if filter.matches(animal) {
+			ids = append(ids, id)
+		}
+	}
+	sortAnimalIDs(ids, filter.Sort, func(id int) Animal { return s.animals.items[id] })
+	return ids, nil
 }
 
-// CreateAnimal adds a new animal to the store.
-// Returns an error if an animal with the same ID already exists.
-func (s *InMemoryAnimalStore) CreateAnimal(animal Animal) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// StreamAnimals calls fn for every animal matching the filter, in the filter's sort order,
+// without building a slice of animals. Only the matching IDs are collected for ordering.
+// The read lock is held while fn runs, so writers wait until the stream completes.
+// Like FilterAnimals it returns an error, without calling fn, when the store is empty.
+func (s *InMemoryAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
 
-	if animal.ID == 0 {
-		// If ID is not provided (0 value), generate one.
-		// NOTE: The problem statement implies ID is usually provided in the payload for POST.
-		// This is a fallback for robustness.
-		animal.ID = s.nextID
-		s.nextID++
-	} else if _, exists := s.animals[animal.ID]; exists {
-		return fmt.Errorf("animal with ID %d already exists", animal.ID)
+	if len(s.animals.items) == 0 {
+		return ErrEmpty
+	}
+
+	var ids []int
+	for id, animal := range s.animals.items {
+		if filter.matches(animal) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if len(filter.Sort) > 0 {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return compareAnimals(s.animals.items[ids[i]], s.animals.items[ids[j]], filter.Sort) < 0
+		})
 	}
 
-	s.animals[animal.ID] = animal
+	for _, id := range ids {
+		if err := fn(s.animals.items[id]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// UpdateAnimal updates an existing animal in the store.
-// Returns an error if the animal with the specified ID does not exist.
-func (s *InMemoryAnimalStore) UpdateAnimal(id int, animal Animal) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ForEach calls fn for every animal in ID order, without building a slice of animals; only
+// the IDs are collected for ordering. As in StreamAnimals the read lock is held while fn runs.
+func (s *InMemoryAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
 
-	if _, exists := s.animals[id]; !exists {
-		return fmt.Errorf("animal with ID %d not found for update", id)
+	ids := make([]int, 0, len(s.animals.items))
+	for id := range s.animals.items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(s.animals.items[id]); err != nil {
+			return err
+		}
 	}
-	// Ensure the ID in the payload matches the path ID
-	animal.ID = id
-	s.animals[id] = animal
 	return nil
 }
 
-// UpsertAnimal updates an existing animal or creates a new one if it doesn't exist.
-func (s *InMemoryAnimalStore) UpsertAnimal(id int, animal Animal) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Query returns the requested page of the animals matching the query's filter, and how many match.
+func (s *InMemoryAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	matched, err := s.FilterAnimals(q.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return q.Page.apply(matched), len(matched), nil
+}
 
-	animal.ID = id // Ensure the ID from the path is used
-	s.animals[id] = animal
-	return nil
+// SampleAnimals returns up to n distinct animals matching the filter, chosen uniformly at random
+// by reservoir sampling so only n animals are held however large the store is. The filter's
+// sort keys are ignored. Like FilterAnimals it returns ErrEmpty when the store is empty.
+func (s *InMemoryAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	if len(s.animals.items) == 0 {
+		return nil, ErrEmpty
+	}
+	sample := newReservoir(n)
+	for _, animal := range s.animals.items {
+		if filter.matches(animal) {
+			sample.add(animal)
+		}
+	}
+	return sample.result(), nil
 }
 
-// DeleteAnimal removes an animal from the store by its ID.
-// Returns an error if the animal with the specified ID does not exist.
-func (s *InMemoryAnimalStore) DeleteAnimal(id int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GroupAnimalsByClass buckets the animals matching the filter by their class, with each
+// bucket ordered by ID. The filter's sort keys are ignored. Classes without matching animals
+// are absent from the map, and an empty store yields an empty map.
+func (s *InMemoryAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
 
-	if _, exists := s.animals[id]; !exists {
-		return fmt.Errorf("animal with ID %d not found for deletion", id)
+	groups := make(map[string][]Animal)
+	for _, animal := range s.animals.items {
+		if filter.matches(animal) {
+			groups[animal.Class] = append(groups[animal.Class], animal)
+		}
 	}
-	delete(s.animals, id)
-	return nil
+	for _, bucket := range groups {
+		sortAnimals(bucket, nil)
+	}
+	return groups, nil
 }
 
-// --- HTTP Handlers ---
+// Facets counts the animals matching the filter per class in one pass under the read lock. An
+// empty store yields no facets.
+func (s *InMemoryAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
 
-// getAnimalsHandler handles GET requests for all animals.
-func getAnimalsHandler(store AnimalStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		animals, err := store.GetAllAnimals()
-		if err != nil {
-			// If no animals found, return 404 Not Found as per problem statement
-			if err.Error() == "no animals found" {
-				http.Error(w, "No animals found in the system", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	counter := newFacetCounter()
+	for _, animal := range s.animals.items {
+		if filter.matches(animal) {
+			counter.add(animal)
 		}
-		json.NewEncoder(w).Encode(animals)
 	}
+	return counter.result(), nil
 }
 
-// getAnimalHandler handles GET requests for a single animal by ID.
-func getAnimalHandler(store AnimalStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		params := mux.Vars(r)
-		id, err := strconv.Atoi(params["id"])
-		if err != nil {
-			http.Error(w, "Invalid animal ID", http.StatusBadRequest)
-			return
+// Analytics aggregates the legs of the animals matching the filter in one pass under the read
+// lock. An empty store yields zeros.
+func (s *InMemoryAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	acc := newAnalyticsAccumulator()
+	for _, animal := range s.animals.items {
+		if filter.matches(animal) {
+			acc.add(animal)
 		}
+	}
+	return acc.analytics(), nil
+}
 
-		animal, err := store.GetAnimalByID(id)
-		if err != nil {
-			// If animal not found, return 404 Not Found
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
+// FuzzySearch returns the animals whose name is within maxDistance Levenshtein edits of the
+// query (case-insensitive), ordered by ascending distance and then ID. It compares the query
+// against every name, so it is O(n) in the number of animals times the name lengths.
+// Like FilterAnimals it returns an error when the store is empty.
+func (s *InMemoryAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	all := s.animals.Values()
+	if len(all) == 0 {
+		return nil, ErrEmpty
+	}
+	return rankByDistance(all, query, maxDistance), nil
+}
+
+// GetAnimalByID retrieves a single animal by its ID.
+func (s *InMemoryAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	animal, ok := s.animals.items[id]
+	if !ok {
+		return nil, fmt.Errorf("animal with ID %d %w", id, ErrNotFound)
+	}
+	s.touch(id)
+	return &animal, nil
+}
+
+// LatestModified returns when a write last changed the data, or the zero time if the store is empty.
+func (s *InMemoryAnimalStore) LatestModified() (time.Time, error) {
+	if s.animals.Len() == 0 {
+		return time.Time{}, nil
+	}
+	return s.modified.time(), nil
+}
+
+// GetAnimalsModifiedSince returns the animals written after t, ordered by UpdatedAt (then ID).
+// Like FilterAnimals it returns ErrEmpty when the store is empty.
+func (s *InMemoryAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	if len(s.animals.items) == 0 {
+		return nil, ErrEmpty
+	}
+	modified := []Animal{}
+	for _, animal := range s.animals.items {
+		if animal.UpdatedAt.After(t) {
+			modified = append(modified, animal)
 		}
-		json.NewEncoder(w).Encode(animal)
 	}
+	sortByUpdatedAt(modified)
+	return modified, nil
 }
 
-// createAnimalHandler handles POST requests to create a new animal.
-// Denies duplicate entries based on ID.
-func createAnimalHandler(store AnimalStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		var animal Animal
-		if err := json.NewDecoder(r.Body).Decode(&animal); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
+// GetAnimalsByIDs looks up several animals under a single lock. Found animals and missing IDs
+// are returned in request order; duplicate IDs are only processed once.
+func (s *InMemoryAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+
+	found := []Animal{}
+	missing := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
 		}
+		seen[id] = true
 
-		// Ensure ID is provided and valid for creation
-		if animal.ID == 0 {
-			http.Error(w, "Animal ID is required for creation", http.StatusBadRequest)
-			return
+		if animal, ok := s.animals.items[id]; ok {
+			found = append(found, animal)
+			s.touch(id)
+		} else {
+			missing = append(missing, id)
 		}
+	}
+	return found, missing, nil
+}
 
-		// Check if animal with this ID already exists to deny duplicate entry
-		_, err := store.GetAnimalByID(animal.ID)
-		if err == nil { // No error means animal found
-			http.Error(w, fmt.Sprintf("Animal with ID %d already exists", animal.ID), http.StatusConflict) // 409 Conflict
-			return
+// NextID returns the current highest ID plus one (1 for an empty store). It is advisory
+// only: another client may take the ID before it is used.
+func (s *InMemoryAnimalStore) NextID() (int, error) {
+	s.animals.mu.RLock()
+	defer s.animals.mu.RUnlock()
+	return s.nextFreeID(), nil
+}
+
+// nextFreeID returns the highest stored ID plus one (1 for an empty store). Callers must hold s.animals.mu.
+func (s *InMemoryAnimalStore) nextFreeID() int {
+	next := 1
+	for id := range s.animals.items {
+		if id >= next {
+			next = id + 1
 		}
+	}
+	return next
+}
 
-		// Attempt to create the animal
-		if err := store.CreateAnimal(animal); err != nil {
-			// Specific check for "already exists" error from store is redundant here due to prior check,
-			// but good for other potential store errors.
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+// ReserveID is not supported by the store itself; see ReservingAnimalStore.
+func (s *InMemoryAnimalStore) ReserveID() (int, time.Time, error) {
+	return 0, time.Time{}, errReservationsUnsupported
+}
+
+// CreateAnimal adds a new animal to the store.
+// Returns ErrAlreadyExists if an animal with the same ID already exists, or ErrCapacityExceeded if the store is full.
+// The existence check and the insert happen under one lock, so concurrent creates of an ID cannot both succeed.
+func (s *InMemoryAnimalStore) CreateAnimal(animal Animal) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	if animal.ID != 0 {
+		if _, exists := s.animals.items[animal.ID]; exists {
+			return fmt.Errorf("animal with ID %d %w", animal.ID, ErrAlreadyExists)
+		}
+	}
+	// Checked under the same lock as the insert, so concurrent creates cannot overshoot the limit
+	if s.full() {
+		if err := s.makeRoom(); err != nil {
+			return err
 		}
+	}
 
-		w.WriteHeader(http.StatusCreated) // 201 Created
-		json.NewEncoder(w).Encode(animal)
+	if animal.ID == 0 {
+		// If ID is not provided (0 value), generate one, skipping IDs that were created explicitly
+		for s.animals.items[s.nextID].ID != 0 {
+			s.nextID++
+		}
+		animal.ID = s.nextID
+		s.nextID++
 	}
+
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	s.animals.items[animal.ID] = animal
+	s.touch(animal.ID)
+	s.modified.advance(now)
+	return nil
 }
 
-// updateAnimalHandler handles PUT requests to update an existing animal or create a new one (upsert).
-func updateAnimalHandler(store AnimalStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		params := mux.Vars(r)
-		id, err := strconv.Atoi(params["id"])
-		if err != nil {
-			http.Error(w, "Invalid animal ID in path", http.StatusBadRequest)
-			return
-		}
+// CreateAnimalAssigningID stores the animal under the highest stored ID plus one, moved past the
+// IDs skip reports, choosing the ID under the same lock as the insert.
+func (s *InMemoryAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
 
-		var animal Animal
-		if err := json.NewDecoder(r.Body).Decode(&animal); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
+	if s.full() {
+		if err := s.makeRoom(); err != nil {
+			return animal, err
 		}
+	}
+	animal.ID = s.nextFreeID()
+	for skip != nil && skip(animal.ID) {
+		animal.ID++
+	}
 
-		// Ensure the ID from the path is used for the operation, ignoring ID in body if different
-		animal.ID = id
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	s.animals.items[animal.ID] = animal
+	s.touch(animal.ID)
+	s.modified.advance(now)
+	return animal, nil
+}
 
-		// Check if the animal exists to determine if it's an update or create
-		_, existsErr := store.GetAnimalByID(id)
+// UpdateAnimal updates an existing animal in the store.
+// Returns an error if the animal with the specified ID does not exist.
+func (s *InMemoryAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
 
-		if existsErr == nil {
-			// Animal exists, perform update
-			if err := store.UpdateAnimal(id, animal); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.WriteHeader(http.StatusOK) // 200 OK for update
-			json.NewEncoder(w).Encode(animal)
-		} else {
-			// Animal does not exist, perform creation (upsert)
-			if err := store.UpsertAnimal(id, animal); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+	existing, exists := s.animals.items[id]
+	if !exists {
+		return fmt.Errorf("animal with ID %d %w for update", id, ErrNotFound)
+	}
+	// Ensure the ID in the payload matches the path ID
+	animal.ID = id
+	animal.CreatedAt = existing.CreatedAt
+	animal.UpdatedAt = time.Now().UTC()
+	s.animals.items[id] = animal
+	s.touch(id)
+	s.modified.advance(animal.UpdatedAt)
+	return nil
+}
+
+// UpsertAnimal updates an existing animal or creates a new one if it doesn't exist.
+// The existing record is read under the same lock so that retried upserts keep the
+// original CreatedAt; only genuinely new records get a fresh CreatedAt. UpdatedAt is always bumped.
+// Creating a new record in a full store fails with ErrCapacityExceeded.
+func (s *InMemoryAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	_, err := s.UpsertAnimalIf(id, animal, nil)
+	return err
+}
+
+// UpsertAnimalIf upserts like UpsertAnimal, but an existing animal is only overwritten if
+// matches (when non-nil) accepts it, decided under the same lock as the write, so no other
+// write can come in between; otherwise it fails with ErrVersionMismatch. A missing animal is
+// created without consulting matches.
+func (s *InMemoryAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	now := time.Now().UTC()
+	animal.ID = id // Ensure the ID from the path is used
+	existing, exists := s.animals.items[id]
+	if exists {
+		if matches != nil && !matches(existing) {
+			return false, fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+		}
+		animal.CreatedAt = existing.CreatedAt
+	} else {
+		if s.full() {
+			if err := s.makeRoom(); err != nil {
+				return false, err
 			}
-			w.WriteHeader(http.StatusCreated) // 201 Created for new resource
-			json.NewEncoder(w).Encode(animal)
 		}
+		animal.CreatedAt = now
 	}
+	animal.UpdatedAt = now
+	s.animals.items[id] = animal
+	s.touch(id)
+	s.modified.advance(now)
+	return !exists, nil
 }
 
-// deleteAnimalHandler handles DELETE requests to delete an animal by ID.
-func deleteAnimalHandler(store AnimalStore) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		params := mux.Vars(r)
-		id, err := strconv.Atoi(params["id"])
-		if err != nil {
-			http.Error(w, "Invalid animal ID", http.StatusBadRequest)
-			return
-		}
+// UpsertAnimals upserts every animal under its own ID while holding the lock once, as
+// UpsertAnimal would one at a time. The batch is checked before anything is written: repeated
+// IDs fail with ErrAlreadyExists, and creates that would overfill the store (or, with eviction,
+// a batch larger than the store) with ErrCapacityExceeded.
+func (s *InMemoryAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	if err := checkUpsertBatch(animals); err != nil {
+		return nil, err
+	}
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
 
-		if err := store.DeleteAnimal(id); err != nil {
-			// If animal not found for deletion, return 404 Not Found
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
+	creates := 0
+	for _, animal := range animals {
+		if _, exists := s.animals.items[animal.ID]; !exists {
+			creates++
 		}
+	}
+	if s.maxAnimals > 0 && len(s.animals.items)+creates > s.maxAnimals && (s.lru == nil || len(animals) > s.maxAnimals) {
+		return nil, ErrCapacityExceeded
+	}
 
-		w.WriteHeader(http.StatusNoContent) // 204 No Content for successful deletion
+	now := time.Now().UTC()
+	results := make([]BatchResult, 0, len(animals))
+	for _, animal := range animals {
+		result := batchUpdated
+		if existing, exists := s.animals.items[animal.ID]; exists {
+			animal.CreatedAt = existing.CreatedAt
+		} else {
+			if s.full() {
+				if err := s.makeRoom(); err != nil {
+					return nil, err
+				}
+			}
+			animal.CreatedAt = now
+			result = batchCreated
+		}
+		animal.UpdatedAt = now
+		s.animals.items[animal.ID] = animal
+		s.touch(animal.ID)
+		results = append(results, BatchResult{ID: animal.ID, Result: result})
 	}
+	if len(animals) > 0 {
+		s.modified.advance(now)
+	}
+	return results, nil
 }
 
-func main() {
-	// Initialize the in-memory animal store
-	animalStore := NewInMemoryAnimalStore()
+// DeleteAnimal removes an animal from the store by its ID.
+// Returns an error if the animal with the specified ID does not exist.
+func (s *InMemoryAnimalStore) DeleteAnimal(id int) error {
+	return s.deleteAnimalIf(id, nil)
+}
 
-	// Add some initial dummy data
-	_ = animalStore.CreateAnimal(Animal{ID: 1, Name: "lion", Class: "mammal", Legs: 4})
-	_ = animalStore.CreateAnimal(Animal{ID: 2, Name: "eagle", Class: "bird", Legs: 2})
-	_ = animalStore.CreateAnimal(Animal{ID: 3, Name: "snake", Class: "reptile", Legs: 0})
+// DeleteAnimalIfVersion deletes like DeleteAnimal, but only the given version of the animal.
+func (s *InMemoryAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return s.deleteAnimalIf(id, func(current Animal) bool { return animalVersion(current) == version })
+}
 
-	r := mux.NewRouter()
+// deleteAnimalIf deletes like DeleteAnimal, but only if matches (when non-nil) accepts the
+// animal, decided under the same lock as the delete; otherwise it fails with ErrVersionMismatch.
+func (s *InMemoryAnimalStore) deleteAnimalIf(id int, matches func(current Animal) bool) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
 
-	// Define API routes with a /v1/animals prefix
-	r.HandleFunc("/v1/animals", getAnimalsHandler(animalStore)).Methods("GET")
-	r.HandleFunc("/v1/animals/{id}", getAnimalHandler(animalStore)).Methods("GET")
-	r.HandleFunc("/v1/animals", createAnimalHandler(animalStore)).Methods("POST")
-	r.HandleFunc("/v1/animals/{id}", updateAnimalHandler(animalStore)).Methods("PUT")
-	r.HandleFunc("/v1/animals/{id}", deleteAnimalHandler(animalStore)).Methods("DELETE")
+	existing, exists := s.animals.items[id]
+	if !exists {
+		return fmt.Errorf("animal with ID %d %w for deletion", id, ErrNotFound)
+	}
+	if matches != nil && !matches(existing) {
+		return fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+	}
+	delete(s.animals.items, id)
+	s.forget(id)
+	s.modified.advance(time.Now().UTC())
+	return nil
+}
+
+// DeleteAnimals removes every animal whose ID is listed, under a single lock.
+// It returns the IDs that were deleted and the IDs that were not found; duplicate IDs are only processed once.
+func (s *InMemoryAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	deleted := []int{}
+	notFound := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if _, exists := s.animals.items[id]; !exists {
+			notFound = append(notFound, id)
+			continue
+		}
+		delete(s.animals.items, id)
+		deleted = append(deleted, id)
+	}
+	s.forget(deleted...)
+	if len(deleted) > 0 {
+		s.modified.advance(time.Now().UTC())
+	}
+	return deleted, notFound, nil
+}
+
+// SetEndangered sets the Endangered flag of an existing animal, bumping its UpdatedAt and
+// leaving every other field as it is. Returns ErrNotFound if the animal does not exist.
+func (s *InMemoryAnimalStore) SetEndangered(id int, endangered bool) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	animal, exists := s.animals.items[id]
+	if !exists {
+		return fmt.Errorf("animal with ID %d %w", id, ErrNotFound)
+	}
+	animal.Endangered = endangered
+	animal.UpdatedAt = time.Now().UTC()
+	s.animals.items[id] = animal
+	s.touch(id)
+	s.modified.advance(animal.UpdatedAt)
+	return nil
+}
+
+// MergeAnimals stores the merged target and deletes the source under a single lock.
+func (s *InMemoryAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	if err := checkMergeIDs(sourceID, targetID); err != nil {
+		return Animal{}, err
+	}
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	source, exists := s.animals.items[sourceID]
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", sourceID, ErrNotFound)
+	}
+	target, exists := s.animals.items[targetID]
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", targetID, ErrNotFound)
+	}
+	merged := mergedRecord(source, target, merge, time.Now().UTC())
+	s.animals.items[targetID] = merged
+	delete(s.animals.items, sourceID)
+	s.touch(targetID)
+	s.forget(sourceID)
+	s.modified.advance(merged.UpdatedAt)
+	return merged, nil
+}
+
+// ReclassifyAnimals moves every animal whose class matches from (case-insensitively) to class
+// to under a single lock, bumping their UpdatedAt, and returns how many changed.
+func (s *InMemoryAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+	now := time.Now().UTC()
+	changed := reclassify(s.animals.items, from, to, now)
+	if changed > 0 {
+		s.modified.advance(now)
+	}
+	return changed, nil
+}
+
+// reclassify rewrites the class of the matching animals in place. Animals already in class to
+// (with the same spelling) are left untouched and not counted. Callers must hold the map's lock.
+func reclassify(animals map[int]Animal, from, to string, now time.Time) int {
+	changed := 0
+	for id, animal := range animals {
+		if strings.EqualFold(animal.Class, from) && animal.Class != to {
+			animal.Class = to
+			animal.UpdatedAt = now
+			animals[id] = animal
+			changed++
+		}
+	}
+	return changed
+}
+
+// ReplaceAllAnimals atomically discards every stored animal and stores the given ones instead.
+// Animals without an ID are assigned one from the restarted ID counter.
+// A dataset larger than the store's capacity is rejected with ErrCapacityExceeded.
+func (s *InMemoryAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	if s.maxAnimals > 0 && len(animals) > s.maxAnimals {
+		return ErrCapacityExceeded
+	}
+
+	now := time.Now().UTC()
+	replaced := make(map[int]Animal, len(animals))
+	nextID := 1
+	for _, animal := range animals {
+		if animal.ID >= nextID {
+			nextID = animal.ID + 1
+		}
+	}
+	for _, animal := range animals {
+		if animal.ID == 0 {
+			animal.ID = nextID
+			nextID++
+		}
+		if _, exists := replaced[animal.ID]; exists {
+			return fmt.Errorf("animal with ID %d %w", animal.ID, ErrAlreadyExists)
+		}
+		animal.CreatedAt = now
+		animal.UpdatedAt = now
+		replaced[animal.ID] = animal
+	}
+
+	s.animals.items = replaced
+	s.nextID = nextID
+	s.syncLRU()
+	s.modified.advance(now)
+	return nil
+}
+
+// WithTransaction holds the store's exclusive lock for the whole of fn, which therefore
+// sees a serializable view: no other reader or writer runs until it finishes. fn operates on
+// a private copy of the data that replaces the live data only if fn succeeds and ctx is
+// still active, so an error rolls back every change. Copying makes a transaction O(n).
+// Creates inside a transaction never evict: in a full store they fail with ErrCapacityExceeded.
+func (s *InMemoryAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	s.animals.mu.Lock()
+	defer s.animals.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &InMemoryAnimalStore{animals: s.animals.clone(), nextID: s.nextID, maxAnimals: s.maxAnimals}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.animals.items = tx.animals.items
+	s.nextID = tx.nextID
+	s.syncLRU()
+	s.modified.advance(tx.modified.time())
+	return nil
+}
+
+// seedAnimals returns the built-in seed dataset, used when no -seed-file is configured.
+func seedAnimals() []Animal {
+	return []Animal{
+		{ID: 1, Name: "lion", Class: "mammal", Legs: 4},
+		{ID: 2, Name: "eagle", Class: "bird", Legs: 2},
+		{ID: 3, Name: "snake", Class: "reptile", Legs: 0},
+	}
+}
+
+// --- HTTP Handlers ---
+
+// isDryRun reports whether the request asks for a dry run via ?dry_run=true.
+// A dry run performs all validation and conflict checks but never mutates the store.
+func isDryRun(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("dry_run")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseClassFilter collects the repeated ?class= query parameters into a
+// lowercased, deduplicated list. Blank values are ignored.
+func parseClassFilter(r *http.Request) []string {
+	var classes []string
+	seen := make(map[string]bool)
+	for _, value := range r.URL.Query()["class"] {
+		class := strings.ToLower(strings.TrimSpace(value))
+		if class == "" || seen[class] {
+			continue
+		}
+		seen[class] = true
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// fuzzySearch runs a fuzzy name search and narrows the ranked result with the filter's criteria.
+// The ranking by distance is kept, so the filter's sort keys do not apply.
+func fuzzySearch(store AnimalStore, query string, maxDistance int, filter AnimalFilter) ([]Animal, error) {
+	ranked, err := store.FuzzySearch(query, maxDistance)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []Animal{}
+	for _, animal := range ranked {
+		if filter.matches(animal) {
+			matched = append(matched, animal)
+		}
+	}
+	return matched, nil
+}
+
+// modifiedAnimals returns the animals written after since that also match the filter, in the
+// store's UpdatedAt order; the filter's sort keys are ignored.
+func modifiedAnimals(store AnimalStore, since time.Time, filter AnimalFilter) ([]Animal, error) {
+	modified, err := store.GetAnimalsModifiedSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []Animal{}
+	for _, animal := range modified {
+		if filter.matches(animal) {
+			matched = append(matched, animal)
+		}
+	}
+	return matched, nil
+}
+
+// acceptsMediaType reports whether the request's Accept header explicitly lists the media type.
+// Wildcards are not considered, so clients must opt in to alternative representations.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			if parsed, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && parsed == mediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getAnimalsHandler handles GET requests for all animals.
+// Repeated ?class= parameters restrict the result to animals in any of the listed classes,
+// ?endangered=true or false to endangered or other animals, ?filter= applies an expression such as legs>2 AND class=mammal, ?sort= orders the result
+// (e.g. ?sort=class,-legs), ?fuzzy= matches names approximately, ?modified_since= returns only
+// animals written after an RFC 3339 time (oldest change first), ?limit=/?offset= select a page,
+// ?fields= reduces every animal to the listed fields (e.g. ?fields=id,name), and ?explain=true
+// describes how the request was evaluated instead of returning the animals. Besides JSON the
+// list is served as NDJSON or, for animals with coordinates, as GeoJSON, depending on Accept.
+// JSON responses go through listCache, which coalesces identical concurrent requests.
+func getAnimalsHandler(store AnimalStore, cfg Config, listCache *ListResponseCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		sortKeys, err := parseSortSpec(r.URL.Query().Get("sort"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := parsePage(r, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter := AnimalFilter{Classes: parseClassFilter(r), Sort: sortKeys}
+		if filter.Endangered, err = parseEndangeredFilter(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if raw := r.URL.Query().Get("filter"); raw != "" {
+			if filter.Expr, err = ParseFilterExpr(raw); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var modifiedSince time.Time
+		if raw := r.URL.Query().Get("modified_since"); raw != "" {
+			if modifiedSince, err = time.Parse(time.RFC3339, raw); err != nil {
+				http.Error(w, "modified_since must be an RFC 3339 timestamp, e.g. 2026-10-14T09:30:00Z", http.StatusBadRequest)
+				return
+			}
+			if r.URL.Query().Get("fuzzy") != "" || acceptsMediaType(r, ndjsonContentType) {
+				http.Error(w, "modified_since cannot be combined with fuzzy or NDJSON streaming", http.StatusBadRequest)
+				return
+			}
+		}
+
+		fields, err := parseFields(r.URL.Query().Get("fields"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		idOnly, err := parseIDOnly(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if idOnly && fields != nil {
+			http.Error(w, "id_only cannot be combined with fields", http.StatusBadRequest)
+			return
+		}
+
+		explain, err := isExplain(r)
+		if err != nil {
+			http.Error(w, "Invalid explain parameter", http.StatusBadRequest)
+			return
+		}
+		if explain {
+			writeExplanation(w, r, store, explainInput{
+				Filter:           filter,
+				Page:             page,
+				Fields:           fields,
+				Fuzzy:            r.URL.Query().Get("fuzzy"),
+				FuzzyMaxDistance: cfg.FuzzyMaxDistance,
+				ModifiedSince:    modifiedSince,
+			})
+			return
+		}
+
+		// ?id_only=true always answers with a JSON list of IDs, whatever the Accept header asks for
+		if !idOnly && acceptsMediaType(r, ndjsonContentType) {
+			if fields != nil {
+				http.Error(w, "fields cannot be combined with NDJSON streaming", http.StatusBadRequest)
+				return
+			}
+			streamAnimalsNDJSON(w, r, store, filter)
+			return
+		}
+
+		if !idOnly && acceptsMediaType(r, geoJSONContentType) {
+			if fields != nil {
+				http.Error(w, "fields cannot be combined with GeoJSON", http.StatusBadRequest)
+				return
+			}
+			animals, err := loadListAnimals(store, cfg, r, filter, modifiedSince)
+			if err != nil && !errors.Is(err, ErrEmpty) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeGeoJSON(w, r, animals, page)
+			return
+		}
+
+		// Last-Modified covers the whole collection, so a 304 for it needs nothing rendered
+		latest, err := store.LatestModified()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !latest.IsZero() {
+			w.Header().Set("Last-Modified", latest.UTC().Format(http.TimeFormat))
+			if notModifiedSince(r, latest) {
+				writeCacheHeaders(w, "", cfg.ListMaxAge)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		// Query().Encode() sorts the parameters, so equivalent query strings share an entry; each tenant has its own
+		key := r.URL.Query().Encode()
+		if tenant := tenantFromContext(r.Context()); tenant != "" {
+			key = tenant + "|" + key
+		}
+		list, err := listCache.get(key, func() (renderedList, error) {
+			if idOnly {
+				return renderIDList(store, cfg, r, filter, modifiedSince, page)
+			}
+			animals, err := loadListAnimals(store, cfg, r, filter, modifiedSince)
+			if err != nil {
+				return renderedList{}, err
+			}
+
+			var body bytes.Buffer
+			if err := encodeJSON(&body, r, projectAnimals(page.apply(animals), fields)); err != nil {
+				return renderedList{}, err
+			}
+			etag := listETag(page.apply(animals), len(animals), fields, body.Bytes(), cfg.ETagMode)
+			return renderedList{body: body.Bytes(), etag: etag, total: len(animals)}, nil
+		})
+		if err != nil {
+			// If no animals found, return 404 Not Found as per problem statement
+			if errors.Is(err, ErrEmpty) {
+				http.Error(w, "No animals found in the system", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeCacheHeaders(w, list.etag, cfg.ListMaxAge)
+		writePageHeaders(w, page, list.total)
+		if etagMatches(r, list.etag) {
+			w.WriteHeader(http.StatusNotModified) // 304: the client's cached copy is still current
+			return
+		}
+		w.Write(list.body)
+	}
+}
+
+// loadListAnimals returns the animals of a list request: those written after modifiedSince,
+// when it is set, or else the ?fuzzy= matches or the plain filter result.
+func loadListAnimals(store AnimalStore, cfg Config, r *http.Request, filter AnimalFilter, modifiedSince time.Time) ([]Animal, error) {
+	if !modifiedSince.IsZero() {
+		return modifiedAnimals(store, modifiedSince, filter)
+	}
+	if query := r.URL.Query().Get("fuzzy"); query != "" {
+		return fuzzySearch(store, query, cfg.FuzzyMaxDistance, filter)
+	}
+	return store.FilterAnimals(filter)
+}
+
+// groupedAnimalsHandler handles GET requests for the animals grouped by class,
+// e.g. {"mammal":[...],"bird":[...]}. Repeated ?class= parameters scope the grouping.
+// The order of the class keys in the JSON object is not guaranteed.
+func groupedAnimalsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		groups, err := store.GroupAnimalsByClass(AnimalFilter{Classes: parseClassFilter(r)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, groups)
+	}
+}
+
+// nextIDResponse is the body of the next-id endpoint.
+type nextIDResponse struct {
+	NextID int `json:"next_id"`
+}
+
+// nextIDHandler handles GET requests for the next free animal ID, e.g. to pre-fill a form.
+// The answer is advisory: a concurrent create may take the ID, so creates can still get 409.
+func nextIDHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		next, err := store.NextID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, nextIDResponse{NextID: next})
+	}
+}
+
+// getAnimalHandler handles GET requests for a single animal by ID. The response carries the
+// animal's ETag, and a request whose If-None-Match lists it gets 304 Not Modified.
+func getAnimalHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		animal, err := store.GetAnimalByID(id)
+		if err != nil {
+			// If animal not found, return 404 Not Found
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAnimalBody(w, r, cfg.ETagMode, http.StatusOK, *animal)
+	}
+}
+
+// respondJSON encodes value as the JSON response body. Output is compact by default;
+// ?pretty=true indents it for reading in a terminal. The Content-Type and status code are
+// left to the caller and are the same in both modes.
+func respondJSON(w http.ResponseWriter, r *http.Request, value interface{}) error {
+	return encodeJSON(w, r, value)
+}
+
+// encodeJSON writes value to w the way respondJSON does, e.g. to serialize a response ahead of time.
+func encodeJSON(w io.Writer, r *http.Request, value interface{}) error {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return encodeJSONValue(w, value, pretty)
+}
+
+// encodeJSONValue writes value as JSON followed by a newline, indented when pretty is set.
+func encodeJSONValue(w io.Writer, value interface{}, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(value)
+}
+
+// animalWithWarnings is the envelope returned by writes when ?warnings=true is requested.
+type animalWithWarnings struct {
+	Data     Animal   `json:"data"`
+	Warnings []string `json:"warnings"`
+}
+
+// writeAnimalResult writes the animal produced by a create or update with the given status. With
+// ?warnings=true the animal is wrapped as {"data": ..., "warnings": [...]} with the soft warnings
+// from warnAnimal; otherwise the plain animal is written with its ETag, so the default response
+// shape is unchanged. A request sending Prefer: return=minimal gets 204 No Content and the
+// animal's ETag instead of a body; any Location header set by the caller is kept.
+func writeAnimalResult(w http.ResponseWriter, r *http.Request, etagMode string, status int, animal Animal) {
+	if prefersMinimalReturn(r) {
+		if etag, err := animalETag(animal, etagMode); err == nil {
+			w.Header().Set("ETag", etag)
+		}
+		w.Header().Set("Preference-Applied", "return=minimal")
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if wants, _ := strconv.ParseBool(r.URL.Query().Get("warnings")); wants {
+		warnings := warnAnimal(animal)
+		if warnings == nil {
+			warnings = []string{}
+		}
+		w.WriteHeader(status)
+		respondJSON(w, r, animalWithWarnings{Data: animal, Warnings: warnings})
+		return
+	}
+	writeAnimalBody(w, r, etagMode, status, animal)
+}
+
+// writeAnimalBody writes the animal as the response body with the given status and its ETag
+// (see responseETag), in the shape of the request's response profile (see negotiateProfile).
+// A GET whose If-None-Match lists the tag gets 304 Not Modified instead.
+func writeAnimalBody(w http.ResponseWriter, r *http.Request, etagMode string, status int, animal Animal) {
+	var body bytes.Buffer
+	if err := encodeJSON(&body, r, profiledAnimal(r, animal)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile := responseProfile(r.Context()); profile != defaultProfile {
+		w.Header().Set("Content-Type", "application/json; profile="+profile)
+	}
+	w.Header().Add("Vary", "Accept")
+	if etag, err := responseETag(animal, body.Bytes(), etagMode); err == nil {
+		w.Header().Set("ETag", etag)
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// parseID returns the animal ID from the request path. IDs are positive integers, so zero,
+// negative and non-numeric IDs are all rejected with errInvalidID.
+func parseID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
+
+// animalLocation builds the URL path of a single animal resource under the given route prefix (e.g. "/v1").
+func animalLocation(prefix string, id int) string {
+	return fmt.Sprintf("%s/animals/%d", prefix, id)
+}
+
+// writeAlreadyExists rejects a create whose ID is taken: 409 Conflict, or 412 Precondition Failed
+// when the request carries If-None-Match: *, meaning the client explicitly required absence.
+func writeAlreadyExists(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Header.Get("If-None-Match") == "*" {
+		http.Error(w, fmt.Sprintf("Animal with ID %d already exists", id), http.StatusPreconditionFailed) // 412 Precondition Failed
+		return
+	}
+	http.Error(w, fmt.Sprintf("Animal with ID %d already exists", id), http.StatusConflict) // 409 Conflict
+}
+
+// createAnimalHandler handles POST requests to create a new animal.
+// Denies duplicate entries based on ID, with 412 instead of 409 when the request carries If-None-Match: *.
+// A body without an ID gets the next free one, unless cfg.AssignIDs is off.
+func createAnimalHandler(store AnimalStore, prefix string, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+
+		animal, legsOmitted, legsErrs, err := decodeAnimal(r.Body)
+		if err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		// Without an ID in the body the server assigns the next free one, unless that is disabled
+		assignID := animal.ID == 0
+		if assignID && !cfg.AssignIDs {
+			http.Error(w, "Animal ID is required for creation", http.StatusBadRequest)
+			return
+		}
+
+		animal = normalizeAnimal(animal, legsOmitted, cfg)
+		if errs := append(legsErrs, validateAnimal(animal, cfg)...); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		// In dry-run mode, report what would have been created without persisting it. Nothing is
+		// written, so the duplicate check has to be done up front here.
+		if dryRun {
+			if assignID {
+				if animal.ID, err = store.NextID(); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			} else if _, err := store.GetAnimalByID(animal.ID); err == nil {
+				writeAlreadyExists(w, r, animal.ID)
+				return
+			}
+			w.Header().Set("X-Dry-Run", "true")
+			writeAnimalResult(w, r, cfg.ETagMode, http.StatusCreated, animal)
+			return
+		}
+
+		// The store rejects duplicate IDs atomically; a separate existence check beforehand would race with concurrent creates
+		if assignID {
+			animal, err = store.CreateAnimalAssigningID(animal, nil)
+		} else {
+			err = store.CreateAnimal(animal)
+		}
+		if err != nil {
+			if errors.Is(err, ErrAlreadyExists) && !assignID {
+				writeAlreadyExists(w, r, animal.ID)
+				return
+			}
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrCapacityExceeded) {
+				http.Error(w, "The store is full: no more animals can be created", http.StatusInsufficientStorage) // 507
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Echo the stored record so the response includes the store-managed timestamps
+		if stored, err := store.GetAnimalByID(animal.ID); err == nil {
+			animal = *stored
+		}
+
+		w.Header().Set("Location", animalLocation(prefix, animal.ID))
+		writeAnimalResult(w, r, cfg.ETagMode, http.StatusCreated, animal) // 201 Created
+	}
+}
+
+// updateAnimalHandler handles PUT requests to update an existing animal or create a new one (upsert).
+func updateAnimalHandler(store AnimalStore, prefix string, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+
+		animal, legsOmitted, legsErrs, err := decodeAnimal(r.Body)
+		if err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		// Ensure the ID from the path is used for the operation, ignoring ID in body if different
+		animal.ID = id
+
+		animal = normalizeAnimal(animal, legsOmitted, cfg)
+		if errs := append(legsErrs, validateAnimal(animal, cfg)...); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		// Check if the animal exists to determine if it's an update or create
+		existing, existsErr := store.GetAnimalByID(id)
+		if existsErr != nil && !errors.Is(existsErr, ErrNotFound) {
+			http.Error(w, existsErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		// If-Match with ETags is a version precondition on an existing animal only, checked
+		// again atomically with the write below; a missing animal is created regardless.
+		versioned := ifMatchListsETags(r)
+		if (existing != nil || !versioned) && !checkIfMatch(w, r, existing, cfg.ETagMode) {
+			return
+		}
+
+		// In dry-run mode, report whether this would be an update or a create without persisting it
+		if dryRun {
+			w.Header().Set("X-Dry-Run", "true")
+			status := http.StatusCreated
+			if existsErr == nil {
+				status = http.StatusOK
+			}
+			writeAnimalResult(w, r, cfg.ETagMode, status, animal)
+			return
+		}
+
+		if versioned {
+			// Overwrite only the version the client read, or create the animal if it is gone
+			created, err := store.UpsertAnimalIf(id, animal, func(current Animal) bool {
+				return ifMatchesAnimal(r, current, cfg.ETagMode)
+			})
+			if errors.Is(err, ErrVersionMismatch) {
+				http.Error(w, "If-Match does not match the animal's current ETag", http.StatusPreconditionFailed) // 412 Precondition Failed
+				return
+			}
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrCapacityExceeded) {
+				http.Error(w, "The store is full: no more animals can be created", http.StatusInsufficientStorage) // 507
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if stored, err := store.GetAnimalByID(id); err == nil {
+				animal = *stored
+			}
+			status := http.StatusOK
+			if created {
+				w.Header().Set("Location", animalLocation(prefix, id))
+				status = http.StatusCreated
+			}
+			writeAnimalResult(w, r, cfg.ETagMode, status, animal)
+		} else if existsErr == nil {
+			// Animal exists, perform update
+			if err := store.UpdateAnimal(id, animal); err != nil {
+				if writeVetoed(w, r, err) {
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if stored, err := store.GetAnimalByID(id); err == nil {
+				animal = *stored
+			}
+			writeAnimalResult(w, r, cfg.ETagMode, http.StatusOK, animal) // 200 OK for update
+		} else {
+			// Animal does not exist, perform creation (upsert)
+			if err := store.UpsertAnimal(id, animal); err != nil {
+				if writeVetoed(w, r, err) {
+					return
+				}
+				if errors.Is(err, ErrCapacityExceeded) {
+					http.Error(w, "The store is full: no more animals can be created", http.StatusInsufficientStorage) // 507
+					return
+				}
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if stored, err := store.GetAnimalByID(id); err == nil {
+				animal = *stored
+			}
+			w.Header().Set("Location", animalLocation(prefix, id))
+			writeAnimalResult(w, r, cfg.ETagMode, http.StatusCreated, animal) // 201 Created for new resource
+		}
+	}
+}
+
+// deleteAnimalHandler handles DELETE requests to delete an animal by ID. With If-Match the
+// animal is only deleted while its ETag still matches: the tag is checked against the animal
+// as read, and DeleteAnimalIfVersion then deletes only that version, so a client never deletes
+// a version it has not seen even if another write lands in between. With cfg.StrictDelete a
+// delete without If-Match is refused with 428 Precondition Required.
+func deleteAnimalHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		if r.Header.Get("If-Match") != "" {
+			var current *Animal
+			current, err = store.GetAnimalByID(id)
+			if err == nil {
+				if !ifMatchesAnimal(r, *current, cfg.ETagMode) {
+					err = fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+				} else {
+					err = store.DeleteAnimalIfVersion(id, animalVersion(*current))
+				}
+			}
+		} else if cfg.StrictDelete {
+			http.Error(w, "Deleting an animal requires If-Match with its current ETag", http.StatusPreconditionRequired) // 428 Precondition Required
+			return
+		} else {
+			err = store.DeleteAnimal(id)
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrVersionMismatch) {
+				http.Error(w, "If-Match does not match the animal's current ETag", http.StatusPreconditionFailed) // 412 Precondition Failed
+				return
+			}
+			// If animal not found for deletion, return 404 Not Found
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent) // 204 No Content for successful deletion
+	}
+}
+
+// maxBatchIDs caps how many IDs a single batch request may reference, to avoid abuse.
+const maxBatchIDs = 100
+
+// batchIDsRequest is the request body for batch operations that take a list of IDs.
+type batchIDsRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// batchGetResponse carries the result of a batch lookup.
+type batchGetResponse struct {
+	Animals  []Animal `json:"animals"`   // Animals that exist, in request order
+	NotFound []int    `json:"not_found"` // IDs that did not exist
+}
+
+// batchGetAnimalsHandler handles POST requests to fetch several animals by ID in one round trip.
+func batchGetAnimalsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req batchIDsRequest
+		if err := decodeJSONBody(r.Body, &req); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		if len(req.IDs) == 0 {
+			http.Error(w, "At least one ID is required", http.StatusBadRequest)
+			return
+		}
+		if len(req.IDs) > maxBatchIDs {
+			http.Error(w, fmt.Sprintf("Too many IDs: at most %d are allowed per request", maxBatchIDs), http.StatusBadRequest)
+			return
+		}
+
+		found, missing, err := store.GetAnimalsByIDs(req.IDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, batchGetResponse{Animals: found, NotFound: missing})
+	}
+}
+
+// batchDeleteResponse summarizes the outcome of a batch delete.
+type batchDeleteResponse struct {
+	Deleted  []int `json:"deleted"`   // IDs that were removed
+	NotFound []int `json:"not_found"` // IDs that did not exist
+}
+
+// isTransactional reports whether the request asks for all-or-nothing semantics via ?transactional=true.
+func isTransactional(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("transactional")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// batchDeleteAnimalsHandler handles POST requests to delete several animals at once.
+// It returns 200 OK with a summary rather than 204, since partial success is likely.
+// With ?transactional=true nothing is deleted unless every ID exists (404 otherwise).
+func batchDeleteAnimalsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req batchIDsRequest
+		if err := decodeJSONBody(r.Body, &req); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		if len(req.IDs) == 0 {
+			http.Error(w, "At least one ID is required", http.StatusBadRequest)
+			return
+		}
+		if len(req.IDs) > maxBatchIDs {
+			http.Error(w, fmt.Sprintf("Too many IDs: at most %d are allowed per request", maxBatchIDs), http.StatusBadRequest)
+			return
+		}
+
+		transactional, err := isTransactional(r)
+		if err != nil {
+			http.Error(w, "Invalid transactional parameter", http.StatusBadRequest)
+			return
+		}
+
+		if !transactional {
+			deleted, notFound, err := store.DeleteAnimals(req.IDs)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			respondJSON(w, r, batchDeleteResponse{Deleted: deleted, NotFound: notFound})
+			return
+		}
+
+		// All-or-nothing: abort the transaction (rolling back the deletes) if any ID is missing
+		var result batchDeleteResponse
+		errMissing := fmt.Errorf("some animals were not found")
+		err = store.WithTransaction(r.Context(), func(tx AnimalStore) error {
+			deleted, notFound, err := tx.DeleteAnimals(req.IDs)
+			if err != nil {
+				return err
+			}
+			result = batchDeleteResponse{Deleted: deleted, NotFound: notFound}
+			if len(notFound) > 0 {
+				return errMissing
+			}
+			return nil
+		})
+		if errors.Is(err, errMissing) {
+			w.WriteHeader(http.StatusNotFound)
+			respondJSON(w, r, batchDeleteResponse{Deleted: []int{}, NotFound: result.NotFound})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, result)
+	}
+}
+
+// resetAnimalsHandler handles POST requests that restore the seed dataset.
+// It clears the store, re-inserts seed (see loadSeed) and returns the seeded set.
+func resetAnimalsHandler(store AnimalStore, seed []Animal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := store.ReplaceAllAnimals(seed); err != nil {
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrCapacityExceeded) {
+				http.Error(w, "The seed dataset exceeds the store capacity", http.StatusInsufficientStorage) // 507
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		animals, err := store.FilterAnimals(AnimalFilter{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, animals)
+	}
+}
+
+// headResponseWriter discards the response body while recording its status and size,
+// so a GET handler can answer a HEAD request with exactly the headers it would send for GET.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+// WriteHeader records the status code; it is sent once the handler has finished.
+func (w *headResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write counts and discards the body bytes.
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	w.length += len(b)
+	return len(b), nil
+}
+
+// withHead lets a GET handler also serve HEAD requests: the handler runs unchanged and
+// sets all its usual headers, but no body is written. Content-Length reflects the body GET would return.
+func withHead(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		hw := &headResponseWriter{ResponseWriter: w}
+		next(hw, r)
+		if hw.status == 0 {
+			hw.status = http.StatusOK
+		}
+		if hw.length > 0 && w.Header().Get("Content-Length") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(hw.length))
+		}
+		w.WriteHeader(hw.status)
+	}
+}
+
+// requireAdmin guards an admin-only handler, answering 403 Forbidden unless admin operations are enabled.
+func requireAdmin(cfg Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.AdminEnabled {
+			http.Error(w, "Admin operations are disabled", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requestScoped builds the handler around a store bound to each request's context, so that
+// context-aware decorators attach their work (e.g. spans) to the request. Stores that are not
+// context-aware get a handler built once, as before.
+func requestScoped(store AnimalStore, build func(store AnimalStore) http.HandlerFunc) http.HandlerFunc {
+	if _, ok := store.(contextBinder); !ok {
+		return build(store)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		build(bindContext(store, r.Context()))(w, r)
+	}
+}
+
+// registerRoutes mounts the animal API on a subrouter under the given prefix (e.g. "/v1").
+// Calling it several times with different prefixes serves the same handlers side by side.
+// The audit and history endpoints are only mounted when auditRing and history are non-nil. listCache serves the JSON list;
+// the caller is responsible for invalidating it on writes under every prefix. Writes are limited per client by writeGuard
+// unless it is nil.
+func registerRoutes(r *mux.Router, prefix string, store AnimalStore, cfg Config, readOnly *ReadOnlyMode, writeGuard *MutationGuard, auditRing *AuditRing, history *AuditHistory, listCache *ListResponseCache, changes *ChangeLog, seed []Animal) {
+	api := r.PathPrefix(prefix).Subrouter()
+	api.Use(nameRouteSpans)
+	api.Use(requireTenant(cfg.Tenants))
+	api.Use(recordChanges(changes))
+	api.Use(withClientIdentity)
+	api.Use(limitBodySize(cfg.MaxBodyBytes))
+	api.Use(logBodies(cfg))
+	api.Use(rejectWritesWhenReadOnly(readOnly))
+	api.Use(guardMutations(writeGuard, cfg.RateLimitExempt))
+	api.Use(requireJSONContentType(cfg.StrictContentType))
+	api.Use(negotiateProfile(cfg.StrictProfiles))
+	api.Use(limitDuration(cfg))
+
+	scoped := func(build func(store AnimalStore) http.HandlerFunc) http.HandlerFunc {
+		return requestScoped(store, build)
+	}
+	listHandler := func(s AnimalStore) http.HandlerFunc { return getAnimalsHandler(s, cfg, listCache) }
+	createHandler := func(s AnimalStore) http.HandlerFunc { return createAnimalHandler(s, prefix, cfg) }
+	updateHandler := func(s AnimalStore) http.HandlerFunc { return updateAnimalHandler(s, prefix, cfg) }
+	patchHandler := func(s AnimalStore) http.HandlerFunc { return patchAnimalHandler(s, cfg) }
+	cloneHandler := func(s AnimalStore) http.HandlerFunc { return cloneAnimalHandler(s, prefix, cfg) }
+	getHandler := func(s AnimalStore) http.HandlerFunc { return getAnimalHandler(s, cfg) }
+	deleteHandler := func(s AnimalStore) http.HandlerFunc { return deleteAnimalHandler(s, cfg) }
+	endangeredHandler := func(s AnimalStore) http.HandlerFunc { return setEndangeredHandler(s, cfg) }
+	reclassifyHandler := func(s AnimalStore) http.HandlerFunc { return reclassifyAnimalsHandler(s, cfg) }
+	mergeHandler := func(s AnimalStore) http.HandlerFunc { return mergeAnimalsHandler(s, cfg) }
+	batchUpsertHandler := func(s AnimalStore) http.HandlerFunc { return batchUpsertAnimalsHandler(s, cfg) }
+	exports := &exportCache{}
+	exportHandler := func(s AnimalStore) http.HandlerFunc { return exportAnimalsHandler(s, exports) }
+	queryHandler := func(s AnimalStore) http.HandlerFunc { return queryAnimalsHandler(s, cfg) }
+	resetHandler := func(s AnimalStore) http.HandlerFunc { return resetAnimalsHandler(s, seed) }
+	pollHandler := func(s AnimalStore) http.HandlerFunc { return pollAnimalsHandler(s, changes, cfg.PollTimeout) }
+
+	api.HandleFunc("/animals", withHead(scoped(listHandler))).Methods("GET", "HEAD")
+	api.HandleFunc("/animals/export", scoped(exportHandler)).Methods("GET").Name(exportRoute) // Must precede /animals/{id}
+	api.HandleFunc("/animals/poll", scoped(pollHandler)).Methods("GET").Name(pollRoute)
+	api.HandleFunc("/animals/grouped", scoped(groupedAnimalsHandler)).Methods("GET")
+	api.HandleFunc("/animals/facets", scoped(facetsHandler)).Methods("GET")
+	api.HandleFunc("/animals/analytics", scoped(analyticsHandler)).Methods("GET")
+	api.HandleFunc("/animals/next-id", scoped(nextIDHandler)).Methods("GET")
+	api.HandleFunc("/animals/sample", scoped(sampleAnimalsHandler)).Methods("GET")
+	api.HandleFunc("/animals/schema", animalSchemaHandler(cfg)).Methods("GET")
+	api.HandleFunc("/animals/{id}", withHead(scoped(getHandler))).Methods("GET", "HEAD")
+	api.HandleFunc("/animals", scoped(createHandler)).Methods("POST")
+	api.HandleFunc("/animals", scoped(batchUpsertHandler)).Methods("PUT")
+	api.HandleFunc("/animals/reserve", scoped(reserveIDHandler)).Methods("POST")
+	api.HandleFunc("/animals/validate", validateAnimalHandler(cfg)).Methods("POST").Name(validateRoute)
+	api.HandleFunc("/animals/{id}/clone", scoped(cloneHandler)).Methods("POST")
+	api.HandleFunc("/animals/{id}/endangered", scoped(endangeredHandler)).Methods("POST")
+	api.HandleFunc("/animals/batch-get", scoped(batchGetAnimalsHandler)).Methods("POST").Name(batchGetRoute)
+	api.HandleFunc("/animals/query", scoped(queryHandler)).Methods("POST").Name(queryRoute)
+	api.HandleFunc("/animals/batch-delete", requireAdmin(cfg, scoped(batchDeleteAnimalsHandler))).Methods("POST")
+	api.HandleFunc("/animals/reclassify", scoped(reclassifyHandler)).Methods("POST")
+	api.HandleFunc("/animals/merge", scoped(mergeHandler)).Methods("POST")
+	api.HandleFunc("/animals/{id}", scoped(updateHandler)).Methods("PUT")
+	api.HandleFunc("/animals/{id}", scoped(patchHandler)).Methods("PATCH")
+	api.HandleFunc("/animals/{id}", scoped(deleteHandler)).Methods("DELETE")
+
+	api.HandleFunc("/graphql", graphqlHandler(store, cfg, readOnly, changes)).Methods("GET", "POST").Name(graphqlRoute)
+	api.HandleFunc("/graphql/schema", graphqlSchemaHandler).Methods("GET")
+
+	api.HandleFunc("/admin/reset", requireAdmin(cfg, scoped(resetHandler))).Methods("POST")
+	if auditRing != nil {
+		api.HandleFunc("/admin/audit", requireAdmin(cfg, auditLogHandler(auditRing))).Methods("GET")
+	}
+	if history != nil {
+		historyHandler := func(s AnimalStore) http.HandlerFunc { return animalHistoryHandler(s, history, cfg) }
+		api.HandleFunc("/animals/{id}/history", requireAdmin(cfg, scoped(historyHandler))).Methods("GET")
+	}
+	api.HandleFunc("/admin/readonly", requireAdmin(cfg, readOnlyHandler(readOnly))).Methods("GET", "POST").Name(readOnlyToggleRoute)
+}
+
+// shutdownTimeout bounds how long shutdown waits for in-flight requests to finish.
+const shutdownTimeout = 10 * time.Second
+
+// openStore creates the storage backend selected by cfg.Storage. The returned function
+// releases the backend's resources (the database file for bolt) and must be called on exit.
+func openStore(cfg Config) (AnimalStore, func() error, error) {
+	noop := func() error { return nil }
+	switch cfg.Storage {
+	case "memory":
+		if cfg.CapacityPolicy == capacityEvictLRU {
+			if cfg.Shards > 1 {
+				return nil, nil, fmt.Errorf("the %s capacity policy does not support sharding", capacityEvictLRU)
+			}
+			return NewEvictingInMemoryAnimalStore(cfg.MaxAnimals), noop, nil
+		}
+		if cfg.Shards > 1 {
+			return NewShardedAnimalStore(cfg.Shards, cfg.MaxAnimals), noop, nil
+		}
+		return NewInMemoryAnimalStore(cfg.MaxAnimals), noop, nil
+	case "bolt":
+		if cfg.CapacityPolicy == capacityEvictLRU {
+			return nil, nil, fmt.Errorf("the %s capacity policy is only supported by the memory backend", capacityEvictLRU)
+		}
+		store, err := NewBoltAnimalStore(cfg.BoltPath, cfg.MaxAnimals)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q (supported: memory, bolt)", cfg.Storage)
+	}
+}
+
+func main() {
+	// Set when the server fails after startup; the exit happens last, after the deferred cleanups
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	cfg := loadConfig()
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("setting up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Open the configured storage backend, one per tenant with tenancy; they are closed after the server has shut down
+	metrics := NewMetrics(cfg.LatencyBuckets)
+	storeMetrics := newStoreMetrics(metrics.registry, cfg.LatencyBuckets)
+	var closeStores []func() error
+	defer func() {
+		for _, closeStore := range closeStores {
+			if err := closeStore(); err != nil {
+				log.Printf("closing %s store: %v", cfg.Storage, err)
+			}
+		}
+	}()
+	var sweepers []*Sweeper
+	openBackend := func(cfg Config, name string) AnimalStore {
+		store, closeStore, err := openStore(cfg)
+		if err != nil {
+			log.Fatalf("opening %s store: %v", name, err)
+		}
+		closeStores = append(closeStores, closeStore)
+
+		// Fail fast, before serving anything, if the backend cannot be used
+		if err := startupCheck(store, name, cfg.StartupAttempts, cfg.StartupTimeout); err != nil {
+			closeStore()
+			log.Fatalf("startup check failed: %v", err)
+		}
+
+		// Record per-method metrics of the backend itself, below every other layer, so each attempt
+		// of a retried operation counts
+		store = storeMetrics.instrument(store)
+		if cfg.StoreRetryAttempts > 1 {
+			store = NewRetryingAnimalStore(store, cfg.StoreRetryAttempts, cfg.StoreRetryDelay, cfg.StoreRetryMaxDelay, cfg.StoreRetryJitter)
+		}
+
+		// Keep ID reservations in front of the backend, so every layer above sees reserved IDs skipped
+		reservingStore := NewReservingAnimalStore(store, cfg.ReservationTTL)
+		sweepers = append(sweepers, reservingStore.Sweeper(cfg.SweepJitter))
+		return reservingStore
+	}
+	var animalStore AnimalStore
+	if len(cfg.Tenants) == 0 {
+		animalStore = openBackend(cfg, cfg.Storage)
+	} else {
+		// Give every tenant a backend of its own, and route each request to that of its tenant
+		stores := make(map[string]AnimalStore, len(cfg.Tenants))
+		for _, tenant := range cfg.Tenants {
+			stores[tenant] = openBackend(tenantConfig(cfg, tenant), fmt.Sprintf("%s (tenant %s)", cfg.Storage, tenant))
+		}
+		animalStore = NewTenantAnimalStore(stores)
+	}
+
+	// Optionally put a read cache in front of the store
+	if cfg.CacheTTL > 0 {
+		animalStore = NewCachingAnimalStore(animalStore, cfg.CacheTTL, cfg.CacheMaxEntries)
+	}
+
+	// Run the registered mutation hooks; outside the cache so they receive the request context
+	hooks := &Hooks{}
+	registerHooks(hooks, cfg)
+	if !hooks.empty() {
+		animalStore = NewHookedAnimalStore(animalStore, hooks)
+	}
+
+	// Optionally record every mutation to the audit log (stdout or a file) and an in-memory ring for the audit endpoint
+	var auditRing *AuditRing
+	var history *AuditHistory
+	if cfg.Audit {
+		output := os.Stdout
+		if cfg.AuditFile != "" {
+			file, err := os.OpenFile(cfg.AuditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				log.Fatalf("opening audit file: %v", err)
+			}
+			defer file.Close()
+			output = file
+		}
+		auditRing = NewAuditRing(cfg.AuditBufferSize)
+		history = NewAuditHistory(cfg.AuditHistorySize)
+		animalStore = NewAuditingAnimalStore(animalStore, multiAuditSink{NewJSONAuditSink(output), auditRing, history})
+	}
+
+	// Trace store operations (outermost, so cache hits are visible too) when tracing is configured
+	if cfg.OTLPEndpoint != "" {
+		animalStore = NewTracingAnimalStore(animalStore)
+	}
+
+	// Optionally report the time spent in the store in the Server-Timing header; outermost, so it covers every layer
+	if cfg.ServerTiming {
+		animalStore = NewTimingAnimalStore(animalStore)
+	}
 
-	fmt.Print("Starting server at port 8000\n")
-	log.Fatal(http.ListenAndServe(":8000", r))
+	// Store the seed dataset, unless a persistent store already holds animals; with tenancy into each tenant's store
+	seed, err := loadSeed(cfg)
+	if err != nil {
+		log.Fatalf("loading seed file: %v", err)
+	}
+	if len(cfg.Tenants) == 0 {
+		seedStore(animalStore, seed, cfg, "the store")
+	}
+	for _, tenant := range cfg.Tenants {
+		seedStore(bindContext(animalStore, withTenant(context.Background(), tenant)), seed, cfg, "the store of tenant "+tenant)
+	}
+
+	r := mux.NewRouter()
+
+	// Operational endpoints, outside the versioned API
+	r.Use(metrics.Middleware)
+
+	// Answer 503 during the scheduled maintenance windows, except on the exempt paths
+	maintenance := NewMaintenanceSchedule(cfg.MaintenanceWindows, cfg.MaintenanceExempt)
+	r.Use(rejectDuringMaintenance(maintenance))
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", healthzHandler).Methods("GET")
+	var ready atomic.Bool
+	r.HandleFunc("/readyz", readyzHandler(&ready)).Methods("GET")
+
+	// Define API routes with a /v1 prefix
+	readOnly := NewReadOnlyMode(false)
+	readOnly.Set(cfg.ReadOnly)
+
+	// Coalesce identical list requests (and cache them for -list-cache-ttl); any write invalidates
+	listCache := NewListResponseCache(cfg.ListCacheTTL)
+	r.Use(invalidateOnWrite(listCache))
+
+	// Number the writes, for the long polls of /animals/poll
+	changes := NewChangeLog()
+
+	// Limit the writes of each client; the counters of idle clients are swept in the background
+	var writeGuard *MutationGuard
+	if cfg.WriteLimit > 0 {
+		writeGuard = NewMutationGuard(cfg.WriteLimit, cfg.WriteWindow)
+		sweepers = append(sweepers, writeGuard.Sweeper(cfg.SweepJitter))
+	}
+
+	registerRoutes(r, "/v1", animalStore, cfg, readOnly, writeGuard, auditRing, history, listCache, changes, seed)
+
+	// Answer unknown paths and unsupported methods with problem documents like other errors
+	r.NotFoundHandler = notFoundHandler(r)
+	r.MethodNotAllowedHandler = methodNotAllowedHandler(r)
+
+	var handler http.Handler = traceRequests(emitServerTiming(cfg.ServerTiming)(cors(cfg)(limitInFlight(cfg.MaxInFlight, cfg.RateLimitExempt)(limitQueryString(cfg.MaxQueryParams, cfg.MaxQueryLength)(normalizeTrailingSlash(cfg.TrailingSlash)(r))))))
+	if cfg.Pprof {
+		handler = servePprof(handler) // In front of all middleware, for localhost only
+	}
+	srv := &http.Server{Addr: ":8000", Handler: handler}
+	srv.RegisterOnShutdown(changes.Close) // End the long polls instead of waiting out their timeouts
+
+	// Stop on Ctrl-C or SIGTERM: fail readiness, drain, finish in-flight requests, then let the
+	// deferred cleanups close the store
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Optionally push the metrics to a Pushgateway, for deployments that cannot be scraped
+	var pusher *push.Pusher
+	if cfg.PushgatewayURL != "" {
+		pusher = newMetricsPusher(metrics, cfg.PushgatewayURL, cfg.PushJob)
+		sweepers = append(sweepers, pushSweeper(pusher, cfg.PushInterval, cfg.SweepJitter))
+	}
+
+	// Background loops run until the first signal; shutdown waits for them to return
+	go maintenance.watch(ctx)
+	for _, sweeper := range sweepers {
+		sweeper.Start(ctx)
+	}
+	// Optionally serve the gRPC AnimalService on its own port, from the same store
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		grpcServer = newGRPCServer(animalStore, cfg, readOnly, listCache, changes)
+		if err := serveGRPC(grpcServer, cfg.GRPCAddr); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Starting gRPC server at %s\n", cfg.GRPCAddr)
+	}
+	ready.Store(true)
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Print("Starting server at port 8000\n")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	// Shut down on a signal, or when the listener fails (e.g. the port is taken), so the
+	// background loops stop and the store is closed either way
+	var listenFailed bool
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		log.Printf("server: %v", err)
+		listenFailed = true
+		exitCode = 1
+	}
+	stop() // A second signal terminates immediately instead of waiting for the drain
+
+	// Pre-shutdown: keep serving while the load balancer notices the failing readiness probe
+	ready.Store(false)
+	log.Print("Shutdown: readiness probe now failing")
+	if cfg.ShutdownDrainDelay > 0 && !listenFailed {
+		log.Printf("Shutdown: draining traffic for %s", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+	log.Print("Shutdown: stopping server, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	log.Print("Shutdown: server stopped")
+	for _, sweeper := range sweepers {
+		<-sweeper.Done()
+	}
+	if pusher != nil {
+		finalPush(pusher)
+	}
 }
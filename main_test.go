@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testConfig returns the configuration the server runs with when no flag is given.
+func testConfig() Config {
+	rules, err := parseLegRules(defaultLegRules)
+	if err != nil {
+		panic(err)
+	}
+	return Config{
+		Storage:           "memory",
+		CapacityPolicy:    capacityReject,
+		Shards:            1,
+		AssignIDs:         true,
+		ETagMode:          etagWeak,
+		DefaultLimit:      100,
+		MaxLimit:          1000,
+		FuzzyMaxDistance:  2,
+		NameNormalization: nameCollapse,
+		MaxNameLength:     100,
+		EmptyClass:        emptyClassAllow,
+		DefaultClass:      "unknown",
+		LegRules:          rules,
+		StrictContentType: true,
+		MaxBodyBytes:      1 << 20,
+	}
+}
+
+// testBackends returns an empty store of every backend, keyed by name, each holding at most
+// maxAnimals animals. The bolt store lives in a temporary directory and is closed by the test.
+func testBackends(t testing.TB, maxAnimals int) map[string]AnimalStore {
+	t.Helper()
+	bolt, err := NewBoltAnimalStore(filepath.Join(t.TempDir(), "animals.db"), maxAnimals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]AnimalStore{
+		"memory":  NewInMemoryAnimalStore(maxAnimals),
+		"sharded": NewShardedAnimalStore(4, maxAnimals),
+		"bolt":    bolt,
+	}
+}
+
+// newTestAPI returns the API routes under /v1, serving store with cfg, as the server mounts them.
+func newTestAPI(store AnimalStore, cfg Config) http.Handler {
+	r := mux.NewRouter()
+	listCache := NewListResponseCache(cfg.ListCacheTTL)
+	r.Use(invalidateOnWrite(listCache))
+	var writeGuard *MutationGuard
+	if cfg.WriteLimit > 0 {
+		writeGuard = NewMutationGuard(cfg.WriteLimit, cfg.WriteWindow)
+	}
+	registerRoutes(r, "/v1", store, cfg, NewReadOnlyMode(false), writeGuard, nil, nil, listCache, NewChangeLog(), seedAnimals())
+	r.NotFoundHandler = notFoundHandler(r)
+	r.MethodNotAllowedHandler = methodNotAllowedHandler(r)
+	return r
+}
+
+// serve sends a request to handler and returns the recorded response. A non-empty body is sent
+// as JSON; headers are name-value pairs.
+func serve(handler http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeAnimalResponse decodes the animal in the body of a response.
+func decodeAnimalResponse(t testing.TB, rec *httptest.ResponseRecorder) Animal {
+	t.Helper()
+	var animal Animal
+	if err := json.Unmarshal(rec.Body.Bytes(), &animal); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return animal
+}
+
+func TestUpsertPreservesCreatedAt(t *testing.T) {
+	for name, store := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.UpsertAnimal(1, Animal{Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			first, err := store.GetAnimalByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.CreatedAt.IsZero() || !first.UpdatedAt.Equal(first.CreatedAt) {
+				t.Errorf("created: CreatedAt %v, UpdatedAt %v, want both set and equal", first.CreatedAt, first.UpdatedAt)
+			}
+
+			time.Sleep(time.Millisecond)
+			// A retried upsert carrying stale or made-up timestamps must not rewrite CreatedAt
+			retry := Animal{Name: "Lion", Class: "mammal", Legs: 4, CreatedAt: time.Unix(0, 0), UpdatedAt: time.Unix(0, 0)}
+			for range 2 {
+				if err := store.UpsertAnimal(1, retry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			second, err := store.GetAnimalByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !second.CreatedAt.Equal(first.CreatedAt) {
+				t.Errorf("CreatedAt = %v after upserts, want %v", second.CreatedAt, first.CreatedAt)
+			}
+			if !second.UpdatedAt.After(first.UpdatedAt) {
+				t.Errorf("UpdatedAt = %v after upserts, want after %v", second.UpdatedAt, first.UpdatedAt)
+			}
+		})
+	}
+}
+
+func TestPutIsRetrySafe(t *testing.T) {
+	api := newTestAPI(NewInMemoryAnimalStore(0), testConfig())
+	body := `{"name": "Okapi", "class": "mammal", "legs": 4}`
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{"first attempt creates", http.StatusCreated},
+		{"retry updates", http.StatusOK},
+		{"second retry updates", http.StatusOK},
+	}
+	var createdAt time.Time
+	for _, tt := range tests {
+		rec := serve(api, http.MethodPut, "/v1/animals/7", body)
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.wantStatus, rec.Body)
+		}
+		animal := decodeAnimalResponse(t, rec)
+		if createdAt.IsZero() {
+			createdAt = animal.CreatedAt
+		} else if !animal.CreatedAt.Equal(createdAt) {
+			t.Errorf("%s: created_at = %v, want %v", tt.name, animal.CreatedAt, createdAt)
+		}
+	}
+}
+
+func TestCreateIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"new ID", `{"id": 9, "name": "Tapir", "class": "mammal", "legs": 4}`, "", http.StatusCreated},
+		{"new ID with If-None-Match: *", `{"id": 9, "name": "Tapir", "class": "mammal", "legs": 4}`, "*", http.StatusCreated},
+		{"assigned ID with If-None-Match: *", `{"name": "Tapir", "class": "mammal", "legs": 4}`, "*", http.StatusCreated},
+		{"taken ID", `{"id": 1, "name": "Tapir", "class": "mammal", "legs": 4}`, "", http.StatusConflict},
+		{"taken ID with If-None-Match: *", `{"id": 1, "name": "Tapir", "class": "mammal", "legs": 4}`, "*", http.StatusPreconditionFailed},
+		{"taken ID with a tag", `{"id": 1, "name": "Tapir", "class": "mammal", "legs": 4}`, `"abc"`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			var headers []string
+			if tt.ifNoneMatch != "" {
+				headers = []string{"If-None-Match", tt.ifNoneMatch}
+			}
+			rec := serve(newTestAPI(store, testConfig()), http.MethodPost, "/v1/animals", tt.body, headers...)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if lion, err := store.GetAnimalByID(1); err != nil || lion.Name != "Lion" {
+				t.Errorf("existing animal = %v, %v; want it untouched", lion, err)
+			}
+		})
+	}
+}
+
+func TestMaxAnimals(t *testing.T) {
+	for name, store := range testBackends(t, 2) {
+		t.Run(name, func(t *testing.T) {
+			for id := 1; id <= 2; id++ {
+				if err := store.CreateAnimal(Animal{ID: id, Name: "Animal", Class: "bird", Legs: 2}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			steps := []struct {
+				name string
+				op   func() error
+				want error
+			}{
+				{"create in a full store", func() error { return store.CreateAnimal(Animal{ID: 3, Name: "Extra"}) }, ErrCapacityExceeded},
+				{"upsert of a new animal", func() error { return store.UpsertAnimal(3, Animal{Name: "Extra"}) }, ErrCapacityExceeded},
+				{"update of a stored animal", func() error { return store.UpdateAnimal(1, Animal{Name: "Changed"}) }, nil},
+				{"upsert of a stored animal", func() error { return store.UpsertAnimal(2, Animal{Name: "Changed"}) }, nil},
+				{"delete makes room", func() error { return store.DeleteAnimal(1) }, nil},
+				{"create after the delete", func() error { return store.CreateAnimal(Animal{ID: 3, Name: "Extra"}) }, nil},
+			}
+			for _, step := range steps {
+				if err := step.op(); !errors.Is(err, step.want) {
+					t.Errorf("%s: error = %v, want %v", step.name, err, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxAnimalsResponse(t *testing.T) {
+	store := NewInMemoryAnimalStore(1)
+	if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+		t.Fatal(err)
+	}
+	api := newTestAPI(store, testConfig())
+	for _, req := range []struct{ method, target string }{
+		{http.MethodPost, "/v1/animals"},
+		{http.MethodPut, "/v1/animals/2"},
+	} {
+		rec := serve(api, req.method, req.target, `{"name": "Tapir", "class": "mammal", "legs": 4}`)
+		if rec.Code != http.StatusInsufficientStorage {
+			t.Errorf("%s %s: status = %d, want %d", req.method, req.target, rec.Code, http.StatusInsufficientStorage)
+		}
+	}
+}
+
+func TestCreateAnimalAssigningIDConcurrently(t *testing.T) {
+	for name, store := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateAnimal(Animal{ID: 10, Name: "Lion"}); err != nil {
+				t.Fatal(err)
+			}
+			const creates = 50
+			ids := make(chan int, creates)
+			var wg sync.WaitGroup
+			for range creates {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					created, err := store.CreateAnimalAssigningID(Animal{Name: "Cub"}, nil)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if created.CreatedAt.IsZero() {
+						t.Errorf("created animal %d has no timestamps", created.ID)
+					}
+					ids <- created.ID
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[int]bool)
+			for id := range ids {
+				if seen[id] || id <= 10 || id > 10+creates {
+					t.Errorf("assigned ID %d: want distinct IDs from 11 to %d", id, 10+creates)
+				}
+				seen[id] = true
+			}
+			if len(seen) != creates {
+				t.Errorf("%d distinct IDs assigned, want %d", len(seen), creates)
+			}
+		})
+	}
+}
+
+func TestCreateAnimalAssigningIDSkipsReservedIDs(t *testing.T) {
+	for name, backend := range testBackends(t, 0) {
+		t.Run(name, func(t *testing.T) {
+			store := NewReservingAnimalStore(backend, time.Hour)
+			reserved, _, err := store.ReserveID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			skipped := func(id int) bool { return id == reserved+1 }
+			created, err := store.CreateAnimalAssigningID(Animal{Name: "Cub"}, skipped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reserved != 1 || created.ID != 3 {
+				t.Errorf("reserved %d, assigned %d; want 1 reserved and 3 assigned past it and the skipped 2", reserved, created.ID)
+			}
+			if _, err := store.GetAnimalByID(created.ID); err != nil {
+				t.Errorf("assigned animal not stored: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateWithAssignedID(t *testing.T) {
+	tests := []struct {
+		name       string
+		assignIDs  bool
+		wantStatus int
+		wantID     int
+	}{
+		{"assigned", true, http.StatusCreated, 4},
+		{"assignment disabled", false, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			for _, animal := range seedAnimals() {
+				if err := store.CreateAnimal(animal); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := testConfig()
+			cfg.AssignIDs = tt.assignIDs
+			rec := serve(newTestAPI(store, cfg), http.MethodPost, "/v1/animals", `{"name": "Tapir", "class": "mammal", "legs": 4}`)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantID == 0 {
+				return
+			}
+			if animal := decodeAnimalResponse(t, rec); animal.ID != tt.wantID {
+				t.Errorf("id = %d, want %d", animal.ID, tt.wantID)
+			}
+			if got, want := rec.Header().Get("Location"), animalLocation("/v1", tt.wantID); got != want {
+				t.Errorf("Location = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// BenchmarkAPI measures the main request paths against the in-memory store holding 10,000
+// animals, through the same routes and middleware the server mounts. Profile one with e.g.
+// go test -run '^$' -bench API/mixed -cpuprofile cpu.out, then go tool pprof cpu.out.
+func BenchmarkAPI(b *testing.B) {
+	const animals = 10000
+	create := `{"name": "Okapi", "class": "mammal", "legs": 4}`
+	workloads := []struct {
+		name     string
+		parallel bool
+		request  func(i int) (method, target, body string, want int)
+	}{
+		{"list", false, func(i int) (string, string, string, int) {
+			return http.MethodGet, "/v1/animals?limit=100", "", http.StatusOK
+		}},
+		{"list-filtered", false, func(i int) (string, string, string, int) {
+			return http.MethodGet, "/v1/animals?class=bird&sort=-name&limit=100", "", http.StatusOK
+		}},
+		{"get", false, func(i int) (string, string, string, int) {
+			return http.MethodGet, fmt.Sprintf("/v1/animals/%d", i%animals+1), "", http.StatusOK
+		}},
+		{"create", false, func(i int) (string, string, string, int) {
+			return http.MethodPost, "/v1/animals", create, http.StatusCreated
+		}},
+		// Eight lookups, a list and a create in every ten requests, from all CPUs at once
+		{"mixed", true, func(i int) (string, string, string, int) {
+			switch i % 10 {
+			case 0:
+				return http.MethodPost, "/v1/animals", create, http.StatusCreated
+			case 1:
+				return http.MethodGet, "/v1/animals?limit=100", "", http.StatusOK
+			default:
+				return http.MethodGet, fmt.Sprintf("/v1/animals/%d", rand.IntN(animals)+1), "", http.StatusOK
+			}
+		}},
+	}
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			store := NewInMemoryAnimalStore(0)
+			for id := 1; id <= animals; id++ {
+				class := "mammal"
+				if id%4 == 0 {
+					class = "bird"
+				}
+				if err := store.CreateAnimal(Animal{ID: id, Name: fmt.Sprintf("Animal %d", id), Class: class, Legs: 4}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			api := newTestAPI(store, testConfig())
+			run := func(i int) bool {
+				method, target, body, want := w.request(i)
+				if rec := serve(api, method, target, body); rec.Code != want {
+					b.Errorf("%s %s: status = %d, want %d", method, target, rec.Code, want)
+					return false
+				}
+				return true
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			if !w.parallel {
+				for i := 0; i < b.N; i++ {
+					if !run(i) {
+						return
+					}
+				}
+				return
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if !run(i) {
+						return
+					}
+				}
+			})
+		})
+	}
+}
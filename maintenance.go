@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Maintenance window modes: what the API still serves during a window.
+const (
+	maintenanceUnavailable = "unavailable" // Every request (except the exempt paths) gets 503
+	maintenanceReadOnly    = "read-only"   // Writes get 503, like read-only mode; reads are served
+)
+
+// defaultMaintenanceExempt are the paths served even during an unavailable window, so that
+// probes and scrapes keep working.
+const defaultMaintenanceExempt = "/healthz,/readyz,/metrics"
+
+// maintenanceWindow is one scheduled maintenance period, either a single absolute interval or a
+// daily or weekly recurring one in UTC.
+type maintenanceWindow struct {
+	spec string // The window as configured, for logging
+	mode string // maintenanceUnavailable or maintenanceReadOnly
+
+	start, end time.Time // Absolute window; both zero for a recurring one
+
+	weekday  time.Weekday  // Day a weekly window starts on; ignored when daily
+	daily    bool          // Whether the recurring window repeats every day
+	from, to time.Duration // Offsets of the recurring window's start and end into its day; to <= from crosses midnight
+}
+
+// recurring reports whether the window repeats rather than covering a single interval.
+func (w maintenanceWindow) recurring() bool {
+	return w.start.IsZero()
+}
+
+// activeAt reports whether the window covers now and, if so, when it ends.
+func (w maintenanceWindow) activeAt(now time.Time) (time.Time, bool) {
+	if !w.recurring() {
+		return w.end, !now.Before(w.start) && now.Before(w.end)
+	}
+
+	// An occurrence that is still running started today or, if it crosses midnight, yesterday
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		if !w.daily && day.Weekday() != w.weekday {
+			continue
+		}
+		start, end := day.Add(w.from), day.Add(w.to)
+		if w.to <= w.from {
+			end = end.Add(24 * time.Hour)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseMaintenanceWindows parses a comma-separated spec of WINDOW[=MODE] entries, where WINDOW
+// is START/END in RFC 3339 (e.g. 2026-11-01T02:00:00Z/2026-11-01T04:00:00Z) or a recurring
+// [DAY ]HH:MM-HH:MM in UTC (e.g. 03:00-03:30 daily, or sun 22:00-02:00 weekly), and MODE is
+// unavailable (the default) or read-only.
+func parseMaintenanceWindows(spec string) ([]maintenanceWindow, error) {
+	var windows []maintenanceWindow
+	for _, entry := range splitList(spec) {
+		window, mode, _ := strings.Cut(entry, "=")
+		w := maintenanceWindow{spec: entry, mode: strings.TrimSpace(mode)}
+		if w.mode == "" {
+			w.mode = maintenanceUnavailable
+		}
+		if w.mode != maintenanceUnavailable && w.mode != maintenanceReadOnly {
+			return nil, fmt.Errorf("invalid maintenance window %q: mode must be %s or %s", entry, maintenanceUnavailable, maintenanceReadOnly)
+		}
+
+		window = strings.TrimSpace(window)
+		if startText, endText, ok := strings.Cut(window, "/"); ok {
+			start, startErr := time.Parse(time.RFC3339, strings.TrimSpace(startText))
+			end, endErr := time.Parse(time.RFC3339, strings.TrimSpace(endText))
+			if startErr != nil || endErr != nil || !end.After(start) {
+				return nil, fmt.Errorf("invalid maintenance window %q: expected START/END in RFC 3339 with END after START", entry)
+			}
+			w.start, w.end = start, end
+		} else if err := parseRecurringWindow(&w, window); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", entry, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// weekdays maps the abbreviated day names of recurring windows to their weekday; full names
+// such as "sunday" are accepted too.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseRecurringWindow fills in w from a recurring window such as "03:00-03:30" or "sun 22:00-02:00".
+func parseRecurringWindow(w *maintenanceWindow, window string) error {
+	w.daily = true
+	if day, times, ok := strings.Cut(window, " "); ok {
+		day = strings.ToLower(day)
+		weekday, known := weekdays[day]
+		if !known && len(day) > 3 {
+			weekday, known = weekdays[day[:3]]
+			known = known && day == strings.ToLower(weekday.String())
+		}
+		if !known {
+			return fmt.Errorf("unknown day %q", day)
+		}
+		w.weekday, w.daily = weekday, false
+		window = strings.TrimSpace(times)
+	}
+
+	fromText, toText, ok := strings.Cut(window, "-")
+	from, fromErr := parseClock(fromText)
+	to, toErr := parseClock(toText)
+	if !ok || fromErr != nil || toErr != nil || from == to {
+		return fmt.Errorf("expected START/END or [DAY ]HH:MM-HH:MM with different times")
+	}
+	w.from, w.to = from, to
+	return nil
+}
+
+// parseClock parses a UTC time of day such as "03:30" into its offset from midnight.
+func parseClock(text string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// MaintenanceSchedule is the set of configured maintenance windows.
+type MaintenanceSchedule struct {
+	windows []maintenanceWindow
+	exempt  *PathMatcher // Paths served regardless of the windows
+}
+
+// NewMaintenanceSchedule creates a schedule of the given windows, exempting the matched paths.
+func NewMaintenanceSchedule(windows []maintenanceWindow, exempt *PathMatcher) *MaintenanceSchedule {
+	return &MaintenanceSchedule{windows: windows, exempt: exempt}
+}
+
+// active returns the window in effect at now, if any. When windows overlap an unavailable one
+// wins over a read-only one, and among windows of the same mode the one ending last, so that
+// Retry-After points past all of them.
+func (s *MaintenanceSchedule) active(now time.Time) (maintenanceWindow, time.Time, bool) {
+	var current maintenanceWindow
+	var until time.Time
+	found := false
+	for _, w := range s.windows {
+		end, ok := w.activeAt(now)
+		if !ok {
+			continue
+		}
+		stricter := w.mode == maintenanceUnavailable && current.mode != maintenanceUnavailable
+		if !found || stricter || (w.mode == current.mode && end.After(until)) {
+			current, until, found = w, end, true
+		}
+	}
+	return current, until, found
+}
+
+// watch logs every time a maintenance window starts or ends, checking once a second until ctx
+// is done. It only logs; requests are checked against the clock by rejectDuringMaintenance.
+func (s *MaintenanceSchedule) watch(ctx context.Context) {
+	if len(s.windows) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var current string
+	for {
+		window, until, active := s.active(time.Now())
+		switch {
+		case active && window.spec != current:
+			log.Printf("maintenance window %s started: API %s until %s", window.spec, window.mode, until.UTC().Format(time.RFC3339))
+			current = window.spec
+		case !active && current != "":
+			log.Printf("maintenance window %s ended: API available", current)
+			current = ""
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rejectDuringMaintenance answers requests with 503 Service Unavailable while a maintenance
+// window is in effect: every request in an unavailable window, and the writes of a read-only
+// window (with the same exceptions as read-only mode). Retry-After gives the seconds until the
+// window ends, rounded up. The schedule's exempt paths are always served. Without windows the
+// middleware is a no-op.
+func rejectDuringMaintenance(schedule *MaintenanceSchedule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(schedule.windows) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			window, until, active := schedule.active(now)
+			if !active || schedule.exempt.Match(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if window.mode == maintenanceReadOnly {
+				if !isWriteMethod(r.Method) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				if route := mux.CurrentRoute(r); route != nil && readOnlySafeRoutes[route.GetName()] {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			retryAfter := int(math.Ceil(until.Sub(now).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "The API is unavailable for scheduled maintenance until "+until.UTC().Format(time.RFC3339), http.StatusServiceUnavailable)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import "sync"
+
+// MemoryStore is a concurrency-safe in-memory collection of entities of type V, keyed by the
+// K that key extracts from each entity. It holds the CRUD logic shared by the in-memory
+// stores, so new entity types (e.g. zoos or enclosures) can reuse it instead of copying it.
+//
+// Its methods each take the lock themselves. Entity stores that need to combine several steps
+// atomically (a capacity check with an insert, say) hold mu directly and work on items.
+type MemoryStore[K comparable, V any] struct {
+	mu    sync.RWMutex // Protects items; reads share the lock, writes hold it exclusively
+	items map[K]V
+	key   func(V) K
+}
+
+// NewMemoryStore creates an empty store whose entities are keyed by key.
+func NewMemoryStore[K comparable, V any](key func(V) K) *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{items: make(map[K]V), key: key}
+}
+
+// clone returns an independent copy of the store, e.g. as the working copy of a transaction.
+// Callers must hold mu.
+func (m *MemoryStore[K, V]) clone() *MemoryStore[K, V] {
+	copied := &MemoryStore[K, V]{items: make(map[K]V, len(m.items)), key: m.key}
+	for k, v := range m.items {
+		copied.items[k] = v
+	}
+	return copied
+}
+
+// Get returns the entity stored under k and whether there is one.
+func (m *MemoryStore[K, V]) Get(k K) (V, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.items[k]
+	return v, ok
+}
+
+// Insert stores v unless its key is already taken, reporting whether it was stored.
+func (m *MemoryStore[K, V]) Insert(v V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := m.key(v)
+	if _, exists := m.items[k]; exists {
+		return false
+	}
+	m.items[k] = v
+	return true
+}
+
+// Replace overwrites the entity with v's key, reporting false (and storing nothing) if there is none.
+func (m *MemoryStore[K, V]) Replace(v V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := m.key(v)
+	if _, exists := m.items[k]; !exists {
+		return false
+	}
+	m.items[k] = v
+	return true
+}
+
+// Put stores v whether or not its key exists, reporting whether it was newly created.
+func (m *MemoryStore[K, V]) Put(v V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := m.key(v)
+	_, exists := m.items[k]
+	m.items[k] = v
+	return !exists
+}
+
+// Delete removes the entity stored under k, reporting whether there was one.
+func (m *MemoryStore[K, V]) Delete(k K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.items[k]; !exists {
+		return false
+	}
+	delete(m.items, k)
+	return true
+}
+
+// Len returns the number of stored entities.
+func (m *MemoryStore[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.items)
+}
+
+// Values returns a snapshot of every stored entity, in no particular order.
+func (m *MemoryStore[K, V]) Values() []V {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	values := make([]V, 0, len(m.items))
+	for _, v := range m.items {
+		values = append(values, v)
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// enclosure is a second entity type, keyed by a string, to show MemoryStore is not tied to animals.
+type enclosure struct {
+	Code     string
+	Capacity int
+}
+
+func TestMemoryStore(t *testing.T) {
+	animals := NewMemoryStore(animalID)
+	enclosures := NewMemoryStore(func(e enclosure) string { return e.Code })
+
+	tests := []struct {
+		name string
+		op   func() bool
+		want bool
+	}{
+		{"insert animal", func() bool { return animals.Insert(Animal{ID: 1, Name: "Lion"}) }, true},
+		{"insert taken animal ID", func() bool { return animals.Insert(Animal{ID: 1, Name: "Tiger"}) }, false},
+		{"replace animal", func() bool { return animals.Replace(Animal{ID: 1, Name: "Lioness"}) }, true},
+		{"replace missing animal", func() bool { return animals.Replace(Animal{ID: 2, Name: "Tiger"}) }, false},
+		{"put new animal", func() bool { return animals.Put(Animal{ID: 2, Name: "Tiger"}) }, true},
+		{"put existing animal", func() bool { return animals.Put(Animal{ID: 2, Name: "Tigress"}) }, false},
+		{"delete animal", func() bool { return animals.Delete(2) }, true},
+		{"delete missing animal", func() bool { return animals.Delete(2) }, false},
+		{"insert enclosure", func() bool { return enclosures.Insert(enclosure{Code: "A1", Capacity: 2}) }, true},
+		{"insert taken enclosure code", func() bool { return enclosures.Insert(enclosure{Code: "A1"}) }, false},
+		{"put enclosure", func() bool { return enclosures.Put(enclosure{Code: "B2", Capacity: 5}) }, true},
+		{"replace enclosure", func() bool { return enclosures.Replace(enclosure{Code: "A1", Capacity: 3}) }, true},
+		{"delete missing enclosure", func() bool { return enclosures.Delete("C3") }, false},
+	}
+	for _, tt := range tests { // In order: each step sees the previous ones
+		if got := tt.op(); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if lion, ok := animals.Get(1); !ok || lion.Name != "Lioness" {
+		t.Errorf("Get(1) = %+v, %v, want the replaced Lioness", lion, ok)
+	}
+	if _, ok := animals.Get(2); ok {
+		t.Error("Get(2) found a deleted animal")
+	}
+	if n := animals.Len(); n != 1 {
+		t.Errorf("animals.Len() = %d, want 1", n)
+	}
+	if a1, ok := enclosures.Get("A1"); !ok || a1.Capacity != 3 {
+		t.Errorf(`Get("A1") = %+v, %v, want capacity 3`, a1, ok)
+	}
+	codes := []string{}
+	for _, e := range enclosures.Values() {
+		codes = append(codes, e.Code)
+	}
+	sort.Strings(codes)
+	if fmt.Sprint(codes) != "[A1 B2]" {
+		t.Errorf("enclosure codes = %v, want [A1 B2]", codes)
+	}
+}
+
+func TestMemoryStoreConcurrentInsert(t *testing.T) {
+	store := NewMemoryStore(func(e enclosure) string { return e.Code })
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inserted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Insert(enclosure{Code: "A1", Capacity: i}) {
+				mu.Lock()
+				inserted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if inserted != 1 || store.Len() != 1 {
+		t.Errorf("%d concurrent inserts of one key succeeded, leaving %d entities; want exactly 1", inserted, store.Len())
+	}
+}
+
+func TestInMemoryAnimalStoreHidesMemoryStore(t *testing.T) {
+	// The generic writes would skip capacity, timestamps and LRU tracking
+	var store any = NewInMemoryAnimalStore(1)
+	for name, ok := range map[string]bool{
+		"Insert":  implements[interface{ Insert(Animal) bool }](store),
+		"Put":     implements[interface{ Put(Animal) bool }](store),
+		"Replace": implements[interface{ Replace(Animal) bool }](store),
+		"Delete":  implements[interface{ Delete(int) bool }](store),
+	} {
+		if ok {
+			t.Errorf("InMemoryAnimalStore exposes MemoryStore.%s", name)
+		}
+	}
+}
+
+// implements reports whether v has the methods of I.
+func implements[I any](v any) bool {
+	_, ok := v.(I)
+	return ok
+}
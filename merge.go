@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Precedences of a merged field: which animal's value is kept when both have one.
+const (
+	preferSource = "source"
+	preferTarget = "target"
+)
+
+// mergeFields lists the fields whose precedence a merge request may set, in response order.
+// The location covers latitude and longitude together, which are only stored as a pair.
+var mergeFields = []string{"name", "class", "legs", "photo_url", "endangered", "location"}
+
+// mergeRequest is the body of the merge endpoint, e.g.
+// {"source": 2, "target": 1, "prefer": "target", "fields": {"name": "source"}}.
+type mergeRequest struct {
+	Source int               `json:"source"` // The duplicate, deleted by the merge
+	Target int               `json:"target"` // The animal that is kept
+	Prefer string            `json:"prefer"` // Precedence of every field not in Fields; source (the default) or target
+	Fields map[string]string `json:"fields"` // Precedence of single fields, overriding Prefer
+}
+
+// mergePolicy is the precedence of each field in a merge.
+type mergePolicy map[string]string
+
+// parseMergePolicy checks the precedences of a merge request and resolves them per field.
+func parseMergePolicy(req mergeRequest) (mergePolicy, []FieldError) {
+	var errs []FieldError
+	prefer := strings.ToLower(strings.TrimSpace(req.Prefer))
+	switch prefer {
+	case "":
+		prefer = preferSource
+	case preferSource, preferTarget:
+	default:
+		errs = append(errs, FieldError{Field: "prefer", Message: "prefer must be source or target"})
+	}
+
+	policy := make(mergePolicy, len(mergeFields))
+	for _, field := range mergeFields {
+		policy[field] = prefer
+	}
+	for _, field := range slices.Sorted(maps.Keys(req.Fields)) {
+		precedence := req.Fields[field]
+		if _, ok := policy[field]; !ok {
+			errs = append(errs, FieldError{Field: "fields." + field, Message: "unknown field (supported: " + strings.Join(mergeFields, ", ") + ")"})
+			continue
+		}
+		switch precedence = strings.ToLower(strings.TrimSpace(precedence)); precedence {
+		case preferSource, preferTarget:
+			policy[field] = precedence
+		default:
+			errs = append(errs, FieldError{Field: "fields." + field, Message: "precedence must be source or target"})
+		}
+	}
+	return policy, errs
+}
+
+// merge returns target with the non-empty fields of source copied into it. A field empty in
+// one animal (an empty string, 0 legs, no location, not endangered) takes the other's value; a
+// field both animals have takes the value of the animal the policy prefers for it. ID and
+// timestamps stay the target's.
+func (p mergePolicy) merge(source, target Animal) Animal {
+	pick := func(field string, sourceEmpty, targetEmpty bool) bool {
+		return !sourceEmpty && (targetEmpty || p[field] == preferSource)
+	}
+	merged := target
+	if pick("name", source.Name == "", target.Name == "") {
+		merged.Name = source.Name
+	}
+	if pick("class", source.Class == "", target.Class == "") {
+		merged.Class = source.Class
+	}
+	if pick("legs", source.Legs == 0, target.Legs == 0) {
+		merged.Legs = source.Legs
+	}
+	if pick("photo_url", source.PhotoURL == "", target.PhotoURL == "") {
+		merged.PhotoURL = source.PhotoURL
+	}
+	if pick("endangered", !source.Endangered, !target.Endangered) {
+		merged.Endangered = source.Endangered
+	}
+	if pick("location", source.Latitude == nil, target.Latitude == nil) {
+		merged.Latitude, merged.Longitude = source.Latitude, source.Longitude
+	}
+	return merged
+}
+
+// mergedRecord returns the record a store keeps for a merge: merge's result under the target's
+// ID and CreatedAt, written at now.
+func mergedRecord(source, target Animal, merge func(source, target Animal) Animal, now time.Time) Animal {
+	merged := merge(source, target)
+	merged.ID = target.ID
+	merged.CreatedAt = target.CreatedAt
+	merged.UpdatedAt = now
+	return merged
+}
+
+// checkMergeIDs rejects a merge of an animal into itself, which would delete it.
+func checkMergeIDs(sourceID, targetID int) error {
+	if sourceID == targetID {
+		return fmt.Errorf("animal with ID %d: %w", sourceID, ErrSameAnimal)
+	}
+	return nil
+}
+
+// mergeAnimalsHandler handles POST requests that merge a duplicate into the animal it
+// duplicates: the target keeps its ID and gets the fields of the source as the precedence rules
+// decide (see mergePolicy.merge), the source is deleted, and the merged animal is returned.
+// Both happen atomically, so no request sees the pair half-merged. It answers 400 if source and
+// target are the same animal, 404 if either does not exist, and 422 for an invalid body or,
+// when leg rules are enforced, a merged animal that breaks the rule of its class.
+func mergeAnimalsHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req mergeRequest
+		if err := decodeJSONBody(r.Body, &req); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		policy, errs := parseMergePolicy(req)
+		if req.Source <= 0 {
+			errs = append(errs, FieldError{Field: "source", Message: "source must be a positive animal ID"})
+		}
+		if req.Target <= 0 {
+			errs = append(errs, FieldError{Field: "target", Message: "target must be a positive animal ID"})
+		}
+		if len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+		if req.Source == req.Target {
+			http.Error(w, "Source and target must be different animals", http.StatusBadRequest)
+			return
+		}
+
+		var merged Animal
+		var err error
+		errRuleBroken := errors.New("leg rule broken")
+		if !cfg.EnforceLegRules {
+			merged, err = store.MergeAnimals(req.Source, req.Target, policy.merge)
+		} else {
+			// Merge, then check the merged animal's legs against its class's rule
+			err = store.WithTransaction(r.Context(), func(tx AnimalStore) error {
+				var err error
+				if merged, err = tx.MergeAnimals(req.Source, req.Target, policy.merge); err != nil {
+					return err
+				}
+				if rule, ok := cfg.LegRules[strings.ToLower(merged.Class)]; ok && (merged.Legs < rule.Min || merged.Legs > rule.Max) {
+					errs = append(errs, FieldError{Field: "legs", Message: fmt.Sprintf("the merged animal has %d legs: %s", merged.Legs, legRuleMessage(merged.Class, rule))})
+					return errRuleBroken
+				}
+				return nil
+			})
+		}
+		if errors.Is(err, errRuleBroken) {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+		if err != nil {
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound) // 404 Not Found
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAnimalResult(w, r, cfg.ETagMode, http.StatusOK, merged)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultLatencyBuckets are histogram buckets (in seconds) tuned for a fast in-memory API:
+// fine-grained from 0.1ms up to 1s, instead of the Prometheus defaults that start at 5ms.
+const defaultLatencyBuckets = "0.0001,0.00025,0.0005,0.001,0.0025,0.005,0.01,0.025,0.05,0.1,0.25,0.5,1"
+
+// uninstrumentedRoutes are route templates excluded from HTTP metrics to avoid noise from scrapes and probes.
+var uninstrumentedRoutes = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// Metrics holds the Prometheus registry and the HTTP collectors of the API.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewMetrics creates a registry with Go runtime and process collectors plus the HTTP
+// request counter and latency histogram using the given buckets.
+func NewMetrics(buckets []float64) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anekazoo_http_requests_total",
+			Help: "Number of HTTP requests served, by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "anekazoo_http_request_duration_seconds",
+			Help:    "HTTP request latency in seconds, by method and route template.",
+			Buckets: buckets,
+		}, []string{"method", "route"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.latency,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format, or in the OpenMetrics
+// format (which carries exemplars) when the scraper asks for it.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Middleware is router middleware recording request count and latency. Requests are labelled
+// with the matched route template (e.g. /v1/animals/{id}) rather than the raw path, so IDs do
+// not explode label cardinality.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		if uninstrumentedRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		observeWithTraceExemplar(m.latency.WithLabelValues(r.Method, route), time.Since(start).Seconds(), r)
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// observeWithTraceExemplar records the value, attaching the request's trace ID as an
+// OpenMetrics exemplar when the request has a valid trace context.
+func observeWithTraceExemplar(observer prometheus.Observer, value float64, r *http.Request) {
+	spanContext := trace.SpanContextFromContext(r.Context())
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanContext.IsValid() {
+		exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+		return
+	}
+	observer.Observe(value)
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and passes it on.
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the implicit 200 status and passes the bytes on.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so streaming handlers keep working when instrumented.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// parseBuckets parses a comma-separated list of strictly increasing bucket bounds in seconds.
+func parseBuckets(spec string) ([]float64, error) {
+	var buckets []float64
+	for _, part := range strings.Split(spec, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("invalid bucket %q: must be a positive number of seconds", part)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be strictly increasing (%v after %v)", bound, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+// healthzHandler is a liveness probe: it answers 200 OK as long as the process serves HTTP.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// readyzHandler is a readiness probe: 200 OK while ready is set, 503 Service Unavailable once
+// shutdown has begun, so load balancers stop routing new traffic before the server stops.
+func readyzHandler(ready *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"shutting down"}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"status":"ready"}` + "\n"))
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// inFlightRetryAfterSeconds is the Retry-After hint sent when a request is shed for lack of capacity.
+const inFlightRetryAfterSeconds = 1
+
+// requiredContentTypes maps each write method to the media type its request body must declare.
+var requiredContentTypes = map[string]string{
+	http.MethodPost:  "application/json",
+	http.MethodPut:   "application/json",
+	http.MethodPatch: mergePatchContentType,
+}
+
+// requireJSONContentType rejects POST/PUT/PATCH requests whose body is not declared with the
+// expected JSON media type (application/json, or application/merge-patch+json for PATCH) with
+// 415 Unsupported Media Type. Parameters such as "; charset=utf-8" are tolerated, and requests
+// without a body are let through. When strict is false the middleware is a no-op, so lenient
+// clients keep working during a migration.
+func requireJSONContentType(strict bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strict || r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if required, ok := requiredContentTypes[r.Method]; ok {
+				mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				if err != nil || mediaType != required {
+					if r.Method == http.MethodPatch {
+						w.Header().Set("Accept-Patch", mergePatchContentType)
+					}
+					http.Error(w, "Content-Type must be "+required, http.StatusUnsupportedMediaType)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitInFlight caps the number of requests being served concurrently at max, using a
+// buffered channel as a semaphore. Acquisition never blocks: when every slot is taken the
+// request is shed immediately with 503 Service Unavailable and a Retry-After header. This
+// bounds concurrency rather than request rate. Exempt paths are served without taking a slot.
+// A max of 0 or less disables the limit.
+func limitInFlight(max int, exempt *PathMatcher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		slots := make(chan struct{}, max)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt.Match(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+				// Released in a defer so the slot is returned even if the handler panics
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", strconv.Itoa(inFlightRetryAfterSeconds))
+				http.Error(w, "Server is at capacity, please retry", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// Trailing-slash policies: how a path such as /v1/animals/ is treated.
+const (
+	trailingSlashRedirect = "redirect" // 308 Permanent Redirect to the canonical path
+	trailingSlashRewrite  = "rewrite"  // Serve the canonical path directly
+	trailingSlashStrict   = "strict"   // No special handling; the variant is 404 Not Found
+)
+
+// limitQueryString rejects requests whose query string is longer than maxLength bytes or has
+// more than maxParams parameters with 400 Bad Request and a problem document, before anything
+// parses it: the router, the filter and search parsers and url.ParseQuery all do work that
+// grows with the query. Parameters are counted by their & separators on the raw string, so
+// the check itself allocates nothing. A limit of 0 or less disables that check.
+func limitQueryString(maxParams, maxLength int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxParams <= 0 && maxLength <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.URL.RawQuery
+			var detail string
+			if maxLength > 0 && len(raw) > maxLength {
+				detail = fmt.Sprintf("The query string is %d bytes long; at most %d are allowed.", len(raw), maxLength)
+			} else if params := strings.Count(raw, "&") + 1; maxParams > 0 && raw != "" && params > maxParams {
+				detail = fmt.Sprintf("The query string has %d parameters; at most %d are allowed.", params, maxParams)
+			}
+			if detail != "" {
+				writeProblem(w, r, ProblemDetails{
+					Type:   problemTypeQueryTooLarge,
+					Title:  "Query string too large",
+					Status: http.StatusBadRequest,
+					Detail: detail,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBodySize caps request bodies at maxBytes with http.MaxBytesReader, so no handler reads
+// more than that into memory; reading past the limit fails with *http.MaxBytesError, which
+// writeInvalidBody answers with 413 Content Too Large. A limit of 0 or less disables the check.
+func limitBodySize(maxBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// normalizeTrailingSlash handles request paths ending in one or more slashes (other than "/"
+// itself) according to policy. It wraps the whole router, since mux middleware only runs once a
+// route has matched. The redirect uses 308 rather than 301 so clients repeat the method and body,
+// which keeps it safe for writes; the query string is preserved. Only the end of the path is
+// touched, so {id} path parameters are unaffected.
+func normalizeTrailingSlash(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if policy == trailingSlashStrict {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := strings.TrimRight(r.URL.Path, "/")
+			if path == r.URL.Path || path == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			canonical := *r.URL
+			canonical.Path = path
+			canonical.RawPath = strings.TrimRight(r.URL.RawPath, "/")
+			if policy == trailingSlashRedirect {
+				http.Redirect(w, r, canonical.RequestURI(), http.StatusPermanentRedirect)
+				return
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.URL = &canonical
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// exportRoute names the ZIP export route, which can send large archives and is never timed out.
+const exportRoute = "export"
+
+// parseRouteTimeouts parses a spec such as "/v1/animals/query=2s,/v1/admin/reset=1m" into
+// per-route request timeouts, keyed by the route's path template (e.g. /v1/animals/{id}).
+// A timeout of 0 disables the limit for that route.
+func parseRouteTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, value, ok := strings.Cut(entry, "=")
+		path = strings.TrimSpace(path)
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || !strings.HasPrefix(path, "/") || err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid route timeout %q: expected /path=DURATION, e.g. /v1/animals/query=2s", entry)
+		}
+		timeouts[path] = timeout
+	}
+	return timeouts, nil
+}
+
+// limitDuration is API middleware that aborts requests running longer than cfg.RequestTimeout,
+// or the route's entry in cfg.RouteTimeouts, with 503 Service Unavailable and a problem
+// document. It uses http.TimeoutHandler, which also gives the handler a request context with
+// that deadline, so store work that honours the context (transactions) stops as well; anything
+// the handler writes after the deadline is discarded. Large responses (the NDJSON stream and the
+// ZIP export) are never timed out, since TimeoutHandler buffers the whole response, and neither
+// are long polls, which bound their own wait with -poll-timeout.
+//
+// With cfg.DeadlineHeader set, a caller such as an API gateway can shorten the limit for its
+// request by sending its own deadline in that header (see parseDeadline); the server's limit
+// stays the maximum. A request whose caller deadline has already passed is answered with 504
+// Gateway Timeout without running the handler, and one that runs past it is aborted with 504
+// too. Large responses and long polls get the caller's deadline on their context only.
+func limitDuration(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := cfg.RequestTimeout
+			route := mux.CurrentRoute(r)
+			streaming := acceptsMediaType(r, ndjsonContentType)
+			if route != nil {
+				if name := route.GetName(); name == exportRoute || name == pollRoute {
+					streaming = true
+				} else if template, err := route.GetPathTemplate(); err == nil {
+					if override, ok := cfg.RouteTimeouts[template]; ok {
+						timeout = override
+					}
+				}
+			}
+			if streaming {
+				timeout = 0
+			}
+
+			callerDeadline := false
+			if raw := r.Header.Get(cfg.DeadlineHeader); cfg.DeadlineHeader != "" && raw != "" {
+				deadline, err := parseDeadline(raw, time.Now())
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Invalid %s header: %v", cfg.DeadlineHeader, err), http.StatusBadRequest)
+					return
+				}
+				remaining := time.Until(deadline)
+				if remaining <= 0 {
+					writeProblem(w, r, ProblemDetails{
+						Type:   problemTypeTimeout,
+						Title:  "Deadline exceeded",
+						Status: http.StatusGatewayTimeout,
+						Detail: "The caller's deadline had passed before the request was served.",
+					})
+					return
+				}
+				if streaming {
+					ctx, cancel := context.WithDeadline(r.Context(), deadline)
+					defer cancel()
+					r = r.WithContext(ctx)
+				} else if timeout <= 0 || remaining < timeout {
+					timeout, callerDeadline = remaining, true
+				}
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			problem := ProblemDetails{
+				Type:   problemTypeTimeout,
+				Title:  "Request timed out",
+				Status: http.StatusServiceUnavailable,
+				Detail: "The request did not complete within " + timeout.String() + ".",
+			}
+			if callerDeadline {
+				problem.Title = "Deadline exceeded"
+				problem.Status = http.StatusGatewayTimeout
+				problem.Detail = "The request did not complete within the caller's deadline of " + timeout.Round(time.Millisecond).String() + "."
+			}
+			body, _ := json.Marshal(problem)
+			http.TimeoutHandler(next, timeout, string(body)).ServeHTTP(timeoutContentType{w, problem.Status}, r)
+		})
+	}
+}
+
+// parseDeadline parses the value of a deadline header received at now. It may be a number of
+// milliseconds left, as Envoy's x-envoy-expected-rq-timeout-ms sends it, a duration left such as
+// 1.5s, or an absolute RFC 3339 time such as 2026-10-14T09:30:00.250Z.
+func parseDeadline(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return now.Add(time.Duration(ms) * time.Millisecond), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither milliseconds, a duration such as 1.5s, nor an RFC 3339 time", value)
+}
+
+// timeoutContentType labels the 503 that http.TimeoutHandler writes on a timeout as a problem
+// document, and turns it into status, which is 504 when the caller's deadline ran out. That
+// response carries no Content-Type of its own, whereas responses a handler completed in time
+// bring the headers the handler set.
+type timeoutContentType struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader sets the problem+json Content-Type and the timeout status on a 503 without a
+// Content-Type, then writes the status.
+func (w timeoutContentType) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/problem+json")
+		status = w.status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitQueryString(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxParams  int
+		maxLength  int
+		query      string
+		wantStatus int
+	}{
+		{"no query", 3, 20, "", http.StatusOK},
+		{"within both limits", 3, 20, "a=1&b=2&c=3", http.StatusOK},
+		{"at the length limit", 0, 11, "a=1&b=2&c=3", http.StatusOK},
+		{"one byte too long", 0, 10, "a=1&b=2&c=3", http.StatusBadRequest},
+		{"one parameter too many", 2, 0, "a=1&b=2&c=3", http.StatusBadRequest},
+		{"repeated parameters count", 2, 0, "a=1&a=1&a=1", http.StatusBadRequest},
+		{"empty parameters count", 2, 0, "&&", http.StatusBadRequest},
+		{"limits disabled", 0, 0, strings.Repeat("a=1&", 10000), http.StatusOK},
+		{"pathological length", 100, 8192, strings.Repeat("class=mammal&", 100000), http.StatusBadRequest},
+		{"pathological parameter count", 100, 0, strings.Repeat("&", 100000), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			handler := limitQueryString(tt.maxParams, tt.maxLength)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/v1/animals", nil)
+			req.URL.RawQuery = tt.query
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v, want it reached only by accepted requests", reached)
+			}
+			if tt.wantStatus == http.StatusBadRequest {
+				if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+					t.Errorf("Content-Type = %q, want a problem document", got)
+				}
+				if !strings.Contains(rec.Body.String(), problemTypeQueryTooLarge) {
+					t.Errorf("body = %s, want type %s", rec.Body, problemTypeQueryTooLarge)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitBodySize(t *testing.T) {
+	const limit = 256
+	padded := func(size int) string { // A valid animal body of exactly size bytes
+		body := `{"name": "Lion", "class": "mammal", "legs": 4, "photo_url": "https://example.com/"}`
+		return body[:len(body)-2] + strings.Repeat("x", size-len(body)) + `"}`
+	}
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		headers    []string
+		wantStatus int
+	}{
+		{"create at the limit", http.MethodPost, "/v1/animals", padded(limit), nil, http.StatusCreated},
+		{"create over the limit", http.MethodPost, "/v1/animals", padded(limit + 1), nil, http.StatusRequestEntityTooLarge},
+		{"replace over the limit", http.MethodPut, "/v1/animals/1", padded(limit + 1), nil, http.StatusRequestEntityTooLarge},
+		{"patch over the limit", http.MethodPatch, "/v1/animals/1", padded(limit + 1), []string{"Content-Type", "application/merge-patch+json"}, http.StatusRequestEntityTooLarge},
+		{"validate over the limit", http.MethodPost, "/v1/animals/validate", padded(limit + 1), nil, http.StatusRequestEntityTooLarge},
+		{"huge body", http.MethodPost, "/v1/animals", `{"name": "` + strings.Repeat("x", 10<<20) + `"}`, nil, http.StatusRequestEntityTooLarge},
+		{"malformed body within the limit", http.MethodPost, "/v1/animals", `{"name": `, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.MaxBodyBytes = limit
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Tiger", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := serve(newTestAPI(store, cfg), tt.method, tt.target, tt.body, tt.headers...)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge {
+				if !strings.Contains(rec.Body.String(), problemTypeBodyTooLarge) || !strings.Contains(rec.Body.String(), "larger than 256 bytes") {
+					t.Errorf("body = %s, want a %s problem naming the limit", rec.Body, problemTypeBodyTooLarge)
+				}
+				if tiger, _ := store.GetAnimalByID(1); tiger.Name != "Tiger" {
+					t.Errorf("an oversized write changed animal 1 to %+v", tiger)
+				}
+			}
+		})
+	}
+
+	// GraphQL reports the limit in its own error format
+	cfg := testConfig()
+	cfg.MaxBodyBytes = limit
+	query := `{"query": "{ animals { id } }", "pad": "` + strings.Repeat("x", limit) + `"}`
+	rec := serve(newTestAPI(NewInMemoryAnimalStore(0), cfg), http.MethodPost, "/v1/graphql", query)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Errorf("GraphQL: status = %d, body %s, want 413 with GraphQL errors", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ndjsonContentType is the media type for newline-delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery is the number of records written between flushes of the response.
+const ndjsonFlushEvery = 100
+
+// streamAnimalsNDJSON writes every animal matching the filter as one JSON object per line.
+// Records are streamed from the store as they are produced, and the response is flushed
+// periodically so clients can process them without waiting for the whole dataset. The stream
+// ends early when the request's context is done, e.g. because the client disconnected.
+// Pagination does not apply: the stream always carries the full filtered list.
+func streamAnimalsNDJSON(w http.ResponseWriter, r *http.Request, store AnimalStore, filter AnimalFilter) {
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w) // Encode terminates each value with a newline
+	written := 0
+
+	err := forEachMatching(r.Context(), store, filter, func(animal Animal) error {
+		if written == 0 {
+			w.Header().Set("Content-Type", ndjsonContentType)
+		}
+		if err := encoder.Encode(animal); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil && written%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if written > 0 {
+			// The status line is already sent; the client sees a truncated stream
+			log.Printf("ndjson: stream aborted after %d records: %v", written, err)
+			return
+		}
+		if errors.Is(err, ErrEmpty) {
+			http.Error(w, "No animals found in the system", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A filter that matches nothing yields an empty (but successful) stream
+	w.Header().Set("Content-Type", ndjsonContentType)
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
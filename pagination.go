@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Page is the effective pagination window applied to a list request.
+type Page struct {
+	Limit  int // Maximum number of items returned
+	Offset int // Number of items skipped from the start of the ordered result
+}
+
+// parsePage reads ?limit= and ?offset= from the request. A missing limit falls back to
+// cfg.DefaultLimit. A limit above cfg.MaxLimit is clamped to the maximum, or rejected
+// when cfg.StrictLimits is set.
+func parsePage(r *http.Request, cfg Config) (Page, error) {
+	page := Page{Limit: cfg.DefaultLimit}
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return Page{}, fmt.Errorf("limit must be a positive integer")
+		}
+		page.Limit = limit
+	}
+	if page.Limit > cfg.MaxLimit {
+		if cfg.StrictLimits {
+			return Page{}, fmt.Errorf("limit must not exceed %d", cfg.MaxLimit)
+		}
+		page.Limit = cfg.MaxLimit
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return Page{}, fmt.Errorf("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+	}
+	return page, nil
+}
+
+// apply returns the part of animals that falls inside the page window.
+func (p Page) apply(animals []Animal) []Animal {
+	return pageOf(p, animals)
+}
+
+// pageOf returns the slice of items covered by the page, for lists of anything but animals
+// (e.g. IDs); Page.apply is the same for animals.
+func pageOf[T any](p Page, items []T) []T {
+	if p.Offset >= len(items) {
+		return []T{}
+	}
+	end := p.Offset + p.Limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[p.Offset:end]
+}
+
+// writePageHeaders reports the pagination metadata: the total number of matching items
+// and the limit and offset that were actually applied.
+func writePageHeaders(w http.ResponseWriter, page Page, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("X-Limit", strconv.Itoa(page.Limit))
+	w.Header().Set("X-Offset", strconv.Itoa(page.Offset))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// mergePatchContentType is the media type of JSON Merge Patch documents (RFC 7386).
+const mergePatchContentType = "application/merge-patch+json"
+
+// applyMergePatch applies an RFC 7386 merge patch to a decoded JSON document.
+// Object members in the patch are merged recursively, a null member removes the field,
+// and any non-object patch replaces the target entirely.
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = applyMergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// patchAnimal returns the animal that results from applying the merge patch to current.
+// Fields the patch sets to null are cleared to their zero value. The ID and timestamps
+// always come from current, since they are not client-controlled.
+func patchAnimal(current Animal, patch map[string]interface{}) (Animal, error) {
+	document, err := json.Marshal(current)
+	if err != nil {
+		return Animal{}, err
+	}
+	var target map[string]interface{}
+	if err := json.Unmarshal(document, &target); err != nil {
+		return Animal{}, err
+	}
+
+	merged, err := json.Marshal(applyMergePatch(target, patch))
+	if err != nil {
+		return Animal{}, err
+	}
+	var patched Animal
+	if err := json.Unmarshal(merged, &patched); err != nil {
+		return Animal{}, err
+	}
+
+	patched.ID = current.ID
+	patched.CreatedAt = current.CreatedAt
+	patched.UpdatedAt = current.UpdatedAt
+	return patched, nil
+}
+
+// patchAnimalHandler handles PATCH requests that partially update an animal using
+// JSON Merge Patch: only the fields present in the body change, and null clears a field.
+// The result is validated like a full update before it is stored.
+func patchAnimalHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, err := parseID(r)
+		if err != nil {
+			writeInvalidID(w, r)
+			return
+		}
+
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+
+		// The patch must be a JSON object; anything else would replace the whole animal. Numbers
+		// are kept literal so an invalid legs value can be reported precisely.
+		var patch map[string]interface{}
+		if err := decodeJSONBody(r.Body, &patch, (*json.Decoder).UseNumber); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+		if patch == nil {
+			writeInvalidBody(w, r, errors.New("expected a JSON merge patch object, got null"))
+			return
+		}
+		if errs := patchLegsErrors(patch); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		current, err := store.GetAnimalByID(id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !checkIfMatch(w, r, current, cfg.ETagMode) {
+			return
+		}
+
+		animal, err := patchAnimal(*current, patch)
+		if err != nil {
+			http.Error(w, "Invalid request body: patch does not produce a valid animal", http.StatusBadRequest)
+			return
+		}
+
+		animal = normalizeAnimal(animal, false, cfg)
+		if errs := validateAnimal(animal, cfg); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		// In dry-run mode, report the patched animal without persisting it
+		if dryRun {
+			w.Header().Set("X-Dry-Run", "true")
+			writeAnimalResult(w, r, cfg.ETagMode, http.StatusOK, animal)
+			return
+		}
+
+		if err := store.UpdateAnimal(id, animal); err != nil {
+			// The animal may have been deleted since it was read
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if writeVetoed(w, r, err) {
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if stored, err := store.GetAnimalByID(id); err == nil {
+			animal = *stored
+		}
+		writeAnimalResult(w, r, cfg.ETagMode, http.StatusOK, animal)
+	}
+}
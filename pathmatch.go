@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathMatcher decides which request paths a middleware leaves alone, e.g. so that probes and
+// scrapes are never shed, limited or held up by maintenance. Each pattern covers a path and
+// everything below it: /metrics matches /metrics and /metrics/x but not /metricsx. Patterns
+// with glob characters (*, ? and [...], see path.Match) match segment by segment, so
+// /v1/animals/*/history matches the history of every animal, and * never spans a slash.
+// A nil or empty matcher matches nothing.
+type PathMatcher struct {
+	patterns []string
+}
+
+// NewPathMatcher creates a matcher of the given patterns, each an absolute path, with or
+// without glob characters. A malformed glob is an error.
+func NewPathMatcher(patterns []string) (*PathMatcher, error) {
+	m := &PathMatcher{}
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("pattern %q must start with /", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		if pattern != "/" {
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		m.patterns = append(m.patterns, pattern)
+	}
+	return m, nil
+}
+
+// parsePathMatcher parses a comma-separated list of patterns into a matcher.
+func parsePathMatcher(spec string) (*PathMatcher, error) {
+	return NewPathMatcher(splitList(spec))
+}
+
+// Match reports whether the path, or a path it lies below, matches one of the patterns.
+func (m *PathMatcher) Match(p string) bool {
+	if m == nil {
+		return false
+	}
+	for _, pattern := range m.patterns {
+		if pattern == "/" {
+			return true
+		}
+		// Try the path and each of its ancestors, cut at a slash
+		for candidate := p; candidate != ""; {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+			i := strings.LastIndexByte(candidate, '/')
+			if i <= 0 {
+				break
+			}
+			candidate = candidate[:i]
+		}
+	}
+	return false
+}
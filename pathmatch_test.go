@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathMatcher(t *testing.T) {
+	tests := []struct {
+		spec string
+		path string
+		want bool
+	}{
+		{"/metrics", "/metrics", true},
+		{"/metrics", "/metrics/x", true},
+		{"/metrics", "/metricsx", false},
+		{"/metrics", "/", false},
+		{"/metrics/", "/metrics", true}, // A trailing slash is ignored
+		{"/docs", "/docs/index.html", true},
+		{"/healthz,/readyz", "/readyz", true},
+		{"/healthz,/readyz", "/v1/animals", false},
+		{"/v1/animals/*/history", "/v1/animals/7/history", true},
+		{"/v1/animals/*/history", "/v1/animals/7/history/2", true},
+		{"/v1/animals/*/history", "/v1/animals/7", false},
+		{"/v1/animals/*/history", "/v1/animals/7/8/history", false}, // * never spans a slash
+		{"/v1/animals/?", "/v1/animals/7", true},
+		{"/v1/animals/?", "/v1/animals/42", false},
+		{"/v1/animals/[0-9]*", "/v1/animals/42", true},
+		{"/v1/animals/[0-9]*", "/v1/animals/grouped", false},
+		{"/", "/anything/at/all", true},
+		{"", "/metrics", false},
+	}
+	for _, tt := range tests {
+		m, err := parsePathMatcher(tt.spec)
+		if err != nil {
+			t.Fatalf("parsePathMatcher(%q): %v", tt.spec, err)
+		}
+		if got := m.Match(tt.path); got != tt.want {
+			t.Errorf("%q matches %q = %v, want %v", tt.spec, tt.path, got, tt.want)
+		}
+	}
+
+	var unset *PathMatcher
+	if unset.Match("/metrics") {
+		t.Error("a nil matcher matched")
+	}
+	for _, spec := range []string{"metrics", "/v1/[animals"} {
+		if _, err := parsePathMatcher(spec); err == nil {
+			t.Errorf("parsePathMatcher(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestExemptPaths(t *testing.T) {
+	exempt, err := parsePathMatcher("/healthz,/metrics,/v1/animals/*/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each saturates its middleware, then checks that the exempt paths still get through
+	tests := []struct {
+		name       string
+		middleware func(next http.Handler) (http.Handler, func())
+		method     string
+		wantStatus int // Of the paths that are not exempt
+	}{
+		{"load shedding", func(next http.Handler) (http.Handler, func()) {
+			release := make(chan struct{})
+			held := make(chan struct{})
+			handler := limitInFlight(1, exempt)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/hold" {
+					close(held)
+					<-release
+					return
+				}
+				next.ServeHTTP(w, r)
+			}))
+			go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hold", nil))
+			<-held
+			return handler, func() { close(release) }
+		}, http.MethodGet, http.StatusServiceUnavailable},
+		{"write limit", func(next http.Handler) (http.Handler, func()) {
+			handler := guardMutations(NewMutationGuard(1, time.Minute), exempt)(next)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/animals", nil))
+			return handler, func() {}
+		}, http.MethodPost, http.StatusTooManyRequests},
+		{"maintenance", func(next http.Handler) (http.Handler, func()) {
+			now := time.Now()
+			window := maintenanceWindow{spec: "test", mode: maintenanceUnavailable, start: now.Add(-time.Hour), end: now.Add(time.Hour)}
+			return rejectDuringMaintenance(NewMaintenanceSchedule([]maintenanceWindow{window}, exempt))(next), func() {}
+		}, http.MethodGet, http.StatusServiceUnavailable},
+	}
+	paths := []struct {
+		path   string
+		exempt bool
+	}{
+		{"/healthz", true},
+		{"/metrics", true},
+		{"/v1/animals/7/history", true},
+		{"/v1/animals", false},
+		{"/v1/animals/7", false},
+		{"/healthzx", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, release := tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer release()
+			for _, p := range paths {
+				// Twice, so a limit of one is exceeded on the first try already
+				for range 2 {
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, httptest.NewRequest(tt.method, p.path, nil))
+					want := tt.wantStatus
+					if p.exempt {
+						want = http.StatusOK
+					}
+					if rec.Code != want {
+						t.Errorf("%s %s: status = %d, want %d", tt.method, p.path, rec.Code, want)
+					}
+				}
+			}
+		})
+	}
+}
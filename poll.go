@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pollRoute names the long-poll route, which waits for changes for up to -poll-timeout and is
+// exempt from the request timeout.
+const pollRoute = "poll"
+
+// pollVersionHeader carries the change version on every poll response, including timeouts.
+const pollVersionHeader = "X-Animals-Version"
+
+// changeLogSize is the number of changes the change log remembers. A poll for a version that
+// has fallen out of the log gets the whole collection instead of the animals changed since.
+const changeLogSize = 1024
+
+// changeClockSlack is subtracted from the start of the earliest change a poll reports, so a
+// small step of the wall clock between the start of a write and its UpdatedAt stamp cannot hide
+// the written animal. Polls may therefore repeat an animal they already reported.
+const changeClockSlack = time.Second
+
+// changeEntry is one write in the change log.
+type changeEntry struct {
+	version uint64
+	tenant  string    // The tenant whose store was written; "" in single-tenant mode
+	started time.Time // When the write started; the animals it wrote have later UpdatedAt stamps
+}
+
+// ChangeLog numbers the writes to the store with a global version that only grows,
+// remembers the last changeLogSize of them, and wakes the waiting polls on every one. Versions
+// are kept in memory only, so each server starts counting at its start time in Unix nanoseconds:
+// a version handed out before a restart is then older than the new log and asks for a reset.
+type ChangeLog struct {
+	mu      sync.Mutex
+	version uint64
+	entries []changeEntry // Oldest first, with consecutive versions ending at version
+	changed chan struct{} // Closed, and replaced, by every change
+	closed  bool
+}
+
+// NewChangeLog creates an empty change log whose version, that of the store as loaded, is the
+// current time. Version 0 is never handed out, so polls use it to ask for the whole collection.
+func NewChangeLog() *ChangeLog {
+	return &ChangeLog{version: uint64(time.Now().UnixNano()), changed: make(chan struct{})}
+}
+
+// Record adds a write to the tenant's store that started at started and wakes the
+// waiting polls.
+func (c *ChangeLog) Record(tenant string, started time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	if len(c.entries) == changeLogSize {
+		c.entries = c.entries[1:]
+	}
+	c.entries = append(c.entries, changeEntry{version: c.version, tenant: tenant, started: started})
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Close wakes every waiting poll for good, so a shutting-down server does not wait out their
+// timeouts; polls then return at once.
+func (c *ChangeLog) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.changed)
+	}
+}
+
+// changeSet is what a poll learns from the change log about the changes after its version.
+type changeSet struct {
+	version uint64          // The current version
+	changed bool            // The tenant's store changed after the version
+	since   time.Time       // When the earliest of those changes started
+	reset   bool            // The log cannot tell what changed: the version predates it or a restart
+	wake    <-chan struct{} // Closed by the next change (or Close)
+	closed  bool
+}
+
+// changesAfter reports the tenant's changes after version. A version of 0, one older than the
+// log (e.g. handed out before a restart) and one newer than the current version ask for a reset.
+func (c *ChangeLog) changesAfter(tenant string, version uint64) changeSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set := changeSet{version: c.version, wake: c.changed, closed: c.closed}
+	if version == 0 || version > c.version || c.version-version > uint64(len(c.entries)) {
+		set.changed, set.reset = true, true
+		return set
+	}
+	for _, entry := range c.entries[len(c.entries)-int(c.version-version):] {
+		if entry.tenant == tenant && (!set.changed || entry.started.Before(set.since)) {
+			set.changed, set.since = true, entry.started
+		}
+	}
+	return set
+}
+
+// recordChanges is API middleware that records every write in the change log, after the handler
+// returns, for the long polls. Reads, POST routes that only read and writes rejected with a 4xx
+// status are not recorded, nor is GraphQL, whose handler records its mutations itself. Writes
+// that failed with a 5xx status are, since they may have reached the store (e.g. one aborted by
+// the request timeout); a poll woken by one may list no animals.
+func recordChanges(changes *ChangeLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isWriteMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if route := mux.CurrentRoute(r); route != nil && (nonMutatingRoutes[route.GetName()] || route.GetName() == graphqlRoute) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			started := time.Now().UTC()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if recorder.status < http.StatusBadRequest || recorder.status >= http.StatusInternalServerError {
+				changes.Record(tenantFromContext(r.Context()), started)
+			}
+		})
+	}
+}
+
+// pollResponse is the body of a poll that saw changes.
+type pollResponse struct {
+	Version string   `json:"version"`         // A string, since versions can exceed what JavaScript numbers hold exactly
+	Reset   bool     `json:"reset,omitempty"` // Animals is the whole collection, not just the changed animals
+	Animals []Animal `json:"animals"`
+}
+
+// pollAnimalsHandler handles long polls for changes to the collection. ?since= is the version
+// of the last poll response (or 0 for the first poll); the poll blocks until a write to the
+// store makes the version exceed it, then answers with the new version and the animals written
+// since, oldest change first. Deleted animals are not listed, so the list may be empty. When the
+// change log cannot tell what changed (since is 0, too old, or from before a restart) the whole
+// collection is returned with reset set. ?timeout= shortens the wait, which never exceeds
+// maxWait; a poll that sees no change in time gets 204 No Content. Every response carries the
+// current version in X-Animals-Version.
+func pollAnimalsHandler(store AnimalStore, changes *ChangeLog, maxWait time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "since must be the version of the last poll response, or 0", http.StatusBadRequest)
+			return
+		}
+		wait := maxWait
+		if raw := r.URL.Query().Get("timeout"); raw != "" {
+			timeout, err := time.ParseDuration(raw)
+			if err != nil || timeout < 0 {
+				http.Error(w, "timeout must be a non-negative duration, e.g. 10s", http.StatusBadRequest)
+				return
+			}
+			wait = min(timeout, maxWait)
+		}
+
+		set := waitForChanges(r.Context(), changes, tenantFromContext(r.Context()), since, wait)
+		w.Header().Set(pollVersionHeader, strconv.FormatUint(set.version, 10))
+		if !set.changed {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var animals []Animal
+		if set.reset {
+			animals, err = store.GetAllAnimals()
+		} else {
+			animals, err = store.GetAnimalsModifiedSince(set.since.Add(-changeClockSlack))
+		}
+		if err != nil && !errors.Is(err, ErrEmpty) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if animals == nil {
+			animals = []Animal{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		respondJSON(w, r, pollResponse{Version: strconv.FormatUint(set.version, 10), Reset: set.reset, Animals: animals})
+	}
+}
+
+// waitForChanges blocks until the tenant's store changes after version, wait elapses, the change
+// log is closed or ctx is done, whichever comes first. Changes to the stores of other tenants
+// advance the version without ending the wait. A poll whose client went away (or whose caller
+// deadline passed) stops waiting and is answered like one that timed out.
+func waitForChanges(ctx context.Context, changes *ChangeLog, tenant string, version uint64, wait time.Duration) changeSet {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		set := changes.changesAfter(tenant, version)
+		if set.changed || set.closed {
+			return set
+		}
+		select {
+		case <-set.wake:
+		case <-timer.C:
+			return changes.changesAfter(tenant, version)
+		case <-ctx.Done():
+			return set
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// pprofPrefix is the path under which the profiling endpoints are served.
+const pprofPrefix = "/debug/pprof/"
+
+// servePprof returns next with the net/http/pprof endpoints mounted in front of it under
+// /debug/pprof/, e.g. /debug/pprof/profile for a CPU profile and /debug/pprof/heap for the heap.
+// They sit outside the router and its middleware, so profiles are neither shed, limited, traced
+// nor timed out, and a 30-second CPU profile is not cut short. Profiles expose the program's
+// internals and cost CPU time, so only clients connecting from a loopback address are served;
+// others get 403 Forbidden.
+func servePprof(next http.Handler) http.Handler {
+	profiles := http.NewServeMux()
+	profiles.HandleFunc(pprofPrefix, pprof.Index) // Also serves the named profiles, e.g. heap and goroutine
+	profiles.HandleFunc(pprofPrefix+"cmdline", pprof.Cmdline)
+	profiles.HandleFunc(pprofPrefix+"profile", pprof.Profile)
+	profiles.HandleFunc(pprofPrefix+"symbol", pprof.Symbol)
+	profiles.HandleFunc(pprofPrefix+"trace", pprof.Trace)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != strings.TrimSuffix(pprofPrefix, "/") && !strings.HasPrefix(r.URL.Path, pprofPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isLoopback(r.RemoteAddr) {
+			http.Error(w, "Profiling endpoints are only served to localhost", http.StatusForbidden)
+			return
+		}
+		profiles.ServeHTTP(w, r) // Redirects /debug/pprof to /debug/pprof/, whose links are relative
+	})
+}
+
+// isLoopback reports whether the remote address of a request is a loopback address, such as
+// 127.0.0.1 or ::1.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Problem type URIs, one per error category (RFC 7807 "type" member).
+// They are relative references so they stay valid wherever the API is mounted.
+const (
+	problemTypeValidation       = "/problems/validation-error"
+	problemTypeNotFound         = "/problems/not-found"
+	problemTypeMethodNotAllowed = "/problems/method-not-allowed"
+	problemTypeVetoed           = "/problems/vetoed"
+	problemTypeInvalidID        = "/problems/invalid-id"
+	problemTypeTimeout          = "/problems/timeout"
+	problemTypeQueryTooLarge    = "/problems/query-too-large"
+	problemTypeInvalidBody      = "/problems/invalid-body"
+	problemTypeBodyTooLarge     = "/problems/body-too-large"
+	problemTypeTooManyWrites    = "/problems/too-many-writes"
+)
+
+// allowCandidateMethods are the methods probed when building the Allow header of a 405 response.
+var allowCandidateMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// FieldError describes a validation failure for a single field of a request payload.
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name of the offending field (e.g. "name")
+	Message string `json:"message"` // Human-readable description of the problem
+}
+
+// ProblemDetails is an RFC 7807 problem document, served as application/problem+json.
+type ProblemDetails struct {
+	Type   string       `json:"type"`             // URI identifying the problem category
+	Title  string       `json:"title"`            // Short, human-readable summary of the category
+	Status int          `json:"status"`           // HTTP status code of this occurrence
+	Detail string       `json:"detail,omitempty"` // Explanation specific to this occurrence
+	Errors []FieldError `json:"errors,omitempty"` // Per-field errors for validation problems
+	Line   int          `json:"line,omitempty"`   // Line of the error in the request body, for invalid-body problems
+	Column int          `json:"column,omitempty"` // Column (in bytes) of the error in the request body
+}
+
+// writeProblem writes the problem document with its status code and the
+// application/problem+json content type, replacing any Content-Type set earlier.
+func writeProblem(w http.ResponseWriter, r *http.Request, problem ProblemDetails) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	respondJSON(w, r, problem)
+}
+
+// writeValidationProblem reports a 422 Unprocessable Entity with the given field errors.
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, errs []FieldError) {
+	writeProblem(w, r, ProblemDetails{
+		Type:   problemTypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "The animal payload contains invalid fields.",
+		Errors: errs,
+	})
+}
+
+// writeInvalidID reports a 400 Bad Request for a path ID rejected by parseID.
+func writeInvalidID(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, ProblemDetails{
+		Type:   problemTypeInvalidID,
+		Title:  "Invalid animal ID",
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("%q is not a valid animal ID: %s.", mux.Vars(r)["id"], errInvalidID),
+	})
+}
+
+// writeInvalidBody reports a 400 Bad Request for a request body that could not be decoded, with
+// the line and column of the error when decodeJSONBody located it. A body cut off by
+// limitBodySize is reported as 413 Content Too Large instead.
+func writeInvalidBody(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, ProblemDetails{
+			Type:   problemTypeBodyTooLarge,
+			Title:  "Request body too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("The request body is larger than %d bytes.", tooLarge.Limit),
+		})
+		return
+	}
+	problem := ProblemDetails{
+		Type:   problemTypeInvalidBody,
+		Title:  "Invalid request body",
+		Status: http.StatusBadRequest,
+		Detail: err.Error() + ".",
+	}
+	var bodyErr *bodyError
+	if errors.As(err, &bodyErr) {
+		problem.Line, problem.Column = bodyErr.Line, bodyErr.Column
+	}
+	writeProblem(w, r, problem)
+}
+
+// notFoundHandler answers requests no route matches with a problem document, replacing mux's
+// plain-text default. A path that exists but does not support the request's method gets a 405
+// with an Allow header; anything else gets a 404. The method check happens here because mux
+// reports method mismatches inside subrouters as plain not-found.
+func notFoundHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			writeMethodNotAllowed(w, r, allowed)
+			return
+		}
+		writeProblem(w, r, ProblemDetails{
+			Type:   problemTypeNotFound,
+			Title:  "Not found",
+			Status: http.StatusNotFound,
+			Detail: "No endpoint exists at " + r.URL.Path + ".",
+		})
+	})
+}
+
+// methodNotAllowedHandler answers requests whose path matches a route but not its method
+// with a 405 problem document and an Allow header.
+func methodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMethodNotAllowed(w, r, allowedMethods(router, r))
+	})
+}
+
+// allowedMethods returns the methods the router serves for the request's path, found by
+// matching a copy of the request against the router once per candidate method.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	for _, method := range allowCandidateMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// writeMethodNotAllowed reports a 405 Method Not Allowed listing the allowed methods.
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed []string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeProblem(w, r, ProblemDetails{
+		Type:   problemTypeMethodNotAllowed,
+		Title:  "Method not allowed",
+		Status: http.StatusMethodNotAllowed,
+		Detail: r.Method + " is not supported on " + r.URL.Path + ".",
+	})
+}
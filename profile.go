@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Response profiles, selected with a profile parameter on Accept, e.g.
+// Accept: application/json;profile=v2.
+const (
+	profileV1 = "v1" // The flat animal object, as served without a profile
+	profileV2 = "v2" // The animal wrapped in an envelope: {"data": {...}}
+
+	defaultProfile = profileV1
+)
+
+// animalEnvelope is the representation of an animal under the v2 profile.
+type animalEnvelope struct {
+	Data Animal `json:"data"`
+}
+
+// animalProfiles maps each profile to the value its animal responses are serialized from. New
+// response shapes are added here, so old clients keep the shape they were written against.
+var animalProfiles = map[string]func(animal Animal) interface{}{
+	profileV1: func(animal Animal) interface{} { return animal },
+	profileV2: func(animal Animal) interface{} { return animalEnvelope{Data: animal} },
+}
+
+// responseProfileKey is the context key under which the negotiated response profile is stored.
+type responseProfileKey struct{}
+
+// acceptedProfile returns the first known profile the request's Accept header asks for on a
+// JSON media range (application/json, application/* or */*), in header order, and whether an
+// unknown one was asked for. Without a profile parameter it returns "".
+func acceptedProfile(r *http.Request) (profile string, unknown bool) {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || params["profile"] == "" {
+				continue
+			}
+			if mediaType != "application/json" && mediaType != "application/*" && mediaType != "*/*" {
+				continue
+			}
+			name := strings.ToLower(params["profile"])
+			if _, ok := animalProfiles[name]; ok {
+				return name, false
+			}
+			unknown = true
+		}
+	}
+	return "", unknown
+}
+
+// negotiateProfile is router middleware that stores the response profile asked for by the
+// request's Accept header in its context, for writeAnimalBody. A request asking only for
+// profiles the server does not know gets the default one, or, when strict is true, 406 Not
+// Acceptable.
+func negotiateProfile(strict bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			profile, unknown := acceptedProfile(r)
+			if profile == "" {
+				if unknown && strict {
+					http.Error(w, "Unknown response profile (supported: "+strings.Join(slices.Sorted(maps.Keys(animalProfiles)), ", ")+")", http.StatusNotAcceptable) // 406 Not Acceptable
+					return
+				}
+				profile = defaultProfile
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), responseProfileKey{}, profile)))
+		})
+	}
+}
+
+// responseProfile returns the profile negotiated for the request in ctx, or the default one.
+func responseProfile(ctx context.Context) string {
+	if profile, ok := ctx.Value(responseProfileKey{}).(string); ok {
+		return profile
+	}
+	return defaultProfile
+}
+
+// profiledAnimal returns the value the animal is serialized from under the request's profile.
+func profiledAnimal(r *http.Request, animal Animal) interface{} {
+	return animalProfiles[responseProfile(r.Context())](animal)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// projectableFields maps the JSON name of every Animal field a ?fields= projection may select
+// to the field's value.
+var projectableFields = map[string]func(Animal) interface{}{
+	"id":         func(a Animal) interface{} { return a.ID },
+	"name":       func(a Animal) interface{} { return a.Name },
+	"class":      func(a Animal) interface{} { return a.Class },
+	"legs":       func(a Animal) interface{} { return a.Legs },
+	"photo_url":  func(a Animal) interface{} { return a.PhotoURL },
+	"endangered": func(a Animal) interface{} { return a.Endangered },
+	"latitude":   func(a Animal) interface{} { return a.Latitude },
+	"longitude":  func(a Animal) interface{} { return a.Longitude },
+	"created_at": func(a Animal) interface{} { return a.CreatedAt },
+	"updated_at": func(a Animal) interface{} { return a.UpdatedAt },
+}
+
+// parseFields parses a comma-separated ?fields= list such as "id,name". Duplicates are dropped
+// and unknown or empty names rejected. An empty spec selects every field and returns nil.
+func parseFields(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := projectableFields[field]; !ok {
+			known := make([]string, 0, len(projectableFields))
+			for name := range projectableFields {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown field %q (supported: %s)", field, strings.Join(known, ", "))
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// animalSummary is the {id, name} projection used by list views. Serializing it skips the map
+// the general projection builds per animal, which matters for lists of thousands of animals.
+type animalSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// projectAnimals returns the value to serialize for animals reduced to the given fields (nil
+// means all of them, and returns animals unchanged). Exactly id and name, in either order, take
+// the animalSummary fast path; other selections become one map per animal. As in the full
+// object, an empty photo_url and missing coordinates are left out.
+func projectAnimals(animals []Animal, fields []string) interface{} {
+	if fields == nil {
+		return animals
+	}
+	if len(fields) == 2 && (fields[0] == "id" && fields[1] == "name" || fields[0] == "name" && fields[1] == "id") {
+		summaries := make([]animalSummary, len(animals))
+		for i, animal := range animals {
+			summaries[i] = animalSummary{ID: animal.ID, Name: animal.Name}
+		}
+		return summaries
+	}
+	return projectToMaps(animals, fields)
+}
+
+// projectToMaps is the general projection: one map per animal holding the given fields.
+func projectToMaps(animals []Animal, fields []string) []map[string]interface{} {
+	projected := make([]map[string]interface{}, len(animals))
+	for i, animal := range animals {
+		values := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if field == "photo_url" && animal.PhotoURL == "" ||
+				field == "latitude" && animal.Latitude == nil ||
+				field == "longitude" && animal.Longitude == nil {
+				continue
+			}
+			values[field] = projectableFields[field](animal)
+		}
+		projected[i] = values
+	}
+	return projected
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// projectionAnimals returns n animals with every field set.
+func projectionAnimals(n int) []Animal {
+	latitude, longitude := -6.2, 106.8
+	animals := make([]Animal, n)
+	for i := range animals {
+		animals[i] = Animal{ID: i + 1, Name: fmt.Sprintf("Animal %d", i+1), Class: "mammal", Legs: 4,
+			PhotoURL: "https://example.com/a.jpg", Latitude: &latitude, Longitude: &longitude}
+	}
+	return animals
+}
+
+func TestProjectAnimals(t *testing.T) {
+	animals := projectionAnimals(2)
+	animals[1].PhotoURL, animals[1].Latitude, animals[1].Longitude = "", nil, nil
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"id", "name"}, `[{"id":1,"name":"Animal 1"},{"id":2,"name":"Animal 2"}]`},
+		{[]string{"name", "id"}, `[{"id":1,"name":"Animal 1"},{"id":2,"name":"Animal 2"}]`},
+		{[]string{"name"}, `[{"name":"Animal 1"},{"name":"Animal 2"}]`},
+		{[]string{"id", "legs"}, `[{"id":1,"legs":4},{"id":2,"legs":4}]`},
+		{[]string{"id", "photo_url", "latitude"}, `[{"id":1,"latitude":-6.2,"photo_url":"https://example.com/a.jpg"},{"id":2}]`},
+	}
+	for _, tt := range tests {
+		body, err := json.Marshal(projectAnimals(animals, tt.fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tt.want {
+			t.Errorf("fields %v: %s, want %s", tt.fields, body, tt.want)
+		}
+	}
+
+	// The fast path serializes exactly like the general projection
+	fast, _ := json.Marshal(projectAnimals(animals, []string{"id", "name"}))
+	general, _ := json.Marshal(projectToMaps(animals, []string{"id", "name"}))
+	if string(fast) != string(general) {
+		t.Errorf("fast path %s differs from the general projection %s", fast, general)
+	}
+}
+
+// BenchmarkProjectIDName measures serializing 5000 animals as {id, name} through the
+// animalSummary fast path and through the general map projection, against the full objects.
+func BenchmarkProjectIDName(b *testing.B) {
+	animals := projectionAnimals(5000)
+	fields := []string{"id", "name"}
+	variants := []struct {
+		name    string
+		project func() interface{}
+	}{
+		{"summary", func() interface{} { return projectAnimals(animals, fields) }},
+		{"maps", func() interface{} { return projectToMaps(animals, fields) }},
+		{"full", func() interface{} { return animals }},
+	}
+	for _, v := range variants {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				body, err := json.Marshal(v.project())
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(body)))
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushTimeout bounds each push to the Pushgateway, so an unreachable gateway cannot hold up
+// shutdown.
+const pushTimeout = 10 * time.Second
+
+// newMetricsPusher returns a pusher sending everything in the registry of m, the same metrics
+// /metrics serves, to the Pushgateway at url under the job name. The metrics are grouped by
+// the host name as instance, so the replicas of a deployment do not overwrite each other.
+func newMetricsPusher(m *Metrics, url, job string) *push.Pusher {
+	pusher := push.New(url, job).Gatherer(m.registry).Client(&http.Client{Timeout: pushTimeout})
+	if host, err := os.Hostname(); err == nil {
+		pusher = pusher.Grouping("instance", host)
+	}
+	return pusher
+}
+
+// pushSweeper returns a sweeper pushing the metrics every interval, jittered by the given
+// fraction; failed pushes back off like any other sweep.
+func pushSweeper(pusher *push.Pusher, interval time.Duration, jitter float64) *Sweeper {
+	return NewSweeper("pushgateway", interval, jitter, func(time.Time) error {
+		return pusher.Push()
+	})
+}
+
+// finalPush pushes the metrics one last time during shutdown, once the server has stopped
+// serving, so the requests of the last interval are not lost.
+func finalPush(pusher *push.Pusher) {
+	if err := pusher.Push(); err != nil {
+		log.Printf("Shutdown: final push to the Pushgateway failed: %v", err)
+		return
+	}
+	log.Print("Shutdown: pushed the final metrics to the Pushgateway")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AnimalQuery is a complete list query: which animals, in what order, and which page of them.
+type AnimalQuery struct {
+	Filter AnimalFilter
+	Page   Page
+}
+
+// legsRange is the inclusive legs bounds of a query body; either bound may be omitted.
+type legsRange struct {
+	Min *int `json:"min"`
+	Max *int `json:"max"`
+}
+
+// queryRequest is the body of the query endpoint, e.g.
+// {"classes": ["mammal"], "legs": {"min": 2}, "name_contains": "li", "sort": ["-legs"], "limit": 10}.
+type queryRequest struct {
+	Classes      []string  `json:"classes"`
+	Legs         legsRange `json:"legs"`
+	NameContains string    `json:"name_contains"`
+	Sort         []string  `json:"sort"`
+	Limit        *int      `json:"limit"`
+	Offset       int       `json:"offset"`
+}
+
+// queryResponse is the envelope returned by the query endpoint.
+type queryResponse struct {
+	Data   []Animal `json:"data"`
+	Total  int      `json:"total"` // Number of matching animals across all pages
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// toQuery validates the request and converts it into an AnimalQuery. Limits follow the same
+// rules as ?limit= on the list: the default applies when none is given, and a limit above the
+// maximum is clamped, or rejected when cfg.StrictLimits is set.
+func (req queryRequest) toQuery(cfg Config) (AnimalQuery, error) {
+	var q AnimalQuery
+	for _, class := range req.Classes {
+		if strings.TrimSpace(class) == "" {
+			return q, errors.New("classes must not contain empty names")
+		}
+	}
+	if (req.Legs.Min != nil && *req.Legs.Min < 0) || (req.Legs.Max != nil && *req.Legs.Max < 0) {
+		return q, errors.New("legs bounds must be non-negative")
+	}
+	if req.Legs.Min != nil && req.Legs.Max != nil && *req.Legs.Min > *req.Legs.Max {
+		return q, errors.New("legs.min must not exceed legs.max")
+	}
+	sortKeys, err := parseSortSpec(strings.Join(req.Sort, ","))
+	if err != nil {
+		return q, err
+	}
+
+	q.Page = Page{Limit: cfg.DefaultLimit, Offset: req.Offset}
+	if req.Limit != nil {
+		if *req.Limit < 1 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		q.Page.Limit = *req.Limit
+	}
+	if q.Page.Limit > cfg.MaxLimit {
+		if cfg.StrictLimits {
+			return q, fmt.Errorf("limit must not exceed %d", cfg.MaxLimit)
+		}
+		q.Page.Limit = cfg.MaxLimit
+	}
+	if req.Offset < 0 {
+		return q, errors.New("offset must be a non-negative integer")
+	}
+
+	q.Filter = AnimalFilter{
+		Classes:      req.Classes,
+		MinLegs:      req.Legs.Min,
+		MaxLegs:      req.Legs.Max,
+		NameContains: req.NameContains,
+		Sort:         sortKeys,
+	}
+	return q, nil
+}
+
+// queryAnimalsHandler handles POST requests that list animals with the criteria in the JSON
+// body rather than in the URL, for queries too complex or too long for query parameters. It
+// combines a class filter, a legs range and a name substring with the list's sort keys and
+// pagination, and answers with the page wrapped in an envelope carrying the total. The body is
+// decoded strictly, so misspelled fields are errors rather than silently ignored criteria.
+// With ?explain=true it describes how the query was evaluated instead.
+func queryAnimalsHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req queryRequest
+		if err := decodeJSONBody(r.Body, &req, (*json.Decoder).DisallowUnknownFields); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+		q, err := req.toQuery(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		explain, err := isExplain(r)
+		if err != nil {
+			http.Error(w, "Invalid explain parameter", http.StatusBadRequest)
+			return
+		}
+		if explain {
+			writeExplanation(w, r, store, explainInput{Filter: q.Filter, Page: q.Page})
+			return
+		}
+
+		animals, total, err := store.Query(q)
+		if err != nil {
+			if errors.Is(err, ErrEmpty) {
+				http.Error(w, "No animals found in the system", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writePageHeaders(w, q.Page, total)
+		respondJSON(w, r, queryResponse{Data: animals, Total: total, Limit: q.Page.Limit, Offset: q.Page.Offset})
+	}
+}
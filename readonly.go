@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+// readOnlyRetryAfterSeconds is the Retry-After hint sent with writes rejected in read-only mode.
+const readOnlyRetryAfterSeconds = 60
+
+// Names of POST routes that stay available in read-only mode.
+const (
+	readOnlyToggleRoute = "admin-readonly" // Switches read-only mode, so it must stay reachable
+	batchGetRoute       = "batch-get"      // A read that uses POST only to carry its ID list
+	validateRoute       = "validate"       // Checks a payload without touching the store
+	queryRoute          = "query"          // A read that uses POST only to carry its criteria
+)
+
+// readOnlySafeRoutes are the routes let through in read-only mode despite their write method.
+var readOnlySafeRoutes = map[string]bool{
+	readOnlyToggleRoute: true,
+	batchGetRoute:       true,
+	validateRoute:       true,
+	queryRoute:          true,
+	graphqlRoute:        true, // Mutations check read-only mode themselves
+}
+
+// ReadOnlyMode is a runtime switch that makes the API reject writes while still serving reads.
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+}
+
+// NewReadOnlyMode creates the switch in the given initial state.
+func NewReadOnlyMode(enabled bool) *ReadOnlyMode {
+	m := &ReadOnlyMode{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether writes are currently rejected.
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set switches read-only mode on or off, logging actual transitions.
+func (m *ReadOnlyMode) Set(enabled bool) {
+	if m.enabled.Swap(enabled) != enabled {
+		if enabled {
+			log.Print("read-only mode enabled: rejecting writes")
+		} else {
+			log.Print("read-only mode disabled: accepting writes")
+		}
+	}
+}
+
+// rejectWritesWhenReadOnly answers POST/PUT/PATCH/DELETE with 503 Service Unavailable and a
+// Retry-After header while read-only mode is on. Reads, and the readOnlySafeRoutes (such as
+// the route that turns the mode off again), are always let through.
+func rejectWritesWhenReadOnly(mode *ReadOnlyMode) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !mode.Enabled() || !isWriteMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if route := mux.CurrentRoute(r); route != nil && readOnlySafeRoutes[route.GetName()] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(readOnlyRetryAfterSeconds))
+			http.Error(w, "The API is in read-only mode for maintenance", http.StatusServiceUnavailable)
+		})
+	}
+}
+
+// isWriteMethod reports whether the HTTP method mutates data.
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// readOnlyState is the request and response body of the read-only admin endpoint.
+type readOnlyState struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// readOnlyHandler handles GET (inspect) and POST (switch) requests for read-only mode.
+// POST expects {"read_only": true|false} and responds with the resulting state.
+func readOnlyHandler(mode *ReadOnlyMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			var req readOnlyState
+			if err := decodeJSONBody(r.Body, &req); err != nil {
+				writeInvalidBody(w, r, err)
+				return
+			}
+			mode.Set(req.ReadOnly)
+		}
+		respondJSON(w, r, readOnlyState{ReadOnly: mode.Enabled()})
+	}
+}
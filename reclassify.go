@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// reclassifyRequest is the body of the reclassify endpoint.
+type reclassifyRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// reclassifyResponse reports how many animals were moved to the new class.
+type reclassifyResponse struct {
+	Reclassified int `json:"reclassified"`
+}
+
+// reclassifyAnimalsHandler handles POST requests that rename a class, moving every animal of
+// class "from" (case-insensitive) to class "to", e.g. after a taxonomy change. It answers 200
+// with the number of animals changed, which is 0 when nothing matches. When leg rules are
+// enforced and "to" has one, the whole change is rejected with 422 if any moved animal would
+// break it; the check and the change run in one transaction.
+func reclassifyAnimalsHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req reclassifyRequest
+		if err := decodeJSONBody(r.Body, &req); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		req.From = strings.TrimSpace(req.From)
+		req.To = strings.TrimSpace(req.To)
+		var errs []FieldError
+		if req.From == "" {
+			errs = append(errs, FieldError{Field: "from", Message: "from is required"})
+		}
+		if req.To == "" {
+			errs = append(errs, FieldError{Field: "to", Message: "to is required"})
+		} else if class, ok := allowedClass(req.To, cfg); ok {
+			req.To = class
+		} else {
+			errs = append(errs, FieldError{Field: "to", Message: allowedClassesMessage("to", cfg)})
+		}
+		if len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		rule, ruled := cfg.LegRules[strings.ToLower(req.To)]
+		if !cfg.EnforceLegRules || !ruled {
+			changed, err := store.ReclassifyAnimals(req.From, req.To)
+			if writeVetoed(w, r, err) {
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			respondJSON(w, r, reclassifyResponse{Reclassified: changed})
+			return
+		}
+
+		// Check the target class's leg rule against the animals being moved, then move them
+		errRuleBroken := errors.New("leg rule broken")
+		var changed int
+		err := store.WithTransaction(r.Context(), func(tx AnimalStore) error {
+			moving, err := tx.FilterAnimals(AnimalFilter{Classes: []string{req.From}})
+			if err != nil && !errors.Is(err, ErrEmpty) {
+				return err
+			}
+			for _, animal := range moving {
+				if animal.Legs < rule.Min || animal.Legs > rule.Max {
+					errs = append(errs, FieldError{
+						Field:   "to",
+						Message: fmt.Sprintf("animal %d has %d legs: %s", animal.ID, animal.Legs, legRuleMessage(req.To, rule)),
+					})
+				}
+			}
+			if len(errs) > 0 {
+				return errRuleBroken
+			}
+			changed, err = tx.ReclassifyAnimals(req.From, req.To)
+			return err
+		})
+		if errors.Is(err, errRuleBroken) {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+		if writeVetoed(w, r, err) {
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, reclassifyResponse{Reclassified: changed})
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errReservationsUnsupported is returned by ReserveID of stores that do not keep reservations
+// themselves; main always puts a ReservingAnimalStore in front of the backend.
+var errReservationsUnsupported = fmt.Errorf("ID reservations need a ReservingAnimalStore: %w", errors.ErrUnsupported)
+
+// idReservations is the set of reserved IDs with their expiry, shared by a ReservingAnimalStore
+// and the views it hands to transactions.
+type idReservations struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	until map[int]time.Time // Reserved ID -> when the reservation expires
+}
+
+// held reports whether id is reserved at now. Callers hold mu.
+func (r *idReservations) held(id int, now time.Time) bool {
+	until, ok := r.until[id]
+	return ok && now.Before(until)
+}
+
+// skip returns the first ID from id upwards that is not reserved. Callers hold mu.
+func (r *idReservations) skip(id int, now time.Time) int {
+	for r.held(id, now) {
+		id++
+	}
+	return id
+}
+
+// release drops the reservations of the given IDs, e.g. because animals were created with them.
+func (r *idReservations) release(ids ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		delete(r.until, id)
+	}
+}
+
+// sweep drops the reservations that expired before now.
+func (r *idReservations) sweep(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, until := range r.until {
+		if !now.Before(until) {
+			delete(r.until, id)
+		}
+	}
+}
+
+// ReservingAnimalStore is an AnimalStore decorator that lets clients reserve an ID and create the
+// animal later, e.g. at the end of a multi-step form. A reserved ID is skipped by NextID, and so
+// by automatic ID assignment and by further reservations, until an animal is created under it
+// or the reservation expires after the TTL; an expired ID is free to be handed out again. The
+// reservation is not a lock: a create that names a reserved ID explicitly succeeds and consumes
+// it. Reservations live in memory only; expired ones are dropped by the store's Sweeper.
+type ReservingAnimalStore struct {
+	inner        AnimalStore
+	reservations *idReservations
+	consumed     *[]int // IDs created inside the enclosing transaction, released once it commits; nil outside one
+}
+
+// NewReservingAnimalStore wraps inner so that IDs can be reserved for ttl.
+func NewReservingAnimalStore(inner AnimalStore, ttl time.Duration) *ReservingAnimalStore {
+	return &ReservingAnimalStore{inner: inner, reservations: &idReservations{ttl: ttl, until: make(map[int]time.Time)}}
+}
+
+// Sweeper returns a sweeper that drops expired reservations once per TTL, jittered by the given
+// fraction. Lookups check the expiry themselves, so sweeping only bounds the memory held by
+// abandoned reservations.
+func (s *ReservingAnimalStore) Sweeper(jitter float64) *Sweeper {
+	return NewSweeper("reservations", s.reservations.ttl, jitter, func(now time.Time) error {
+		s.reservations.sweep(now)
+		return nil
+	})
+}
+
+// WithContext returns a copy of the store bound to ctx, sharing its reservations.
+func (s *ReservingAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &ReservingAnimalStore{inner: bindContext(s.inner, ctx), reservations: s.reservations, consumed: s.consumed}
+}
+
+// created consumes the reservation of an animal created under id, or defers that until the
+// enclosing transaction commits.
+func (s *ReservingAnimalStore) created(id int) {
+	if s.consumed != nil {
+		*s.consumed = append(*s.consumed, id)
+		return
+	}
+	s.reservations.release(id)
+}
+
+// ReserveID reserves the next free ID that is not already reserved and returns it with the time
+// the reservation expires.
+func (s *ReservingAnimalStore) ReserveID() (int, time.Time, error) {
+	if s.consumed != nil {
+		return 0, time.Time{}, errors.New("IDs cannot be reserved inside a transaction")
+	}
+	s.reservations.mu.Lock()
+	defer s.reservations.mu.Unlock()
+
+	next, err := s.inner.NextID()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	now := time.Now().UTC()
+	id := s.reservations.skip(next, now)
+	until := now.Add(s.reservations.ttl)
+	s.reservations.until[id] = until
+	return id, until, nil
+}
+
+// NextID returns the underlying store's next free ID, moved past any reserved IDs.
+func (s *ReservingAnimalStore) NextID() (int, error) {
+	next, err := s.inner.NextID()
+	if err != nil {
+		return 0, err
+	}
+	s.reservations.mu.Lock()
+	defer s.reservations.mu.Unlock()
+	return s.reservations.skip(next, time.Now().UTC()), nil
+}
+
+// CreateAnimal creates the animal and consumes the reservation of its ID, if any.
+func (s *ReservingAnimalStore) CreateAnimal(animal Animal) error {
+	if err := s.inner.CreateAnimal(animal); err != nil {
+		return err
+	}
+	s.created(animal.ID)
+	return nil
+}
+
+// CreateAnimalAssigningID creates the animal under a new ID that is not reserved. The
+// reservations are locked until the animal is stored, so no reservation can take the ID
+// between the store choosing it and inserting the animal.
+func (s *ReservingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	s.reservations.mu.Lock()
+	defer s.reservations.mu.Unlock()
+	now := time.Now().UTC()
+	return s.inner.CreateAnimalAssigningID(animal, func(id int) bool {
+		return s.reservations.held(id, now) || (skip != nil && skip(id))
+	})
+}
+
+// UpsertAnimal upserts the animal and consumes the reservation of its ID, if any.
+func (s *ReservingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	if err := s.inner.UpsertAnimal(id, animal); err != nil {
+		return err
+	}
+	s.created(id)
+	return nil
+}
+
+// UpsertAnimalIf conditionally upserts the animal and consumes the reservation of its ID, if any.
+func (s *ReservingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	created, err := s.inner.UpsertAnimalIf(id, animal, matches)
+	if err != nil {
+		return false, err
+	}
+	s.created(id)
+	return created, nil
+}
+
+// MergeAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	return s.inner.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals upserts the animals and consumes the reservations of their IDs, if any.
+func (s *ReservingAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	results, err := s.inner.UpsertAnimals(animals)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		s.created(result.ID)
+	}
+	return results, nil
+}
+
+// WithTransaction runs fn against a transactional view that shares the reservations. Creates
+// made through the view consume their reservations only once the transaction commits.
+func (s *ReservingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	var consumed []int
+	err := s.inner.WithTransaction(ctx, func(tx AnimalStore) error {
+		return fn(&ReservingAnimalStore{inner: tx, reservations: s.reservations, consumed: &consumed})
+	})
+	if err != nil {
+		return err
+	}
+	s.reservations.release(consumed...)
+	return nil
+}
+
+// GetAllAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) GetAllAnimals() ([]Animal, error) {
+	return s.inner.GetAllAnimals()
+}
+
+// FilterAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	return s.inner.FilterAnimals(filter)
+}
+
+// GetAnimalIDs passes through to the underlying store.
+func (s *ReservingAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	return s.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	return s.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach passes through to the underlying store.
+func (s *ReservingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	return s.inner.ForEach(ctx, fn)
+}
+
+// Facets passes through to the underlying store.
+func (s *ReservingAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	return s.inner.Facets(filter)
+}
+
+// Analytics passes through to the underlying store.
+func (s *ReservingAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	return s.inner.Analytics(filter)
+}
+
+// GroupAnimalsByClass passes through to the underlying store.
+func (s *ReservingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	return s.inner.GroupAnimalsByClass(filter)
+}
+
+// FuzzySearch passes through to the underlying store.
+func (s *ReservingAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	return s.inner.FuzzySearch(query, maxDistance)
+}
+
+// SampleAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	return s.inner.SampleAnimals(filter, n)
+}
+
+// Query passes through to the underlying store.
+func (s *ReservingAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	return s.inner.Query(q)
+}
+
+// GetAnimalByID passes through to the underlying store.
+func (s *ReservingAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	return s.inner.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs passes through to the underlying store.
+func (s *ReservingAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	return s.inner.GetAnimalsByIDs(ids)
+}
+
+// GetAnimalsModifiedSince passes through to the underlying store.
+func (s *ReservingAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	return s.inner.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified passes through to the underlying store.
+func (s *ReservingAnimalStore) LatestModified() (time.Time, error) {
+	return s.inner.LatestModified()
+}
+
+// UpdateAnimal passes through to the underlying store.
+func (s *ReservingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	return s.inner.UpdateAnimal(id, animal)
+}
+
+// DeleteAnimal passes through to the underlying store.
+func (s *ReservingAnimalStore) DeleteAnimal(id int) error {
+	return s.inner.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion passes through to the underlying store.
+func (s *ReservingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return s.inner.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	return s.inner.DeleteAnimals(ids)
+}
+
+// ReplaceAllAnimals passes through to the underlying store. Reservations are kept; one whose ID
+// the new dataset uses simply fails to create with 409 Conflict.
+func (s *ReservingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	return s.inner.ReplaceAllAnimals(animals)
+}
+
+// ReclassifyAnimals passes through to the underlying store.
+func (s *ReservingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	return s.inner.ReclassifyAnimals(from, to)
+}
+
+// SetEndangered passes through to the underlying store.
+func (s *ReservingAnimalStore) SetEndangered(id int, endangered bool) error {
+	return s.inner.SetEndangered(id, endangered)
+}
+
+// reservationResponse is the body of a successful reservation.
+type reservationResponse struct {
+	ID        int       `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// reserveIDHandler handles POST requests that reserve an animal ID for a later create, answering
+// with the ID and when the reservation expires.
+func reserveIDHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, expiresAt, err := store.ReserveID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, r, reservationResponse{ID: id, ExpiresAt: expiresAt})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrTransient marks a store error as worth retrying, such as a dropped connection to a
+// database server. Backends wrap it (fmt.Errorf("...: %w", ErrTransient)) for failures that
+// isRetryable does not recognize by itself.
+var ErrTransient = errors.New("transient store error")
+
+// isRetryable reports whether err is a transient failure that may succeed when the operation is
+// repeated: ErrTransient, network timeouts, and connections that were refused, reset or broken
+// off. Logical errors such as ErrNotFound or ErrAlreadyExists, and a cancelled or expired
+// request context, are final.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrTransient) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, transient := range []error{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, io.ErrUnexpectedEOF} {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPolicy is how often and how patiently a RetryingAnimalStore repeats a failed operation.
+type retryPolicy struct {
+	attempts  int           // Total tries of an operation, including the first
+	baseDelay time.Duration // Wait before the first retry; doubles per retry
+	maxDelay  time.Duration // Upper bound on the wait
+	jitter    float64       // Random shift of each wait, as a fraction of it, in [0, 1)
+}
+
+// delay is how long to wait before the given retry (1 for the first).
+func (p retryPolicy) delay(retry int) time.Duration {
+	wait := min(p.baseDelay<<min(retry-1, 30), p.maxDelay)
+	if p.jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(wait))
+	}
+	return wait
+}
+
+// RetryingAnimalStore is an AnimalStore decorator that repeats operations failing with a
+// transient error (see isRetryable), waiting with exponential backoff and jitter between
+// attempts, so that a blip in the connection to a backend does not surface as a 500. Other
+// errors are returned at once, as is the last transient one when the attempts are used up.
+//
+// Only operations that are safe to repeat are retried: reads, and writes that set a state
+// rather than change it (UpdateAnimal, UpsertAnimal, UpsertAnimals, ReplaceAllAnimals,
+// SetEndangered). Creates, deletes, reclassifies, conditional upserts and ID reservations
+// may have been applied before the connection broke, so a retry could fail or count
+// differently; they are tried once. StreamAnimals is only retried while it has not delivered
+// any animal yet. Transactions are neither retried nor retried within, since their work is
+// lost with the connection. Bound to a request via WithContext, waits end when it is done.
+type RetryingAnimalStore struct {
+	inner  AnimalStore
+	policy retryPolicy
+	ctx    context.Context
+}
+
+// NewRetryingAnimalStore wraps inner so that transient failures are tried up to attempts
+// times in all, waiting baseDelay before the first retry, doubling up to maxDelay, each wait
+// shifted by up to ±jitter of it.
+func NewRetryingAnimalStore(inner AnimalStore, attempts int, baseDelay, maxDelay time.Duration, jitter float64) *RetryingAnimalStore {
+	return &RetryingAnimalStore{
+		inner:  inner,
+		policy: retryPolicy{attempts: attempts, baseDelay: baseDelay, maxDelay: maxDelay, jitter: jitter},
+		ctx:    context.Background(),
+	}
+}
+
+// WithContext returns a copy of the store bound to ctx.
+func (s *RetryingAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &RetryingAnimalStore{inner: bindContext(s.inner, ctx), policy: s.policy, ctx: ctx}
+}
+
+// do runs op until it succeeds, fails for good or the attempts are used up. The method name
+// identifies the operation in the log.
+func (s *RetryingAnimalStore) do(method string, op func() error) error {
+	err := op()
+	for attempt := 1; attempt < s.policy.attempts && isRetryable(err); attempt++ {
+		wait := s.policy.delay(attempt)
+		log.Printf("store: %s failed (attempt %d of %d), retrying in %s: %v", method, attempt, s.policy.attempts, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		err = op()
+	}
+	return err
+}
+
+// GetAllAnimals retries the underlying GetAllAnimals.
+func (s *RetryingAnimalStore) GetAllAnimals() (animals []Animal, err error) {
+	err = s.do("GetAllAnimals", func() error { animals, err = s.inner.GetAllAnimals(); return err })
+	return animals, err
+}
+
+// FilterAnimals retries the underlying FilterAnimals.
+func (s *RetryingAnimalStore) FilterAnimals(filter AnimalFilter) (animals []Animal, err error) {
+	err = s.do("FilterAnimals", func() error { animals, err = s.inner.FilterAnimals(filter); return err })
+	return animals, err
+}
+
+// GetAnimalIDs retries the underlying GetAnimalIDs.
+func (s *RetryingAnimalStore) GetAnimalIDs(filter AnimalFilter) (ids []int, err error) {
+	err = s.do("GetAnimalIDs", func() error { ids, err = s.inner.GetAnimalIDs(filter); return err })
+	return ids, err
+}
+
+// StreamAnimals retries the underlying StreamAnimals as long as fn has not been called, since
+// a retry would hand it the same animals again.
+func (s *RetryingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	delivered := false
+	var streamErr error
+	err := s.do("StreamAnimals", func() error {
+		streamErr = s.inner.StreamAnimals(filter, func(animal Animal) error {
+			delivered = true
+			return fn(animal)
+		})
+		if delivered {
+			return nil // Stop retrying; the stream's own error is returned below
+		}
+		return streamErr
+	})
+	if delivered {
+		return streamErr
+	}
+	return err
+}
+
+// ForEach retries the underlying ForEach as long as fn has not been called, like StreamAnimals.
+func (s *RetryingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	delivered := false
+	var iterErr error
+	err := s.do("ForEach", func() error {
+		iterErr = s.inner.ForEach(ctx, func(animal Animal) error {
+			delivered = true
+			return fn(animal)
+		})
+		if delivered {
+			return nil // Stop retrying; the iteration's own error is returned below
+		}
+		return iterErr
+	})
+	if delivered {
+		return iterErr
+	}
+	return err
+}
+
+// GroupAnimalsByClass retries the underlying GroupAnimalsByClass.
+func (s *RetryingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (groups map[string][]Animal, err error) {
+	err = s.do("GroupAnimalsByClass", func() error { groups, err = s.inner.GroupAnimalsByClass(filter); return err })
+	return groups, err
+}
+
+// Facets retries the underlying Facets.
+func (s *RetryingAnimalStore) Facets(filter AnimalFilter) (facets []Facet, err error) {
+	err = s.do("Facets", func() error { facets, err = s.inner.Facets(filter); return err })
+	return facets, err
+}
+
+// Analytics retries the underlying Analytics.
+func (s *RetryingAnimalStore) Analytics(filter AnimalFilter) (result AnalyticsResult, err error) {
+	err = s.do("Analytics", func() error { result, err = s.inner.Analytics(filter); return err })
+	return result, err
+}
+
+// FuzzySearch retries the underlying FuzzySearch.
+func (s *RetryingAnimalStore) FuzzySearch(query string, maxDistance int) (animals []Animal, err error) {
+	err = s.do("FuzzySearch", func() error { animals, err = s.inner.FuzzySearch(query, maxDistance); return err })
+	return animals, err
+}
+
+// SampleAnimals retries the underlying SampleAnimals.
+func (s *RetryingAnimalStore) SampleAnimals(filter AnimalFilter, n int) (animals []Animal, err error) {
+	err = s.do("SampleAnimals", func() error { animals, err = s.inner.SampleAnimals(filter, n); return err })
+	return animals, err
+}
+
+// Query retries the underlying Query.
+func (s *RetryingAnimalStore) Query(q AnimalQuery) (page []Animal, total int, err error) {
+	err = s.do("Query", func() error { page, total, err = s.inner.Query(q); return err })
+	return page, total, err
+}
+
+// GetAnimalByID retries the underlying GetAnimalByID.
+func (s *RetryingAnimalStore) GetAnimalByID(id int) (animal *Animal, err error) {
+	err = s.do("GetAnimalByID", func() error { animal, err = s.inner.GetAnimalByID(id); return err })
+	return animal, err
+}
+
+// GetAnimalsByIDs retries the underlying GetAnimalsByIDs.
+func (s *RetryingAnimalStore) GetAnimalsByIDs(ids []int) (found []Animal, missing []int, err error) {
+	err = s.do("GetAnimalsByIDs", func() error { found, missing, err = s.inner.GetAnimalsByIDs(ids); return err })
+	return found, missing, err
+}
+
+// GetAnimalsModifiedSince retries the underlying GetAnimalsModifiedSince.
+func (s *RetryingAnimalStore) GetAnimalsModifiedSince(t time.Time) (animals []Animal, err error) {
+	err = s.do("GetAnimalsModifiedSince", func() error { animals, err = s.inner.GetAnimalsModifiedSince(t); return err })
+	return animals, err
+}
+
+// LatestModified retries the underlying LatestModified.
+func (s *RetryingAnimalStore) LatestModified() (latest time.Time, err error) {
+	err = s.do("LatestModified", func() error { latest, err = s.inner.LatestModified(); return err })
+	return latest, err
+}
+
+// NextID retries the underlying NextID, which is advisory and reserves nothing.
+func (s *RetryingAnimalStore) NextID() (next int, err error) {
+	err = s.do("NextID", func() error { next, err = s.inner.NextID(); return err })
+	return next, err
+}
+
+// ReserveID is tried once.
+func (s *RetryingAnimalStore) ReserveID() (int, time.Time, error) {
+	return s.inner.ReserveID()
+}
+
+// CreateAnimal is tried once: a create that went through before the failure would collide
+// with itself when repeated.
+func (s *RetryingAnimalStore) CreateAnimal(animal Animal) error {
+	return s.inner.CreateAnimal(animal)
+}
+
+// CreateAnimalAssigningID is tried once, like CreateAnimal: a repeated create would store the
+// animal a second time under another ID.
+func (s *RetryingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	return s.inner.CreateAnimalAssigningID(animal, skip)
+}
+
+// UpdateAnimal retries the underlying UpdateAnimal.
+func (s *RetryingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	return s.do("UpdateAnimal", func() error { return s.inner.UpdateAnimal(id, animal) })
+}
+
+// UpsertAnimal retries the underlying UpsertAnimal.
+func (s *RetryingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	return s.do("UpsertAnimal", func() error { return s.inner.UpsertAnimal(id, animal) })
+}
+
+// UpsertAnimalIf is tried once: a repeated write would find its own version and fail the
+// precondition.
+func (s *RetryingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	return s.inner.UpsertAnimalIf(id, animal, matches)
+}
+
+// MergeAnimals is tried once: a repeat would find the source already deleted.
+func (s *RetryingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	return s.inner.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals retries the underlying UpsertAnimals.
+func (s *RetryingAnimalStore) UpsertAnimals(animals []Animal) (results []BatchResult, err error) {
+	err = s.do("UpsertAnimals", func() error { results, err = s.inner.UpsertAnimals(animals); return err })
+	return results, err
+}
+
+// DeleteAnimal is tried once: a repeated delete would report the animal as not found.
+func (s *RetryingAnimalStore) DeleteAnimal(id int) error {
+	return s.inner.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion is tried once, like DeleteAnimal.
+func (s *RetryingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return s.inner.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals is tried once, like DeleteAnimal.
+func (s *RetryingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	return s.inner.DeleteAnimals(ids)
+}
+
+// ReplaceAllAnimals retries the underlying ReplaceAllAnimals.
+func (s *RetryingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	return s.do("ReplaceAllAnimals", func() error { return s.inner.ReplaceAllAnimals(animals) })
+}
+
+// ReclassifyAnimals is tried once: a repeat would count none of the animals already moved.
+func (s *RetryingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	return s.inner.ReclassifyAnimals(from, to)
+}
+
+// SetEndangered retries the underlying SetEndangered.
+func (s *RetryingAnimalStore) SetEndangered(id int, endangered bool) error {
+	return s.do("SetEndangered", func() error { return s.inner.SetEndangered(id, endangered) })
+}
+
+// WithTransaction is tried once, and hands fn the underlying transaction without retries.
+func (s *RetryingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	return s.inner.WithTransaction(ctx, fn)
+}
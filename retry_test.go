@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyStore fails the calls it overrides with err until failures of them have failed, then
+// passes them on. It counts every call.
+type flakyStore struct {
+	AnimalStore
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakyStore) fail() error {
+	s.calls++
+	if s.failures > 0 {
+		s.failures--
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyStore) GetAnimalByID(id int) (*Animal, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.AnimalStore.GetAnimalByID(id)
+}
+
+func (s *flakyStore) UpdateAnimal(id int, animal Animal) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.AnimalStore.UpdateAnimal(id, animal)
+}
+
+func (s *flakyStore) CreateAnimal(animal Animal) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.AnimalStore.CreateAnimal(animal)
+}
+
+func (s *flakyStore) DeleteAnimal(id int) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.AnimalStore.DeleteAnimal(id)
+}
+
+func (s *flakyStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	s.calls++
+	if err := s.AnimalStore.StreamAnimals(filter, fn); err != nil {
+		return err
+	}
+	if s.failures > 0 {
+		s.failures--
+		return s.err // Broken off after delivering the animals
+	}
+	return nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"transient", fmt.Errorf("query animals: %w", ErrTransient), true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"broken pipe", fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{"network timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{"unexpected EOF", fmt.Errorf("reading reply: %w", io.ErrUnexpectedEOF), true},
+		{"not found", fmt.Errorf("animal with ID 1: %w", ErrNotFound), false},
+		{"already exists", fmt.Errorf("animal with ID 1: %w", ErrAlreadyExists), false},
+		{"version mismatch", ErrVersionMismatch, false},
+		{"cancelled request", context.Canceled, false},
+		{"expired request", fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{attempts: 10, baseDelay: 10 * time.Millisecond, maxDelay: 50 * time.Millisecond}
+	for retry, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := policy.delay(retry + 1); got != want*time.Millisecond {
+			t.Errorf("delay before retry %d = %v, want %v", retry+1, got, want*time.Millisecond)
+		}
+	}
+	if got := policy.delay(100); got != policy.maxDelay {
+		t.Errorf("delay before retry 100 = %v, want the maximum", got)
+	}
+
+	policy.jitter = 0.5
+	for range 100 {
+		if got := policy.delay(2); got < 10*time.Millisecond || got > 30*time.Millisecond {
+			t.Fatalf("jittered delay = %v, want within ±50%% of 20ms", got)
+		}
+	}
+}
+
+func TestRetryingAnimalStore(t *testing.T) {
+	transient := fmt.Errorf("query: %w", ErrTransient)
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		op        func(store AnimalStore) error
+		wantCalls int
+		wantErr   error // Matched with errors.Is; nil means success
+	}{
+		{"read recovers", 2, transient, func(s AnimalStore) error { _, err := s.GetAnimalByID(1); return err }, 3, nil},
+		{"read gives up", 5, transient, func(s AnimalStore) error { _, err := s.GetAnimalByID(1); return err }, 3, ErrTransient},
+		{"read not found is final", 0, nil, func(s AnimalStore) error { _, err := s.GetAnimalByID(2); return err }, 1, ErrNotFound},
+		{"read with a logical error", 5, ErrVersionMismatch, func(s AnimalStore) error { _, err := s.GetAnimalByID(1); return err }, 1, ErrVersionMismatch},
+		{"idempotent write recovers", 1, syscall.ECONNRESET, func(s AnimalStore) error { return s.UpdateAnimal(1, Animal{Name: "Lion", Legs: 3}) }, 2, nil},
+		{"create is tried once", 1, transient, func(s AnimalStore) error { return s.CreateAnimal(Animal{ID: 2, Name: "Tiger"}) }, 1, ErrTransient},
+		{"delete is tried once", 1, transient, func(s AnimalStore) error { return s.DeleteAnimal(1) }, 1, ErrTransient},
+		{"stream is not repeated once delivered", 1, transient, func(s AnimalStore) error {
+			return s.StreamAnimals(AnimalFilter{}, func(Animal) error { return nil })
+		}, 1, ErrTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewInMemoryAnimalStore(0)
+			if err := backend.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			flaky := &flakyStore{AnimalStore: backend, failures: tt.failures, err: tt.err}
+			store := NewRetryingAnimalStore(flaky, 3, time.Millisecond, 2*time.Millisecond, 0.1)
+
+			err := tt.op(store)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", flaky.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryingAnimalStoreStopsWithContext(t *testing.T) {
+	flaky := &flakyStore{AnimalStore: NewInMemoryAnimalStore(0), failures: 5, err: ErrTransient}
+	ctx, cancel := context.WithCancel(context.Background())
+	store := NewRetryingAnimalStore(flaky, 3, time.Hour, time.Hour, 0).WithContext(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := store.GetAnimalByID(1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTransient) {
+			t.Errorf("error = %v, want the last transient error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retry kept waiting after the request was cancelled")
+	}
+	if flaky.calls != 1 {
+		t.Errorf("calls = %d, want no retry after the request was cancelled", flaky.calls)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+)
+
+// maxSampleSize is the largest number of animals a single sample request may ask for.
+const maxSampleSize = 100
+
+// reservoir draws a uniform random sample of up to n animals from a stream of unknown length
+// (Algorithm R), keeping only n animals in memory however many pass through.
+type reservoir struct {
+	n       int
+	seen    int
+	samples []Animal
+}
+
+// newReservoir creates a reservoir holding at most n animals.
+func newReservoir(n int) *reservoir {
+	return &reservoir{n: n, samples: make([]Animal, 0, min(n, 64))}
+}
+
+// add offers the next animal of the stream to the sample.
+func (r *reservoir) add(animal Animal) {
+	r.seen++
+	if len(r.samples) < r.n {
+		r.samples = append(r.samples, animal)
+		return
+	}
+	// Keep the i-th animal with probability n/i, replacing a random sampled one
+	if j := rand.IntN(r.seen); j < r.n {
+		r.samples[j] = animal
+	}
+}
+
+// result returns the sample in random order; the fill order of the reservoir is not random.
+func (r *reservoir) result() []Animal {
+	rand.Shuffle(len(r.samples), func(i, j int) { r.samples[i], r.samples[j] = r.samples[j], r.samples[i] })
+	return r.samples
+}
+
+// sampleAnimalsHandler handles GET requests for n distinct animals chosen uniformly at random,
+// e.g. for recommendations. ?n= sets the sample size (default 1, at most maxSampleSize) and
+// repeated ?class= parameters sample within those classes. Fewer than n animals are returned
+// when fewer match.
+func sampleAnimalsHandler(store AnimalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		n := 1
+		if raw := r.URL.Query().Get("n"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxSampleSize {
+				http.Error(w, "n must be an integer between 1 and "+strconv.Itoa(maxSampleSize), http.StatusBadRequest)
+				return
+			}
+			n = parsed
+		}
+
+		animals, err := store.SampleAnimals(AnimalFilter{Classes: parseClassFilter(r)}, n)
+		if err != nil {
+			if errors.Is(err, ErrEmpty) {
+				http.Error(w, "No animals found in the system", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, animals)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema (draft 2020-12) used to describe the Animal model.
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type"`
+	Format      string                 `json:"format,omitempty"`
+	MinLength   *int                   `json:"minLength,omitempty"`
+	MaxLength   *int                   `json:"maxLength,omitempty"`
+	Minimum     *int64                 `json:"minimum,omitempty"`
+	Maximum     *int64                 `json:"maximum,omitempty"`
+	ReadOnly    bool                   `json:"readOnly,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Examples    []string               `json:"examples,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+// timeType is reflected as a JSON string in date-time format, the way encoding/json writes it.
+var timeType = reflect.TypeOf(time.Time{})
+
+// animalSchema builds the JSON Schema of Animal. The properties and their types are reflected
+// from the struct's fields and json tags, so new fields show up without touching this file;
+// the constraints validateAnimal enforces, which reflection cannot see, are added on top.
+// Required lists what a write must send under cfg. With cfg.AllowedClasses the class is an
+// enum of those classes; otherwise classes are free text, so the common classes are given as
+// examples rather than as an enum that would reject the others.
+func animalSchema(cfg Config) *jsonSchema {
+	schema := reflectSchema(reflect.TypeOf(Animal{}))
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.Title = "Animal"
+
+	one, zero := 1, int64(0)
+	maxLegs := int64(math.MaxInt32)
+	props := schema.Properties
+	props["id"].Description = "Unique ID of the animal; assigned by the server when omitted on create"
+	props["name"].Description = "Name of the animal"
+	props["name"].MinLength = &one
+	if cfg.MaxNameLength > 0 {
+		props["name"].MaxLength = &cfg.MaxNameLength
+	}
+	if len(cfg.AllowedClasses) > 0 {
+		props["class"].Description = "Class of the animal, one of the allowed classes; matched case-insensitively and stored as spelled here"
+		props["class"].Enum = append([]string(nil), cfg.AllowedClasses...)
+		if cfg.EmptyClass != emptyClassReject {
+			props["class"].Enum = append(props["class"].Enum, "") // Accepted by the empty-class policy
+		}
+	} else {
+		props["class"].Description = "Class of the animal, case-insensitive; any value is accepted"
+		props["class"].Examples = sortedKeys(commonClasses)
+	}
+	props["legs"].Description = "Number of legs"
+	props["legs"].Minimum = &zero
+	props["legs"].Maximum = &maxLegs
+	props["photo_url"].Description = "Absolute http(s) URL of a photo"
+	props["photo_url"].Format = "uri"
+	props["endangered"].Description = "Whether the species is endangered; defaults to false"
+	minLatitude, maxLatitude := int64(-90), int64(90)
+	minLongitude, maxLongitude := int64(-180), int64(180)
+	props["latitude"].Description = "Latitude of the habitat in degrees; requires longitude"
+	props["latitude"].Minimum, props["latitude"].Maximum = &minLatitude, &maxLatitude
+	props["longitude"].Description = "Longitude of the habitat in degrees; requires latitude"
+	props["longitude"].Minimum, props["longitude"].Maximum = &minLongitude, &maxLongitude
+	props["created_at"].Description = "When the animal was first stored"
+	props["created_at"].ReadOnly = true
+	props["updated_at"].Description = "When the animal was last written"
+	props["updated_at"].ReadOnly = true
+
+	schema.Required = []string{"name"}
+	if !cfg.AssignIDs {
+		schema.Required = append(schema.Required, "id")
+	}
+	if cfg.EmptyClass == emptyClassReject {
+		schema.Required = append(schema.Required, "class")
+	}
+	if cfg.EnforceLegRules {
+		props["legs"].Description += "; per-class rules apply: " + legRulesSummary(cfg.LegRules)
+	}
+	if len(cfg.DefaultLegs) > 0 {
+		props["legs"].Description += "; defaults per class when omitted: " + defaultLegsSummary(cfg.DefaultLegs)
+	}
+	return schema
+}
+
+// reflectSchema maps a Go type to its JSON Schema type. Struct fields become properties named
+// after their json tags; fields tagged "-" and unexported fields are left out.
+func reflectSchema(t reflect.Type) *jsonSchema {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return reflectSchema(t.Elem()) // Optional fields are pointers; absence is expressed by Required
+	case t == timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &jsonSchema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case t.Kind() == reflect.Struct:
+		schema := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema.Properties[name] = reflectSchema(field.Type)
+		}
+		return schema
+	}
+	return &jsonSchema{Type: "object"}
+}
+
+// sortedKeys returns the keys of the set in alphabetical order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// legRulesSummary describes the leg rules in class order, e.g. "a bird must have 2 legs, a ...".
+func legRulesSummary(rules map[string]legRule) string {
+	classes := make([]string, 0, len(rules))
+	for class := range rules {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = legRuleMessage(class, rules[class])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// defaultLegsSummary lists the per-class leg defaults for a schema description, e.g. "bird=2, snake=0".
+func defaultLegsSummary(defaults map[string]int) string {
+	classes := make([]string, 0, len(defaults))
+	for class := range defaults {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	parts := make([]string, len(classes))
+	for i, class := range classes {
+		parts[i] = class + "=" + strconv.Itoa(defaults[class])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// animalSchemaHandler handles GET requests for the JSON Schema of the Animal model, e.g. for
+// client code generators. The schema only depends on the configuration, so it is built once.
+func animalSchemaHandler(cfg Config) http.HandlerFunc {
+	schema := animalSchema(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		if err := encodeJSON(&body, r, schema); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(body.Bytes())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAnimalSchemaClass(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowed      []string
+		emptyClass   string
+		wantEnum     []string
+		wantExamples bool
+		wantRequired []string
+	}{
+		{name: "free text", emptyClass: emptyClassAllow, wantExamples: true, wantRequired: []string{"name"}},
+		{name: "allowed classes", allowed: []string{"mammal", "bird"}, emptyClass: emptyClassAllow,
+			wantEnum: []string{"mammal", "bird", ""}, wantRequired: []string{"name"}},
+		{name: "allowed classes, defaulted when empty", allowed: []string{"mammal", "unknown"}, emptyClass: emptyClassDefault,
+			wantEnum: []string{"mammal", "unknown", ""}, wantRequired: []string{"name"}},
+		{name: "allowed classes, required", allowed: []string{"mammal", "bird"}, emptyClass: emptyClassReject,
+			wantEnum: []string{"mammal", "bird"}, wantRequired: []string{"name", "class"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.AllowedClasses = tt.allowed
+			cfg.EmptyClass = tt.emptyClass
+
+			rec := serve(newTestAPI(NewInMemoryAnimalStore(0), cfg), http.MethodGet, "/v1/animals/schema", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s)", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/schema+json" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			var schema jsonSchema
+			if err := json.Unmarshal(rec.Body.Bytes(), &schema); err != nil {
+				t.Fatal(err)
+			}
+			class := schema.Properties["class"]
+			if class == nil || class.Type != "string" {
+				t.Fatalf("class property = %+v, want a string", class)
+			}
+			if !reflect.DeepEqual(class.Enum, tt.wantEnum) {
+				t.Errorf("class enum = %q, want %q", class.Enum, tt.wantEnum)
+			}
+			if (len(class.Examples) > 0) != tt.wantExamples {
+				t.Errorf("class examples = %q, want examples %v", class.Examples, tt.wantExamples)
+			}
+			if !reflect.DeepEqual(schema.Required, tt.wantRequired) {
+				t.Errorf("required = %q, want %q", schema.Required, tt.wantRequired)
+			}
+		})
+	}
+}
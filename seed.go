@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// seedColumns are the CSV columns a seed file may have, named after the JSON fields.
+var seedColumns = []string{"id", "name", "class", "legs", "endangered", "photo_url", "latitude", "longitude"}
+
+// seedEntry is one animal read from a seed file, before validation. Where locates it in the
+// file for the log, e.g. "entry 3" or "line 4".
+type seedEntry struct {
+	where       string
+	animal      Animal
+	legsOmitted bool
+	errs        []FieldError
+	malformed   bool // The entry could not be decoded at all, so it is not validated further
+}
+
+// loadSeed returns the dataset stored at startup into an empty store and restored by the admin
+// reset endpoint: nothing when seeding is disabled, the built-in seedAnimals() when no seed file
+// is configured, and otherwise the valid animals of the file. Entries are validated like a PUT
+// of the animal; invalid ones, and repeated IDs, are logged and left out. Entries without an ID
+// are numbered after the highest ID of the file, in file order, so every load of the same file
+// yields the same dataset. A file that cannot be read or parsed as a whole is an error.
+func loadSeed(cfg Config) ([]Animal, error) {
+	if !cfg.Seed {
+		return nil, nil
+	}
+	if cfg.SeedFile == "" {
+		return seedAnimals(), nil
+	}
+
+	data, err := os.ReadFile(cfg.SeedFile)
+	if err != nil {
+		return nil, err
+	}
+	var entries []seedEntry
+	if strings.EqualFold(filepath.Ext(cfg.SeedFile), ".csv") {
+		entries, err = readSeedCSV(data)
+	} else {
+		entries, err = readSeedJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.SeedFile, err)
+	}
+
+	animals := make([]Animal, 0, len(entries))
+	seen := make(map[int]bool, len(entries))
+	maxID := 0
+	for _, entry := range entries {
+		animal := normalizeAnimal(entry.animal, entry.legsOmitted, cfg)
+		errs := entry.errs
+		if !entry.malformed {
+			errs = append(errs, validateAnimal(animal, cfg)...)
+		}
+		switch {
+		case animal.ID < 0:
+			errs = append(errs, FieldError{Field: "id", Message: "id must be positive"})
+		case animal.ID > 0 && seen[animal.ID]:
+			errs = append(errs, FieldError{Field: "id", Message: fmt.Sprintf("id %d is listed more than once", animal.ID)})
+		}
+		if len(errs) > 0 {
+			log.Printf("Seed file %s: rejected %s: %s", cfg.SeedFile, entry.where, formatFieldErrors(errs))
+			continue
+		}
+		seen[animal.ID] = true
+		maxID = max(maxID, animal.ID)
+		animals = append(animals, animal)
+	}
+	for i := range animals {
+		if animals[i].ID == 0 {
+			maxID++
+			animals[i].ID = maxID
+		}
+	}
+	if rejected := len(entries) - len(animals); rejected > 0 {
+		log.Printf("Seed file %s: %d of %d entries rejected", cfg.SeedFile, rejected, len(entries))
+	}
+	return animals, nil
+}
+
+// seedStore stores the seed dataset into the store if it is empty, logging how many animals it
+// took and why it refused any. The name describes the store in the log.
+func seedStore(store AnimalStore, seed []Animal, cfg Config, name string) {
+	if empty, err := isEmpty(context.Background(), store); err != nil || !empty {
+		if cfg.Seed {
+			log.Printf("Seeding skipped: %s already holds animals", name)
+		}
+		return
+	}
+	seeded := 0
+	for _, animal := range seed {
+		if err := store.CreateAnimal(animal); err != nil {
+			log.Printf("Seeding: %s rejected animal %d (%s): %v", name, animal.ID, animal.Name, err)
+			continue
+		}
+		seeded++
+	}
+	if cfg.Seed {
+		log.Printf("Seeded %d of %d animals from %s into %s", seeded, len(seed), seedSource(cfg), name)
+	}
+}
+
+// formatFieldErrors joins field errors into one line for the log.
+func formatFieldErrors(errs []FieldError) string {
+	messages := make([]string, len(errs))
+	for i, fieldErr := range errs {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// readSeedJSON reads a seed file holding a JSON array of animals, in the body format of a PUT.
+// An entry that is not a valid animal object is kept with its error, so only it is rejected.
+func readSeedJSON(data []byte) ([]seedEntry, error) {
+	var items []json.RawMessage
+	if err := decodeJSONBody(bytes.NewReader(data), &items); err != nil {
+		return nil, err
+	}
+	entries := make([]seedEntry, len(items))
+	for i, item := range items {
+		entry := seedEntry{where: fmt.Sprintf("entry %d", i)}
+		var err error
+		entry.animal, entry.legsOmitted, entry.errs, err = decodeAnimal(bytes.NewReader(item))
+		if err != nil {
+			entry.errs, entry.malformed = []FieldError{{Field: "body", Message: err.Error()}}, true
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// readSeedCSV reads a seed file in CSV form. The first row names the columns, any of
+// seedColumns in any order; an empty cell leaves its field unset, so an empty legs cell
+// counts as leaving legs out. A cell that does not parse is kept as a field error.
+func readSeedCSV(data []byte) ([]seedEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(seedColumns, header[i]) {
+			return nil, fmt.Errorf("unknown column %q: expected any of %s", column, strings.Join(seedColumns, ", "))
+		}
+	}
+
+	var entries []seedEntry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		entry := seedEntry{where: fmt.Sprintf("line %d", line), legsOmitted: true}
+		for i, cell := range record {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				entry.setField(header[i], cell)
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// setField parses a non-empty CSV cell into the named field of the entry's animal.
+func (e *seedEntry) setField(column, cell string) {
+	animal := &e.animal
+	switch column {
+	case "id":
+		id, err := strconv.Atoi(cell)
+		if err != nil {
+			e.errs = append(e.errs, FieldError{Field: "id", Message: fmt.Sprintf("id must be an integer, got %q", cell)})
+		}
+		animal.ID = id
+	case "name":
+		animal.Name = cell
+	case "class":
+		animal.Class = cell
+	case "legs":
+		e.legsOmitted = false
+		legs, fieldErr := parseLegs(json.Number(cell))
+		if fieldErr != nil {
+			e.errs = append(e.errs, *fieldErr)
+		}
+		animal.Legs = legs
+	case "endangered":
+		endangered, err := strconv.ParseBool(cell)
+		if err != nil {
+			e.errs = append(e.errs, FieldError{Field: "endangered", Message: fmt.Sprintf("endangered must be true or false, got %q", cell)})
+		}
+		animal.Endangered = endangered
+	case "photo_url":
+		animal.PhotoURL = cell
+	case "latitude", "longitude":
+		value, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			e.errs = append(e.errs, FieldError{Field: column, Message: fmt.Sprintf("%s must be a number, got %q", column, cell)})
+			return
+		}
+		if column == "latitude" {
+			animal.Latitude = &value
+		} else {
+			animal.Longitude = &value
+		}
+	}
+}
+
+// seedSource describes where the seed dataset comes from, for the startup log.
+func seedSource(cfg Config) string {
+	if cfg.SeedFile == "" {
+		return "the built-in dataset"
+	}
+	return cfg.SeedFile
+}
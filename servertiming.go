@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// serverTimingKey is the context key of a request's serverTiming.
+type serverTimingKey struct{}
+
+// serverTiming accumulates the time a request spends in store operations.
+type serverTiming struct {
+	store atomic.Int64 // Nanoseconds spent in store operations
+}
+
+// serverTimingFrom returns the request's serverTiming, or nil if timing is off.
+func serverTimingFrom(ctx context.Context) *serverTiming {
+	timing, _ := ctx.Value(serverTimingKey{}).(*serverTiming)
+	return timing
+}
+
+// emitServerTiming adds a Server-Timing header to every response when enabled, e.g.
+// "total;dur=12.3, store;dur=4.1": total is the time from receiving the request until the
+// response header is written, and store the part of it spent in store operations (measured by
+// TimingAnimalStore through the request context). Browsers show both in their devtools. The
+// header is written before the body, so a streamed body is not included in total.
+func emitServerTiming(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timing := &serverTiming{}
+			tw := &serverTimingWriter{ResponseWriter: w, start: time.Now(), timing: timing}
+			next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), serverTimingKey{}, timing)))
+		})
+	}
+}
+
+// serverTimingWriter sets the Server-Timing header just before the response header is written.
+type serverTimingWriter struct {
+	http.ResponseWriter
+	start   time.Time
+	timing  *serverTiming
+	written bool
+}
+
+// WriteHeader sets the Server-Timing header, then writes the status.
+func (w *serverTimingWriter) WriteHeader(status int) {
+	if !w.written {
+		w.written = true
+		w.Header().Add("Server-Timing", strings.Join([]string{
+			formatServerTiming("total", time.Since(w.start)),
+			formatServerTiming("store", time.Duration(w.timing.store.Load())),
+		}, ", "))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the header first if the handler did not.
+func (w *serverTimingWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so streaming handlers keep working while timed.
+func (w *serverTimingWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// formatServerTiming renders one Server-Timing metric with its duration in milliseconds.
+func formatServerTiming(name string, d time.Duration) string {
+	return fmt.Sprintf("%s;dur=%.1f", name, float64(d)/float64(time.Millisecond))
+}
+
+// TimingAnimalStore is an AnimalStore decorator that adds the duration of every store operation
+// to the Server-Timing of the request it is bound to via WithContext. Unbound, it only passes
+// calls through. A transaction is timed as a whole, including fn.
+type TimingAnimalStore struct {
+	inner  AnimalStore
+	timing *serverTiming // nil when not bound to a timed request
+}
+
+// NewTimingAnimalStore wraps inner so that store time shows up in Server-Timing.
+func NewTimingAnimalStore(inner AnimalStore) *TimingAnimalStore {
+	return &TimingAnimalStore{inner: inner}
+}
+
+// WithContext returns a copy of the store that times operations for the request of ctx.
+func (t *TimingAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &TimingAnimalStore{inner: bindContext(t.inner, ctx), timing: serverTimingFrom(ctx)}
+}
+
+// observe adds the time since start to the request's store time; call it deferred.
+func (t *TimingAnimalStore) observe(start time.Time) {
+	if t.timing != nil {
+		t.timing.store.Add(int64(time.Since(start)))
+	}
+}
+
+// GetAllAnimals times the underlying GetAllAnimals.
+func (t *TimingAnimalStore) GetAllAnimals() ([]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.GetAllAnimals()
+}
+
+// FilterAnimals times the underlying FilterAnimals.
+func (t *TimingAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.FilterAnimals(filter)
+}
+
+// GetAnimalIDs times the underlying GetAnimalIDs.
+func (t *TimingAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	defer t.observe(time.Now())
+	return t.inner.GetAnimalIDs(filter)
+}
+
+// StreamAnimals times the underlying StreamAnimals.
+func (t *TimingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	defer t.observe(time.Now())
+	return t.inner.StreamAnimals(filter, fn)
+}
+
+// ForEach times the underlying ForEach.
+func (t *TimingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	defer t.observe(time.Now())
+	return t.inner.ForEach(ctx, fn)
+}
+
+// Facets times the underlying Facets.
+func (t *TimingAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	defer t.observe(time.Now())
+	return t.inner.Facets(filter)
+}
+
+// Analytics times the underlying Analytics.
+func (t *TimingAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	defer t.observe(time.Now())
+	return t.inner.Analytics(filter)
+}
+
+// GroupAnimalsByClass times the underlying GroupAnimalsByClass.
+func (t *TimingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.GroupAnimalsByClass(filter)
+}
+
+// FuzzySearch times the underlying FuzzySearch.
+func (t *TimingAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.FuzzySearch(query, maxDistance)
+}
+
+// SampleAnimals times the underlying SampleAnimals.
+func (t *TimingAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.SampleAnimals(filter, n)
+}
+
+// Query times the underlying Query.
+func (t *TimingAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	defer t.observe(time.Now())
+	return t.inner.Query(q)
+}
+
+// GetAnimalByID times the underlying GetAnimalByID.
+func (t *TimingAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs times the underlying GetAnimalsByIDs.
+func (t *TimingAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	defer t.observe(time.Now())
+	return t.inner.GetAnimalsByIDs(ids)
+}
+
+// GetAnimalsModifiedSince times the underlying GetAnimalsModifiedSince.
+func (t *TimingAnimalStore) GetAnimalsModifiedSince(since time.Time) ([]Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.GetAnimalsModifiedSince(since)
+}
+
+// LatestModified times the underlying LatestModified.
+func (t *TimingAnimalStore) LatestModified() (time.Time, error) {
+	defer t.observe(time.Now())
+	return t.inner.LatestModified()
+}
+
+// NextID times the underlying NextID.
+func (t *TimingAnimalStore) NextID() (int, error) {
+	defer t.observe(time.Now())
+	return t.inner.NextID()
+}
+
+// ReserveID times the underlying ReserveID.
+func (t *TimingAnimalStore) ReserveID() (int, time.Time, error) {
+	defer t.observe(time.Now())
+	return t.inner.ReserveID()
+}
+
+// CreateAnimal times the underlying CreateAnimal.
+func (t *TimingAnimalStore) CreateAnimal(animal Animal) error {
+	defer t.observe(time.Now())
+	return t.inner.CreateAnimal(animal)
+}
+
+// CreateAnimalAssigningID times the underlying CreateAnimalAssigningID.
+func (t *TimingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.CreateAnimalAssigningID(animal, skip)
+}
+
+// UpdateAnimal times the underlying UpdateAnimal.
+func (t *TimingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	defer t.observe(time.Now())
+	return t.inner.UpdateAnimal(id, animal)
+}
+
+// UpsertAnimal times the underlying UpsertAnimal.
+func (t *TimingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	defer t.observe(time.Now())
+	return t.inner.UpsertAnimal(id, animal)
+}
+
+// UpsertAnimalIf times the underlying UpsertAnimalIf.
+func (t *TimingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	defer t.observe(time.Now())
+	return t.inner.UpsertAnimalIf(id, animal, matches)
+}
+
+// MergeAnimals times the underlying MergeAnimals.
+func (t *TimingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	defer t.observe(time.Now())
+	return t.inner.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals times the underlying UpsertAnimals.
+func (t *TimingAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	defer t.observe(time.Now())
+	return t.inner.UpsertAnimals(animals)
+}
+
+// DeleteAnimal times the underlying DeleteAnimal.
+func (t *TimingAnimalStore) DeleteAnimal(id int) error {
+	defer t.observe(time.Now())
+	return t.inner.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion times the underlying DeleteAnimalIfVersion.
+func (t *TimingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	defer t.observe(time.Now())
+	return t.inner.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals times the underlying DeleteAnimals.
+func (t *TimingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	defer t.observe(time.Now())
+	return t.inner.DeleteAnimals(ids)
+}
+
+// ReplaceAllAnimals times the underlying ReplaceAllAnimals.
+func (t *TimingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	defer t.observe(time.Now())
+	return t.inner.ReplaceAllAnimals(animals)
+}
+
+// ReclassifyAnimals times the underlying ReclassifyAnimals.
+func (t *TimingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	defer t.observe(time.Now())
+	return t.inner.ReclassifyAnimals(from, to)
+}
+
+// SetEndangered times the underlying SetEndangered.
+func (t *TimingAnimalStore) SetEndangered(id int, endangered bool) error {
+	defer t.observe(time.Now())
+	return t.inner.SetEndangered(id, endangered)
+}
+
+// WithTransaction times the whole transaction; the store handed to fn is not timed separately.
+func (t *TimingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	defer t.observe(time.Now())
+	return t.inner.WithTransaction(ctx, fn)
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedAnimalStore implements AnimalStore by spreading animals over N independent shards,
+// each a map guarded by its own lock, so writes to different IDs rarely contend.
+// An animal lives in shard id % N. Single-animal operations lock one shard; list-style
+// reads visit the shards one after another and merge the results, so they see each shard
+// consistently but are not a point-in-time snapshot of the whole store. Operations that
+// need such a snapshot (ReplaceAllAnimals, WithTransaction) lock every shard in index order.
+type ShardedAnimalStore struct {
+	shards     []*InMemoryAnimalStore // Each shard is used only for its map and lock; capacity is tracked here
+	count      atomic.Int64           // Number of stored animals across all shards, used for the capacity check
+	nextID     atomic.Int64           // For auto-generating IDs across shards
+	maxAnimals int                    // Maximum number of stored animals across all shards; 0 means unlimited
+	modified   watermark              // When a write last changed the data in any shard
+}
+
+// NewShardedAnimalStore creates a ShardedAnimalStore with the given number of shards
+// (at least 1) holding at most maxAnimals animals in total (0 means unlimited).
+func NewShardedAnimalStore(shards, maxAnimals int) *ShardedAnimalStore {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &ShardedAnimalStore{shards: make([]*InMemoryAnimalStore, shards), maxAnimals: maxAnimals}
+	for i := range s.shards {
+		s.shards[i] = NewInMemoryAnimalStore(0)
+	}
+	s.nextID.Store(1) // Start ID from 1
+	return s
+}
+
+// shardFor returns the shard that owns the given ID.
+func (s *ShardedAnimalStore) shardFor(id int) *InMemoryAnimalStore {
+	n := len(s.shards)
+	return s.shards[((id%n)+n)%n]
+}
+
+// reserve claims room for one more animal, returning false if the store is full.
+// Callers insert right after a successful reserve while still holding the shard lock.
+func (s *ShardedAnimalStore) reserve() bool {
+	for {
+		current := s.count.Load()
+		if s.maxAnimals > 0 && current >= int64(s.maxAnimals) {
+			return false
+		}
+		if s.count.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
+
+// snapshot copies the animals matching the filter out of every shard, visiting one shard at a time.
+// It also reports the total number of animals seen, so callers can tell an empty store from no matches.
+func (s *ShardedAnimalStore) snapshot(filter AnimalFilter) ([]Animal, int) {
+	matched := []Animal{}
+	total := 0
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		total += len(shard.animals.items)
+		for _, animal := range shard.animals.items {
+			if filter.matches(animal) {
+				matched = append(matched, animal)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	return matched, total
+}
+
+// Query returns the requested page of the animals matching the query's filter, and how many match.
+func (s *ShardedAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	matched, err := s.FilterAnimals(q.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return q.Page.apply(matched), len(matched), nil
+}
+
+// SampleAnimals draws a uniform random sample of up to n matching animals with one reservoir
+// fed from every shard, visiting one shard at a time like snapshot.
+func (s *ShardedAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	sample := newReservoir(n)
+	total := 0
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		total += len(shard.animals.items)
+		for _, animal := range shard.animals.items {
+			if filter.matches(animal) {
+				sample.add(animal)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	return sample.result(), nil
+}
+
+// LatestModified returns when a write last changed the data, or the zero time if the store is empty.
+func (s *ShardedAnimalStore) LatestModified() (time.Time, error) {
+	if s.count.Load() == 0 {
+		return time.Time{}, nil
+	}
+	return s.modified.time(), nil
+}
+
+// GetAnimalsModifiedSince collects the animals written after t from every shard, visiting one
+// shard at a time, and orders them by UpdatedAt (then ID).
+func (s *ShardedAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	modified := []Animal{}
+	total := 0
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		total += len(shard.animals.items)
+		for _, animal := range shard.animals.items {
+			if animal.UpdatedAt.After(t) {
+				modified = append(modified, animal)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	sortByUpdatedAt(modified)
+	return modified, nil
+}
+
+// lockAll takes every shard's exclusive lock in index order; unlockAll releases them.
+func (s *ShardedAnimalStore) lockAll() {
+	for _, shard := range s.shards {
+		shard.animals.mu.Lock()
+	}
+}
+
+func (s *ShardedAnimalStore) unlockAll() {
+	for _, shard := range s.shards {
+		shard.animals.mu.Unlock()
+	}
+}
+
+// distribute replaces the contents of every shard with the given animals. Callers must hold all shard locks.
+func (s *ShardedAnimalStore) distribute(animals map[int]Animal) {
+	for _, shard := range s.shards {
+		shard.animals.items = make(map[int]Animal, len(animals)/len(s.shards)+1)
+	}
+	for id, animal := range animals {
+		s.shardFor(id).animals.items[id] = animal
+	}
+	s.count.Store(int64(len(animals)))
+}
+
+// GetAllAnimals retrieves all animals from every shard.
+func (s *ShardedAnimalStore) GetAllAnimals() ([]Animal, error) {
+	all, total := s.snapshot(AnimalFilter{})
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	return all, nil
+}
+
+// FilterAnimals retrieves all animals matching the filter, ordered by its sort keys (ID by default).
+// Like InMemoryAnimalStore it returns an error when the store is empty.
+func (s *ShardedAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	matched, total := s.snapshot(filter)
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	sortAnimals(matched, filter.Sort)
+	return matched, nil
+}
+
+// GetAnimalIDs returns the IDs of the animals matching the filter, in the filter's sort order,
+// visiting one shard at a time. Without sort keys only the IDs are collected; sorting by other
+// fields needs the matching animals copied first, as for FilterAnimals.
+func (s *ShardedAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	if len(filter.Sort) > 0 {
+		matched, err := s.FilterAnimals(filter)
+		if err != nil {
+			return nil, err
+		}
+		return animalIDs(matched), nil
+	}
+
+	ids := []int{}
+	total := 0
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		total += len(shard.animals.items)
+		for id, animal := range shard.animals.items {
+			if filter.matches(animal) {
+				ids = append(ids, id)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// StreamAnimals calls fn for every animal matching the filter, in the filter's sort order.
+// Ordering across shards needs the matches merged first, so unlike InMemoryAnimalStore this
+// copies the matching animals; no lock is held while fn runs.
+func (s *ShardedAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	matched, err := s.FilterAnimals(filter)
+	if err != nil {
+		return err
+	}
+	for _, animal := range matched {
+		if err := fn(animal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEach calls fn for every animal in ID order. Only the IDs are collected for ordering; each
+// animal is then read under its own shard's lock, which is released before fn runs, so an
+// animal deleted in the meantime is skipped.
+func (s *ShardedAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	var ids []int
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		for id := range shard.animals.items {
+			ids = append(ids, id)
+		}
+		shard.animals.mu.RUnlock()
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		shard := s.shardFor(id)
+		shard.animals.mu.RLock()
+		animal, ok := shard.animals.items[id]
+		shard.animals.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := fn(animal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GroupAnimalsByClass buckets the animals matching the filter by their class, with each
+// bucket ordered by ID. The filter's sort keys are ignored, and an empty store yields an empty map.
+func (s *ShardedAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	matched, _ := s.snapshot(filter)
+	groups := make(map[string][]Animal)
+	for _, animal := range matched {
+		groups[animal.Class] = append(groups[animal.Class], animal)
+	}
+	for _, bucket := range groups {
+		sortAnimals(bucket, nil)
+	}
+	return groups, nil
+}
+
+// Facets counts the animals matching the filter per class, visiting one shard at a time. An
+// empty store yields no facets.
+func (s *ShardedAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	counter := newFacetCounter()
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		for _, animal := range shard.animals.items {
+			if filter.matches(animal) {
+				counter.add(animal)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	return counter.result(), nil
+}
+
+// Analytics aggregates the legs of the animals matching the filter, visiting one shard at a
+// time. An empty store yields zeros.
+func (s *ShardedAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	acc := newAnalyticsAccumulator()
+	for _, shard := range s.shards {
+		shard.animals.mu.RLock()
+		for _, animal := range shard.animals.items {
+			if filter.matches(animal) {
+				acc.add(animal)
+			}
+		}
+		shard.animals.mu.RUnlock()
+	}
+	return acc.analytics(), nil
+}
+
+// FuzzySearch returns the animals whose name is within maxDistance Levenshtein edits of the
+// query, ordered by ascending distance and then ID. It returns an error when the store is empty.
+func (s *ShardedAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	all, total := s.snapshot(AnimalFilter{})
+	if total == 0 {
+		return nil, ErrEmpty
+	}
+	return rankByDistance(all, query, maxDistance), nil
+}
+
+// GetAnimalByID retrieves a single animal from its shard.
+func (s *ShardedAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	return s.shardFor(id).GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs looks up several animals, locking each shard once. Found animals and missing
+// IDs are returned in request order; duplicate IDs are only processed once.
+func (s *ShardedAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	byShard := make(map[*InMemoryAnimalStore][]int)
+	for _, id := range ids {
+		shard := s.shardFor(id)
+		byShard[shard] = append(byShard[shard], id)
+	}
+
+	animals := make(map[int]Animal, len(ids))
+	for shard, shardIDs := range byShard {
+		found, _, err := shard.GetAnimalsByIDs(shardIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, animal := range found {
+			animals[animal.ID] = animal
+		}
+	}
+
+	found := []Animal{}
+	missing := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if animal, ok := animals[id]; ok {
+			found = append(found, animal)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+// NextID returns the highest ID across all shards plus one (1 for an empty store).
+// It is advisory only: another client may take the ID before it is used.
+func (s *ShardedAnimalStore) NextID() (int, error) {
+	next := 1
+	for _, shard := range s.shards {
+		id, err := shard.NextID()
+		if err != nil {
+			return 0, err
+		}
+		if id > next {
+			next = id
+		}
+	}
+	return next, nil
+}
+
+// ReserveID is not supported by the store itself; see ReservingAnimalStore.
+func (s *ShardedAnimalStore) ReserveID() (int, time.Time, error) {
+	return 0, time.Time{}, errReservationsUnsupported
+}
+
+// CreateAnimal adds a new animal to its shard.
+// Returns ErrAlreadyExists if an animal with the same ID already exists, or ErrCapacityExceeded if the store is full.
+func (s *ShardedAnimalStore) CreateAnimal(animal Animal) error {
+	if animal.ID == 0 {
+		// Generated IDs come from a store-wide counter so shards never hand out the same one
+		animal.ID = int(s.nextID.Add(1) - 1)
+	}
+
+	shard := s.shardFor(animal.ID)
+	shard.animals.mu.Lock()
+	defer shard.animals.mu.Unlock()
+
+	if _, exists := shard.animals.items[animal.ID]; exists {
+		return fmt.Errorf("animal with ID %d %w", animal.ID, ErrAlreadyExists)
+	}
+	if !s.reserve() {
+		return ErrCapacityExceeded
+	}
+
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	shard.animals.items[animal.ID] = animal
+	s.modified.advance(now)
+	return nil
+}
+
+// CreateAnimalAssigningID stores the animal under the highest ID of all shards plus one, moved
+// past the IDs skip reports. Every shard is locked while the ID is chosen and the animal
+// inserted, so the highest ID cannot change in between.
+func (s *ShardedAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	s.lockAll()
+	defer s.unlockAll()
+
+	animal.ID = 1
+	for _, shard := range s.shards {
+		animal.ID = max(animal.ID, shard.nextFreeID())
+	}
+	for skip != nil && skip(animal.ID) {
+		animal.ID++
+	}
+	if !s.reserve() {
+		return animal, ErrCapacityExceeded
+	}
+
+	now := time.Now().UTC()
+	animal.CreatedAt = now
+	animal.UpdatedAt = now
+	s.shardFor(animal.ID).animals.items[animal.ID] = animal
+	s.modified.advance(now)
+	return animal, nil
+}
+
+// UpdateAnimal updates an existing animal in its shard.
+// Returns an error if the animal with the specified ID does not exist.
+func (s *ShardedAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	shard := s.shardFor(id)
+	if err := shard.UpdateAnimal(id, animal); err != nil {
+		return err
+	}
+	s.modified.advance(shard.modified.time())
+	return nil
+}
+
+// UpsertAnimal updates an existing animal or creates a new one if it doesn't exist, keeping the
+// original CreatedAt of existing records. Creating a new record in a full store fails with ErrCapacityExceeded.
+func (s *ShardedAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	_, err := s.UpsertAnimalIf(id, animal, nil)
+	return err
+}
+
+// UpsertAnimalIf upserts like UpsertAnimal, but an existing animal is only overwritten if
+// matches (when non-nil) accepts it, checked under the shard's lock; otherwise it fails with
+// ErrVersionMismatch.
+func (s *ShardedAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	shard := s.shardFor(id)
+	shard.animals.mu.Lock()
+	defer shard.animals.mu.Unlock()
+
+	now := time.Now().UTC()
+	animal.ID = id // Ensure the ID from the path is used
+	existing, exists := shard.animals.items[id]
+	if exists {
+		if matches != nil && !matches(existing) {
+			return false, fmt.Errorf("animal with ID %d: %w", id, ErrVersionMismatch)
+		}
+		animal.CreatedAt = existing.CreatedAt
+	} else if !s.reserve() {
+		return false, ErrCapacityExceeded
+	} else {
+		animal.CreatedAt = now
+	}
+	animal.UpdatedAt = now
+	shard.animals.items[id] = animal
+	s.modified.advance(now)
+	return !exists, nil
+}
+
+// UpsertAnimals upserts every animal in its shard while holding every shard's lock, so the batch
+// is atomic across shards. Repeated IDs and creates beyond the store's capacity fail as in
+// InMemoryAnimalStore.UpsertAnimals, before anything is written.
+func (s *ShardedAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	if err := checkUpsertBatch(animals); err != nil {
+		return nil, err
+	}
+	s.lockAll()
+	defer s.unlockAll()
+
+	creates := 0
+	for _, animal := range animals {
+		if _, exists := s.shardFor(animal.ID).animals.items[animal.ID]; !exists {
+			creates++
+		}
+	}
+	if s.maxAnimals > 0 && s.count.Load()+int64(creates) > int64(s.maxAnimals) {
+		return nil, ErrCapacityExceeded
+	}
+
+	now := time.Now().UTC()
+	results := make([]BatchResult, 0, len(animals))
+	for _, animal := range animals {
+		shard := s.shardFor(animal.ID)
+		result := batchUpdated
+		if existing, exists := shard.animals.items[animal.ID]; exists {
+			animal.CreatedAt = existing.CreatedAt
+		} else {
+			animal.CreatedAt = now
+			result = batchCreated
+		}
+		animal.UpdatedAt = now
+		shard.animals.items[animal.ID] = animal
+		results = append(results, BatchResult{ID: animal.ID, Result: result})
+	}
+	s.count.Add(int64(creates))
+	if len(animals) > 0 {
+		s.modified.advance(now)
+	}
+	return results, nil
+}
+
+// DeleteAnimal removes an animal from its shard.
+// Returns an error if the animal with the specified ID does not exist.
+func (s *ShardedAnimalStore) DeleteAnimal(id int) error {
+	return s.deleteAnimalIf(id, nil)
+}
+
+// DeleteAnimalIfVersion deletes like DeleteAnimal, but only the given version of the animal.
+func (s *ShardedAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	return s.deleteAnimalIf(id, func(current Animal) bool { return animalVersion(current) == version })
+}
+
+// deleteAnimalIf deletes like DeleteAnimal, but only if matches (when non-nil) accepts the
+// animal, checked under the shard's lock; otherwise it fails with ErrVersionMismatch.
+func (s *ShardedAnimalStore) deleteAnimalIf(id int, matches func(current Animal) bool) error {
+	if err := s.shardFor(id).deleteAnimalIf(id, matches); err != nil {
+		return err
+	}
+	s.count.Add(-1)
+	s.modified.advance(time.Now().UTC())
+	return nil
+}
+
+// DeleteAnimals removes every animal whose ID is listed, locking each shard once. It returns
+// the IDs that were deleted and the IDs that were not found, in request order; duplicate IDs
+// are only processed once. The deletes are atomic per shard, not across shards.
+func (s *ShardedAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	byShard := make(map[*InMemoryAnimalStore][]int)
+	for _, id := range ids {
+		shard := s.shardFor(id)
+		byShard[shard] = append(byShard[shard], id)
+	}
+
+	removed := make(map[int]bool, len(ids))
+	for shard, shardIDs := range byShard {
+		deleted, _, err := shard.DeleteAnimals(shardIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range deleted {
+			removed[id] = true
+		}
+		s.count.Add(-int64(len(deleted)))
+	}
+	if len(removed) > 0 {
+		s.modified.advance(time.Now().UTC())
+	}
+
+	deleted := []int{}
+	notFound := []int{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if removed[id] {
+			deleted = append(deleted, id)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return deleted, notFound, nil
+}
+
+// SetEndangered sets the Endangered flag of an animal in its shard.
+// Returns ErrNotFound if the animal does not exist.
+func (s *ShardedAnimalStore) SetEndangered(id int, endangered bool) error {
+	shard := s.shardFor(id)
+	if err := shard.SetEndangered(id, endangered); err != nil {
+		return err
+	}
+	s.modified.advance(shard.modified.time())
+	return nil
+}
+
+// MergeAnimals stores the merged target and deletes the source while holding the locks of
+// both their shards, so the merge is atomic even when they live in different shards.
+func (s *ShardedAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	if err := checkMergeIDs(sourceID, targetID); err != nil {
+		return Animal{}, err
+	}
+	sourceShard, targetShard := s.shardFor(sourceID), s.shardFor(targetID)
+	// Lock in shard order, as lockAll does, so concurrent merges cannot deadlock
+	for _, shard := range s.shards {
+		if shard == sourceShard || shard == targetShard {
+			shard.animals.mu.Lock()
+			defer shard.animals.mu.Unlock()
+		}
+	}
+
+	source, exists := sourceShard.animals.items[sourceID]
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", sourceID, ErrNotFound)
+	}
+	target, exists := targetShard.animals.items[targetID]
+	if !exists {
+		return Animal{}, fmt.Errorf("animal with ID %d %w for merge", targetID, ErrNotFound)
+	}
+	merged := mergedRecord(source, target, merge, time.Now().UTC())
+	targetShard.animals.items[targetID] = merged
+	delete(sourceShard.animals.items, sourceID)
+	s.count.Add(-1)
+	s.modified.advance(merged.UpdatedAt)
+	return merged, nil
+}
+
+// ReclassifyAnimals moves the matching animals to class to while holding every shard's lock,
+// so the change is atomic across shards.
+func (s *ShardedAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	s.lockAll()
+	defer s.unlockAll()
+
+	now := time.Now().UTC()
+	changed := 0
+	for _, shard := range s.shards {
+		changed += reclassify(shard.animals.items, from, to, now)
+	}
+	if changed > 0 {
+		s.modified.advance(now)
+	}
+	return changed, nil
+}
+
+// ReplaceAllAnimals atomically discards every stored animal and stores the given ones instead,
+// holding every shard's lock while the new data is swapped in. ID assignment and the capacity
+// check follow InMemoryAnimalStore.ReplaceAllAnimals.
+func (s *ShardedAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	staged := NewInMemoryAnimalStore(s.maxAnimals)
+	if err := staged.ReplaceAllAnimals(animals); err != nil {
+		return err
+	}
+
+	s.lockAll()
+	defer s.unlockAll()
+
+	s.distribute(staged.animals.items)
+	s.nextID.Store(int64(staged.nextID))
+	s.modified.advance(staged.modified.time())
+	return nil
+}
+
+// WithTransaction holds every shard's exclusive lock for the whole of fn, which runs against a
+// private single-map copy of the data as in InMemoryAnimalStore.WithTransaction. On success the
+// copy is spread back over the shards; an error or cancelled ctx discards it.
+func (s *ShardedAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	s.lockAll()
+	defer s.unlockAll()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &InMemoryAnimalStore{animals: NewMemoryStore(animalID), nextID: int(s.nextID.Load()), maxAnimals: s.maxAnimals}
+	for _, shard := range s.shards {
+		for id, animal := range shard.animals.items {
+			tx.animals.items[id] = animal
+		}
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.distribute(tx.animals.items)
+	s.nextID.Store(int64(tx.nextID))
+	s.modified.advance(tx.modified.time())
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestShardedAnimalStore(t *testing.T) {
+	store := NewShardedAnimalStore(4, 0)
+	var wg sync.WaitGroup
+	for id := 1; id <= 100; id++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.CreateAnimal(Animal{ID: id, Name: fmt.Sprintf("Animal %d", id), Class: "bird", Legs: 2}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, shard := range store.shards {
+		if got := len(shard.animals.items); got != 25 {
+			t.Errorf("shard %d holds %d animals, want 25", i, got)
+		}
+		for id := range shard.animals.items {
+			if id%4 != i {
+				t.Errorf("shard %d holds animal %d", i, id)
+			}
+		}
+	}
+	all, err := store.FilterAnimals(AnimalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 100 {
+		t.Fatalf("FilterAnimals returned %d animals, want 100", len(all))
+	}
+	for i, animal := range all {
+		if animal.ID != i+1 {
+			t.Fatalf("FilterAnimals()[%d].ID = %d, want the shards merged in ID order", i, animal.ID)
+		}
+	}
+	if next, _ := store.NextID(); next != 101 {
+		t.Errorf("NextID = %d, want 101", next)
+	}
+}
+
+// benchmarkStores are the memory backends compared by the benchmarks: the single-lock store
+// and the sharded store at a few shard counts.
+var benchmarkStores = []struct {
+	name string
+	new  func() AnimalStore
+}{
+	{"single-lock", func() AnimalStore { return NewInMemoryAnimalStore(0) }},
+	{"sharded-4", func() AnimalStore { return NewShardedAnimalStore(4, 0) }},
+	{"sharded-16", func() AnimalStore { return NewShardedAnimalStore(16, 0) }},
+}
+
+// fillStore stores n animals with IDs 1 to n.
+func fillStore(b *testing.B, store AnimalStore, n int) {
+	b.Helper()
+	for id := 1; id <= n; id++ {
+		if err := store.CreateAnimal(Animal{ID: id, Name: fmt.Sprintf("Animal %d", id), Class: "mammal", Legs: 4}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParallelUpdates measures the contention of concurrent writes to random animals.
+func BenchmarkParallelUpdates(b *testing.B) {
+	for _, bs := range benchmarkStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.new()
+			fillStore(b, store, 10000)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					id := rand.IntN(10000) + 1
+					if err := store.UpdateAnimal(id, Animal{Name: "Updated", Class: "mammal", Legs: 4}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkParallelMixed measures a read-heavy mix: nine lookups for every update.
+func BenchmarkParallelMixed(b *testing.B) {
+	for _, bs := range benchmarkStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.new()
+			fillStore(b, store, 10000)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					id := rand.IntN(10000) + 1
+					var err error
+					if i%10 == 0 {
+						err = store.UpdateAnimal(id, Animal{Name: "Updated", Class: "mammal", Legs: 4})
+					} else {
+						_, err = store.GetAnimalByID(id)
+					}
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// pinger is implemented by storage backends that depend on something that can be unavailable,
+// such as a database file. Ping reports whether the backend can serve requests; it should give
+// up when ctx is done. The in-memory backends have nothing to check and do not implement it.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// startupRetryDelay is the pause after the first failed startup check; it doubles per attempt.
+const startupRetryDelay = 500 * time.Millisecond
+
+// startupCheck pings the store before the server starts, so that an unusable backend fails the
+// process with a clear error instead of answering every request with 500. Each attempt is
+// bounded by timeout, and failed attempts are logged and retried with a doubling delay until
+// attempts are used up. Stores that are not pingers pass immediately.
+func startupCheck(store AnimalStore, name string, attempts int, timeout time.Duration) error {
+	p, ok := store.(pinger)
+	if !ok {
+		return nil
+	}
+
+	delay := startupRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := pingWithTimeout(p, timeout)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Startup check: %s store reachable after %d attempts", name, attempt)
+			}
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("%s store unreachable after %d attempts: %w", name, attempt, err)
+		}
+		log.Printf("Startup check: %s store unreachable (attempt %d of %d): %v; retrying in %s", name, attempt, attempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// pingWithTimeout runs one ping, giving up after timeout even if the backend ignores its context.
+func pingWithTimeout(p pinger, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- p.Ping(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("no answer within %s", timeout)
+		}
+		return ctx.Err()
+	}
+}
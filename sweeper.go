@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// maxSweepBackoff caps how many intervals a failing sweeper waits between runs.
+const maxSweepBackoff = 8
+
+// Sweeper runs a cleanup function in the background, e.g. to drop expired entries of an
+// in-memory table, once per interval until its context is done. Each wait is shifted by a
+// random amount of up to ±jitter times the interval, so sweepers started together do not
+// fire in lockstep. A failing sweep is logged and the wait doubles after every consecutive
+// failure, up to maxSweepBackoff intervals; the next successful sweep restores the interval.
+type Sweeper struct {
+	name     string
+	interval time.Duration
+	jitter   float64 // Fraction of the interval, in [0, 1)
+	sweep    func(now time.Time) error
+	done     chan struct{}
+}
+
+// NewSweeper creates a sweeper calling sweep every interval, jittered by up to the given
+// fraction of it. The name identifies the sweeper in the log. It does nothing until Start.
+func NewSweeper(name string, interval time.Duration, jitter float64, sweep func(now time.Time) error) *Sweeper {
+	return &Sweeper{name: name, interval: interval, jitter: jitter, sweep: sweep, done: make(chan struct{})}
+}
+
+// Start runs the sweeper in a goroutine until ctx is done. It must be called at most once.
+func (s *Sweeper) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Done is closed once the sweeper has stopped after its context was done.
+func (s *Sweeper) Done() <-chan struct{} {
+	return s.done
+}
+
+// run sweeps after each wait until ctx is done. A sweep in progress is not interrupted.
+func (s *Sweeper) run(ctx context.Context) {
+	defer close(s.done)
+	failures := 0
+	timer := time.NewTimer(s.wait(failures))
+	defer timer.Stop()
+	for {
+		select {
+		case now := <-timer.C:
+			if err := s.sweep(now); err != nil {
+				failures++
+				log.Printf("sweeper %s: %v", s.name, err)
+			} else {
+				failures = 0
+			}
+			timer.Reset(s.wait(failures))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wait is how long to wait before the next sweep after the given number of consecutive failures.
+func (s *Sweeper) wait(failures int) time.Duration {
+	wait := s.interval * time.Duration(min(1<<min(failures, 16), maxSweepBackoff))
+	if s.jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * s.jitter * float64(wait))
+	}
+	return wait
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSweeperWait(t *testing.T) {
+	const interval = time.Second
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, interval},
+		{1, 2 * interval},
+		{2, 4 * interval},
+		{3, 8 * interval},
+		{4, 8 * interval}, // Capped at maxSweepBackoff intervals
+		{100, 8 * interval},
+	}
+	sweeper := NewSweeper("test", interval, 0, nil)
+	for _, tt := range tests {
+		if got := sweeper.wait(tt.failures); got != tt.want {
+			t.Errorf("wait after %d failures = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	// Jitter shifts each wait by up to the fraction, in both directions
+	jittered := NewSweeper("test", interval, 0.25, nil)
+	seen := make(map[time.Duration]bool)
+	for range 1000 {
+		wait := jittered.wait(1)
+		if wait < 2*interval*3/4 || wait > 2*interval*5/4 {
+			t.Fatalf("jittered wait = %v, want within ±25%% of %v", wait, 2*interval)
+		}
+		seen[wait] = true
+	}
+	if len(seen) < 100 {
+		t.Errorf("%d distinct jittered waits out of 1000, want them spread out", len(seen))
+	}
+}
+
+func TestSweeperRunsUntilDone(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	sweeper := NewSweeper("test", interval, 0.1, func(now time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, now)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	sweeper.Start(ctx)
+	time.Sleep(10 * interval)
+	cancel()
+
+	select {
+	case <-sweeper.Done():
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop after its context was done")
+	}
+	mu.Lock()
+	swept := len(times)
+	mu.Unlock()
+	if swept < 3 {
+		t.Errorf("swept %d times in 10 intervals, want several", swept)
+	}
+	time.Sleep(3 * interval)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != swept {
+		t.Errorf("swept %d more times after Done", len(times)-swept)
+	}
+}
+
+func TestSweeperBacksOff(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	start := time.Now()
+	sweeper := NewSweeper("test", interval, 0, func(now time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, now)
+		if len(times) <= 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sweeper.Start(ctx)
+
+	// Failures at 1, 3 and 7 intervals, then a success at 15 and the interval again at 16
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		swept := len(times)
+		mu.Unlock()
+		if swept >= 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+	cancel()
+	<-sweeper.Done()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) < 5 {
+		t.Fatalf("swept %d times, want 5", len(times))
+	}
+	previous := start
+	for i, want := range []time.Duration{interval, 2 * interval, 4 * interval, 8 * interval} {
+		if gap := times[i].Sub(previous); gap < want {
+			t.Errorf("wait before sweep %d = %v, want at least %v", i+1, gap, want)
+		}
+		previous = times[i]
+	}
+	if gap := times[4].Sub(times[3]); gap >= 8*interval {
+		t.Errorf("wait after a successful sweep = %v, want the interval restored", gap)
+	}
+}
+
+func TestMutationGuardSweep(t *testing.T) {
+	const window = 10 * time.Millisecond
+	guard := NewMutationGuard(5, window)
+	now := time.Now()
+	guard.allow("idle", now.Add(-2*window))
+	guard.allow("busy", now)
+
+	guard.sweep(now)
+	guard.mu.Lock()
+	_, idle := guard.clients["idle"]
+	_, busy := guard.clients["busy"]
+	guard.mu.Unlock()
+	if idle || !busy {
+		t.Errorf("after a sweep: idle client kept %v, busy client kept %v; want only the busy one", idle, busy)
+	}
+
+	// The guard's sweeper drops the rest once they are idle
+	ctx, cancel := context.WithCancel(context.Background())
+	sweeper := guard.Sweeper(0)
+	sweeper.Start(ctx)
+	time.Sleep(5 * window)
+	cancel()
+	<-sweeper.Done()
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	if len(guard.clients) != 0 {
+		t.Errorf("%d counters left after the clients idled for several windows", len(guard.clients))
+	}
+}
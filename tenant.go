@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tenantHeader names the request header that selects the tenant when tenancy is enabled.
+const tenantHeader = "X-Tenant-ID"
+
+// tenantIDPattern restricts tenant IDs to characters that are safe in file names and logs.
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errNoTenant is returned by a TenantAnimalStore that is not bound to a request of a tenant.
+var errNoTenant = errors.New("no tenant selected: the store must be bound to a request with a valid " + tenantHeader)
+
+// tenantKey is the context key under which the tenant of a request is stored.
+type tenantKey struct{}
+
+// withTenant returns a copy of ctx carrying the tenant.
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant stored in ctx, or "" in single-tenant mode and outside
+// a request.
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// requireTenant is router middleware that, when tenants are configured, answers requests
+// without an X-Tenant-ID header, or with one that is not in the allowlist, with 400, and
+// stores the tenant of every other request in its context. Tenant IDs are case-sensitive.
+// Without tenants every request passes unchanged.
+func requireTenant(tenants []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(tenants) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := strings.TrimSpace(r.Header.Get(tenantHeader))
+			if tenant == "" {
+				http.Error(w, "Missing "+tenantHeader+" header: every request must name its tenant", http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(tenants, tenant) {
+				http.Error(w, fmt.Sprintf("Unknown tenant %q", tenant), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), tenant)))
+		})
+	}
+}
+
+// tenantConfig returns the configuration of the backend of one tenant: a bolt database gets a
+// file of its own next to the configured one, e.g. anekazoo-zoo1.db.
+func tenantConfig(cfg Config, tenant string) Config {
+	ext := filepath.Ext(cfg.BoltPath)
+	cfg.BoltPath = strings.TrimSuffix(cfg.BoltPath, ext) + "-" + tenant + ext
+	return cfg
+}
+
+// TenantAnimalStore serves several tenants from one deployment, each with a store of its own,
+// so their animals, IDs, counts and capacity are fully isolated. Bound to a request via
+// WithContext, every call goes to the store of the request's tenant (see requireTenant); the
+// unbound store belongs to no tenant and fails every call with errNoTenant. The decorators
+// above it must pass the context down, which every one but the read cache does.
+type TenantAnimalStore struct {
+	stores map[string]AnimalStore // Tenant -> its store
+	tenant string
+	ctx    context.Context
+}
+
+// NewTenantAnimalStore routes between the given stores, keyed by tenant ID.
+func NewTenantAnimalStore(stores map[string]AnimalStore) *TenantAnimalStore {
+	return &TenantAnimalStore{stores: stores, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the store bound to the tenant stored in ctx.
+func (s *TenantAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &TenantAnimalStore{stores: s.stores, tenant: tenantFromContext(ctx), ctx: ctx}
+}
+
+// store returns the store of the bound tenant, itself bound to the same context.
+func (s *TenantAnimalStore) store() (AnimalStore, error) {
+	store, ok := s.stores[s.tenant]
+	if !ok {
+		return nil, errNoTenant
+	}
+	return bindContext(store, s.ctx), nil
+}
+
+// GetAllAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GetAllAnimals() ([]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAllAnimals()
+}
+
+// FilterAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.FilterAnimals(filter)
+}
+
+// GetAnimalIDs runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAnimalIDs(filter)
+}
+
+// StreamAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.StreamAnimals(filter, fn)
+}
+
+// ForEach runs on the store of the bound tenant.
+func (s *TenantAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.ForEach(ctx, fn)
+}
+
+// GroupAnimalsByClass runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.GroupAnimalsByClass(filter)
+}
+
+// Facets runs on the store of the bound tenant.
+func (s *TenantAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.Facets(filter)
+}
+
+// Analytics runs on the store of the bound tenant.
+func (s *TenantAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	store, err := s.store()
+	if err != nil {
+		return AnalyticsResult{}, err
+	}
+	return store.Analytics(filter)
+}
+
+// FuzzySearch runs on the store of the bound tenant.
+func (s *TenantAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.FuzzySearch(query, maxDistance)
+}
+
+// SampleAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.SampleAnimals(filter, n)
+}
+
+// Query runs on the store of the bound tenant.
+func (s *TenantAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, 0, err
+	}
+	return store.Query(q)
+}
+
+// GetAnimalByID runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAnimalByID(id)
+}
+
+// GetAnimalsByIDs runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.GetAnimalsByIDs(ids)
+}
+
+// GetAnimalsModifiedSince runs on the store of the bound tenant.
+func (s *TenantAnimalStore) GetAnimalsModifiedSince(t time.Time) ([]Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAnimalsModifiedSince(t)
+}
+
+// LatestModified runs on the store of the bound tenant.
+func (s *TenantAnimalStore) LatestModified() (time.Time, error) {
+	store, err := s.store()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return store.LatestModified()
+}
+
+// NextID runs on the store of the bound tenant.
+func (s *TenantAnimalStore) NextID() (int, error) {
+	store, err := s.store()
+	if err != nil {
+		return 0, err
+	}
+	return store.NextID()
+}
+
+// ReserveID runs on the store of the bound tenant, so each tenant has reservations of its own.
+func (s *TenantAnimalStore) ReserveID() (int, time.Time, error) {
+	store, err := s.store()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return store.ReserveID()
+}
+
+// CreateAnimal runs on the store of the bound tenant.
+func (s *TenantAnimalStore) CreateAnimal(animal Animal) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.CreateAnimal(animal)
+}
+
+// CreateAnimalAssigningID runs on the store of the bound tenant, so IDs are assigned per tenant.
+func (s *TenantAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return animal, err
+	}
+	return store.CreateAnimalAssigningID(animal, skip)
+}
+
+// UpdateAnimal runs on the store of the bound tenant.
+func (s *TenantAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.UpdateAnimal(id, animal)
+}
+
+// UpsertAnimal runs on the store of the bound tenant.
+func (s *TenantAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.UpsertAnimal(id, animal)
+}
+
+// UpsertAnimalIf runs on the store of the bound tenant.
+func (s *TenantAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	store, err := s.store()
+	if err != nil {
+		return false, err
+	}
+	return store.UpsertAnimalIf(id, animal, matches)
+}
+
+// MergeAnimals runs on the store of the bound tenant, so only animals of one tenant can merge.
+func (s *TenantAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	store, err := s.store()
+	if err != nil {
+		return Animal{}, err
+	}
+	return store.MergeAnimals(sourceID, targetID, merge)
+}
+
+// UpsertAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	return store.UpsertAnimals(animals)
+}
+
+// DeleteAnimal runs on the store of the bound tenant.
+func (s *TenantAnimalStore) DeleteAnimal(id int) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.DeleteAnimal(id)
+}
+
+// DeleteAnimalIfVersion runs on the store of the bound tenant.
+func (s *TenantAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.DeleteAnimalIfVersion(id, version)
+}
+
+// DeleteAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.DeleteAnimals(ids)
+}
+
+// ReplaceAllAnimals runs on the store of the bound tenant, leaving the other tenants alone.
+func (s *TenantAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.ReplaceAllAnimals(animals)
+}
+
+// ReclassifyAnimals runs on the store of the bound tenant.
+func (s *TenantAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	store, err := s.store()
+	if err != nil {
+		return 0, err
+	}
+	return store.ReclassifyAnimals(from, to)
+}
+
+// SetEndangered runs on the store of the bound tenant.
+func (s *TenantAnimalStore) SetEndangered(id int, endangered bool) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.SetEndangered(id, endangered)
+}
+
+// WithTransaction runs a transaction of the bound tenant's store, handing fn its view directly.
+func (s *TenantAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.WithTransaction(ctx, fn)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies this service's instrumentation scope.
+const tracerName = "AnekaZoo"
+
+// setupTracing installs the global tracer provider and W3C trace-context propagation.
+// When no OTLP endpoint is configured, the global no-op provider stays in place and tracing
+// costs next to nothing. The returned function flushes and stops the exporter.
+func setupTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceRequests wraps the router so every incoming request gets a server span, continuing
+// any trace context propagated by the caller.
+func traceRequests(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "http.request")
+}
+
+// nameRouteSpans is router middleware that renames the request span after the matched route
+// template (e.g. "GET /v1/animals/{id}") and records the animal ID from the path, if any.
+func nameRouteSpans(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(attribute.String("http.route", template))
+			}
+		}
+		if id, err := strconv.Atoi(mux.Vars(r)["id"]); err == nil {
+			span.SetAttributes(attribute.Int("animal.id", id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TracingAnimalStore is an AnimalStore decorator that records a span, named after the
+// method, around every store operation. Bound to a request context via WithContext, the
+// spans become children of the request span.
+type TracingAnimalStore struct {
+	inner  AnimalStore
+	tracer trace.Tracer
+	ctx    context.Context
+}
+
+// NewTracingAnimalStore wraps inner with tracing using the global tracer provider.
+func NewTracingAnimalStore(inner AnimalStore) *TracingAnimalStore {
+	return &TracingAnimalStore{inner: inner, tracer: otel.Tracer(tracerName), ctx: context.Background()}
+}
+
+// WithContext returns a copy of the store whose spans are parented to ctx.
+func (t *TracingAnimalStore) WithContext(ctx context.Context) AnimalStore {
+	return &TracingAnimalStore{inner: bindContext(t.inner, ctx), tracer: t.tracer, ctx: ctx}
+}
+
+// start opens a span for the named store method.
+func (t *TracingAnimalStore) start(method string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := t.tracer.Start(t.ctx, "AnimalStore."+method, trace.WithAttributes(attrs...))
+	return span
+}
+
+// end records the outcome of the operation on the span and closes it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
+
+// GetAllAnimals traces the underlying GetAllAnimals.
+func (t *TracingAnimalStore) GetAllAnimals() ([]Animal, error) {
+	span := t.start("GetAllAnimals")
+	animals, err := t.inner.GetAllAnimals()
+	span.SetAttributes(attribute.Int("animal.count", len(animals)))
+	end(span, err)
+	return animals, err
+}
+
+// FilterAnimals traces the underlying FilterAnimals.
+func (t *TracingAnimalStore) FilterAnimals(filter AnimalFilter) ([]Animal, error) {
+	span := t.start("FilterAnimals", attribute.String("animal.filter", filter.key()))
+	animals, err := t.inner.FilterAnimals(filter)
+	span.SetAttributes(attribute.Int("animal.count", len(animals)))
+	end(span, err)
+	return animals, err
+}
+
+// GetAnimalIDs traces the underlying GetAnimalIDs.
+func (t *TracingAnimalStore) GetAnimalIDs(filter AnimalFilter) ([]int, error) {
+	span := t.start("GetAnimalIDs", attribute.String("animal.filter", filter.key()))
+	ids, err := t.inner.GetAnimalIDs(filter)
+	span.SetAttributes(attribute.Int("animal.count", len(ids)))
+	end(span, err)
+	return ids, err
+}
+
+// StreamAnimals traces the underlying StreamAnimals; the span covers the whole stream.
+func (t *TracingAnimalStore) StreamAnimals(filter AnimalFilter, fn func(Animal) error) error {
+	span := t.start("StreamAnimals", attribute.String("animal.filter", filter.key()))
+	err := t.inner.StreamAnimals(filter, fn)
+	end(span, err)
+	return err
+}
+
+// ForEach traces the underlying ForEach; the span covers the whole iteration.
+func (t *TracingAnimalStore) ForEach(ctx context.Context, fn func(Animal) error) error {
+	span := t.start("ForEach")
+	err := t.inner.ForEach(ctx, fn)
+	end(span, err)
+	return err
+}
+
+// Facets traces the underlying Facets.
+func (t *TracingAnimalStore) Facets(filter AnimalFilter) ([]Facet, error) {
+	span := t.start("Facets", attribute.String("animal.filter", filter.key()))
+	facets, err := t.inner.Facets(filter)
+	span.SetAttributes(attribute.Int("animal.facets", len(facets)))
+	end(span, err)
+	return facets, err
+}
+
+// Analytics traces the underlying Analytics.
+func (t *TracingAnimalStore) Analytics(filter AnimalFilter) (AnalyticsResult, error) {
+	span := t.start("Analytics", attribute.String("animal.filter", filter.key()))
+	result, err := t.inner.Analytics(filter)
+	span.SetAttributes(attribute.Int("animal.count", result.Count))
+	end(span, err)
+	return result, err
+}
+
+// GroupAnimalsByClass traces the underlying GroupAnimalsByClass.
+func (t *TracingAnimalStore) GroupAnimalsByClass(filter AnimalFilter) (map[string][]Animal, error) {
+	span := t.start("GroupAnimalsByClass", attribute.String("animal.filter", filter.key()))
+	groups, err := t.inner.GroupAnimalsByClass(filter)
+	end(span, err)
+	return groups, err
+}
+
+// FuzzySearch traces the underlying FuzzySearch.
+func (t *TracingAnimalStore) FuzzySearch(query string, maxDistance int) ([]Animal, error) {
+	span := t.start("FuzzySearch", attribute.String("animal.query", query), attribute.Int("animal.max_distance", maxDistance))
+	animals, err := t.inner.FuzzySearch(query, maxDistance)
+	span.SetAttributes(attribute.Int("animal.count", len(animals)))
+	end(span, err)
+	return animals, err
+}
+
+// GetAnimalsModifiedSince traces the underlying GetAnimalsModifiedSince.
+func (t *TracingAnimalStore) GetAnimalsModifiedSince(since time.Time) ([]Animal, error) {
+	span := t.start("GetAnimalsModifiedSince", attribute.String("animal.modified_since", since.Format(time.RFC3339Nano)))
+	animals, err := t.inner.GetAnimalsModifiedSince(since)
+	span.SetAttributes(attribute.Int("animal.count", len(animals)))
+	end(span, err)
+	return animals, err
+}
+
+// LatestModified traces the underlying LatestModified.
+func (t *TracingAnimalStore) LatestModified() (time.Time, error) {
+	span := t.start("LatestModified")
+	latest, err := t.inner.LatestModified()
+	end(span, err)
+	return latest, err
+}
+
+// ReserveID traces the underlying ReserveID.
+func (t *TracingAnimalStore) ReserveID() (int, time.Time, error) {
+	span := t.start("ReserveID")
+	id, expiresAt, err := t.inner.ReserveID()
+	span.SetAttributes(attribute.Int("animal.id", id))
+	end(span, err)
+	return id, expiresAt, err
+}
+
+// Query traces the underlying Query.
+func (t *TracingAnimalStore) Query(q AnimalQuery) ([]Animal, int, error) {
+	span := t.start("Query", attribute.String("animal.filter", q.Filter.key()),
+		attribute.Int("animal.limit", q.Page.Limit), attribute.Int("animal.offset", q.Page.Offset))
+	animals, total, err := t.inner.Query(q)
+	span.SetAttributes(attribute.Int("animal.count", len(animals)), attribute.Int("animal.total", total))
+	end(span, err)
+	return animals, total, err
+}
+
+// SampleAnimals traces the underlying SampleAnimals.
+func (t *TracingAnimalStore) SampleAnimals(filter AnimalFilter, n int) ([]Animal, error) {
+	span := t.start("SampleAnimals", attribute.String("animal.filter", filter.key()), attribute.Int("animal.sample_size", n))
+	animals, err := t.inner.SampleAnimals(filter, n)
+	span.SetAttributes(attribute.Int("animal.count", len(animals)))
+	end(span, err)
+	return animals, err
+}
+
+// GetAnimalByID traces the underlying GetAnimalByID.
+func (t *TracingAnimalStore) GetAnimalByID(id int) (*Animal, error) {
+	span := t.start("GetAnimalByID", attribute.Int("animal.id", id))
+	animal, err := t.inner.GetAnimalByID(id)
+	end(span, err)
+	return animal, err
+}
+
+// GetAnimalsByIDs traces the underlying GetAnimalsByIDs.
+func (t *TracingAnimalStore) GetAnimalsByIDs(ids []int) ([]Animal, []int, error) {
+	span := t.start("GetAnimalsByIDs", attribute.IntSlice("animal.ids", ids))
+	found, missing, err := t.inner.GetAnimalsByIDs(ids)
+	span.SetAttributes(attribute.Int("animal.found", len(found)), attribute.Int("animal.missing", len(missing)))
+	end(span, err)
+	return found, missing, err
+}
+
+// NextID traces the underlying NextID.
+func (t *TracingAnimalStore) NextID() (int, error) {
+	span := t.start("NextID")
+	next, err := t.inner.NextID()
+	span.SetAttributes(attribute.Int("animal.next_id", next))
+	end(span, err)
+	return next, err
+}
+
+// CreateAnimal traces the underlying CreateAnimal.
+func (t *TracingAnimalStore) CreateAnimal(animal Animal) error {
+	span := t.start("CreateAnimal", attribute.Int("animal.id", animal.ID))
+	err := t.inner.CreateAnimal(animal)
+	end(span, err)
+	return err
+}
+
+// CreateAnimalAssigningID traces the underlying CreateAnimalAssigningID, with the assigned ID.
+func (t *TracingAnimalStore) CreateAnimalAssigningID(animal Animal, skip func(id int) bool) (Animal, error) {
+	span := t.start("CreateAnimalAssigningID")
+	created, err := t.inner.CreateAnimalAssigningID(animal, skip)
+	span.SetAttributes(attribute.Int("animal.id", created.ID))
+	end(span, err)
+	return created, err
+}
+
+// UpdateAnimal traces the underlying UpdateAnimal.
+func (t *TracingAnimalStore) UpdateAnimal(id int, animal Animal) error {
+	span := t.start("UpdateAnimal", attribute.Int("animal.id", id))
+	err := t.inner.UpdateAnimal(id, animal)
+	end(span, err)
+	return err
+}
+
+// UpsertAnimal traces the underlying UpsertAnimal.
+func (t *TracingAnimalStore) UpsertAnimal(id int, animal Animal) error {
+	span := t.start("UpsertAnimal", attribute.Int("animal.id", id))
+	err := t.inner.UpsertAnimal(id, animal)
+	end(span, err)
+	return err
+}
+
+// DeleteAnimal traces the underlying DeleteAnimal.
+func (t *TracingAnimalStore) DeleteAnimal(id int) error {
+	span := t.start("DeleteAnimal", attribute.Int("animal.id", id))
+	err := t.inner.DeleteAnimal(id)
+	end(span, err)
+	return err
+}
+
+// DeleteAnimalIfVersion traces the underlying DeleteAnimalIfVersion.
+func (t *TracingAnimalStore) DeleteAnimalIfVersion(id int, version int64) error {
+	span := t.start("DeleteAnimalIfVersion", attribute.Int("animal.id", id))
+	err := t.inner.DeleteAnimalIfVersion(id, version)
+	end(span, err)
+	return err
+}
+
+// UpsertAnimalIf traces the underlying UpsertAnimalIf.
+func (t *TracingAnimalStore) UpsertAnimalIf(id int, animal Animal, matches func(current Animal) bool) (bool, error) {
+	span := t.start("UpsertAnimalIf", attribute.Int("animal.id", id))
+	created, err := t.inner.UpsertAnimalIf(id, animal, matches)
+	span.SetAttributes(attribute.Bool("animal.created", created))
+	end(span, err)
+	return created, err
+}
+
+// MergeAnimals traces the underlying MergeAnimals.
+func (t *TracingAnimalStore) MergeAnimals(sourceID, targetID int, merge func(source, target Animal) Animal) (Animal, error) {
+	span := t.start("MergeAnimals", attribute.Int("animal.source_id", sourceID), attribute.Int("animal.target_id", targetID))
+	merged, err := t.inner.MergeAnimals(sourceID, targetID, merge)
+	end(span, err)
+	return merged, err
+}
+
+// UpsertAnimals traces the underlying UpsertAnimals.
+func (t *TracingAnimalStore) UpsertAnimals(animals []Animal) ([]BatchResult, error) {
+	span := t.start("UpsertAnimals", attribute.Int("animal.count", len(animals)))
+	results, err := t.inner.UpsertAnimals(animals)
+	created := 0
+	for _, result := range results {
+		if result.Result == batchCreated {
+			created++
+		}
+	}
+	span.SetAttributes(attribute.Int("animal.created", created), attribute.Int("animal.updated", len(results)-created))
+	end(span, err)
+	return results, err
+}
+
+// DeleteAnimals traces the underlying DeleteAnimals.
+func (t *TracingAnimalStore) DeleteAnimals(ids []int) ([]int, []int, error) {
+	span := t.start("DeleteAnimals", attribute.IntSlice("animal.ids", ids))
+	deleted, notFound, err := t.inner.DeleteAnimals(ids)
+	span.SetAttributes(attribute.Int("animal.deleted", len(deleted)), attribute.Int("animal.not_found", len(notFound)))
+	end(span, err)
+	return deleted, notFound, err
+}
+
+// SetEndangered traces the underlying SetEndangered.
+func (t *TracingAnimalStore) SetEndangered(id int, endangered bool) error {
+	span := t.start("SetEndangered", attribute.Int("animal.id", id), attribute.Bool("animal.endangered", endangered))
+	err := t.inner.SetEndangered(id, endangered)
+	end(span, err)
+	return err
+}
+
+// ReclassifyAnimals traces the underlying ReclassifyAnimals.
+func (t *TracingAnimalStore) ReclassifyAnimals(from, to string) (int, error) {
+	span := t.start("ReclassifyAnimals", attribute.String("animal.class.from", from), attribute.String("animal.class.to", to))
+	changed, err := t.inner.ReclassifyAnimals(from, to)
+	span.SetAttributes(attribute.Int("animal.changed", changed))
+	end(span, err)
+	return changed, err
+}
+
+// ReplaceAllAnimals traces the underlying ReplaceAllAnimals.
+func (t *TracingAnimalStore) ReplaceAllAnimals(animals []Animal) error {
+	span := t.start("ReplaceAllAnimals", attribute.Int("animal.count", len(animals)))
+	err := t.inner.ReplaceAllAnimals(animals)
+	end(span, err)
+	return err
+}
+
+// WithTransaction traces the underlying WithTransaction; the span covers the whole transaction.
+func (t *TracingAnimalStore) WithTransaction(ctx context.Context, fn func(store AnimalStore) error) error {
+	span := t.start("WithTransaction")
+	err := t.inner.WithTransaction(ctx, fn)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxBatchUpsert caps how many animals a single batch upsert may carry.
+const maxBatchUpsert = 1000
+
+// Outcomes of a batch upsert item.
+const (
+	batchCreated = "created" // No animal had the ID, so one was created
+	batchUpdated = "updated" // The animal with the ID was overwritten
+)
+
+// BatchResult is the outcome of one animal of a batch upsert.
+type BatchResult struct {
+	ID     int    `json:"id"`
+	Result string `json:"result"` // "created" or "updated"
+}
+
+// duplicateID returns an ID that more than one of the animals carries, if any.
+func duplicateID(animals []Animal) (int, bool) {
+	seen := make(map[int]bool, len(animals))
+	for _, animal := range animals {
+		if seen[animal.ID] {
+			return animal.ID, true
+		}
+		seen[animal.ID] = true
+	}
+	return 0, false
+}
+
+// checkUpsertBatch rejects a batch with a repeated ID, which UpsertAnimals implementations
+// report as ErrAlreadyExists before writing anything.
+func checkUpsertBatch(animals []Animal) error {
+	if id, ok := duplicateID(animals); ok {
+		return fmt.Errorf("animal with ID %d listed twice: %w", id, ErrAlreadyExists)
+	}
+	return nil
+}
+
+// batchUpsertResponse reports the outcome of each upserted animal, in request order, and with
+// ?prune=true the IDs of the animals deleted for being absent from the payload.
+type batchUpsertResponse struct {
+	Results []BatchResult `json:"results"`
+	Pruned  []int         `json:"pruned,omitempty"`
+}
+
+// errBatchDryRun rolls back the transaction of a dry-run batch upsert once its results are known.
+var errBatchDryRun = errors.New("dry run")
+
+// batchUpsertAnimalsHandler handles PUT requests carrying a JSON array of animals, each with
+// its ID, and upserts them all at once: animals that exist are overwritten, the others created.
+// Every animal is validated like a single PUT first, and any invalid one rejects the whole
+// batch with 422, field names prefixed with its index (e.g. "[2].name"). The upserts are
+// applied atomically.
+//
+// With ?prune=true the payload is the complete desired state: every stored animal whose ID is
+// not in it is deleted, in the same transaction as the upserts. Pruning is destructive, so it
+// is only allowed with admin operations enabled, and an empty payload, which would delete
+// everything, is refused. ?dry_run=true runs the whole batch and reports its results without
+// committing it.
+func batchUpsertAnimalsHandler(store AnimalStore, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+		prune := false
+		if raw := r.URL.Query().Get("prune"); raw != "" {
+			if prune, err = strconv.ParseBool(raw); err != nil {
+				http.Error(w, "Invalid prune parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if prune && !cfg.AdminEnabled {
+			http.Error(w, "Admin operations are disabled: pruning requires them", http.StatusForbidden)
+			return
+		}
+
+		var items []json.RawMessage
+		if err := decodeJSONBody(r.Body, &items); err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+		if len(items) > maxBatchUpsert {
+			http.Error(w, fmt.Sprintf("Too many animals: at most %d are allowed per request", maxBatchUpsert), http.StatusBadRequest)
+			return
+		}
+		if prune && len(items) == 0 {
+			http.Error(w, "Refusing to prune with an empty payload, which would delete every animal", http.StatusBadRequest)
+			return
+		}
+
+		animals := make([]Animal, len(items))
+		var errs []FieldError
+		seen := make(map[int]bool, len(items))
+		for i, item := range items {
+			animal, legsOmitted, legsErrs, err := decodeAnimal(bytes.NewReader(item))
+			if err != nil {
+				writeInvalidBody(w, r, fmt.Errorf("animal at index %d: %s", i, err))
+				return
+			}
+			animal = normalizeAnimal(animal, legsOmitted, cfg)
+			itemErrs := append(legsErrs, validateAnimal(animal, cfg)...)
+			switch {
+			case animal.ID <= 0:
+				itemErrs = append(itemErrs, FieldError{Field: "id", Message: "id is required and must be positive"})
+			case seen[animal.ID]:
+				itemErrs = append(itemErrs, FieldError{Field: "id", Message: fmt.Sprintf("id %d is listed more than once", animal.ID)})
+			}
+			seen[animal.ID] = true
+			for _, fieldErr := range itemErrs {
+				fieldErr.Field = fmt.Sprintf("[%d].%s", i, fieldErr.Field)
+				errs = append(errs, fieldErr)
+			}
+			animals[i] = animal
+		}
+		if len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+
+		var response batchUpsertResponse
+		if !prune && !dryRun {
+			response.Results, err = store.UpsertAnimals(animals)
+		} else {
+			err = store.WithTransaction(r.Context(), func(tx AnimalStore) error {
+				results, err := tx.UpsertAnimals(animals)
+				if err != nil {
+					return err
+				}
+				response = batchUpsertResponse{Results: results}
+				if prune {
+					if response.Pruned, err = pruneAbsent(tx, seen); err != nil {
+						return err
+					}
+				}
+				if dryRun {
+					return errBatchDryRun
+				}
+				return nil
+			})
+			if errors.Is(err, errBatchDryRun) {
+				w.Header().Set("X-Dry-Run", "true")
+				err = nil
+			}
+		}
+		if writeVetoed(w, r, err) {
+			return
+		}
+		if errors.Is(err, ErrCapacityExceeded) {
+			http.Error(w, "The store is full: no more animals can be created", http.StatusInsufficientStorage) // 507
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, r, response)
+	}
+}
+
+// pruneAbsent deletes every animal of the transaction whose ID is not kept, returning the
+// deleted IDs.
+func pruneAbsent(tx AnimalStore, keep map[int]bool) ([]int, error) {
+	all, err := tx.GetAllAnimals()
+	if err != nil && !errors.Is(err, ErrEmpty) {
+		return nil, err
+	}
+	var absent []int
+	for _, animal := range all {
+		if !keep[animal.ID] {
+			absent = append(absent, animal.ID)
+		}
+	}
+	if len(absent) == 0 {
+		return nil, nil
+	}
+	deleted, _, err := tx.DeleteAnimals(absent)
+	return deleted, err
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// legRule bounds the number of legs allowed for animals of one class.
+type legRule struct {
+	Min int
+	Max int
+}
+
+// defaultLegRules is the class → leg rule spec used when none is configured.
+const defaultLegRules = "bird=2,insect=6"
+
+// parseLegRules parses a spec such as "bird=2,insect=6,spider=6-8" into per-class rules.
+// Each entry is class=N (exactly N legs) or class=MIN-MAX. Class names are case-insensitive.
+func parseLegRules(spec string) (map[string]legRule, error) {
+	rules := make(map[string]legRule)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		class, bounds, ok := strings.Cut(entry, "=")
+		class = strings.ToLower(strings.TrimSpace(class))
+		if !ok || class == "" {
+			return nil, fmt.Errorf("invalid leg rule %q: expected class=N or class=MIN-MAX", entry)
+		}
+
+		minText, maxText, isRange := strings.Cut(bounds, "-")
+		if !isRange {
+			maxText = minText
+		}
+		min, errMin := strconv.Atoi(strings.TrimSpace(minText))
+		max, errMax := strconv.Atoi(strings.TrimSpace(maxText))
+		if errMin != nil || errMax != nil || min < 0 || min > max {
+			return nil, fmt.Errorf("invalid leg rule %q: bounds must be non-negative integers with MIN <= MAX", entry)
+		}
+		rules[class] = legRule{Min: min, Max: max}
+	}
+	return rules, nil
+}
+
+// parseDefaultLegs parses a spec such as "bird=2,snake=0" into the leg count given to animals of
+// each class that are written without legs. Class names are case-insensitive.
+func parseDefaultLegs(spec string) (map[string]int, error) {
+	defaults := make(map[string]int)
+	for _, entry := range splitList(spec) {
+		class, value, ok := strings.Cut(entry, "=")
+		class = strings.ToLower(strings.TrimSpace(class))
+		legs, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || class == "" || err != nil || legs < 0 {
+			return nil, fmt.Errorf("invalid default legs %q: expected class=N with N a non-negative integer", entry)
+		}
+		defaults[class] = legs
+	}
+	return defaults, nil
+}
+
+// Empty-class policies: what a write does with an animal whose class is empty or blank.
+const (
+	emptyClassAllow   = "allow"   // Store it as given
+	emptyClassReject  = "reject"  // Reject it with 422
+	emptyClassDefault = "default" // Store it with cfg.DefaultClass
+)
+
+// Name normalizations: what a write does with an animal's name before it is validated.
+const (
+	nameRaw      = "raw"      // Store it as given
+	nameCollapse = "collapse" // Trim it and collapse every run of whitespace into one space
+	nameNFC      = "nfc"      // Collapse it and normalize it to Unicode NFC
+)
+
+// normalizeName normalizes a name under the given normalization, so that "  Li  on\t" becomes
+// "Li on" and names that only differ in how their accents are encoded are stored alike (nfc).
+// Whitespace includes tabs and newlines; control characters that are not whitespace, such as
+// NUL, are left for validateAnimal to reject.
+func normalizeName(name, mode string) string {
+	if mode == nameRaw {
+		return name
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	if mode == nameNFC {
+		name = norm.NFC.String(name)
+	}
+	return name
+}
+
+// allowedClass returns the entry of cfg.AllowedClasses that class matches, ignoring case and
+// surrounding whitespace, and whether there is one. Every class is allowed when the list is
+// empty; class is then returned as it is.
+func allowedClass(class string, cfg Config) (string, bool) {
+	if len(cfg.AllowedClasses) == 0 {
+		return class, true
+	}
+	for _, allowed := range cfg.AllowedClasses {
+		if strings.EqualFold(strings.TrimSpace(class), allowed) {
+			return allowed, true
+		}
+	}
+	return class, false
+}
+
+// allowedClassesMessage is the field error of a class outside cfg.AllowedClasses.
+func allowedClassesMessage(field string, cfg Config) string {
+	return fmt.Sprintf("%s must be one of %s", field, strings.Join(cfg.AllowedClasses, ", "))
+}
+
+// normalizeAnimal applies the defaults configured for incoming animals before they are
+// validated and stored. The name is normalized under cfg.NameNormalization. Under the
+// "default" empty-class policy a missing or blank class becomes cfg.DefaultClass, and a class
+// on cfg.AllowedClasses is stored as it is spelled there. When the body left out legs
+// (legsOmitted), an animal whose class, trimmed and ignoring case, has an entry in
+// cfg.DefaultLegs gets that many; an explicit 0 is kept.
+func normalizeAnimal(animal Animal, legsOmitted bool, cfg Config) Animal {
+	animal.Name = normalizeName(animal.Name, cfg.NameNormalization)
+	if cfg.EmptyClass == emptyClassDefault && strings.TrimSpace(animal.Class) == "" {
+		animal.Class = cfg.DefaultClass
+	}
+	if class, ok := allowedClass(animal.Class, cfg); ok {
+		animal.Class = class
+	}
+	if legsOmitted {
+		if legs, ok := cfg.DefaultLegs[strings.ToLower(strings.TrimSpace(animal.Class))]; ok {
+			animal.Legs = legs
+		}
+	}
+	return animal
+}
+
+// validateAnimal checks that an animal payload is acceptable for storage.
+// It returns one FieldError per problem found, or nil if the animal is valid.
+// Class-specific leg rules are only checked when cfg.EnforceLegRules is set, an empty class
+// only under the "reject" empty-class policy, and other classes only against a non-empty
+// cfg.AllowedClasses. Names are limited to cfg.MaxNameLength characters (when positive) and
+// must not contain control characters.
+func validateAnimal(animal Animal, cfg Config) []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(animal.Name) == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "name is required"})
+	} else if strings.IndexFunc(animal.Name, unicode.IsControl) >= 0 {
+		errs = append(errs, FieldError{Field: "name", Message: "name must not contain control characters"})
+	}
+	if cfg.MaxNameLength > 0 && utf8.RuneCountInString(animal.Name) > cfg.MaxNameLength {
+		errs = append(errs, FieldError{Field: "name", Message: fmt.Sprintf("name must be at most %d characters", cfg.MaxNameLength)})
+	}
+	if animal.Legs < 0 {
+		errs = append(errs, FieldError{Field: "legs", Message: "legs cannot be negative"})
+	} else if cfg.EnforceLegRules {
+		if rule, ok := cfg.LegRules[strings.ToLower(animal.Class)]; ok && (animal.Legs < rule.Min || animal.Legs > rule.Max) {
+			errs = append(errs, FieldError{Field: "legs", Message: legRuleMessage(animal.Class, rule)})
+		}
+	}
+	if strings.TrimSpace(animal.Class) == "" {
+		if cfg.EmptyClass == emptyClassReject {
+			errs = append(errs, FieldError{Field: "class", Message: "class is required"})
+		}
+	} else if _, ok := allowedClass(animal.Class, cfg); !ok {
+		errs = append(errs, FieldError{Field: "class", Message: allowedClassesMessage("class", cfg)})
+	}
+	if animal.PhotoURL != "" && !isHTTPURL(animal.PhotoURL) {
+		errs = append(errs, FieldError{Field: "photo_url", Message: "photo_url must be an absolute http or https URL"})
+	}
+	errs = append(errs, validateCoordinates(animal)...)
+	return errs
+}
+
+// validateCoordinates checks that latitude and longitude are given together and within range.
+func validateCoordinates(animal Animal) []FieldError {
+	var errs []FieldError
+	if animal.Latitude != nil && (*animal.Latitude < -90 || *animal.Latitude > 90) {
+		errs = append(errs, FieldError{Field: "latitude", Message: "latitude must be between -90 and 90"})
+	}
+	if animal.Longitude != nil && (*animal.Longitude < -180 || *animal.Longitude > 180) {
+		errs = append(errs, FieldError{Field: "longitude", Message: "longitude must be between -180 and 180"})
+	}
+	switch {
+	case animal.Latitude != nil && animal.Longitude == nil:
+		errs = append(errs, FieldError{Field: "longitude", Message: "longitude is required when latitude is given"})
+	case animal.Latitude == nil && animal.Longitude != nil:
+		errs = append(errs, FieldError{Field: "latitude", Message: "latitude is required when longitude is given"})
+	}
+	return errs
+}
+
+// animalPayload decodes an animal from a request body with legs kept as the raw JSON value,
+// which shadows the embedded Animal.Legs, so that fractions and out-of-range values can be
+// reported as validation errors instead of failing the whole decode.
+type animalPayload struct {
+	Animal
+	Legs json.RawMessage `json:"legs"`
+}
+
+// errLegsNotNumber rejects a legs value that is not a JSON number, such as a string.
+var errLegsNotNumber = errors.New("legs must be a JSON number")
+
+// decodeAnimal reads an animal from a JSON request body, and reports whether the body left out
+// legs (or sent null), as opposed to giving a value such as 0. A malformed body is returned as
+// an error (400); a legs value that is not an integer in int32 range is returned as a field
+// error (422), with legs left at 0.
+func decodeAnimal(body io.Reader) (animal Animal, legsOmitted bool, legsErrs []FieldError, err error) {
+	var payload animalPayload
+	if err := decodeJSONBody(body, &payload); err != nil {
+		return Animal{}, false, nil, err
+	}
+	animal = payload.Animal
+	if len(payload.Legs) == 0 || string(payload.Legs) == "null" {
+		return animal, true, nil, nil
+	}
+	if c := payload.Legs[0]; c != '-' && (c < '0' || c > '9') {
+		return Animal{}, false, nil, errLegsNotNumber
+	}
+	legs, fieldErr := parseLegs(json.Number(payload.Legs))
+	if fieldErr != nil {
+		return animal, false, []FieldError{*fieldErr}, nil
+	}
+	animal.Legs = legs
+	return animal, false, nil, nil
+}
+
+// parseLegs converts a JSON number to a leg count. Integral values in exponent form such as 1e3
+// are accepted; fractions and values outside the int32 range are not.
+func parseLegs(number json.Number) (int, *FieldError) {
+	if legs, err := strconv.ParseInt(number.String(), 10, 32); err == nil {
+		return int(legs), nil
+	}
+	value, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, &FieldError{Field: "legs", Message: "legs must be a number"}
+	}
+	if value < 0 {
+		return 0, &FieldError{Field: "legs", Message: "legs cannot be negative"}
+	}
+	if err != nil || value > math.MaxInt32 {
+		return 0, &FieldError{Field: "legs", Message: fmt.Sprintf("legs must be at most %d", math.MaxInt32)}
+	}
+	if value != math.Trunc(value) {
+		return 0, &FieldError{Field: "legs", Message: fmt.Sprintf("legs must be a whole number, got %s", number)}
+	}
+	return int(value), nil
+}
+
+// patchLegsErrors checks the legs value of a merge patch decoded with UseNumber and rewrites a
+// valid one as a plain integer, so that 1e3 can be merged into the int field; null (clearing
+// the field) and non-numbers are left to the patch itself.
+func patchLegsErrors(patch map[string]interface{}) []FieldError {
+	number, ok := patch["legs"].(json.Number)
+	if !ok {
+		return nil
+	}
+	legs, fieldErr := parseLegs(number)
+	if fieldErr != nil {
+		return []FieldError{*fieldErr}
+	}
+	patch["legs"] = legs
+	return nil
+}
+
+// isHTTPURL reports whether raw is an absolute http or https URL with a host.
+// The URL is only parsed, never fetched.
+func isHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// legRuleMessage describes a violated leg rule, e.g. "a bird must have 2 legs".
+func legRuleMessage(class string, rule legRule) string {
+	if rule.Min == rule.Max {
+		return fmt.Sprintf("a %s must have %d legs", class, rule.Min)
+	}
+	return fmt.Sprintf("a %s must have between %d and %d legs", class, rule.Min, rule.Max)
+}
+
+// Soft-warning thresholds: suspicious but valid values.
+const warnLegsFrom = 100 // Leg counts from this one up are unusually high
+
+// commonClasses are the animal classes that do not trigger an "unusual class" warning.
+var commonClasses = map[string]bool{
+	"mammal": true, "bird": true, "reptile": true, "amphibian": true,
+	"fish": true, "insect": true, "arachnid": true, "crustacean": true, "mollusc": true,
+}
+
+// warnAnimal returns soft warnings for values that are suspicious but not invalid.
+// Unlike validateAnimal, warnings never reject a request.
+func warnAnimal(animal Animal) []string {
+	var warnings []string
+	if animal.Legs >= warnLegsFrom {
+		warnings = append(warnings, fmt.Sprintf("unusually high leg count (%d)", animal.Legs))
+	}
+	if class := strings.ToLower(animal.Class); class != "" && !commonClasses[class] {
+		warnings = append(warnings, fmt.Sprintf("unusual class %q", animal.Class))
+	}
+	return warnings
+}
+
+// validationResult is the body of a successful validate request.
+type validationResult struct {
+	Valid bool `json:"valid"`
+}
+
+// validateAnimalHandler handles POST requests that only validate an animal payload, e.g. for
+// live form validation. It accepts the same body as create but never reads or writes the
+// store, so ID conflicts are not checked. Invalid payloads get the usual 422 problem document.
+func validateAnimalHandler(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		animal, legsOmitted, errs, err := decodeAnimal(r.Body)
+		if err != nil {
+			writeInvalidBody(w, r, err)
+			return
+		}
+
+		animal = normalizeAnimal(animal, legsOmitted, cfg)
+		if errs = append(errs, validateAnimal(animal, cfg)...); len(errs) > 0 {
+			writeValidationProblem(w, r, errs) // 422 Unprocessable Entity
+			return
+		}
+		respondJSON(w, r, validationResult{Valid: true})
+	}
+}
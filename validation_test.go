@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLegRules(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]legRule
+		wantErr bool
+	}{
+		{spec: "", want: map[string]legRule{}},
+		{spec: "bird=2,insect=6", want: map[string]legRule{"bird": {2, 2}, "insect": {6, 6}}},
+		{spec: " Spider = 6-8 , ,snake=0", want: map[string]legRule{"spider": {6, 8}, "snake": {0, 0}}},
+		{spec: "bird", wantErr: true},
+		{spec: "=2", wantErr: true},
+		{spec: "bird=two", wantErr: true},
+		{spec: "bird=-2", wantErr: true},
+		{spec: "spider=8-6", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLegRules(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLegRules(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLegRules(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLegRules(t *testing.T) {
+	rules, err := parseLegRules("bird=2,spider=6-8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		animal  Animal
+		enforce bool
+		want    []FieldError
+	}{
+		{"bird with 2 legs", Animal{Name: "Eagle", Class: "bird", Legs: 2}, true, nil},
+		{"bird with 4 legs", Animal{Name: "Eagle", Class: "bird", Legs: 4}, true,
+			[]FieldError{{Field: "legs", Message: "a bird must have 2 legs"}}},
+		{"class is case-insensitive", Animal{Name: "Eagle", Class: "Bird", Legs: 3}, true,
+			[]FieldError{{Field: "legs", Message: "a Bird must have 2 legs"}}},
+		{"range lower bound", Animal{Name: "Tarantula", Class: "spider", Legs: 6}, true, nil},
+		{"range upper bound", Animal{Name: "Tarantula", Class: "spider", Legs: 8}, true, nil},
+		{"outside range", Animal{Name: "Tarantula", Class: "spider", Legs: 10}, true,
+			[]FieldError{{Field: "legs", Message: "a spider must have between 6 and 8 legs"}}},
+		{"class without rule", Animal{Name: "Lion", Class: "mammal", Legs: 7}, true, nil},
+		{"rules not enforced", Animal{Name: "Eagle", Class: "bird", Legs: 4}, false, nil},
+		{"negative legs reported once", Animal{Name: "Eagle", Class: "bird", Legs: -1}, true,
+			[]FieldError{{Field: "legs", Message: "legs cannot be negative"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.LegRules = rules
+			cfg.EnforceLegRules = tt.enforce
+			if got := validateAnimal(tt.animal, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateAnimal = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarnAnimal(t *testing.T) {
+	tests := []struct {
+		name   string
+		animal Animal
+		want   []string
+	}{
+		{"common animal", Animal{Class: "mammal", Legs: 4}, nil},
+		{"99 legs", Animal{Class: "insect", Legs: 99}, nil},
+		{"100 legs", Animal{Class: "insect", Legs: 100}, []string{"unusually high leg count (100)"}},
+		{"354 legs", Animal{Class: "insect", Legs: 354}, []string{"unusually high leg count (354)"}},
+		{"unusual class", Animal{Class: "Chilopod", Legs: 30}, []string{`unusual class "Chilopod"`}},
+		{"common class in any case", Animal{Class: "BIRD", Legs: 2}, nil},
+		{"no class", Animal{Legs: 2}, nil},
+		{"both", Animal{Class: "chilopod", Legs: 354}, []string{"unusually high leg count (354)", `unusual class "chilopod"`}},
+	}
+	for _, tt := range tests {
+		if got := warnAnimal(tt.animal); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: warnAnimal = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedClasses(t *testing.T) {
+	tests := []struct {
+		name       string
+		class      string
+		emptyClass string
+		wantClass  string
+		wantErrs   []FieldError
+	}{
+		{name: "listed class", class: "bird", wantClass: "bird"},
+		{name: "case and whitespace are ignored", class: " BIRD ", wantClass: "bird"},
+		{name: "stored as listed", class: "mammal", wantClass: "Mammal"},
+		{name: "unlisted class", class: "mamal", wantClass: "mamal",
+			wantErrs: []FieldError{{Field: "class", Message: "class must be one of Mammal, bird"}}},
+		{name: "empty class allowed by policy", class: "", wantClass: ""},
+		{name: "empty class rejected by policy", class: " ", emptyClass: emptyClassReject, wantClass: " ",
+			wantErrs: []FieldError{{Field: "class", Message: "class is required"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.AllowedClasses = []string{"Mammal", "bird"}
+			if tt.emptyClass != "" {
+				cfg.EmptyClass = tt.emptyClass
+			}
+			animal := normalizeAnimal(Animal{Name: "Rex", Class: tt.class}, false, cfg)
+			if animal.Class != tt.wantClass {
+				t.Errorf("normalized class = %q, want %q", animal.Class, tt.wantClass)
+			}
+			if got := validateAnimal(animal, cfg); !reflect.DeepEqual(got, tt.wantErrs) {
+				t.Errorf("validateAnimal = %v, want %v", got, tt.wantErrs)
+			}
+		})
+	}
+
+	// Without a list every class is valid
+	if errs := validateAnimal(Animal{Name: "Rex", Class: "mamal"}, testConfig()); errs != nil {
+		t.Errorf("validateAnimal without -allowed-classes = %v, want no errors", errs)
+	}
+}
+
+func TestReclassifyToAllowedClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		to         string
+		wantStatus int
+		wantClass  string
+	}{
+		{"allowed", "BIRD", http.StatusOK, "bird"},
+		{"not allowed", "sauropsid", http.StatusUnprocessableEntity, "mammal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			cfg := testConfig()
+			cfg.AllowedClasses = []string{"mammal", "bird"}
+			rec := serve(newTestAPI(store, cfg), http.MethodPost, "/v1/animals/reclassify", `{"from": "mammal", "to": "`+tt.to+`"}`)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if lion, _ := store.GetAnimalByID(1); lion.Class != tt.wantClass {
+				t.Errorf("class = %q, want %q", lion.Class, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestParseLegs(t *testing.T) {
+	tests := []struct {
+		number  string
+		want    int
+		wantErr string
+	}{
+		{number: "4", want: 4},
+		{number: "0", want: 0},
+		{number: "2.0", want: 2},
+		{number: "1e3", want: 1000},
+		{number: "1e9", want: 1000000000},
+		{number: "2147483647", want: 2147483647},
+		{number: "-1", want: -1}, // Reported by validateAnimal, like any negative count
+		{number: "-2.5", wantErr: "legs cannot be negative"},
+		{number: "3.9", wantErr: "legs must be a whole number, got 3.9"},
+		{number: "2147483648", wantErr: "legs must be at most 2147483647"},
+		{number: "1e10", wantErr: "legs must be at most 2147483647"},
+		{number: "1e400", wantErr: "legs must be at most 2147483647"},
+	}
+	for _, tt := range tests {
+		got, fieldErr := parseLegs(json.Number(tt.number))
+		switch {
+		case tt.wantErr != "":
+			if fieldErr == nil || fieldErr.Message != tt.wantErr {
+				t.Errorf("parseLegs(%s) error = %v, want %q", tt.number, fieldErr, tt.wantErr)
+			}
+		case fieldErr != nil:
+			t.Errorf("parseLegs(%s) error = %v", tt.number, fieldErr)
+		case got != tt.want:
+			t.Errorf("parseLegs(%s) = %d, want %d", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestLegsInRequestBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		wantStatus  int
+		wantMessage string // Expected in the problem document when legs are rejected
+	}{
+		{"create with 4", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": 4}`, http.StatusCreated, ""},
+		{"create with 1e9", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": 1e9}`, http.StatusCreated, ""},
+		{"create with 3.9", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": 3.9}`, http.StatusUnprocessableEntity, "legs must be a whole number, got 3.9"},
+		{"create with -1", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": -1}`, http.StatusUnprocessableEntity, "legs cannot be negative"},
+		{"create with 1e10", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": 1e10}`, http.StatusUnprocessableEntity, "legs must be at most 2147483647"},
+		{"create with a string", http.MethodPost, "/v1/animals", `{"name": "Lion", "legs": "4"}`, http.StatusBadRequest, ""},
+		{"replace with 3.9", http.MethodPut, "/v1/animals/1", `{"name": "Lion", "legs": 3.9}`, http.StatusUnprocessableEntity, "legs must be a whole number, got 3.9"},
+		{"replace with -1", http.MethodPut, "/v1/animals/1", `{"name": "Lion", "legs": -1}`, http.StatusUnprocessableEntity, "legs cannot be negative"},
+		{"patch with 1e9", http.MethodPatch, "/v1/animals/1", `{"legs": 1e9}`, http.StatusOK, ""},
+		{"patch with 3.9", http.MethodPatch, "/v1/animals/1", `{"legs": 3.9}`, http.StatusUnprocessableEntity, "legs must be a whole number, got 3.9"},
+		{"patch with -1", http.MethodPatch, "/v1/animals/1", `{"legs": -1}`, http.StatusUnprocessableEntity, "legs cannot be negative"},
+		{"clone with 1e3", http.MethodPost, "/v1/animals/1/clone", `{"legs": 1e3}`, http.StatusCreated, ""},
+		{"clone with 3.9", http.MethodPost, "/v1/animals/1/clone", `{"legs": 3.9}`, http.StatusUnprocessableEntity, "legs must be a whole number, got 3.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Lion", Class: "mammal", Legs: 4}); err != nil {
+				t.Fatal(err)
+			}
+			var headers []string
+			if tt.method == http.MethodPatch {
+				headers = []string{"Content-Type", "application/merge-patch+json"}
+			}
+			rec := serve(newTestAPI(store, testConfig()), tt.method, tt.target, tt.body, headers...)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantMessage != "" && !strings.Contains(rec.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want the message %q", rec.Body, tt.wantMessage)
+			}
+			if lion, _ := store.GetAnimalByID(1); tt.wantStatus >= 400 && lion.Legs != 4 {
+				t.Errorf("rejected request changed legs to %d", lion.Legs)
+			}
+		})
+	}
+}
+
+func TestEmptyClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		body       string
+		wantStatus int
+		wantClass  string
+	}{
+		{"allow keeps a missing class", emptyClassAllow, `{"name": "Rex"}`, http.StatusCreated, ""},
+		{"allow keeps an empty class", emptyClassAllow, `{"name": "Rex", "class": ""}`, http.StatusCreated, ""},
+		{"reject a missing class", emptyClassReject, `{"name": "Rex"}`, http.StatusUnprocessableEntity, ""},
+		{"reject a blank class", emptyClassReject, `{"name": "Rex", "class": "  "}`, http.StatusUnprocessableEntity, ""},
+		{"reject keeps a given class", emptyClassReject, `{"name": "Rex", "class": "mammal"}`, http.StatusCreated, "mammal"},
+		{"default a missing class", emptyClassDefault, `{"name": "Rex"}`, http.StatusCreated, "unknown"},
+		{"default a blank class", emptyClassDefault, `{"name": "Rex", "class": " \t"}`, http.StatusCreated, "unknown"},
+		{"default a null class", emptyClassDefault, `{"name": "Rex", "class": null}`, http.StatusCreated, "unknown"},
+		{"default keeps a given class", emptyClassDefault, `{"name": "Rex", "class": "bird"}`, http.StatusCreated, "bird"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.EmptyClass = tt.policy
+			store := NewInMemoryAnimalStore(0)
+			api := newTestAPI(store, cfg)
+
+			rec := serve(api, http.MethodPost, "/v1/animals", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if !strings.Contains(rec.Body.String(), "class is required") {
+					t.Errorf("body = %s, want the class field error", rec.Body)
+				}
+				return
+			}
+			if created := decodeAnimalResponse(t, rec); created.Class != tt.wantClass {
+				t.Errorf("created class = %q, want %q", created.Class, tt.wantClass)
+			}
+
+			// A replacement is held to the same policy
+			rec = serve(api, http.MethodPut, "/v1/animals/1", tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("PUT status = %d (%s)", rec.Code, rec.Body)
+			}
+			if stored, _ := store.GetAnimalByID(1); stored.Class != tt.wantClass {
+				t.Errorf("replaced class = %q, want %q", stored.Class, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestDefaultLegs(t *testing.T) {
+	defaults, err := parseDefaultLegs("bird=2, Snake=0, mammalia=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		body     string
+		wantLegs int
+	}{
+		{"omitted legs are defaulted", `{"name": "Eagle", "class": "bird"}`, 2},
+		{"null legs are defaulted", `{"name": "Eagle", "class": "bird", "legs": null}`, 2},
+		{"explicit zero is kept", `{"name": "Eagle", "class": "bird", "legs": 0}`, 0},
+		{"explicit legs are kept", `{"name": "Eagle", "class": "bird", "legs": 1}`, 1},
+		{"case is ignored", `{"name": "Eagle", "class": "BIRD"}`, 2},
+		{"default of zero", `{"name": "Cobra", "class": "snake", "legs": null}`, 0},
+		{"class is trimmed and case-folded", `{"name": "Lion", "class": " Mammalia\t"}`, 4},
+		{"class without a default", `{"name": "Newt", "class": "amphibian"}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.DefaultLegs = defaults
+			store := NewInMemoryAnimalStore(0)
+			api := newTestAPI(store, cfg)
+
+			rec := serve(api, http.MethodPost, "/v1/animals", tt.body)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST status = %d (%s)", rec.Code, rec.Body)
+			}
+			created := decodeAnimalResponse(t, rec)
+			if created.Legs != tt.wantLegs {
+				t.Errorf("created legs = %d, want %d", created.Legs, tt.wantLegs)
+			}
+
+			// A replacement is defaulted the same way
+			rec = serve(api, http.MethodPut, fmt.Sprintf("/v1/animals/%d", created.ID), tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("PUT status = %d (%s)", rec.Code, rec.Body)
+			}
+			if stored, _ := store.GetAnimalByID(created.ID); stored.Legs != tt.wantLegs {
+				t.Errorf("replaced legs = %d, want %d", stored.Legs, tt.wantLegs)
+			}
+		})
+	}
+
+	// Defaulting is opt-in
+	if animal := normalizeAnimal(Animal{Name: "Eagle", Class: "bird"}, true, testConfig()); animal.Legs != 0 {
+		t.Errorf("legs without -default-legs = %d, want 0", animal.Legs)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		in   string
+		want string
+	}{
+		{"trims and collapses", nameCollapse, "  Li  on\t", "Li on"},
+		{"tabs and newlines are whitespace", nameCollapse, "Snow\t\n Leopard\r\n", "Snow Leopard"},
+		{"non-breaking space", nameCollapse, "Red\u00a0 Panda", "Red Panda"},
+		{"already clean", nameCollapse, "Lion", "Lion"},
+		{"only whitespace", nameCollapse, " \t\n", ""},
+		{"NUL is left for validation", nameCollapse, " Li\x00on ", "Li\x00on"},
+		{"decomposed accent kept by collapse", nameCollapse, "Ocelote\u0301", "Ocelote\u0301"},
+		{"decomposed accent composed by nfc", nameNFC, "  Ocelote\u0301 ", "Ocelot\u00e9"},
+		{"raw keeps everything", nameRaw, "  Li  on\t", "  Li  on\t"},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.in, tt.mode); got != tt.want {
+			t.Errorf("%s: normalizeName(%q, %s) = %q, want %q", tt.name, tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestNameSanitization(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		body       string
+		wantStatus int
+		wantName   string
+	}{
+		{"whitespace collapsed", nameCollapse, `{"name": "  Li  on\t", "class": "mammal"}`, http.StatusCreated, "Li on"},
+		{"accents composed", nameNFC, `{"name": "Ocelote\u0301", "class": "mammal"}`, http.StatusCreated, "Ocelot\u00e9"},
+		{"raw whitespace kept", nameRaw, `{"name": " Lion ", "class": "mammal"}`, http.StatusCreated, " Lion "},
+		{"embedded NUL", nameCollapse, `{"name": "Li\u0000on", "class": "mammal"}`, http.StatusUnprocessableEntity, ""},
+		{"other control character", nameCollapse, `{"name": "Li\u0007on", "class": "mammal"}`, http.StatusUnprocessableEntity, ""},
+		{"raw tab is a control character", nameRaw, `{"name": "Li\ton", "class": "mammal"}`, http.StatusUnprocessableEntity, ""},
+		{"blank after collapsing", nameCollapse, `{"name": " \t ", "class": "mammal"}`, http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.NameNormalization = tt.mode
+			store := NewInMemoryAnimalStore(0)
+			if err := store.CreateAnimal(Animal{ID: 1, Name: "Tiger", Class: "mammal"}); err != nil {
+				t.Fatal(err)
+			}
+			api := newTestAPI(store, cfg)
+
+			rec := serve(api, http.MethodPost, "/v1/animals", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusCreated {
+				if created := decodeAnimalResponse(t, rec); created.Name != tt.wantName {
+					t.Errorf("created name = %q, want %q", created.Name, tt.wantName)
+				}
+			} else if !strings.Contains(rec.Body.String(), `"name"`) {
+				t.Errorf("body = %s, want a field error for name", rec.Body)
+			}
+
+			// Replacements and merge patches are sanitized the same way
+			rec = serve(api, http.MethodPut, "/v1/animals/1", tt.body)
+			if rec.Code != tt.wantStatus && !(tt.wantStatus == http.StatusCreated && rec.Code == http.StatusOK) {
+				t.Fatalf("PUT status = %d (%s)", rec.Code, rec.Body)
+			}
+			rec = serve(api, http.MethodPatch, "/v1/animals/1", tt.body, "Content-Type", "application/merge-patch+json")
+			if tiger, _ := store.GetAnimalByID(1); tt.wantStatus == http.StatusCreated && tiger.Name != tt.wantName {
+				t.Errorf("stored name after PUT and PATCH = %q, want %q", tiger.Name, tt.wantName)
+			} else if tt.wantStatus != http.StatusCreated && (rec.Code != tt.wantStatus || tiger.Name != "Tiger") {
+				t.Errorf("PATCH status = %d, stored name %q; want %d and the name unchanged", rec.Code, tiger.Name, tt.wantStatus)
+			}
+		})
+	}
+}
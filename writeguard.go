@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// nonMutatingRoutes are POST routes that only read, so the mutation guard does not count them.
+var nonMutatingRoutes = map[string]bool{
+	batchGetRoute: true,
+	validateRoute: true,
+	queryRoute:    true,
+}
+
+// writeCounter is the sliding-window state of one client: the writes counted in the current
+// fixed window and in the one before it.
+type writeCounter struct {
+	windowStart       time.Time
+	current, previous int
+}
+
+// roll moves the counter forward to the window containing now. After a gap of more than one
+// window the previous count no longer matters and is dropped.
+func (c *writeCounter) roll(now time.Time, window time.Duration) {
+	elapsed := now.Sub(c.windowStart)
+	if elapsed < window {
+		return
+	}
+	if elapsed < 2*window {
+		c.previous = c.current
+	} else {
+		c.previous = 0
+	}
+	c.current = 0
+	c.windowStart = c.windowStart.Add(elapsed.Truncate(window))
+}
+
+// estimate is the sliding-window count at now: all writes of the current window plus the
+// share of the previous window's writes that still falls within the last window length.
+func (c *writeCounter) estimate(now time.Time, window time.Duration) float64 {
+	overlap := 1 - float64(now.Sub(c.windowStart))/float64(window)
+	return float64(c.previous)*overlap + float64(c.current)
+}
+
+// retryAfter estimates how long a client at or over limit must wait until its next write is
+// allowed, assuming it sends nothing in the meantime.
+func (c *writeCounter) retryAfter(now time.Time, window time.Duration, limit int) time.Duration {
+	elapsed := now.Sub(c.windowStart)
+	if c.current < limit && c.previous > 0 {
+		// The previous window's share decays until one more write fits beside this window's
+		return time.Duration(float64(window)*(1-float64(limit-c.current-1)/float64(c.previous))) - elapsed
+	}
+	// This window alone is at the limit: wait for it to end and its share to decay
+	return window - elapsed + time.Duration(float64(window)*(1-float64(limit-1)/float64(c.current)))
+}
+
+// MutationGuard limits how many writes each client may make within a sliding window, to stop
+// runaway scripts. Unlike load shedding it never touches reads. The window is approximated
+// with two fixed windows, weighting the previous one by how much of it still overlaps the
+// sliding window, so each client costs a constant amount of memory however many writes it
+// makes. Counters of clients idle for two windows are dropped by the guard's Sweeper.
+type MutationGuard struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*writeCounter
+}
+
+// NewMutationGuard creates a guard allowing limit writes per client within window.
+func NewMutationGuard(limit int, window time.Duration) *MutationGuard {
+	return &MutationGuard{limit: limit, window: window, clients: make(map[string]*writeCounter)}
+}
+
+// Sweeper returns a sweeper that drops idle counters once per window, jittered by the given
+// fraction. A dropped client starts over with a fresh counter, which is correct once it has
+// not written for two windows.
+func (g *MutationGuard) Sweeper(jitter float64) *Sweeper {
+	return NewSweeper("write-guard", g.window, jitter, func(now time.Time) error {
+		g.sweep(now)
+		return nil
+	})
+}
+
+// allow counts a write by client unless that would exceed the limit. It returns whether the
+// write is allowed, how many more writes the client has left, and when rejected, how long
+// until it may write again.
+func (g *MutationGuard) allow(client string, now time.Time) (bool, int, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counter, ok := g.clients[client]
+	if !ok {
+		counter = &writeCounter{windowStart: now}
+		g.clients[client] = counter
+	}
+	counter.roll(now, g.window)
+
+	used := counter.estimate(now, g.window)
+	if used+1 > float64(g.limit) {
+		return false, 0, counter.retryAfter(now, g.window, g.limit)
+	}
+	counter.current++
+	return true, max(g.limit-int(math.Ceil(used+1)), 0), 0
+}
+
+// sweep drops the counters of clients without writes for two windows.
+func (g *MutationGuard) sweep(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for client, counter := range g.clients {
+		if now.Sub(counter.windowStart) >= 2*g.window {
+			delete(g.clients, client)
+		}
+	}
+}
+
+// guardMutations is API middleware that counts the POST, PUT, PATCH and DELETE requests of each
+// client (as identified by withClientIdentity) and answers those beyond the guard's limit with
+// 429 Too Many Requests, a Retry-After header and a problem document. Counted writes carry
+// X-Write-Limit and X-Write-Remaining headers. Reads, and POST routes that only read (batch
+// get, query, validate), are neither counted nor limited, and neither are the exempt paths. A
+// nil guard disables the middleware.
+func guardMutations(guard *MutationGuard, exempt *PathMatcher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if guard == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isWriteMethod(r.Method) || exempt.Match(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if route := mux.CurrentRoute(r); route != nil && nonMutatingRoutes[route.GetName()] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, remaining, wait := guard.allow(clientIdentity(r.Context()), time.Now())
+			w.Header().Set("X-Write-Limit", strconv.Itoa(guard.limit))
+			w.Header().Set("X-Write-Remaining", strconv.Itoa(remaining))
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+				writeProblem(w, r, ProblemDetails{
+					Type:   problemTypeTooManyWrites,
+					Title:  "Too many writes",
+					Status: http.StatusTooManyRequests,
+					Detail: fmt.Sprintf("At most %d writes are allowed per client within %s.", guard.limit, guard.window),
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}